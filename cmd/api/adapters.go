@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// eventAdapter converts between a Lambda event payload and the API Gateway
+// REST (v1) proxy request and response that the API handles
+type eventAdapter interface {
+	request() events.APIGatewayProxyRequest
+	response(events.APIGatewayProxyResponse) any
+}
+
+// parseEvent detects the type of an HTTP event from its raw JSON and
+// returns an adapter for it. REST API, HTTP API (v2), ALB and Lambda
+// Function URL events are supported.
+func parseEvent(raw json.RawMessage) (eventAdapter, error) {
+	var probe struct {
+		Version        string `json:"version"`
+		HTTPMethod     string `json:"httpMethod"`
+		RequestContext struct {
+			ELB        json.RawMessage `json:"elb"`
+			DomainName string          `json:"domainName"`
+		} `json:"requestContext"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	switch {
+	case probe.RequestContext.ELB != nil:
+		var event events.ALBTargetGroupRequest
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse ALB event: %w", err)
+		}
+		return albEvent{event}, nil
+
+	case probe.Version == "2.0" && strings.Contains(probe.RequestContext.DomainName, ".lambda-url."):
+		var event events.LambdaFunctionURLRequest
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse Function URL event: %w", err)
+		}
+		return functionURLEvent{event}, nil
+
+	case probe.Version == "2.0":
+		var event events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse HTTP API event: %w", err)
+		}
+		return httpAPIEvent{event}, nil
+
+	case probe.HTTPMethod != "":
+		var event events.APIGatewayProxyRequest
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse REST API event: %w", err)
+		}
+		return restAPIEvent{event}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported event type")
+}
+
+// restAPIEvent is an API Gateway REST API (v1 payload) event, which needs no conversion
+type restAPIEvent struct {
+	event events.APIGatewayProxyRequest
+}
+
+func (e restAPIEvent) request() events.APIGatewayProxyRequest {
+	return e.event
+}
+
+func (e restAPIEvent) response(response events.APIGatewayProxyResponse) any {
+	return response
+}
+
+// httpAPIEvent is an API Gateway HTTP API (v2 payload) event
+type httpAPIEvent struct {
+	event events.APIGatewayV2HTTPRequest
+}
+
+func (e httpAPIEvent) request() events.APIGatewayProxyRequest {
+	ctx := e.event.RequestContext
+	return v2ProxyRequest(ctx.HTTP.Method, e.event.RawPath, e.event.RawQueryString, e.event.Headers,
+		e.event.Cookies, e.event.Body, e.event.IsBase64Encoded, ctx.RequestID, ctx.HTTP.SourceIP, ctx.Stage)
+}
+
+func (e httpAPIEvent) response(response events.APIGatewayProxyResponse) any {
+	headers, cookies := v2Headers(response)
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode:      response.StatusCode,
+		Headers:         headers,
+		Body:            response.Body,
+		IsBase64Encoded: response.IsBase64Encoded,
+		Cookies:         cookies,
+	}
+}
+
+// functionURLEvent is a Lambda Function URL event, which uses the v2 payload format
+type functionURLEvent struct {
+	event events.LambdaFunctionURLRequest
+}
+
+func (e functionURLEvent) request() events.APIGatewayProxyRequest {
+	ctx := e.event.RequestContext
+	return v2ProxyRequest(ctx.HTTP.Method, e.event.RawPath, e.event.RawQueryString, e.event.Headers,
+		e.event.Cookies, e.event.Body, e.event.IsBase64Encoded, ctx.RequestID, ctx.HTTP.SourceIP, "")
+}
+
+func (e functionURLEvent) response(response events.APIGatewayProxyResponse) any {
+	headers, cookies := v2Headers(response)
+	return events.LambdaFunctionURLResponse{
+		StatusCode:      response.StatusCode,
+		Headers:         headers,
+		Body:            response.Body,
+		IsBase64Encoded: response.IsBase64Encoded,
+		Cookies:         cookies,
+	}
+}
+
+// albEvent is an Application Load Balancer target group event
+type albEvent struct {
+	event events.ALBTargetGroupRequest
+}
+
+func (e albEvent) request() events.APIGatewayProxyRequest {
+	request := events.APIGatewayProxyRequest{
+		Path:            e.event.Path,
+		HTTPMethod:      e.event.HTTPMethod,
+		Body:            e.event.Body,
+		IsBase64Encoded: e.event.IsBase64Encoded,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			HTTPMethod: e.event.HTTPMethod,
+			Path:       e.event.Path,
+		},
+	}
+
+	// Depending on the target group settings, ALB sends either single or
+	// multi-value headers and query parameters, and does not decode the
+	// query parameters
+	multiHeaders := e.event.MultiValueHeaders
+	if multiHeaders == nil {
+		multiHeaders = singleToMulti(e.event.Headers)
+	}
+	request.Headers, request.MultiValueHeaders = lastValues(multiHeaders), multiHeaders
+
+	multiQuery := e.event.MultiValueQueryStringParameters
+	if multiQuery == nil {
+		multiQuery = singleToMulti(e.event.QueryStringParameters)
+	}
+	multiQuery = unescapeQuery(multiQuery)
+	request.QueryStringParameters, request.MultiValueQueryStringParameters = lastValues(multiQuery), multiQuery
+
+	// ALB has no request ID, so use the trace ID it adds to every request
+	for key, values := range multiHeaders {
+		if strings.EqualFold(key, "X-Amzn-Trace-Id") && len(values) > 0 {
+			request.RequestContext.RequestID = values[0]
+		}
+		if strings.EqualFold(key, "X-Forwarded-For") && len(values) > 0 {
+			request.RequestContext.Identity.SourceIP = strings.TrimSpace(strings.Split(values[0], ",")[0])
+		}
+	}
+
+	return request
+}
+
+func (e albEvent) response(response events.APIGatewayProxyResponse) any {
+	albResponse := events.ALBTargetGroupResponse{
+		StatusCode:        response.StatusCode,
+		StatusDescription: fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		Body:              response.Body,
+		IsBase64Encoded:   response.IsBase64Encoded,
+	}
+
+	// ALB requires multi-value headers in the response if the target group
+	// sends them in the request, and ignores them otherwise
+	if e.event.MultiValueHeaders != nil {
+		albResponse.MultiValueHeaders = mergeHeaders(response)
+	} else {
+		albResponse.Headers = lastValues(mergeHeaders(response))
+	}
+
+	return albResponse
+}
+
+// v2ProxyRequest converts the fields of a v2 payload to a v1 proxy request
+func v2ProxyRequest(method, path, rawQuery string, headers map[string]string, cookies []string, body string, isBase64 bool, requestID, sourceIP, stage string) events.APIGatewayProxyRequest {
+	request := events.APIGatewayProxyRequest{
+		Path:            path,
+		HTTPMethod:      method,
+		Body:            body,
+		IsBase64Encoded: isBase64,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  requestID,
+			HTTPMethod: method,
+			Path:       path,
+			Stage:      stage,
+		},
+	}
+	request.RequestContext.Identity.SourceIP = sourceIP
+
+	// v2 joins repeated headers with commas and moves cookies out of the headers
+	if len(headers) > 0 || len(cookies) > 0 {
+		request.Headers = make(map[string]string, len(headers)+1)
+		for key, value := range headers {
+			request.Headers[key] = value
+		}
+		if len(cookies) > 0 {
+			request.Headers["cookie"] = strings.Join(cookies, "; ")
+		}
+		request.MultiValueHeaders = singleToMulti(request.Headers)
+	}
+
+	// v2 also joins repeated query parameters with commas, so read them from
+	// the raw query string instead
+	if query, err := url.ParseQuery(rawQuery); err == nil && len(query) > 0 {
+		request.QueryStringParameters = lastValues(query)
+		request.MultiValueQueryStringParameters = query
+	}
+
+	return request
+}
+
+// v2Headers converts a v1 response's headers to the v2 format, which has no
+// multi-value headers and returns cookies separately
+func v2Headers(response events.APIGatewayProxyResponse) (map[string]string, []string) {
+	headers := make(map[string]string)
+	var cookies []string
+
+	for key, values := range mergeHeaders(response) {
+		if strings.EqualFold(key, "Set-Cookie") {
+			cookies = append(cookies, values...)
+			continue
+		}
+		headers[key] = strings.Join(values, ",")
+	}
+
+	return headers, cookies
+}
+
+// mergeHeaders combines a v1 response's single and multi-value headers,
+// with multi-value headers taking precedence as in API Gateway
+func mergeHeaders(response events.APIGatewayProxyResponse) map[string][]string {
+	merged := make(map[string][]string, len(response.Headers)+len(response.MultiValueHeaders))
+	for key, value := range response.Headers {
+		merged[key] = []string{value}
+	}
+	for key, values := range response.MultiValueHeaders {
+		merged[key] = values
+	}
+	return merged
+}
+
+// singleToMulti converts a single-value map to a multi-value map
+func singleToMulti(values map[string]string) map[string][]string {
+	if values == nil {
+		return nil
+	}
+	multi := make(map[string][]string, len(values))
+	for key, value := range values {
+		multi[key] = []string{value}
+	}
+	return multi
+}
+
+// lastValues converts a multi-value map to a single-value map, keeping the
+// last value of each key as API Gateway does
+func lastValues(values map[string][]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	single := make(map[string]string, len(values))
+	for key, v := range values {
+		if len(v) > 0 {
+			single[key] = v[len(v)-1]
+		}
+	}
+	return single
+}
+
+// unescapeQuery decodes the URL-encoded query parameters ALB sends
+func unescapeQuery(query map[string][]string) map[string][]string {
+	if query == nil {
+		return nil
+	}
+	unescaped := make(map[string][]string, len(query))
+	for key, values := range query {
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		for _, value := range values {
+			if v, err := url.QueryUnescape(value); err == nil {
+				value = v
+			}
+			unescaped[key] = append(unescaped[key], value)
+		}
+	}
+	return unescaped
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// readEvent reads a recorded Lambda event from testdata
+func readEvent(t *testing.T, name string) json.RawMessage {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "events", name))
+	if err != nil {
+		t.Fatalf("Failed to read event %s: %v", name, err)
+	}
+	return raw
+}
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		file      string
+		adapter   eventAdapter
+		method    string
+		owner     string
+		requestID string
+	}{
+		{"rest-api.json", restAPIEvent{}, http.MethodGet, "john@doe.com", "c6af9ac6-7b61-11e6-9a41-93e8deadbeef"},
+		{"http-api.json", httpAPIEvent{}, http.MethodGet, "john@doe.com", "JKJaXmPLvHcESHA="},
+		{"function-url.json", functionURLEvent{}, http.MethodPost, "john@doe.com", "id"},
+		{"alb.json", albEvent{}, http.MethodGet, "john@doe.com", "Root=1-5bdb40ca-556d8b0c50dc66f0511bf520"},
+		{"alb-multi-value.json", albEvent{}, http.MethodGet, "john@doe.com", "Root=1-5bdb40ca-556d8b0c50dc66f0511bf520"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			// Act
+			adapter, err := parseEvent(readEvent(t, tt.file))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if reflect.TypeOf(adapter) != reflect.TypeOf(tt.adapter) {
+				t.Fatalf("Expected adapter %T, got %T", tt.adapter, adapter)
+			}
+
+			request := adapter.request()
+			if request.HTTPMethod != tt.method {
+				t.Errorf("Expected method %s, got %s", tt.method, request.HTTPMethod)
+			}
+			if request.Path != "/api/tasks/" {
+				t.Errorf("Expected path /api/tasks/, got %s", request.Path)
+			}
+			if request.QueryStringParameters["owner"] != tt.owner {
+				t.Errorf("Expected owner %s, got %s", tt.owner, request.QueryStringParameters["owner"])
+			}
+			if request.RequestContext.RequestID != tt.requestID {
+				t.Errorf("Expected request ID %s, got %s", tt.requestID, request.RequestContext.RequestID)
+			}
+		})
+	}
+}
+
+func TestParseEventUnsupported(t *testing.T) {
+	for _, raw := range []string{`{"Records": []}`, `not json`} {
+		// Act
+		_, err := parseEvent(json.RawMessage(raw))
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected error for event %s, got nil", raw)
+		}
+	}
+}
+
+func TestHTTPAPIEventMultiValue(t *testing.T) {
+	// Arrange
+	adapter, _ := parseEvent(readEvent(t, "http-api.json"))
+
+	// Act
+	request := adapter.request()
+
+	// Assert
+	if want := []string{"OPEN", "CLOSED"}; !reflect.DeepEqual(request.MultiValueQueryStringParameters["status"], want) {
+		t.Errorf("Expected status values %v, got %v", want, request.MultiValueQueryStringParameters["status"])
+	}
+	if request.Headers["cookie"] != "session=abc; theme=dark" {
+		t.Errorf("Expected cookies to be joined into the cookie header, got '%s'", request.Headers["cookie"])
+	}
+	if request.RequestContext.Identity.SourceIP != "203.0.113.1" {
+		t.Errorf("Expected source IP 203.0.113.1, got %s", request.RequestContext.Identity.SourceIP)
+	}
+}
+
+func TestALBEventMultiValue(t *testing.T) {
+	// Arrange
+	adapter, _ := parseEvent(readEvent(t, "alb-multi-value.json"))
+
+	// Act
+	request := adapter.request()
+	response := adapter.response(events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		Headers:           map[string]string{"Content-Type": "application/json"},
+		MultiValueHeaders: map[string][]string{"Set-Cookie": {"a=1", "b=2"}},
+	}).(events.ALBTargetGroupResponse)
+
+	// Assert
+	if want := []string{"OPEN", "CLOSED"}; !reflect.DeepEqual(request.MultiValueQueryStringParameters["status"], want) {
+		t.Errorf("Expected status values %v, got %v", want, request.MultiValueQueryStringParameters["status"])
+	}
+	if response.StatusDescription != "200 OK" {
+		t.Errorf("Expected status description '200 OK', got '%s'", response.StatusDescription)
+	}
+	if response.Headers != nil {
+		t.Errorf("Expected no single-value headers, got %v", response.Headers)
+	}
+	if len(response.MultiValueHeaders["Set-Cookie"]) != 2 {
+		t.Errorf("Expected 2 Set-Cookie values, got %v", response.MultiValueHeaders["Set-Cookie"])
+	}
+	if response.MultiValueHeaders["Content-Type"][0] != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %v", response.MultiValueHeaders["Content-Type"])
+	}
+}
+
+func TestHTTPAPIEventResponse(t *testing.T) {
+	// Arrange
+	adapter, _ := parseEvent(readEvent(t, "http-api.json"))
+
+	// Act
+	response := adapter.response(events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusCreated,
+		Body:              `{}`,
+		Headers:           map[string]string{"Content-Type": "application/json"},
+		MultiValueHeaders: map[string][]string{"Set-Cookie": {"a=1", "b=2"}, "Vary": {"Accept", "Origin"}},
+	}).(events.APIGatewayV2HTTPResponse)
+
+	// Assert
+	if response.StatusCode != http.StatusCreated || response.Body != `{}` {
+		t.Errorf("Expected 201 with body {}, got %d with %s", response.StatusCode, response.Body)
+	}
+	if !reflect.DeepEqual(response.Cookies, []string{"a=1", "b=2"}) {
+		t.Errorf("Expected cookies [a=1 b=2], got %v", response.Cookies)
+	}
+	if response.Headers["Vary"] != "Accept,Origin" {
+		t.Errorf("Expected Vary 'Accept,Origin', got '%s'", response.Headers["Vary"])
+	}
+	if _, ok := response.Headers["Set-Cookie"]; ok {
+		t.Error("Expected Set-Cookie to be moved to cookies")
+	}
+}
+
+func TestFunctionURLEventRoundTrip(t *testing.T) {
+	// Arrange
+	api := &API{store: NewMockTaskStore()}
+	adapter, _ := parseEvent(readEvent(t, "function-url.json"))
+
+	// Act
+	response, err := api.HandleRequest(context.Background(), adapter.request())
+	urlResponse := adapter.response(response).(events.LambdaFunctionURLResponse)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if urlResponse.StatusCode != http.StatusCreated {
+		t.Errorf("Expected status code %d, got %d: %s", http.StatusCreated, urlResponse.StatusCode, urlResponse.Body)
+	}
+
+	var task Task
+	if err := json.Unmarshal([]byte(urlResponse.Body), &task); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if task.Title != "Clean your office" {
+		t.Errorf("Expected title 'Clean your office', got '%s'", task.Title)
+	}
+}
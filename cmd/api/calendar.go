@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// calendarFeedPath is the path of the iCalendar feed
+const calendarFeedPath = "/api/tasks/calendar.ics"
+
+// calendarProductID identifies this API as the creator of calendar objects
+const calendarProductID = "-//tasks-api//Tasks//EN"
+
+// feedTokenBytes is the number of random bytes in a feed token
+const feedTokenBytes = 32
+
+// FeedTokenResponse is the response to creating a calendar feed token. The
+// token is only ever returned here; the store keeps its hash.
+type FeedTokenResponse struct {
+	Token string `json:"token"`
+	// Path is the feed's path, including the token, to subscribe to
+	Path string `json:"path"`
+}
+
+// newFeedToken returns a new random feed token
+func newFeedToken() (string, error) {
+	b := make([]byte, feedTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashFeedToken returns the hash a feed token is stored and looked up by
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createFeedToken creates a calendar feed token for an owner, revoking the
+// previous one
+func (api *API) createFeedToken(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Get the owner from the query parameters
+	var errs ValidationErrors
+	owner := request.QueryStringParameters["owner"]
+	validateText(&errs, "owner", &owner, true, maxOwnerLength)
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	token, err := newFeedToken()
+	if err != nil {
+		return errorResponse(request, err, "Feed token", "Failed to create feed token")
+	}
+	if err := api.store.PutFeedToken(ctx, owner, hashFeedToken(token)); err != nil {
+		return errorResponse(request, err, "Feed token", "Failed to save feed token")
+	}
+
+	// Marshal the token to JSON
+	body, err := json.Marshal(FeedTokenResponse{
+		Token: token,
+		Path:  calendarFeedPath + "?" + url.Values{"token": {token}}.Encode(),
+	})
+	if err != nil {
+		return errorResponse(request, err, "Feed token", "Failed to marshal feed token")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusCreated,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Cache-Control": "no-store",
+		},
+	}, nil
+}
+
+// deleteFeedToken revokes an owner's calendar feed token
+func (api *API) deleteFeedToken(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Get the owner from the query parameters
+	var errs ValidationErrors
+	owner := request.QueryStringParameters["owner"]
+	validateText(&errs, "owner", &owner, true, maxOwnerLength)
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	err := api.store.DeleteFeedToken(ctx, owner)
+	if err != nil {
+		return errorResponse(request, err, "Feed token", "Failed to delete feed token")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusNoContent,
+	}, nil
+}
+
+// calendarFeed renders the tasks of a feed token's owner as an iCalendar
+// object of VTODO components
+func (api *API) calendarFeed(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Get the token from the query parameters
+	token := request.QueryStringParameters["token"]
+	if token == "" {
+		var errs ValidationErrors
+		errs.add("token", FieldRequired)
+		return invalidRequestResponse(request, errs.err())
+	}
+
+	// Unknown and revoked tokens look the same, so they reveal nothing
+	owner, err := api.store.FeedTokenOwner(ctx, hashFeedToken(token))
+	if err != nil {
+		return errorResponse(request, err, "Calendar feed", "Failed to get feed token")
+	}
+
+	// List the open tasks, then the closed tasks
+	open, err := api.store.ListOpen(ctx, owner)
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to list tasks")
+	}
+	closed, err := api.store.ListClosed(ctx, owner)
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to list tasks")
+	}
+
+	// Encode the tasks
+	var body bytes.Buffer
+	if err := encodeCalendar(&body, append(open, closed...), time.Now()); err != nil {
+		return errorResponse(request, err, "Task", "Failed to encode tasks")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       body.String(),
+		Headers: map[string]string{
+			"Content-Type":  "text/calendar; charset=utf-8",
+			"Cache-Control": "private, no-cache",
+		},
+	}, nil
+}
+
+// encodeCalendar writes tasks as an iCalendar object with a VTODO per task,
+// stamped with the time the feed was generated
+func encodeCalendar(w io.Writer, tasks []Task, now time.Time) error {
+	iw := &icalWriter{w: w}
+	iw.Property("BEGIN", "VCALENDAR")
+	iw.Property("VERSION", "2.0")
+	iw.Text("PRODID", calendarProductID)
+	iw.Property("CALSCALE", "GREGORIAN")
+	iw.Text("X-WR-CALNAME", "Tasks")
+
+	for _, task := range tasks {
+		iw.Property("BEGIN", "VTODO")
+		iw.Text("UID", calendarUID(task))
+		iw.Time("DTSTAMP", now)
+		iw.Text("SUMMARY", task.Title)
+		if task.Description != "" {
+			iw.Text("DESCRIPTION", task.Description)
+		}
+		if task.Due != "" {
+			writeCalendarDue(iw, task.Due)
+		}
+		if task.Recurrence != "" {
+			iw.Property("RRULE", task.Recurrence)
+		}
+		iw.Property("STATUS", calendarStatus(task.Status))
+		iw.Property("END", "VTODO")
+	}
+
+	iw.Property("END", "VCALENDAR")
+	return iw.Err()
+}
+
+// calendarUID returns the UID of a task's VTODO: the UID it was imported
+// with, so calendar clients recognize it, or otherwise its ID
+func calendarUID(task Task) string {
+	if task.UID != "" {
+		return task.UID
+	}
+	return task.ID.String()
+}
+
+// writeCalendarDue writes a task's due date or time as a DUE property
+func writeCalendarDue(iw *icalWriter, due string) {
+	if date, err := time.Parse(dueDateFormat, due); err == nil {
+		iw.Property("DUE;VALUE=DATE", date.Format(icalDateFormat))
+		return
+	}
+	if t, err := time.Parse(time.RFC3339, due); err == nil {
+		iw.Time("DUE", t)
+	}
+}
+
+// calendarStatus maps a task status to a VTODO status
+func calendarStatus(status TaskStatus) string {
+	if status == TaskStatusClosed {
+		return "COMPLETED"
+	}
+	return "NEEDS-ACTION"
+}
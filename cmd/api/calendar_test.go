@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// feedTokenRequest builds a request to create or revoke a feed token
+func feedTokenRequest(method, owner string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		Path:                  "/api/tasks/calendar/token",
+		HTTPMethod:            method,
+		QueryStringParameters: map[string]string{"owner": owner},
+	}
+}
+
+// calendarRequest builds a calendar feed request
+func calendarRequest(token string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		Path:                  calendarFeedPath,
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: map[string]string{"token": token},
+	}
+}
+
+// createTestFeedToken creates a feed token through the API
+func createTestFeedToken(t *testing.T, api *API, owner string) FeedTokenResponse {
+	t.Helper()
+	response, err := api.HandleRequest(context.Background(), feedTokenRequest(http.MethodPost, owner))
+	if err != nil || response.StatusCode != http.StatusCreated {
+		t.Fatalf("Failed to create feed token: %d %s %v", response.StatusCode, response.Body, err)
+	}
+	var created FeedTokenResponse
+	if err := json.Unmarshal([]byte(response.Body), &created); err != nil {
+		t.Fatalf("Failed to unmarshal feed token: %v", err)
+	}
+	return created
+}
+
+func TestCalendarFeed(t *testing.T) {
+	// Arrange
+	api := newExportAPI(t, "Buy milk, eggs", "File taxes")
+	_ = api.store.Add(context.Background(), NewTask(uuid.New(), "Someone else's task", "other@example.com"))
+	created := createTestFeedToken(t, api, "test@example.com")
+
+	// Act
+	response, err := api.HandleRequest(context.Background(), calendarRequest(created.Token))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, response.StatusCode, response.Body)
+	}
+	if response.Headers["Content-Type"] != "text/calendar; charset=utf-8" {
+		t.Errorf("Expected a text/calendar content type, got %q", response.Headers["Content-Type"])
+	}
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"SUMMARY:Buy milk\\, eggs\r\nSTATUS:NEEDS-ACTION\r\n",
+		"SUMMARY:File taxes\r\nSTATUS:COMPLETED\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(response.Body, want) {
+			t.Errorf("Expected the feed to contain %q, got %q", want, response.Body)
+		}
+	}
+	if strings.Contains(response.Body, "Someone else") {
+		t.Error("Expected the feed to only contain the token owner's tasks")
+	}
+	if created.Path != calendarFeedPath+"?token="+url.QueryEscape(created.Token) {
+		t.Errorf("Expected the path to include the token, got %q", created.Path)
+	}
+}
+
+func TestCalendarFeedTokens(t *testing.T) {
+	// Arrange
+	api := newExportAPI(t, "Buy milk")
+	first := createTestFeedToken(t, api, "test@example.com")
+	second := createTestFeedToken(t, api, "test@example.com")
+
+	// Act
+	replaced, _ := api.HandleRequest(context.Background(), calendarRequest(first.Token))
+	current, _ := api.HandleRequest(context.Background(), calendarRequest(second.Token))
+	revoke, _ := api.HandleRequest(context.Background(), feedTokenRequest(http.MethodDelete, "test@example.com"))
+	revoked, _ := api.HandleRequest(context.Background(), calendarRequest(second.Token))
+	revokeAgain, _ := api.HandleRequest(context.Background(), feedTokenRequest(http.MethodDelete, "test@example.com"))
+	missing, _ := api.HandleRequest(context.Background(), calendarRequest(""))
+
+	// Assert
+	if first.Token == second.Token {
+		t.Error("Expected a new token each time")
+	}
+	if replaced.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a replaced token to get %d, got %d", http.StatusNotFound, replaced.StatusCode)
+	}
+	if current.StatusCode != http.StatusOK {
+		t.Errorf("Expected the current token to get %d, got %d", http.StatusOK, current.StatusCode)
+	}
+	if revoke.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected revoking to return %d, got %d", http.StatusNoContent, revoke.StatusCode)
+	}
+	if revoked.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a revoked token to get %d, got %d", http.StatusNotFound, revoked.StatusCode)
+	}
+	if revokeAgain.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected revoking twice to return %d, got %d", http.StatusNotFound, revokeAgain.StatusCode)
+	}
+	if missing.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a missing token to get %d, got %d", http.StatusBadRequest, missing.StatusCode)
+	}
+}
+
+func TestEncodeCalendar(t *testing.T) {
+	// Arrange
+	id := uuid.MustParse("0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90")
+	task := NewTask(id, strings.Repeat("long title ", 10), "test@example.com")
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	var b bytes.Buffer
+
+	// Act
+	err := encodeCalendar(&b, []Task{task}, now)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//tasks-api//Tasks//EN\r\n" +
+		"CALSCALE:GREGORIAN\r\n" +
+		"X-WR-CALNAME:Tasks\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90\r\n" +
+		"DTSTAMP:20240102T020405Z\r\n" +
+		"SUMMARY:long title long title long title long title long title long title l\r\n" +
+		" ong title long title long title long title \r\n" +
+		"STATUS:NEEDS-ACTION\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+	if b.String() != want {
+		t.Errorf("Expected %q, got %q", want, b.String())
+	}
+}
+
+func TestEncodeCalendarOptionalFields(t *testing.T) {
+	tests := []struct {
+		name string
+		task Task
+		want []string
+	}{
+		{
+			"imported",
+			Task{ID: uuid.New(), Title: "Call", UID: "call@example.com", Description: "Ask about\nthe weekend", Due: "2024-05-01T07:00:00Z", Recurrence: "FREQ=WEEKLY;BYDAY=SU"},
+			[]string{"UID:call@example.com\r\n", "DESCRIPTION:Ask about\\nthe weekend\r\n", "DUE:20240501T070000Z\r\n", "RRULE:FREQ=WEEKLY;BYDAY=SU\r\n"},
+		},
+		{
+			"due date",
+			Task{ID: uuid.New(), Title: "Pay rent", Due: "2024-05-01"},
+			[]string{"DUE;VALUE=DATE:20240501\r\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var b bytes.Buffer
+
+			// Act
+			err := encodeCalendar(&b, []Task{tt.task}, time.Now())
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(b.String(), want) {
+					t.Errorf("Expected the calendar to contain %q, got %q", want, b.String())
+				}
+			}
+		})
+	}
+}
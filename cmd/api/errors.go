@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	// ErrNotFound is returned when a task does not exist
+	ErrNotFound = errors.New("task not found")
+	// ErrConflict is returned when a write conflicts with the current state of a task
+	ErrConflict = errors.New("conflict")
+	// ErrThrottled is returned when the store rejects a request because of capacity limits
+	ErrThrottled = errors.New("throttled")
+)
+
+// storeError wraps a DynamoDB error with the matching sentinel error, so
+// callers can use errors.Is without depending on the SDK's error types
+func storeError(err error) error {
+	var (
+		conditionFailed *types.ConditionalCheckFailedException
+		txConflict      *types.TransactionConflictException
+		throughput      *types.ProvisionedThroughputExceededException
+		requestLimit    *types.RequestLimitExceeded
+		apiErr          smithy.APIError
+	)
+
+	switch {
+	case errors.As(err, &conditionFailed), errors.As(err, &txConflict):
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case errors.As(err, &throughput), errors.As(err, &requestLimit):
+		return fmt.Errorf("%w: %w", ErrThrottled, err)
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() == "ThrottlingException":
+		return fmt.Errorf("%w: %w", ErrThrottled, err)
+	}
+
+	return err
+}
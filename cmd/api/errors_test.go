@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestStoreError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"conditional check failed", &types.ConditionalCheckFailedException{}, ErrConflict},
+		{"transaction conflict", &types.TransactionConflictException{}, ErrConflict},
+		{"provisioned throughput exceeded", &types.ProvisionedThroughputExceededException{}, ErrThrottled},
+		{"request limit exceeded", &types.RequestLimitExceeded{}, ErrThrottled},
+		{"throttling exception", &smithy.GenericAPIError{Code: "ThrottlingException"}, ErrThrottled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			err := storeError(tt.err)
+
+			// Assert
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v to wrap %v", err, tt.want)
+			}
+			if !errors.As(err, new(smithy.APIError)) {
+				t.Errorf("Expected %v to keep the original error", err)
+			}
+		})
+	}
+}
+
+func TestStoreErrorUnknown(t *testing.T) {
+	// Arrange
+	original := errors.New("boom")
+
+	// Act
+	err := storeError(original)
+
+	// Assert
+	if err != original {
+		t.Errorf("Expected unknown errors to be returned unchanged, got %v", err)
+	}
+}
+
+func TestTransactionErrorUnwrap(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   error
+	}{
+		{TransactionReasonNotFound, ErrNotFound},
+		{TransactionReasonAlreadyExists, ErrConflict},
+		{TransactionReasonConflict, ErrConflict},
+		{"ThrottlingError", ErrThrottled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			// Act
+			err := &TransactionError{Index: 0, Reason: tt.reason}
+
+			// Assert
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v to wrap %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// maxInlineExportBytes is the largest export body returned in the response
+// itself. Lambda rejects responses over 6 MB, so this leaves room for the
+// headers and the JSON encoding of the response.
+const maxInlineExportBytes = 5 << 20
+
+// exportFormat is a format tasks can be exported in
+type exportFormat struct {
+	contentType string
+	extension   string
+	encode      func(w io.Writer, tasks []Task) error
+}
+
+// exportFormats maps the format query parameter to its format
+var exportFormats = map[string]exportFormat{
+	"csv":   {contentType: "text/csv; charset=utf-8", extension: "csv", encode: encodeCSV},
+	"jsonl": {contentType: "application/x-ndjson", extension: "jsonl", encode: encodeJSONLines},
+	"md":    {contentType: "text/markdown; charset=utf-8", extension: "md", encode: encodeMarkdown},
+}
+
+// ExportStore saves exports that are too large to return in a response
+type ExportStore interface {
+	// Save stores an export and returns a URL it can be downloaded from
+	Save(ctx context.Context, name, contentType string, body []byte) (string, error)
+}
+
+// exportTasks exports all of an owner's tasks, open and closed
+func (api *API) exportTasks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Get the owner and format from the query parameters
+	var errs ValidationErrors
+	owner := request.QueryStringParameters["owner"]
+	validateText(&errs, "owner", &owner, true, maxOwnerLength)
+
+	formatName := request.QueryStringParameters["format"]
+	if formatName == "" {
+		formatName = "csv"
+	}
+	format, ok := exportFormats[formatName]
+	if !ok {
+		errs.add("format", FieldInvalidValue)
+	}
+
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	// List the open tasks, then the closed tasks
+	open, err := api.store.ListOpen(ctx, owner)
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to list tasks")
+	}
+	closed, err := api.store.ListClosed(ctx, owner)
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to list tasks")
+	}
+
+	// Encode the tasks
+	var body bytes.Buffer
+	if err := format.encode(&body, append(open, closed...)); err != nil {
+		return errorResponse(request, err, "Task", "Failed to encode tasks")
+	}
+
+	name := fmt.Sprintf("tasks-%s.%s", time.Now().UTC().Format("2006-01-02"), format.extension)
+	return api.exportResponse(ctx, request, body.Bytes(), name, format.contentType)
+}
+
+// exportResponse returns an export in the response if it fits, gzipped if
+// the client accepts it and that makes it fit, or otherwise saves it to the
+// export store and redirects to it
+func (api *API) exportResponse(ctx context.Context, request events.APIGatewayProxyRequest, body []byte, name, contentType string) (events.APIGatewayProxyResponse, error) {
+	limit := api.maxExportBytes
+	if limit == 0 {
+		limit = maxInlineExportBytes
+	}
+
+	headers := map[string]string{
+		"Content-Type":        contentType,
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", name),
+		"Vary":                "Accept-Encoding",
+	}
+
+	// The body is sent as a JSON string, so measure it encoded
+	if encoded, err := json.Marshal(string(body)); err == nil && len(encoded) <= limit {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(body),
+			Headers:    headers,
+		}, nil
+	}
+
+	if acceptsGzip(request) {
+		compressed, err := gzipBytes(body)
+		if err != nil {
+			return errorResponse(request, err, "Task", "Failed to compress export")
+		}
+		if base64.StdEncoding.EncodedLen(len(compressed)) <= limit {
+			headers["Content-Encoding"] = "gzip"
+			return events.APIGatewayProxyResponse{
+				StatusCode:      http.StatusOK,
+				Body:            base64.StdEncoding.EncodeToString(compressed),
+				IsBase64Encoded: true,
+				Headers:         headers,
+			}, nil
+		}
+	}
+
+	if api.exports == nil {
+		return problemResponse(request, http.StatusRequestEntityTooLarge, CodeExportTooLarge,
+			"The export is too large to return in a response and no export storage is configured")
+	}
+
+	url, err := api.exports.Save(ctx, name, contentType, body)
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to save export")
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusSeeOther,
+		Headers: map[string]string{
+			"Location": url,
+		},
+	}, nil
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding header allows gzip
+func acceptsGzip(request events.APIGatewayProxyRequest) bool {
+	for _, encoding := range strings.Split(requestHeader(request, "Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// gzipBytes compresses data with gzip
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeCSV writes tasks as CSV with a header row. Labels are separated by
+// spaces.
+func encodeCSV(w io.Writer, tasks []Task) error {
+	cw := csv.NewWriter(w)
+	header := []string{"id", "title", "status", "owner", "description", "due", "recurrence", "uid", "priority", "labels"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		record := []string{
+			task.ID.String(), csvText(task.Title), string(task.Status), csvText(task.Owner),
+			csvText(task.Description), task.Due, csvText(task.Recurrence), csvText(task.UID),
+			string(task.Priority), csvText(strings.Join(task.Labels, " ")),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvText prefixes values that spreadsheets would evaluate as formulas with
+// a single quote, so that opening an export cannot run a formula
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// encodeJSONLines writes tasks as one JSON object per line
+func encodeJSONLines(w io.Writer, tasks []Task) error {
+	encoder := json.NewEncoder(w)
+	for _, task := range tasks {
+		if err := encoder.Encode(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeMarkdown writes tasks as a Markdown table
+func encodeMarkdown(w io.Writer, tasks []Task) error {
+	var b strings.Builder
+	b.WriteString("| ID | Title | Status | Owner | Description | Due | Recurrence | UID | Priority | Labels |\n")
+	b.WriteString("|----|-------|--------|-------|-------------|-----|------------|-----|----------|--------|\n")
+	for _, task := range tasks {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s | %s | %s | %s |\n", task.ID, markdownText(task.Title), task.Status,
+			markdownText(task.Owner), markdownText(task.Description), task.Due, markdownText(task.Recurrence),
+			markdownText(task.UID), task.Priority, markdownText(strings.Join(task.Labels, " ")))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownText escapes a value for a Markdown table cell, so that it cannot
+// end the cell or the row, or add formatting
+var markdownText = strings.NewReplacer(
+	`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ",
+	"*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`,
+).Replace
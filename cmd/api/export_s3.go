@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+)
+
+// exportURLExpiry is how long the download URL of a saved export is valid
+const exportURLExpiry = 15 * time.Minute
+
+// S3ExportStore saves exports to an S3 bucket and returns presigned URLs
+type S3ExportStore struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+}
+
+var _ ExportStore = (*S3ExportStore)(nil)
+
+// NewS3ExportStore creates an export store backed by an S3 bucket
+func NewS3ExportStore(bucket string) (*S3ExportStore, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg)
+	return &S3ExportStore{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		bucket:  bucket,
+	}, nil
+}
+
+// Save uploads an export under a random key and returns a presigned URL
+// that downloads it
+func (s *S3ExportStore) Save(ctx context.Context, name, contentType string, body []byte) (string, error) {
+	key := "exports/" + uuid.New().String() + "/" + name
+
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:             aws.String(s.bucket),
+		Key:                aws.String(key),
+		Body:               bytes.NewReader(body),
+		ContentType:        aws.String(contentType),
+		ContentDisposition: aws.String(fmt.Sprintf("attachment; filename=%q", name)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload export to S3: %w", err)
+	}
+
+	request, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(exportURLExpiry))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign export URL: %w", err)
+	}
+
+	return request.URL, nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// recordingExportStore records saved exports
+type recordingExportStore struct {
+	name, contentType string
+	body              []byte
+	err               error
+}
+
+func (s *recordingExportStore) Save(ctx context.Context, name, contentType string, body []byte) (string, error) {
+	s.name, s.contentType, s.body = name, contentType, body
+	return "https://exports.example.com/" + name, s.err
+}
+
+// newExportAPI creates an API whose store has an open and a closed task
+func newExportAPI(t *testing.T, titles ...string) *API {
+	t.Helper()
+	store := NewMockTaskStore()
+	for i, title := range titles {
+		task := NewTask(uuid.New(), title, "test@example.com")
+		if i%2 == 1 {
+			task.Status = TaskStatusClosed
+		}
+		if err := store.Add(context.Background(), task); err != nil {
+			t.Fatalf("Failed to add task: %v", err)
+		}
+	}
+	return NewAPI(store)
+}
+
+// exportRequest builds an export request
+func exportRequest(format string, headers map[string]string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		Path:                  "/api/tasks/export",
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: map[string]string{"owner": "test@example.com", "format": format},
+		Headers:               headers,
+	}
+}
+
+func TestExportTasksFormats(t *testing.T) {
+	tests := []struct {
+		format      string
+		contentType string
+		extension   string
+	}{
+		{"", "text/csv; charset=utf-8", ".csv"},
+		{"csv", "text/csv; charset=utf-8", ".csv"},
+		{"jsonl", "application/x-ndjson", ".jsonl"},
+		{"md", "text/markdown; charset=utf-8", ".md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			// Arrange
+			api := newExportAPI(t, "Open Task", "Closed Task")
+
+			// Act
+			response, err := api.HandleRequest(context.Background(), exportRequest(tt.format, nil))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if response.StatusCode != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, response.StatusCode, response.Body)
+			}
+			if response.Headers["Content-Type"] != tt.contentType {
+				t.Errorf("Expected content type %s, got %s", tt.contentType, response.Headers["Content-Type"])
+			}
+			disposition := response.Headers["Content-Disposition"]
+			if !strings.HasPrefix(disposition, `attachment; filename="tasks-`) || !strings.HasSuffix(disposition, tt.extension+`"`) {
+				t.Errorf("Expected an attachment with extension %s, got %s", tt.extension, disposition)
+			}
+			if !strings.Contains(response.Body, "Open Task") || !strings.Contains(response.Body, "Closed Task") {
+				t.Errorf("Expected open and closed tasks, got %s", response.Body)
+			}
+		})
+	}
+}
+
+func TestExportTasksInvalidParameters(t *testing.T) {
+	// Arrange
+	api := newExportAPI(t)
+	request := exportRequest("xlsx", nil)
+	request.QueryStringParameters["owner"] = ""
+
+	// Act
+	response, _ := api.HandleRequest(context.Background(), request)
+
+	// Assert
+	if response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, response.StatusCode)
+	}
+	var problem Problem
+	_ = json.Unmarshal([]byte(response.Body), &problem)
+	if len(problem.Errors) != 2 {
+		t.Errorf("Expected errors for owner and format, got %+v", problem.Errors)
+	}
+}
+
+func TestEncodeCSV(t *testing.T) {
+	// Arrange
+	tasks := []Task{
+		NewTask(uuid.New(), `Say "hi", then leave`, "test@example.com"),
+		NewTask(uuid.New(), "=HYPERLINK(\"http://evil\")", "test@example.com"),
+	}
+	var buf bytes.Buffer
+
+	// Act
+	err := encodeCSV(&buf, tasks)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got %v", err)
+	}
+	if len(records) != 3 || strings.Join(records[0], ",") != "id,title,status,owner,description,due,recurrence,uid,priority,labels" {
+		t.Fatalf("Expected a header and two rows, got %v", records)
+	}
+	if records[1][1] != `Say "hi", then leave` {
+		t.Errorf("Expected the title to round-trip, got %q", records[1][1])
+	}
+	if records[2][1] != `'=HYPERLINK("http://evil")` {
+		t.Errorf("Expected the formula to be neutralised, got %q", records[2][1])
+	}
+}
+
+func TestEncodeJSONLines(t *testing.T) {
+	// Arrange
+	tasks := []Task{NewTask(uuid.New(), "First", "a@example.com"), NewTask(uuid.New(), "Second", "a@example.com")}
+	var buf bytes.Buffer
+
+	// Act
+	err := encodeJSONLines(&buf, tasks)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	var task Task
+	if err := json.Unmarshal([]byte(lines[1]), &task); err != nil || !reflect.DeepEqual(task, tasks[1]) {
+		t.Errorf("Expected %+v, got %+v (%v)", tasks[1], task, err)
+	}
+}
+
+func TestEncodeMarkdown(t *testing.T) {
+	// Arrange
+	tasks := []Task{NewTask(uuid.New(), "a | b *c*", "test@example.com")}
+	var buf bytes.Buffer
+
+	// Act
+	err := encodeMarkdown(&buf, tasks)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header, a separator and a row, got %q", buf.String())
+	}
+	if !strings.Contains(lines[2], `a \| b \*c\*`) {
+		t.Errorf("Expected the title to be escaped, got %s", lines[2])
+	}
+}
+
+func TestExportTasksLarge(t *testing.T) {
+	// Arrange: repetitive titles compress well
+	titles := make([]string, 50)
+	for i := range titles {
+		titles[i] = strings.Repeat("Write the quarterly report ", 5)
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		api := newExportAPI(t, titles...)
+		api.maxExportBytes = 2000
+
+		// Act
+		response, _ := api.HandleRequest(context.Background(), exportRequest("csv", map[string]string{"accept-encoding": "br, gzip"}))
+
+		// Assert
+		if response.StatusCode != http.StatusOK || !response.IsBase64Encoded || response.Headers["Content-Encoding"] != "gzip" {
+			t.Fatalf("Expected a gzipped response, got %d %v %v", response.StatusCode, response.IsBase64Encoded, response.Headers)
+		}
+		compressed, _ := base64.StdEncoding.DecodeString(response.Body)
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatalf("Expected gzip data, got %v", err)
+		}
+		body, _ := io.ReadAll(reader)
+		if records, err := csv.NewReader(bytes.NewReader(body)).ReadAll(); err != nil || len(records) != 51 {
+			t.Errorf("Expected 51 records, got %d (%v)", len(records), err)
+		}
+	})
+
+	t.Run("saved", func(t *testing.T) {
+		api := newExportAPI(t, titles...)
+		api.maxExportBytes = 100
+		exports := &recordingExportStore{}
+		api.exports = exports
+
+		// Act
+		response, _ := api.HandleRequest(context.Background(), exportRequest("md", map[string]string{"Accept-Encoding": "gzip"}))
+
+		// Assert
+		if response.StatusCode != http.StatusSeeOther {
+			t.Fatalf("Expected status code %d, got %d", http.StatusSeeOther, response.StatusCode)
+		}
+		if response.Headers["Location"] != "https://exports.example.com/"+exports.name {
+			t.Errorf("Expected a redirect to the saved export, got %s", response.Headers["Location"])
+		}
+		if exports.contentType != "text/markdown; charset=utf-8" || len(exports.body) == 0 {
+			t.Errorf("Expected the Markdown export to be saved, got %q with %d bytes", exports.contentType, len(exports.body))
+		}
+	})
+
+	t.Run("save fails", func(t *testing.T) {
+		api := newExportAPI(t, titles...)
+		api.maxExportBytes = 100
+		api.exports = &recordingExportStore{err: errors.New("access denied")}
+
+		// Act
+		response, _ := api.HandleRequest(context.Background(), exportRequest("csv", nil))
+
+		// Assert
+		if response.StatusCode != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, response.StatusCode)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		api := newExportAPI(t, titles...)
+		api.maxExportBytes = 2000
+
+		// Act: without gzip, the export does not fit
+		response, _ := api.HandleRequest(context.Background(), exportRequest("csv", map[string]string{"Accept-Encoding": "gzip;q=0"}))
+
+		// Assert
+		var problem Problem
+		_ = json.Unmarshal([]byte(response.Body), &problem)
+		if response.StatusCode != http.StatusRequestEntityTooLarge || problem.Code != CodeExportTooLarge {
+			t.Errorf("Expected status code %d with code %s, got %d %s", http.StatusRequestEntityTooLarge, CodeExportTooLarge, response.StatusCode, problem.Code)
+		}
+	})
+}
@@ -3,76 +3,94 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"strings"
+	"sync"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/google/uuid"
 )
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Message string `json:"message"`
-}
-
 // CreateTaskRequest represents a request to create a task
 type CreateTaskRequest struct {
-	Title string `json:"title"`
-	Owner string `json:"owner"`
+	Title    string       `json:"title"`
+	Owner    string       `json:"owner"`
+	Priority TaskPriority `json:"priority,omitempty"`
+	Labels   []string     `json:"labels,omitempty"`
+}
+
+// Validate trims the request's fields and reports any that are invalid
+func (r *CreateTaskRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateText(&errs, "title", &r.Title, true, maxTitleLength)
+	validateText(&errs, "owner", &r.Owner, true, maxOwnerLength)
+	validatePriority(&errs, "priority", &r.Priority)
+	validateLabels(&errs, "labels", &r.Labels)
+	return errs
 }
 
 // API handles API requests
 type API struct {
-	store *TaskStore
+	store Store
+	// exports saves exports too large for a response; nil disables saving
+	exports ExportStore
+	// maxExportBytes is the largest export returned in a response; zero
+	// means maxInlineExportBytes
+	maxExportBytes int
+
+	routerOnce sync.Once
+	router     *router
 }
 
-// NewAPI creates a new API
-func NewAPI(tableName string) (*API, error) {
-	store, err := NewTaskStore(tableName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create task store: %w", err)
-	}
-
+// NewAPI creates a new API backed by the given store
+func NewAPI(store Store) *API {
 	return &API{
 		store: store,
-	}, nil
+	}
 }
 
-// HandleRequest handles API Gateway proxy requests
-func (api *API) HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Extract the path and method
-	path := request.Path
-	method := request.HTTPMethod
-
-	// Handle health check
-	if path == "/api/health-check/" && method == http.MethodGet {
+// routes builds the API's route table
+func (api *API) routes() *router {
+	r := &router{}
+	r.handle(http.MethodGet, "/api/health-check", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 		return api.healthCheck(ctx)
-	}
-
-	// Handle tasks
-	if strings.HasPrefix(path, "/api/tasks/") {
-		// Extract the task ID if present
-		parts := strings.Split(path, "/")
-		if len(parts) > 3 {
-			taskID := parts[3]
-			if taskID != "" {
-				return api.handleTaskByID(ctx, method, taskID, request)
-			}
-		}
+	})
+	r.handle(http.MethodGet, "/api/tasks", api.listTasks)
+	r.handle(http.MethodPost, "/api/tasks", api.createTask)
+	r.handle(http.MethodPost, "/api/tasks/batch", api.batchTasks)
+	r.handle(http.MethodGet, calendarFeedPath, api.calendarFeed)
+	r.handle(http.MethodPost, "/api/tasks/calendar/token", api.createFeedToken)
+	r.handle(http.MethodDelete, "/api/tasks/calendar/token", api.deleteFeedToken)
+	r.handle(http.MethodGet, "/api/tasks/export", api.exportTasks)
+	r.handle(http.MethodPost, "/api/tasks/import", api.importTasks)
+	r.handle(http.MethodGet, "/api/tasks/search", api.searchTasks)
+	r.handle(http.MethodGet, "/api/tasks/{id}", api.getTask)
+	r.handle(http.MethodGet, "/api/stats", api.getStats)
+	r.handle(http.MethodPost, "/api/transactions", api.executeTransaction)
+	r.handle(http.MethodGet, "/api/views", api.listViews)
+	r.handle(http.MethodPost, "/api/views", api.createView)
+	r.handle(http.MethodGet, "/api/views/{id}", api.getView)
+	r.handle(http.MethodDelete, "/api/views/{id}", api.deleteView)
+	r.handle(http.MethodGet, "/api/views/{id}/tasks", api.listViewTasks)
+	r.handle(http.MethodGet, "/api/webhooks", api.listWebhooks)
+	r.handle(http.MethodPost, "/api/webhooks", api.createWebhook)
+	r.handle(http.MethodDelete, "/api/webhooks/{id}", api.deleteWebhook)
+	r.handle(http.MethodGet, "/api/webhooks/{id}/deliveries", api.listDeliveries)
+	return r
+}
 
-		// Handle tasks collection
-		return api.handleTasks(ctx, method, request)
+// HandleRequest handles API Gateway proxy requests
+func (api *API) HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Assign a request ID if API Gateway did not, so that every response and
+	// log line for this request shares the same one
+	if request.RequestContext.RequestID == "" {
+		request.RequestContext.RequestID = uuid.New().String()
 	}
 
-	// Handle unknown paths
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusNotFound,
-		Body:       `{"message": "Not Found"}`,
-		Headers: map[string]string{
-			"Content-Type": "application/json",
-		},
-	}, nil
+	// Route the request
+	api.routerOnce.Do(func() {
+		api.router = api.routes()
+	})
+	return api.router.dispatch(ctx, request)
 }
 
 // healthCheck handles health check requests
@@ -86,87 +104,54 @@ func (api *API) healthCheck(ctx context.Context) (events.APIGatewayProxyResponse
 	}, nil
 }
 
-// handleTasks handles requests to the tasks collection
-func (api *API) handleTasks(ctx context.Context, method string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	switch method {
-	case http.MethodGet:
-		return api.listTasks(ctx, request)
-	case http.MethodPost:
-		return api.createTask(ctx, request)
-	default:
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusMethodNotAllowed,
-			Body:       `{"message": "Method Not Allowed"}`,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+// listTasks lists an owner's tasks with a status, or those matching a query
+func (api *API) listTasks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Get the owner, status and query from the query parameters
+	var errs ValidationErrors
+	owner := request.QueryStringParameters["owner"]
+	validateText(&errs, "owner", &owner, true, maxOwnerLength)
+
+	status := TaskStatus(request.QueryStringParameters["status"])
+	if status != "" {
+		validateStatus(&errs, "status", status)
 	}
-}
 
-// handleTaskByID handles requests to a specific task
-func (api *API) handleTaskByID(ctx context.Context, method, taskID string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	switch method {
-	case http.MethodGet:
-		return api.getTask(ctx, taskID, request)
-	default:
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusMethodNotAllowed,
-			Body:       `{"message": "Method Not Allowed"}`,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+	filter, query := queryField(&errs, "q", request.QueryStringParameters["q"])
+	if query && status != "" {
+		// The status parameter narrows the query like a status clause
+		if filter.Status != "" && filter.Status != status {
+			errs.add("status", FieldInvalidValue)
+		}
+		filter.Status = status
 	}
-}
 
-// listTasks lists tasks
-func (api *API) listTasks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Get the owner from the query parameters
-	owner := request.QueryStringParameters["owner"]
-	if owner == "" {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       `{"message": "Owner is required"}`,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
 	}
 
-	// Get the status from the query parameters
-	status := request.QueryStringParameters["status"]
 	var tasks []Task
 	var err error
 
-	// List tasks by status
-	if status == string(TaskStatusClosed) {
+	switch {
+	case query:
+		// List the tasks matching the query, with either status unless it says
+		tasks, err = api.store.QueryTasks(ctx, owner, filter)
+	case status == TaskStatusClosed:
+		// List tasks by status
 		tasks, err = api.store.ListClosed(ctx, owner)
-	} else {
+	default:
 		// Default to open tasks
 		tasks, err = api.store.ListOpen(ctx, owner)
 	}
 
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       fmt.Sprintf(`{"message": "Failed to list tasks: %s"}`, err.Error()),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+		return errorResponse(request, err, "Task", "Failed to list tasks")
 	}
 
 	// Marshal the tasks to JSON
 	body, err := json.Marshal(tasks)
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       fmt.Sprintf(`{"message": "Failed to marshal tasks: %s"}`, err.Error()),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+		return errorResponse(request, err, "Task", "Failed to marshal tasks")
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -179,53 +164,28 @@ func (api *API) listTasks(ctx context.Context, request events.APIGatewayProxyReq
 }
 
 // getTask gets a task by ID
-func (api *API) getTask(ctx context.Context, taskIDStr string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Parse the task ID
-	taskID, err := uuid.Parse(taskIDStr)
-	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       fmt.Sprintf(`{"message": "Invalid task ID: %s"}`, err.Error()),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
-	}
-
-	// Get the owner from the query parameters
+func (api *API) getTask(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Parse the task ID and get the owner from the query parameters
+	var errs ValidationErrors
+	taskIDStr := request.PathParameters["id"]
+	taskID := validateTaskID(&errs, "id", &taskIDStr)
 	owner := request.QueryStringParameters["owner"]
-	if owner == "" {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       `{"message": "Owner is required"}`,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+	validateText(&errs, "owner", &owner, true, maxOwnerLength)
+
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
 	}
 
 	// Get the task
 	task, err := api.store.GetByID(ctx, taskID, owner)
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusNotFound,
-			Body:       fmt.Sprintf(`{"message": "Task not found: %s"}`, err.Error()),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+		return errorResponse(request, err, "Task", "Failed to get task")
 	}
 
 	// Marshal the task to JSON
 	body, err := json.Marshal(task)
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       fmt.Sprintf(`{"message": "Failed to marshal task: %s"}`, err.Error()),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+		return errorResponse(request, err, "Task", "Failed to marshal task")
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -241,61 +201,24 @@ func (api *API) getTask(ctx context.Context, taskIDStr string, request events.AP
 func (api *API) createTask(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	// Parse the request body
 	var createRequest CreateTaskRequest
-	if err := json.Unmarshal([]byte(request.Body), &createRequest); err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       fmt.Sprintf(`{"message": "Invalid request body: %s"}`, err.Error()),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
-	}
-
-	// Validate the request
-	if createRequest.Title == "" {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       `{"message": "Title is required"}`,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
-	}
-
-	if createRequest.Owner == "" {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       `{"message": "Owner is required"}`,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+	if err := decodeRequest(request, &createRequest); err != nil {
+		return invalidRequestResponse(request, err)
 	}
 
 	// Create the task
 	task := NewTask(uuid.New(), createRequest.Title, createRequest.Owner)
+	task.Priority = createRequest.Priority
+	task.Labels = createRequest.Labels
 
 	// Add the task to the store
 	if err := api.store.Add(ctx, task); err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       fmt.Sprintf(`{"message": "Failed to create task: %s"}`, err.Error()),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+		return errorResponse(request, err, "Task", "Failed to create task")
 	}
 
 	// Marshal the task to JSON
 	body, err := json.Marshal(task)
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       fmt.Sprintf(`{"message": "Failed to marshal task: %s"}`, err.Error()),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+		return errorResponse(request, err, "Task", "Failed to marshal task")
 	}
 
 	return events.APIGatewayProxyResponse{
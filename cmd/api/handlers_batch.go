@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// maxBatchOperations is the maximum number of operations in a batch request
+const maxBatchOperations = 100
+
+// BatchOperation represents a single operation in a batch request
+type BatchOperation struct {
+	Op    OperationType `json:"op"`
+	ID    string        `json:"id,omitempty"`
+	Title string        `json:"title,omitempty"`
+	Owner string        `json:"owner"`
+}
+
+// Validate trims the operation's fields and reports any that are invalid
+func (op *BatchOperation) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateText(&errs, "owner", &op.Owner, true, maxOwnerLength)
+
+	switch op.Op {
+	case OperationCreate:
+		validateText(&errs, "title", &op.Title, true, maxTitleLength)
+		if op.ID != "" {
+			errs.add("id", FieldNotAllowed)
+		}
+	case OperationUpdate:
+		validateTaskID(&errs, "id", &op.ID)
+		validateText(&errs, "title", &op.Title, true, maxTitleLength)
+	case OperationClose, OperationDelete:
+		validateTaskID(&errs, "id", &op.ID)
+		if op.Title != "" {
+			errs.add("title", FieldNotAllowed)
+		}
+	case "":
+		errs.add("op", FieldRequired)
+	default:
+		errs.add("op", FieldInvalidValue)
+	}
+
+	return errs
+}
+
+// BatchRequest represents a request to run several operations at once
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// Validate checks the number of operations. Each operation is validated
+// separately so that one invalid operation does not fail the whole batch.
+func (r *BatchRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateCount(&errs, "operations", len(r.Operations), maxBatchOperations)
+	return errs
+}
+
+// BatchResult represents the outcome of a single operation in a batch
+type BatchResult struct {
+	Index   int          `json:"index"`
+	Status  int          `json:"status"`
+	Task    *Task        `json:"task,omitempty"`
+	Code    ErrorCode    `json:"code,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// BatchResponse represents the response to a batch request
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// batchTasks runs a batch of create, update, close and delete operations
+func (api *API) batchTasks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Parse the request body
+	var batchRequest BatchRequest
+	if err := decodeRequest(request, &batchRequest); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	// Validate each operation and build its write. Updates, closes and
+	// deletes are applied by the store to the tasks as stored, so that they
+	// only change the fields they set.
+	results := make([]BatchResult, len(batchRequest.Operations))
+	seen := make(map[TaskKey]bool)
+	var writes []TaskWrite
+	var writeIndexes []int
+
+	for i := range batchRequest.Operations {
+		results[i] = BatchResult{Index: i}
+
+		if errs := batchRequest.Operations[i].Validate(); len(errs) > 0 {
+			results[i].Status = http.StatusBadRequest
+			results[i].Code = CodeValidationFailed
+			results[i].Message = "The operation has invalid fields"
+			results[i].Errors = errs
+			continue
+		}
+
+		op := batchRequest.Operations[i]
+		if op.Op == OperationCreate {
+			writes = append(writes, TaskWrite{Task: NewTask(uuid.New(), op.Title, op.Owner)})
+			writeIndexes = append(writeIndexes, i)
+			continue
+		}
+
+		// The ID has already been validated
+		taskID := uuid.MustParse(op.ID)
+
+		// A transaction cannot write the same item twice
+		key := TaskKey{Owner: op.Owner, ID: taskID}
+		if seen[key] {
+			results[i].Status = http.StatusConflict
+			results[i].Code = CodeConflict
+			results[i].Message = "Task is already modified by another operation in this batch"
+			continue
+		}
+		seen[key] = true
+
+		task := NewTask(taskID, op.Title, op.Owner)
+		writes = append(writes, TaskWrite{Op: op.Op, Task: task})
+		writeIndexes = append(writeIndexes, i)
+	}
+
+	// Write the tasks and record the outcome against their operations
+	for j, err := range api.store.BatchWrite(ctx, writes) {
+		i := writeIndexes[j]
+		if err != nil {
+			status, code, message := errorStatus(err, "Task")
+			if message == "" {
+				message = "Failed to write task"
+			}
+			log.Printf("request %s: failed to write task for operation %d: %v", requestID(request), i, err)
+
+			results[i].Status = status
+			results[i].Code = code
+			results[i].Message = message
+			continue
+		}
+
+		switch batchRequest.Operations[i].Op {
+		case OperationCreate:
+			results[i].Status = http.StatusCreated
+			results[i].Task = &writes[j].Task
+		case OperationDelete:
+			results[i].Status = http.StatusNoContent
+		default:
+			results[i].Status = http.StatusOK
+			results[i].Task = &writes[j].Task
+		}
+	}
+
+	// Marshal the results to JSON
+	body, err := json.Marshal(BatchResponse{Results: results})
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to marshal results")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+func TestHandleRequestBatch(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	api := &API{store: store}
+	ctx := context.Background()
+	owner := "test@example.com"
+	toUpdate := NewTask(uuid.New(), "Old Title", owner)
+	toUpdate.Description = "Notes"
+	toUpdate.Labels = []string{"home"}
+	toClose := NewTask(uuid.New(), "Close Me", owner)
+	toDelete := NewTask(uuid.New(), "Delete Me", owner)
+	_ = store.Add(ctx, toUpdate)
+	_ = store.Add(ctx, toClose)
+	_ = store.Add(ctx, toDelete)
+
+	body, _ := json.Marshal(BatchRequest{
+		Operations: []BatchOperation{
+			{Op: OperationCreate, Title: "New Task", Owner: owner},
+			{Op: OperationUpdate, ID: toUpdate.ID.String(), Title: "New Title", Owner: owner},
+			{Op: OperationClose, ID: toClose.ID.String(), Owner: owner},
+			{Op: OperationDelete, ID: toDelete.ID.String(), Owner: owner},
+			{Op: OperationClose, ID: uuid.New().String(), Owner: owner},
+			{Op: OperationCreate, Owner: owner},
+		},
+	})
+	request := events.APIGatewayProxyRequest{
+		Path:       "/api/tasks/batch",
+		HTTPMethod: http.MethodPost,
+		Body:       string(body),
+	}
+
+	// Act
+	response, err := api.HandleRequest(ctx, request)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, response.StatusCode)
+	}
+
+	var batchResponse BatchResponse
+	if err := json.Unmarshal([]byte(response.Body), &batchResponse); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+
+	expected := []int{
+		http.StatusCreated,
+		http.StatusOK,
+		http.StatusOK,
+		http.StatusNoContent,
+		http.StatusNotFound,
+		http.StatusBadRequest,
+	}
+	if len(batchResponse.Results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(batchResponse.Results))
+	}
+	for i, status := range expected {
+		if batchResponse.Results[i].Index != i {
+			t.Errorf("Expected result %d to have index %d, got %d", i, i, batchResponse.Results[i].Index)
+		}
+		if batchResponse.Results[i].Status != status {
+			t.Errorf("Expected result %d to have status %d, got %d", i, status, batchResponse.Results[i].Status)
+		}
+	}
+
+	// Check the store was updated
+	if task, _ := store.GetByID(ctx, toUpdate.ID, owner); task.Title != "New Title" || task.Description != "Notes" || len(task.Labels) != 1 {
+		t.Errorf("Expected only the title to change to 'New Title', got %+v", task)
+	}
+	if task := batchResponse.Results[1].Task; task == nil || task.Title != "New Title" || task.Description != "Notes" {
+		t.Errorf("Expected the updated task in the result, got %+v", task)
+	}
+	if task, _ := store.GetByID(ctx, toClose.ID, owner); task.Status != TaskStatusClosed {
+		t.Errorf("Expected task status to be %s, got %s", TaskStatusClosed, task.Status)
+	}
+	if _, err := store.GetByID(ctx, toDelete.ID, owner); err == nil {
+		t.Error("Expected deleted task to be gone")
+	}
+	created := batchResponse.Results[0].Task
+	if created == nil {
+		t.Fatal("Expected created task in result")
+	}
+	if _, err := store.GetByID(ctx, created.ID, owner); err != nil {
+		t.Errorf("Expected created task to be stored, got %v", err)
+	}
+}
+
+func TestHandleRequestBatchDuplicateTask(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	api := &API{store: store}
+	ctx := context.Background()
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	_ = store.Add(ctx, task)
+
+	body, _ := json.Marshal(BatchRequest{
+		Operations: []BatchOperation{
+			{Op: OperationUpdate, ID: task.ID.String(), Title: "New Title", Owner: task.Owner},
+			{Op: OperationClose, ID: task.ID.String(), Owner: task.Owner},
+		},
+	})
+	request := events.APIGatewayProxyRequest{
+		Path:       "/api/tasks/batch",
+		HTTPMethod: http.MethodPost,
+		Body:       string(body),
+	}
+
+	// Act
+	response, err := api.HandleRequest(ctx, request)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var batchResponse BatchResponse
+	if err := json.Unmarshal([]byte(response.Body), &batchResponse); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if batchResponse.Results[0].Status != http.StatusOK {
+		t.Errorf("Expected first result to have status %d, got %d", http.StatusOK, batchResponse.Results[0].Status)
+	}
+	if batchResponse.Results[1].Status != http.StatusConflict {
+		t.Errorf("Expected second result to have status %d, got %d", http.StatusConflict, batchResponse.Results[1].Status)
+	}
+}
+
+func TestHandleRequestBatchTooManyOperations(t *testing.T) {
+	// Arrange
+	api := &API{store: NewMockTaskStore()}
+	ctx := context.Background()
+	operations := make([]BatchOperation, maxBatchOperations+1)
+	for i := range operations {
+		operations[i] = BatchOperation{Op: OperationCreate, Title: "Task", Owner: "test@example.com"}
+	}
+	body, _ := json.Marshal(BatchRequest{Operations: operations})
+	request := events.APIGatewayProxyRequest{
+		Path:       "/api/tasks/batch",
+		HTTPMethod: http.MethodPost,
+		Body:       string(body),
+	}
+
+	// Act
+	response, err := api.HandleRequest(ctx, request)
+
+	// Assert
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, response.StatusCode)
+	}
+}
@@ -3,10 +3,15 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
 )
 
 func TestHealthCheck(t *testing.T) {
@@ -88,15 +93,234 @@ func TestHandleRequestNotFound(t *testing.T) {
 	if response.StatusCode != http.StatusNotFound {
 		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, response.StatusCode)
 	}
+	if contentType := response.Headers["Content-Type"]; contentType != "application/problem+json" {
+		t.Errorf("Expected content type to be application/problem+json, got %s", contentType)
+	}
 
 	// Parse the response body
-	var body map[string]string
-	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+	var problem Problem
+	if err := json.Unmarshal([]byte(response.Body), &problem); err != nil {
 		t.Errorf("Failed to parse response body: %v", err)
 	}
 
-	// Check the message
-	if message, ok := body["message"]; !ok || message != "Not Found" {
-		t.Errorf("Expected message to be 'Not Found', got '%s'", message)
+	// Check the problem
+	if problem.Code != CodeNotFound {
+		t.Errorf("Expected code to be %s, got %s", CodeNotFound, problem.Code)
+	}
+	if problem.Status != http.StatusNotFound {
+		t.Errorf("Expected status to be %d, got %d", http.StatusNotFound, problem.Status)
+	}
+	if problem.RequestID == "" {
+		t.Error("Expected request ID to be set")
+	}
+}
+
+// errorStore is a store whose GetByID always fails with the given error
+type errorStore struct {
+	*MockTaskStore
+	err error
+}
+
+// GetByID returns the store's error
+func (s *errorStore) GetByID(ctx context.Context, taskID uuid.UUID, owner string) (Task, error) {
+	return Task{}, s.err
+}
+
+func TestHandleRequestGetTaskErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   ErrorCode
+	}{
+		{"not found", ErrNotFound, http.StatusNotFound, CodeNotFound},
+		{"throttled", fmt.Errorf("failed to get task from DynamoDB: %w", ErrThrottled), http.StatusServiceUnavailable, CodeThrottled},
+		{"internal", errors.New("operation error DynamoDB: GetItem, secret details"), http.StatusInternalServerError, CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			api := &API{store: &errorStore{MockTaskStore: NewMockTaskStore(), err: tt.err}}
+			ctx := context.Background()
+			request := events.APIGatewayProxyRequest{
+				Path:                  "/api/tasks/" + uuid.New().String(),
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: map[string]string{"owner": "test@example.com"},
+				RequestContext:        events.APIGatewayProxyRequestContext{RequestID: "request-1"},
+			}
+
+			// Act
+			response, err := api.HandleRequest(ctx, request)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if response.StatusCode != tt.status {
+				t.Errorf("Expected status code %d, got %d", tt.status, response.StatusCode)
+			}
+
+			var problem Problem
+			if err := json.Unmarshal([]byte(response.Body), &problem); err != nil {
+				t.Fatalf("Failed to parse response body: %v", err)
+			}
+			if problem.Code != tt.code {
+				t.Errorf("Expected code to be %s, got %s", tt.code, problem.Code)
+			}
+			if problem.RequestID != "request-1" {
+				t.Errorf("Expected request ID to be request-1, got %s", problem.RequestID)
+			}
+			if strings.Contains(response.Body, "DynamoDB") {
+				t.Errorf("Expected store error details not to leak, got %s", response.Body)
+			}
+		})
+	}
+}
+
+func TestHandleRequestCreateTaskUnknownField(t *testing.T) {
+	// Arrange
+	api := &API{store: NewMockTaskStore()}
+	ctx := context.Background()
+	request := events.APIGatewayProxyRequest{
+		Path:       "/api/tasks/",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"title": "", "owner": "bad\nowner", "tags": []}`,
+	}
+
+	// Act
+	response, err := api.HandleRequest(ctx, request)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, response.StatusCode)
+	}
+
+	var problem Problem
+	if err := json.Unmarshal([]byte(response.Body), &problem); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if problem.Code != CodeValidationFailed {
+		t.Errorf("Expected code to be %s, got %s", CodeValidationFailed, problem.Code)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "tags" || problem.Errors[0].Code != FieldUnknown {
+		t.Errorf("Expected unknown field error for tags, got %v", problem.Errors)
+	}
+}
+
+func TestHandleRequestCreateTaskWithPriorityAndLabels(t *testing.T) {
+	// Arrange
+	api := &API{store: NewMockTaskStore()}
+	ctx := context.Background()
+	request := events.APIGatewayProxyRequest{
+		Path:       "/api/tasks/",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"title": "Fix login", "owner": "test@example.com", "priority": "high", "labels": ["UI", "bug"]}`,
+	}
+
+	// Act
+	response, err := api.HandleRequest(ctx, request)
+
+	// Assert
+	if err != nil || response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d (%v)", http.StatusCreated, response.StatusCode, err)
+	}
+	var task Task
+	if err := json.Unmarshal([]byte(response.Body), &task); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if task.Priority != TaskPriorityHigh {
+		t.Errorf("Expected priority %s, got %s", TaskPriorityHigh, task.Priority)
+	}
+	if !reflect.DeepEqual(task.Labels, []string{"bug", "ui"}) {
+		t.Errorf("Expected labels [bug ui], got %q", task.Labels)
+	}
+	stored, _ := api.store.GetByID(ctx, task.ID, task.Owner)
+	if !reflect.DeepEqual(stored, task) {
+		t.Errorf("Expected %+v to be stored, got %+v", task, stored)
+	}
+}
+
+func TestHandleRequestCreateTaskReportsAllErrors(t *testing.T) {
+	// Arrange
+	api := &API{store: NewMockTaskStore()}
+	ctx := context.Background()
+	request := events.APIGatewayProxyRequest{
+		Path:       "/api/tasks/",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"title": "  ", "owner": "bad\u0000owner"}`,
+	}
+
+	// Act
+	response, err := api.HandleRequest(ctx, request)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var problem Problem
+	if err := json.Unmarshal([]byte(response.Body), &problem); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if problem.Code != CodeValidationFailed {
+		t.Errorf("Expected code to be %s, got %s", CodeValidationFailed, problem.Code)
+	}
+	want := []FieldError{
+		{Field: "title", Code: FieldRequired},
+		{Field: "owner", Code: FieldInvalidCharacters},
+	}
+	if !reflect.DeepEqual(problem.Errors, want) {
+		t.Errorf("Expected errors %v, got %v", want, problem.Errors)
+	}
+}
+
+func TestHandleRequestRoutes(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	api := &API{store: store}
+	ctx := context.Background()
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	_ = store.Add(ctx, task)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{"health check without slash", http.MethodGet, "/api/health-check", http.StatusOK},
+		{"list tasks without slash", http.MethodGet, "/api/tasks", http.StatusOK},
+		{"get task", http.MethodGet, "/api/tasks/" + task.ID.String(), http.StatusOK},
+		{"get task with trailing slash", http.MethodGet, "/api/tasks/" + task.ID.String() + "/", http.StatusOK},
+		{"get task with extra segments", http.MethodGet, "/api/tasks/" + task.ID.String() + "/anything", http.StatusNotFound},
+		{"unsupported method", http.MethodPut, "/api/tasks", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{
+				Path:                  tt.path,
+				HTTPMethod:            tt.method,
+				QueryStringParameters: map[string]string{"owner": task.Owner},
+			}
+
+			// Act
+			response, err := api.HandleRequest(ctx, request)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if response.StatusCode != tt.status {
+				t.Errorf("Expected status code %d, got %d", tt.status, response.StatusCode)
+			}
+			if tt.status == http.StatusMethodNotAllowed && response.Headers["Allow"] == "" {
+				t.Error("Expected Allow header on 405 response")
+			}
+		})
 	}
 }
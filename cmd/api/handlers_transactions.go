@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// TransactionOperation represents a single mutation in a transaction request
+type TransactionOperation struct {
+	BatchOperation
+	Condition *TaskCondition `json:"condition,omitempty"`
+}
+
+// Validate trims the operation's fields and reports any that are invalid
+func (op *TransactionOperation) Validate() ValidationErrors {
+	errs := op.BatchOperation.Validate()
+
+	if op.Condition != nil {
+		switch {
+		case op.Op == OperationCreate:
+			errs.add("condition", FieldNotAllowed)
+		case op.Condition.Status == "":
+			errs.add("condition.status", FieldRequired)
+		default:
+			validateStatus(&errs, "condition.status", op.Condition.Status)
+		}
+	}
+
+	return errs
+}
+
+// TransactionRequest represents a request to apply several mutations atomically
+type TransactionRequest struct {
+	Operations []TransactionOperation `json:"operations"`
+}
+
+// Validate reports every invalid field across all operations, since a
+// transaction is only executed if every operation is valid
+func (r *TransactionRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateCount(&errs, "operations", len(r.Operations), transactionLimit)
+
+	// DynamoDB rejects transactions that touch the same item twice
+	seen := make(map[TaskKey]bool)
+	counted := make(map[string]bool)
+	owners := make(map[string]bool)
+	for i := range r.Operations {
+		op := &r.Operations[i]
+		opErrs := op.Validate()
+		errs.addPrefixed(indexField("operations", i), opErrs)
+
+		if len(opErrs) == 0 {
+			owners[op.Owner] = true
+		}
+		if len(opErrs) == 0 && op.Op != OperationUpdate {
+			counted[op.Owner] = true
+		}
+
+		if len(opErrs) == 0 && op.Op != OperationCreate {
+			key := TaskKey{Owner: op.Owner, ID: uuid.MustParse(op.ID)}
+			if seen[key] {
+				errs.add(indexField("operations", i)+".id", FieldDuplicate)
+			}
+			seen[key] = true
+		}
+	}
+
+	// The transaction also updates the stats of each owner whose tasks it
+	// creates, closes or deletes, and appends an entry to the outbox of each
+	// owner whose tasks it changes, which takes two items
+	if len(r.Operations) <= transactionLimit && len(r.Operations)+len(counted)+2*len(owners) > transactionLimit {
+		errs.add("operations", FieldTooMany)
+	}
+
+	return errs
+}
+
+// TransactionResult represents a mutation applied by a transaction
+type TransactionResult struct {
+	Index int           `json:"index"`
+	ID    uuid.UUID     `json:"id"`
+	Op    OperationType `json:"op"`
+}
+
+// TransactionResponse represents the response to a successful transaction
+type TransactionResponse struct {
+	Results []TransactionResult `json:"results"`
+}
+
+// executeTransaction applies a list of task mutations so that they all
+// succeed or all fail
+func (api *API) executeTransaction(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Parse and validate the request body
+	var txRequest TransactionRequest
+	if err := decodeRequest(request, &txRequest); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	// Build the mutations
+	mutations := make([]TaskMutation, len(txRequest.Operations))
+	results := make([]TransactionResult, len(txRequest.Operations))
+
+	for i, op := range txRequest.Operations {
+		taskID := uuid.New()
+		if op.Op != OperationCreate {
+			// The ID has already been validated
+			taskID = uuid.MustParse(op.ID)
+		}
+
+		task := NewTask(taskID, op.Title, op.Owner)
+		mutations[i] = TaskMutation{Op: op.Op, Task: task, Condition: op.Condition}
+		results[i] = TransactionResult{Index: i, ID: taskID, Op: op.Op}
+	}
+
+	// Execute the transaction
+	if err := api.store.Transact(ctx, mutations); err != nil {
+		var txErr *TransactionError
+		if errors.As(err, &txErr) {
+			return transactionErrorResponse(request, txErr)
+		}
+
+		return errorResponse(request, err, "Task", "Failed to execute transaction")
+	}
+
+	// Marshal the results to JSON
+	body, err := json.Marshal(TransactionResponse{Results: results})
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to marshal results")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// transactionErrorStatus maps a cancellation reason to an HTTP status code and error code
+func transactionErrorStatus(reason string) (int, ErrorCode) {
+	switch reason {
+	case TransactionReasonNotFound:
+		return http.StatusNotFound, CodeNotFound
+	case TransactionReasonAlreadyExists, TransactionReasonConflict:
+		return http.StatusConflict, CodeConflict
+	case TransactionReasonConditionFailed:
+		return http.StatusPreconditionFailed, CodePreconditionFailed
+	case "ThrottlingError", "ProvisionedThroughputExceeded", "RequestLimitExceeded":
+		return http.StatusServiceUnavailable, CodeThrottled
+	case "ValidationError":
+		return http.StatusBadRequest, CodeValidationFailed
+	default:
+		return http.StatusInternalServerError, CodeInternal
+	}
+}
+
+// transactionErrorResponse builds a problem details response pointing at the
+// operation that caused a transaction to fail
+func transactionErrorResponse(request events.APIGatewayProxyRequest, txErr *TransactionError) (events.APIGatewayProxyResponse, error) {
+	status, code := transactionErrorStatus(txErr.Reason)
+
+	problem := newProblem(request, status, code, fmt.Sprintf("Operation %d failed: %s", txErr.Index, txErr.Reason))
+	problem.Index = &txErr.Index
+	problem.Reason = txErr.Reason
+
+	return newProblemResponse(problem)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+func TestHandleRequestTransaction(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	api := &API{store: store}
+	ctx := context.Background()
+	owner := "test@example.com"
+	task := NewTask(uuid.New(), "Task A", owner)
+	_ = store.Add(ctx, task)
+
+	body, _ := json.Marshal(TransactionRequest{
+		Operations: []TransactionOperation{
+			{
+				BatchOperation: BatchOperation{Op: OperationClose, ID: task.ID.String(), Owner: owner},
+				Condition:      &TaskCondition{Status: TaskStatusOpen},
+			},
+			{BatchOperation: BatchOperation{Op: OperationCreate, Title: "Task B", Owner: owner}},
+		},
+	})
+	request := events.APIGatewayProxyRequest{
+		Path:       "/api/transactions",
+		HTTPMethod: http.MethodPost,
+		Body:       string(body),
+	}
+
+	// Act
+	response, err := api.HandleRequest(ctx, request)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, response.StatusCode, response.Body)
+	}
+
+	var txResponse TransactionResponse
+	if err := json.Unmarshal([]byte(response.Body), &txResponse); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if len(txResponse.Results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(txResponse.Results))
+	}
+	if closed, _ := store.GetByID(ctx, task.ID, owner); closed.Status != TaskStatusClosed {
+		t.Errorf("Expected task status to be %s, got %s", TaskStatusClosed, closed.Status)
+	}
+	if _, err := store.GetByID(ctx, txResponse.Results[1].ID, owner); err != nil {
+		t.Errorf("Expected created task to be stored, got %v", err)
+	}
+}
+
+func TestHandleRequestTransactionConditionFailed(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	api := &API{store: store}
+	ctx := context.Background()
+	owner := "test@example.com"
+	task := Task{ID: uuid.New(), Title: "Task A", Status: TaskStatusClosed, Owner: owner}
+	_ = store.Add(ctx, task)
+
+	body, _ := json.Marshal(TransactionRequest{
+		Operations: []TransactionOperation{
+			{BatchOperation: BatchOperation{Op: OperationCreate, Title: "Task B", Owner: owner}},
+			{
+				BatchOperation: BatchOperation{Op: OperationUpdate, ID: task.ID.String(), Title: "Renamed", Owner: owner},
+				Condition:      &TaskCondition{Status: TaskStatusOpen},
+			},
+		},
+	})
+	request := events.APIGatewayProxyRequest{
+		Path:       "/api/transactions",
+		HTTPMethod: http.MethodPost,
+		Body:       string(body),
+	}
+
+	// Act
+	response, err := api.HandleRequest(ctx, request)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected status code %d, got %d", http.StatusPreconditionFailed, response.StatusCode)
+	}
+
+	var problem Problem
+	if err := json.Unmarshal([]byte(response.Body), &problem); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if problem.Index == nil || *problem.Index != 1 {
+		t.Errorf("Expected failing index to be 1, got %v", problem.Index)
+	}
+	if problem.Code != CodePreconditionFailed {
+		t.Errorf("Expected code to be %s, got %s", CodePreconditionFailed, problem.Code)
+	}
+
+	// Nothing should have been applied
+	if tasks, _ := store.ListOpen(ctx, owner); len(tasks) != 0 {
+		t.Errorf("Expected no open tasks, got %d", len(tasks))
+	}
+	if unchanged, _ := store.GetByID(ctx, task.ID, owner); unchanged.Title != task.Title {
+		t.Errorf("Expected task title to be %s, got %s", task.Title, unchanged.Title)
+	}
+}
+
+func TestHandleRequestTransactionNotFound(t *testing.T) {
+	// Arrange
+	api := &API{store: NewMockTaskStore()}
+	ctx := context.Background()
+	body, _ := json.Marshal(TransactionRequest{
+		Operations: []TransactionOperation{
+			{BatchOperation: BatchOperation{Op: OperationDelete, ID: uuid.New().String(), Owner: "test@example.com"}},
+		},
+	})
+	request := events.APIGatewayProxyRequest{
+		Path:       "/api/transactions/",
+		HTTPMethod: http.MethodPost,
+		Body:       string(body),
+	}
+
+	// Act
+	response, err := api.HandleRequest(ctx, request)
+
+	// Assert
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, response.StatusCode)
+	}
+}
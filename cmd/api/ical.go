@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	// Calendars name time zones by IANA name, and the Lambda runtime has no
+	// time zone database of its own
+	_ "time/tzdata"
+)
+
+// icalLineLimit is the longest content line in octets, excluding the line
+// break, that RFC 5545 allows before a line must be folded
+const icalLineLimit = 75
+
+// icalTimeFormat is the RFC 5545 DATE-TIME format in UTC
+const icalTimeFormat = "20060102T150405Z"
+
+// icalTextEscaper escapes the characters with a special meaning in TEXT values
+var icalTextEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// icalWriter writes iCalendar content lines, folding long lines and ending
+// every line with CRLF. The first write error is kept and later writes are
+// skipped, so callers only need to check Err once.
+type icalWriter struct {
+	w   io.Writer
+	err error
+}
+
+// Property writes a property whose value is already in its encoded form
+func (iw *icalWriter) Property(name, value string) {
+	if iw.err != nil {
+		return
+	}
+	_, iw.err = io.WriteString(iw.w, icalFold(name+":"+value))
+}
+
+// Text writes a property with a TEXT value, escaping it
+func (iw *icalWriter) Text(name, value string) {
+	iw.Property(name, icalEscapeText(value))
+}
+
+// Time writes a property with a DATE-TIME value in UTC
+func (iw *icalWriter) Time(name string, t time.Time) {
+	iw.Property(name, t.UTC().Format(icalTimeFormat))
+}
+
+// Err returns the first error that occurred while writing
+func (iw *icalWriter) Err() error {
+	return iw.err
+}
+
+// icalEscapeText escapes a TEXT value. Line breaks become \n and other
+// control characters, which TEXT values cannot contain, are dropped.
+func icalEscapeText(s string) string {
+	s = icalTextEscaper.Replace(s)
+	return strings.Map(func(r rune) rune {
+		if r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// icalFold folds a content line into lines of at most icalLineLimit octets
+// and terminates it with CRLF. Continuation lines start with a space, and
+// lines are only broken between characters so UTF-8 sequences stay whole.
+func icalFold(line string) string {
+	var b strings.Builder
+	limit := icalLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space counts towards the limit
+		limit = icalLineLimit - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// icalDateFormat is the RFC 5545 DATE format
+const icalDateFormat = "20060102"
+
+// icalLocalTimeFormat is the RFC 5545 DATE-TIME format without a UTC designator
+const icalLocalTimeFormat = "20060102T150405"
+
+// icalProperty is a parsed content line
+type icalProperty struct {
+	// Name is the property name in upper case
+	Name string
+	// Params maps upper-case parameter names to their values, unquoted
+	Params map[string]string
+	Value  string
+}
+
+// icalComponent is a component such as VCALENDAR or VTODO, with the
+// properties and components directly inside it
+type icalComponent struct {
+	Name       string
+	Properties []icalProperty
+	Components []icalComponent
+}
+
+// Property returns the first property with the given name
+func (c icalComponent) Property(name string) (icalProperty, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return icalProperty{}, false
+}
+
+// parseICal parses an iCalendar stream into its top-level components
+func parseICal(body string) ([]icalComponent, error) {
+	// The stack holds the components being read, under a root for the stream
+	stack := []icalComponent{{}}
+	for i, line := range icalUnfold(body) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		p, err := parseICalProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("content line %d: %w", i+1, err)
+		}
+
+		switch p.Name {
+		case "BEGIN":
+			stack = append(stack, icalComponent{Name: strings.ToUpper(p.Value)})
+		case "END":
+			top := stack[len(stack)-1]
+			if len(stack) == 1 || top.Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("content line %d: unexpected END:%s", i+1, p.Value)
+			}
+			stack = stack[:len(stack)-1]
+			parent := &stack[len(stack)-1]
+			parent.Components = append(parent.Components, top)
+		default:
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("content line %d: property %s is outside a component", i+1, p.Name)
+			}
+			top := &stack[len(stack)-1]
+			top.Properties = append(top.Properties, p)
+		}
+	}
+
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("component %s is not ended", stack[len(stack)-1].Name)
+	}
+	return stack[0].Components, nil
+}
+
+// icalUnfold splits an iCalendar stream into content lines, joining folded
+// lines. Bare LF line breaks are accepted as well as CRLF.
+func icalUnfold(body string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimPrefix(body, "\ufeff"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(lines) > 0 && line != "" && (line[0] == ' ' || line[0] == '\t') {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseICalProperty parses a content line of the form
+// NAME;PARAM=VALUE;...:VALUE. Parameter values may be quoted, in which case
+// they can contain ";", ":" and ",".
+func parseICalProperty(line string) (icalProperty, error) {
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return icalProperty{}, errors.New("content line has no name or value")
+	}
+	p := icalProperty{Name: strings.ToUpper(line[:end])}
+	rest := line[end:]
+
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		name, value, ok := strings.Cut(rest, "=")
+		if !ok || name == "" {
+			return icalProperty{}, fmt.Errorf("property %s has an invalid parameter", p.Name)
+		}
+
+		// Read the value up to the next unquoted ";" or ":"
+		var b strings.Builder
+		quoted := false
+		i := 0
+		for ; i < len(value); i++ {
+			c := value[i]
+			if c == '"' {
+				quoted = !quoted
+				continue
+			}
+			if !quoted && (c == ';' || c == ':') {
+				break
+			}
+			b.WriteByte(c)
+		}
+		if quoted {
+			return icalProperty{}, fmt.Errorf("property %s has an unterminated quoted parameter", p.Name)
+		}
+
+		if p.Params == nil {
+			p.Params = make(map[string]string)
+		}
+		p.Params[strings.ToUpper(name)] = b.String()
+		rest = value[i:]
+	}
+
+	value, ok := strings.CutPrefix(rest, ":")
+	if !ok {
+		return icalProperty{}, fmt.Errorf("property %s has no value", p.Name)
+	}
+	p.Value = value
+	return p, nil
+}
+
+// icalUnescapeText reverses icalEscapeText
+func icalUnescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			// \\, \; and \, stand for the character itself
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// parseICalDue converts a DATE or DATE-TIME property to a task due value: a
+// date, or a time in UTC. Times in a named time zone are converted to UTC,
+// and floating times, which have no zone, are taken to be in UTC.
+func parseICalDue(p icalProperty) (string, error) {
+	if p.Params["VALUE"] == "DATE" || len(p.Value) == len(icalDateFormat) {
+		date, err := time.Parse(icalDateFormat, p.Value)
+		if err != nil {
+			return "", err
+		}
+		return date.Format(dueDateFormat), nil
+	}
+
+	if strings.HasSuffix(p.Value, "Z") {
+		due, err := time.Parse(icalTimeFormat, p.Value)
+		if err != nil {
+			return "", err
+		}
+		return due.Format(time.RFC3339), nil
+	}
+
+	location := time.UTC
+	// A leading "/" marks a globally unique TZID, which IANA names are
+	if tzid := strings.TrimPrefix(p.Params["TZID"], "/"); tzid != "" {
+		var err error
+		if location, err = time.LoadLocation(tzid); err != nil {
+			return "", fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+	due, err := time.ParseInLocation(icalLocalTimeFormat, p.Value, location)
+	if err != nil {
+		return "", err
+	}
+	return due.UTC().Format(time.RFC3339), nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestICalEscapeText(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain", "Buy milk", "Buy milk"},
+		{"separators", "Milk, eggs; bread", `Milk\, eggs\; bread`},
+		{"backslash", `C:\tasks`, `C:\\tasks`},
+		{"line breaks", "one\ntwo\r\nthree", `one\ntwo\nthree`},
+		{"control characters", "a\x00b\tc", "ab\tc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := icalEscapeText(tt.value)
+
+			// Assert
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestICalFold(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Buy milk"},
+		{"exactly the limit", "SUMMARY:" + strings.Repeat("a", icalLineLimit-len("SUMMARY:"))},
+		{"long", "SUMMARY:" + strings.Repeat("a", 200)},
+		{"multibyte", "SUMMARY:" + strings.Repeat("日本語", 40)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			folded := icalFold(tt.line)
+
+			// Assert
+			if !strings.HasSuffix(folded, "\r\n") {
+				t.Fatalf("Expected the line to end with CRLF, got %q", folded)
+			}
+			lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+			for i, line := range lines {
+				if len(line) > icalLineLimit {
+					t.Errorf("Expected line %d to be at most %d octets, got %d", i, icalLineLimit, len(line))
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("Expected continuation line %d to start with a space, got %q", i, line)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("Expected line %d to be valid UTF-8, got %q", i, line)
+				}
+			}
+			if unfolded := strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", ""); unfolded != tt.line {
+				t.Errorf("Expected unfolding to restore %q, got %q", tt.line, unfolded)
+			}
+		})
+	}
+}
+
+func TestParseICal(t *testing.T) {
+	// Arrange
+	body := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:abc@example.com\r\n" +
+		"SUMMARY:A long summary that was\r\n" +
+		"  folded\r\n" +
+		"ATTENDEE;CN=\"Doe; John\";ROLE=CHAIR:mailto:john@example.com\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"ACTION:DISPLAY\r\n" +
+		"END:VALARM\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	// Act
+	components, err := parseICal(body)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(components) != 1 || components[0].Name != "VCALENDAR" || len(components[0].Components) != 1 {
+		t.Fatalf("Expected a calendar with one component, got %+v", components)
+	}
+	todo := components[0].Components[0]
+	if summary, _ := todo.Property("SUMMARY"); summary.Value != "A long summary that was folded" {
+		t.Errorf("Expected the folded summary to be joined, got %q", summary.Value)
+	}
+	attendee, _ := todo.Property("ATTENDEE")
+	if attendee.Params["CN"] != "Doe; John" || attendee.Params["ROLE"] != "CHAIR" || attendee.Value != "mailto:john@example.com" {
+		t.Errorf("Expected quoted parameters to be parsed, got %+v", attendee)
+	}
+	if len(todo.Components) != 1 || todo.Components[0].Name != "VALARM" {
+		t.Errorf("Expected the alarm to be nested in the task, got %+v", todo.Components)
+	}
+	if _, ok := todo.Property("ACTION"); ok {
+		t.Error("Expected the alarm's properties to stay in the alarm")
+	}
+}
+
+func TestParseICalInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no value", "BEGIN:VCALENDAR\nVERSION\nEND:VCALENDAR\n"},
+		{"not ended", "BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VCALENDAR\n"},
+		{"unexpected end", "BEGIN:VCALENDAR\nEND:VTODO\n"},
+		{"outside a component", "SUMMARY:Loose\n"},
+		{"unterminated quote", "BEGIN:VCALENDAR\nX-TEST;CN=\"Doe:value\nEND:VCALENDAR\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, err := parseICal(tt.body)
+
+			// Assert
+			if err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestICalUnescapeText(t *testing.T) {
+	for _, value := range []string{"plain", `Milk, eggs; C:\bread`, "one\ntwo", `trailing\`} {
+		// Act
+		got := icalUnescapeText(icalEscapeText(value))
+
+		// Assert
+		if got != value {
+			t.Errorf("Expected %q to round-trip, got %q", value, got)
+		}
+	}
+
+	if got := icalUnescapeText(`a\Nb`); got != "a\nb" {
+		t.Errorf("Expected \\N to be a line break, got %q", got)
+	}
+}
+
+func TestParseICalDue(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    string
+		wantErr bool
+	}{
+		{"date", "DUE;VALUE=DATE:20240501", "2024-05-01", false},
+		{"date without value type", "DUE:20240501", "2024-05-01", false},
+		{"UTC", "DUE:20240501T090000Z", "2024-05-01T09:00:00Z", false},
+		{"time zone", "DUE;TZID=Europe/Berlin:20240501T090000", "2024-05-01T07:00:00Z", false},
+		{"globally unique time zone", "DUE;TZID=/America/New_York:20240101T090000", "2024-01-01T14:00:00Z", false},
+		{"floating", "DUE:20240501T090000", "2024-05-01T09:00:00Z", false},
+		{"unknown time zone", "DUE;TZID=W. Europe Standard Time:20240501T090000", "", true},
+		{"invalid", "DUE:tomorrow", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			p, err := parseICalProperty(tt.line)
+			if err != nil {
+				t.Fatalf("Failed to parse property: %v", err)
+			}
+
+			// Act
+			got, err := parseICalDue(p)
+
+			// Assert
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
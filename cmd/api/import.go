@@ -0,0 +1,715 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// maxImportRows is the maximum number of tasks in an import request
+const maxImportRows = 1000
+
+// importParsers maps the format query parameter to the parser for it
+var importParsers = map[string]func(body string, columns map[string]string) ([]importRow, error){
+	"csv":     parseCSVImport,
+	"json":    parseJSONImport,
+	"todotxt": parseTodoTxtImport,
+	"ics":     parseICSImport,
+}
+
+// importContentTypes maps request content types to the format they imply
+// when the format query parameter is not set
+var importContentTypes = map[string]string{
+	"text/csv":         "csv",
+	"application/json": "json",
+	"text/plain":       "todotxt",
+	"text/calendar":    "ics",
+}
+
+// importColumns are the task fields a CSV column can be mapped to
+var importColumns = []string{"title", "status", "description", "due", "recurrence", "uid", "priority", "labels"}
+
+var (
+	// todoTxtFields are the task fields a todo.txt line carries
+	todoTxtFields = []string{"title", "status", "priority"}
+	// icsFields are the task fields a VTODO component carries
+	icsFields = []string{"title", "description", "due", "recurrence", "status"}
+)
+
+// importRow is a task read from an import, before validation
+type importRow struct {
+	title  string
+	status string
+	// description, due, recurrence and uid are read from CSV, JSON and
+	// iCalendar
+	description string
+	due         string
+	recurrence  string
+	uid         string
+	// taskPriority is a TaskPriority value, read from CSV and JSON
+	taskPriority string
+	labels       []string
+	// fields lists the task fields the row's format carries, which are the
+	// ones importing the row again overwrites on the task it matches
+	fields []string
+	// err is set if the row could not be read at all
+	err error
+	// errs lists fields that could be read but have invalid types
+	errs ValidationErrors
+
+	// todo.txt metadata; the priority letter is also stored as the task's
+	// priority, and projects and contexts stay in the title
+	priority string
+	projects []string
+	contexts []string
+}
+
+// ImportResult represents the outcome of importing a single row
+type ImportResult struct {
+	// Row is the 1-based position of the row in the import, not counting a
+	// CSV header or blank todo.txt lines
+	Row     int          `json:"row"`
+	Status  int          `json:"status"`
+	Task    *Task        `json:"task,omitempty"`
+	Code    ErrorCode    `json:"code,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+
+	Priority string   `json:"priority,omitempty"`
+	Projects []string `json:"projects,omitempty"`
+	Contexts []string `json:"contexts,omitempty"`
+}
+
+// ImportResponse represents the response to an import request
+type ImportResponse struct {
+	DryRun   bool           `json:"dryRun"`
+	Imported int            `json:"imported"`
+	Failed   int            `json:"failed"`
+	Results  []ImportResult `json:"results"`
+}
+
+// importTasks creates tasks from a CSV, JSON, todo.txt or iCalendar body.
+// Rows with a UID update the owner's task with that UID or ID instead, so a
+// calendar can be imported again. In a dry run the rows are only validated.
+func (api *API) importTasks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Get the owner and options from the query parameters
+	var errs ValidationErrors
+	owner := request.QueryStringParameters["owner"]
+	validateText(&errs, "owner", &owner, true, maxOwnerLength)
+
+	format := importFormat(request)
+	parse, ok := importParsers[format]
+	if !ok {
+		errs.add("format", FieldInvalidValue)
+	}
+
+	columns, err := parseColumnMapping(request.QueryStringParameters["columns"])
+	if err != nil {
+		errs.add("columns", FieldInvalidFormat)
+	}
+
+	dryRun := false
+	if value := request.QueryStringParameters["dryRun"]; value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			errs.add("dryRun", FieldInvalidValue)
+		}
+	}
+
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	// Read the rows
+	body, err := requestBody(request)
+	if err != nil {
+		return invalidRequestResponse(request, err)
+	}
+	rows, err := parse(body, columns)
+	if err != nil {
+		return invalidRequestResponse(request, err)
+	}
+	validateCount(&errs, "rows", len(rows), maxImportRows)
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	// Find the tasks that rows with a UID update
+	var existing map[string]Task
+	if slices.ContainsFunc(rows, func(row importRow) bool { return row.uid != "" }) {
+		if existing, err = api.tasksByUID(ctx, owner); err != nil {
+			return errorResponse(request, err, "Task", "Failed to list tasks")
+		}
+	}
+
+	// Validate each row and build the writes
+	response := ImportResponse{DryRun: dryRun, Results: make([]ImportResult, len(rows))}
+	var writes []TaskWrite
+	var writeIndexes []int
+	seenUIDs := make(map[string]bool)
+
+	for i, row := range rows {
+		result := &response.Results[i]
+		*result = ImportResult{Row: i + 1, Priority: row.priority, Projects: row.projects, Contexts: row.contexts}
+
+		task, rowErr := row.task(owner)
+		var rowErrs ValidationErrors
+		switch {
+		case errors.As(rowErr, &rowErrs):
+			result.Status = http.StatusBadRequest
+			result.Code = CodeValidationFailed
+			result.Message = "The row has invalid fields"
+			result.Errors = rowErrs
+			continue
+		case rowErr != nil:
+			result.Status = http.StatusBadRequest
+			result.Code = CodeInvalidBody
+			result.Message = rowErr.Error()
+			continue
+		}
+
+		status := http.StatusCreated
+		if task.UID != "" {
+			// Two rows for the same task would overwrite each other
+			if seenUIDs[task.UID] {
+				result.Status = http.StatusConflict
+				result.Code = CodeConflict
+				result.Message = "Task is already imported by another row"
+				continue
+			}
+			seenUIDs[task.UID] = true
+
+			if match, ok := existing[task.UID]; ok {
+				task = row.merge(match, task)
+				status = http.StatusOK
+			}
+		}
+
+		result.Task = &task
+		if dryRun {
+			result.Status = http.StatusOK
+			continue
+		}
+
+		result.Status = status
+		writes = append(writes, TaskWrite{Task: task})
+		writeIndexes = append(writeIndexes, i)
+	}
+
+	// Write the tasks and record any failures against their rows
+	if len(writes) > 0 {
+		for j, err := range api.store.BatchWrite(ctx, writes) {
+			if err != nil {
+				i := writeIndexes[j]
+				status, code, message := errorStatus(err, "Task")
+				if message == "" {
+					message = "Failed to write task"
+				}
+				log.Printf("request %s: failed to write task for import row %d: %v", requestID(request), i+1, err)
+
+				response.Results[i].Status = status
+				response.Results[i].Code = code
+				response.Results[i].Task = nil
+				response.Results[i].Message = message
+			}
+		}
+	}
+
+	for _, result := range response.Results {
+		if result.Status < http.StatusBadRequest {
+			response.Imported++
+		} else {
+			response.Failed++
+		}
+	}
+
+	// Marshal the results to JSON
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to marshal results")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// task validates a row and converts it to a new task for owner
+func (row importRow) task(owner string) (Task, error) {
+	if row.err != nil {
+		return Task{}, row.err
+	}
+	// A field with the wrong type leaves the others unread
+	if err := row.errs.err(); err != nil {
+		return Task{}, err
+	}
+
+	var errs ValidationErrors
+	title := row.title
+	validateText(&errs, "title", &title, true, maxTitleLength)
+	status, ok := parseImportStatus(row.status)
+	if !ok {
+		errs.add("status", FieldInvalidValue)
+	}
+	description := row.description
+	validateDescription(&errs, "description", &description, maxDescriptionLength)
+	due := row.due
+	validateDue(&errs, "due", &due)
+	recurrence := row.recurrence
+	validateRecurrence(&errs, "recurrence", &recurrence)
+	uid := row.uid
+	validateText(&errs, "uid", &uid, false, maxUIDLength)
+	priority := TaskPriority(row.taskPriority)
+	validatePriority(&errs, "priority", &priority)
+	if row.priority != "" {
+		priority = todoTxtPriority(row.priority)
+	}
+	labels := row.labels
+	validateLabels(&errs, "labels", &labels)
+	if err := errs.err(); err != nil {
+		return Task{}, err
+	}
+
+	task := NewTask(uuid.New(), title, owner)
+	task.Status = status
+	task.Description = description
+	task.Due = due
+	task.Recurrence = recurrence
+	task.UID = uid
+	task.Priority = priority
+	task.Labels = labels
+	return task, nil
+}
+
+// merge returns the existing task a row matched, with the fields the row's
+// format carries taken from task, the task the row was read as. Fields the
+// format has no place for, such as a calendar's labels, keep their existing
+// values.
+func (row importRow) merge(existing, task Task) Task {
+	merged := existing
+	merged.Labels = slices.Clone(existing.Labels)
+	for _, field := range row.fields {
+		switch field {
+		case "title":
+			merged.Title = task.Title
+		case "status":
+			merged.Status = task.Status
+		case "description":
+			merged.Description = task.Description
+		case "due":
+			merged.Due = task.Due
+		case "recurrence":
+			merged.Recurrence = task.Recurrence
+		case "priority":
+			merged.Priority = task.Priority
+		case "labels":
+			merged.Labels = task.Labels
+		}
+	}
+	return merged
+}
+
+// todoTxtPriority maps a todo.txt priority letter to a TaskPriority: A is
+// high, B is normal and every later letter is low. Tasks without a letter
+// have no priority.
+func todoTxtPriority(letter string) TaskPriority {
+	switch letter {
+	case "":
+		return ""
+	case "A":
+		return TaskPriorityHigh
+	case "B":
+		return TaskPriorityNormal
+	default:
+		return TaskPriorityLow
+	}
+}
+
+// tasksByUID returns an owner's tasks keyed by UID, and by ID so that tasks
+// exported by the calendar feed without a UID are matched too. A UID takes
+// precedence over an ID with the same value.
+func (api *API) tasksByUID(ctx context.Context, owner string) (map[string]Task, error) {
+	open, err := api.store.ListOpen(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	closed, err := api.store.ListClosed(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := append(open, closed...)
+	byUID := make(map[string]Task, len(tasks))
+	for _, task := range tasks {
+		byUID[task.ID.String()] = task
+	}
+	for _, task := range tasks {
+		if task.UID != "" {
+			byUID[task.UID] = task
+		}
+	}
+	return byUID, nil
+}
+
+// importFormat returns the format query parameter, or the format implied
+// by the Content-Type header if it is not set
+func importFormat(request events.APIGatewayProxyRequest) string {
+	if format := request.QueryStringParameters["format"]; format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(requestHeader(request, "Content-Type"))
+	return importContentTypes[mediaType]
+}
+
+// parseColumnMapping parses a CSV column mapping such as
+// "title:Summary,status:State" into a map from task field to column header
+func parseColumnMapping(value string) (map[string]string, error) {
+	columns := make(map[string]string)
+	if value == "" {
+		return columns, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		field, header, ok := strings.Cut(pair, ":")
+		field, header = strings.TrimSpace(field), strings.TrimSpace(header)
+		if !ok || header == "" || !slices.Contains(importColumns, field) || columns[field] != "" {
+			return nil, fmt.Errorf("invalid column mapping %q", pair)
+		}
+		columns[field] = header
+	}
+	return columns, nil
+}
+
+// parseImportStatus maps the status values used by other tools to a
+// TaskStatus; a missing status means the task is open
+func parseImportStatus(value string) (TaskStatus, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "open", "todo", "pending", "false", "no", "0":
+		return TaskStatusOpen, true
+	case "closed", "done", "complete", "completed", "true", "yes", "x", "1":
+		return TaskStatusClosed, true
+	}
+	return "", false
+}
+
+// parseCSVImport reads CSV with a header row. Columns are found by header,
+// case-insensitively: "title", "status", "description", "due",
+// "recurrence", "uid", "priority" and "labels" unless columns maps them to
+// other headers. Labels are separated by spaces or commas. Other columns
+// are ignored.
+func parseCSVImport(body string, columns map[string]string) ([]importRow, error) {
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(body, "\ufeff")))
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("request body is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	// Find the column of each field
+	indexes := make(map[string]int)
+	for _, field := range importColumns {
+		name := field
+		if mapped, ok := columns[field]; ok {
+			name = mapped
+		}
+		for i, column := range header {
+			if strings.EqualFold(strings.TrimSpace(column), name) {
+				indexes[field] = i
+				break
+			}
+		}
+		if _, ok := indexes[field]; !ok && (field == "title" || columns[field] != "") {
+			return nil, fmt.Errorf("CSV has no %q column", name)
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		// A row with the wrong number of fields is reported on its own
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+			rows = append(rows, importRow{err: fmt.Errorf("row has %d fields, expected %d", len(record), len(header))})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		row := importRow{title: uncsvText(record[indexes["title"]]), fields: []string{"title"}}
+		if i, ok := indexes["status"]; ok {
+			row.status = record[i]
+			row.fields = append(row.fields, "status")
+		}
+		if i, ok := indexes["description"]; ok {
+			row.description = uncsvText(record[i])
+			row.fields = append(row.fields, "description")
+		}
+		if i, ok := indexes["due"]; ok {
+			row.due = record[i]
+			row.fields = append(row.fields, "due")
+		}
+		if i, ok := indexes["recurrence"]; ok {
+			row.recurrence = uncsvText(record[i])
+			row.fields = append(row.fields, "recurrence")
+		}
+		if i, ok := indexes["uid"]; ok {
+			row.uid = uncsvText(record[i])
+		}
+		if i, ok := indexes["priority"]; ok {
+			row.taskPriority = record[i]
+			row.fields = append(row.fields, "priority")
+		}
+		if i, ok := indexes["labels"]; ok {
+			row.labels = strings.FieldsFunc(uncsvText(record[i]), func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			})
+			row.fields = append(row.fields, "labels")
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// uncsvText removes the quote csvText adds before formulas, so exports can
+// be imported again
+func uncsvText(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// parseJSONImport reads a JSON array of objects with "title" and "status"
+// fields, and optionally "uid", "description", "due", "recurrence",
+// "priority" and "labels". A task matched by its UID only has the optional
+// fields that are present replaced. Other fields are ignored, so exports
+// from other tools can be imported as they are.
+func parseJSONImport(body string, columns map[string]string) ([]importRow, error) {
+	if len(columns) > 0 {
+		return nil, errors.New("column mappings only apply to CSV")
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &elements); err != nil {
+		if strings.TrimSpace(body) == "" {
+			return nil, errors.New("request body is empty")
+		}
+		return nil, fmt.Errorf("body must be a JSON array: %w", err)
+	}
+
+	rows := make([]importRow, len(elements))
+	for i, element := range elements {
+		var task struct {
+			Title       string    `json:"title"`
+			Status      string    `json:"status"`
+			UID         string    `json:"uid"`
+			Description *string   `json:"description"`
+			Due         *string   `json:"due"`
+			Recurrence  *string   `json:"recurrence"`
+			Priority    *string   `json:"priority"`
+			Labels      *[]string `json:"labels"`
+		}
+		if err := json.Unmarshal(element, &task); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) && typeErr.Field != "" {
+				rows[i].errs.add(typeErr.Field, FieldInvalidType)
+			} else {
+				rows[i].err = errors.New("row must be a JSON object")
+			}
+			continue
+		}
+		rows[i].title, rows[i].status, rows[i].uid = task.Title, task.Status, task.UID
+		rows[i].fields = []string{"title", "status"}
+		if task.Description != nil {
+			rows[i].description = *task.Description
+			rows[i].fields = append(rows[i].fields, "description")
+		}
+		if task.Due != nil {
+			rows[i].due = *task.Due
+			rows[i].fields = append(rows[i].fields, "due")
+		}
+		if task.Recurrence != nil {
+			rows[i].recurrence = *task.Recurrence
+			rows[i].fields = append(rows[i].fields, "recurrence")
+		}
+		if task.Priority != nil {
+			rows[i].taskPriority = *task.Priority
+			rows[i].fields = append(rows[i].fields, "priority")
+		}
+		if task.Labels != nil {
+			rows[i].labels = *task.Labels
+			rows[i].fields = append(rows[i].fields, "labels")
+		}
+	}
+
+	return rows, nil
+}
+
+var (
+	// todoDate matches a todo.txt date at the start of the remaining text
+	todoDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `)
+	// todoPriority matches a todo.txt priority such as "(A) "
+	todoPriority = regexp.MustCompile(`^\(([A-Z])\) `)
+)
+
+// parseTodoTxtImport reads todo.txt lines: an optional "x " completion
+// mark and completion date, an optional priority, an optional creation date
+// and the description. The description, including its +project and
+// @context tags, becomes the title; blank lines are skipped.
+func parseTodoTxtImport(body string, columns map[string]string) ([]importRow, error) {
+	if len(columns) > 0 {
+		return nil, errors.New("column mappings only apply to CSV")
+	}
+
+	var rows []importRow
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		rows = append(rows, parseTodoTxtLine(line))
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("request body is empty")
+	}
+	return rows, nil
+}
+
+// parseTodoTxtLine reads a single todo.txt task
+func parseTodoTxtLine(line string) importRow {
+	row := importRow{status: string(TaskStatusOpen), fields: todoTxtFields}
+	rest := line + " "
+
+	if strings.HasPrefix(rest, "x ") {
+		row.status = string(TaskStatusClosed)
+		rest = strings.TrimPrefix(rest, "x ")
+		// Completed tasks have a completion date, then a creation date
+		if todoDate.MatchString(rest) {
+			rest = rest[len("2006-01-02 "):]
+		}
+	}
+	if match := todoPriority.FindStringSubmatch(rest); match != nil {
+		row.priority = match[1]
+		rest = rest[len(match[0]):]
+	}
+	if todoDate.MatchString(rest) {
+		rest = rest[len("2006-01-02 "):]
+	}
+
+	row.title = strings.TrimSpace(rest)
+	for _, word := range strings.Fields(row.title) {
+		switch {
+		case len(word) > 1 && word[0] == '+':
+			row.projects = append(row.projects, word[1:])
+		case len(word) > 1 && word[0] == '@':
+			row.contexts = append(row.contexts, word[1:])
+		case strings.HasPrefix(word, "pri:") && len(word) == len("pri:A") && row.priority == "":
+			// Completed tasks keep their priority as a pri: tag
+			row.priority = word[len("pri:"):]
+		}
+	}
+
+	return row
+}
+
+// parseICSImport reads the VTODO components of an iCalendar object. SUMMARY,
+// DESCRIPTION, DUE, STATUS, RRULE and UID are read; other properties and
+// components, such as events and alarms, are ignored.
+func parseICSImport(body string, columns map[string]string) ([]importRow, error) {
+	if len(columns) > 0 {
+		return nil, errors.New("column mappings only apply to CSV")
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, errors.New("request body is empty")
+	}
+
+	components, err := parseICal(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid iCalendar: %w", err)
+	}
+
+	var rows []importRow
+	for _, calendar := range components {
+		if calendar.Name != "VCALENDAR" {
+			continue
+		}
+		for _, component := range calendar.Components {
+			if component.Name == "VTODO" {
+				rows = append(rows, parseVTodo(component))
+			}
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("calendar has no VTODO components")
+	}
+	return rows, nil
+}
+
+// parseVTodo reads a task from a VTODO component. A DUE that cannot be
+// converted is kept as it is, so that validation reports it.
+func parseVTodo(component icalComponent) importRow {
+	row := importRow{fields: icsFields}
+	if p, ok := component.Property("SUMMARY"); ok {
+		row.title = icalUnescapeText(p.Value)
+	}
+	if p, ok := component.Property("DESCRIPTION"); ok {
+		row.description = icalUnescapeText(p.Value)
+	}
+	if p, ok := component.Property("UID"); ok {
+		row.uid = icalUnescapeText(p.Value)
+	}
+	if p, ok := component.Property("RRULE"); ok {
+		row.recurrence = p.Value
+	}
+	if p, ok := component.Property("DUE"); ok {
+		row.due = p.Value
+		if due, err := parseICalDue(p); err == nil {
+			row.due = due
+		}
+	}
+
+	// Clients that omit STATUS still record when a task was completed
+	p, ok := component.Property("STATUS")
+	switch status := strings.ToUpper(p.Value); {
+	case status == "COMPLETED" || status == "CANCELLED":
+		row.status = string(TaskStatusClosed)
+	case status == "NEEDS-ACTION" || status == "IN-PROCESS":
+		row.status = string(TaskStatusOpen)
+	case !ok:
+		row.status = string(TaskStatusOpen)
+		if _, completed := component.Property("COMPLETED"); completed {
+			row.status = string(TaskStatusClosed)
+		}
+	default:
+		row.status = p.Value
+	}
+
+	return row
+}
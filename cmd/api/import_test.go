@@ -0,0 +1,467 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// importRequest builds an import request with the given query parameters
+func importRequest(body string, params map[string]string) events.APIGatewayProxyRequest {
+	query := map[string]string{"owner": "test@example.com"}
+	for key, value := range params {
+		query[key] = value
+	}
+	return events.APIGatewayProxyRequest{
+		Path:                  "/api/tasks/import",
+		HTTPMethod:            http.MethodPost,
+		QueryStringParameters: query,
+		Body:                  body,
+	}
+}
+
+// runImport sends an import request and decodes the response
+func runImport(t *testing.T, api *API, request events.APIGatewayProxyRequest) ImportResponse {
+	t.Helper()
+	response, err := api.HandleRequest(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, response.StatusCode, response.Body)
+	}
+	var result ImportResponse
+	if err := json.Unmarshal([]byte(response.Body), &result); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	return result
+}
+
+func TestImportTasksCSV(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	api := NewAPI(store)
+	body := "Summary,State,Assignee\r\n\"Write report, draft\",done,bob\r\nReview PR,,bob\r\n,open,bob\r\nToo,many,fields,here\r\n"
+
+	// Act
+	result := runImport(t, api, importRequest(body, map[string]string{
+		"format":  "csv",
+		"columns": "title:Summary,status:State",
+	}))
+
+	// Assert
+	if result.Imported != 2 || result.Failed != 2 {
+		t.Errorf("Expected 2 imported and 2 failed, got %d and %d", result.Imported, result.Failed)
+	}
+	if task := result.Results[0].Task; task == nil || task.Title != "Write report, draft" || task.Status != TaskStatusClosed {
+		t.Errorf("Expected a closed task from row 1, got %+v", result.Results[0])
+	}
+	if errs := result.Results[2].Errors; len(errs) != 1 || errs[0] != (FieldError{Field: "title", Code: FieldRequired}) {
+		t.Errorf("Expected a missing title on row 3, got %+v", result.Results[2])
+	}
+	if result.Results[3].Code != CodeInvalidBody || result.Results[3].Row != 4 {
+		t.Errorf("Expected row 4 to be unreadable, got %+v", result.Results[3])
+	}
+	closed, _ := store.ListClosed(context.Background(), "test@example.com")
+	open, _ := store.ListOpen(context.Background(), "test@example.com")
+	if len(closed) != 1 || len(open) != 1 {
+		t.Errorf("Expected 1 closed and 1 open task to be stored, got %d and %d", len(closed), len(open))
+	}
+}
+
+func TestImportTasksRoundTripsExport(t *testing.T) {
+	// Arrange
+	api := newExportAPI(t, "=SUM(A1)", "Closed Task")
+	exported, _ := api.HandleRequest(context.Background(), exportRequest("csv", nil))
+	target := NewAPI(NewMockTaskStore())
+
+	// Act
+	result := runImport(t, target, importRequest(exported.Body, map[string]string{"format": "csv"}))
+
+	// Assert
+	var titles []string
+	for _, r := range result.Results {
+		titles = append(titles, r.Task.Title+"/"+string(r.Task.Status))
+	}
+	if strings.Join(titles, ",") != "=SUM(A1)/OPEN,Closed Task/CLOSED" {
+		t.Errorf("Expected the exported tasks, got %v", titles)
+	}
+}
+
+func TestImportTasksRoundTripsPriorityAndLabels(t *testing.T) {
+	// Arrange: a label starting with "-" is quoted in CSV like a formula
+	store := NewMockTaskStore()
+	task := NewTask(uuid.New(), "Labelled", "test@example.com")
+	task.Priority = TaskPriorityUrgent
+	task.Labels = []string{"-later", "home"}
+	if err := store.Add(context.Background(), task); err != nil {
+		t.Fatalf("Failed to add task: %v", err)
+	}
+	api := NewAPI(store)
+	tests := []struct {
+		export, format string
+		body           func(string) string
+	}{
+		{"csv", "csv", func(body string) string { return body }},
+		{"jsonl", "json", func(body string) string {
+			return "[" + strings.Join(strings.Split(strings.TrimSpace(body), "\n"), ",") + "]"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.export, func(t *testing.T) {
+			exported, _ := api.HandleRequest(context.Background(), exportRequest(tt.export, nil))
+
+			// Act
+			result := runImport(t, NewAPI(NewMockTaskStore()), importRequest(tt.body(exported.Body), map[string]string{"format": tt.format}))
+
+			// Assert
+			if len(result.Results) != 1 || result.Results[0].Task == nil {
+				t.Fatalf("Expected the task to be imported, got %+v", result.Results)
+			}
+			if imported := result.Results[0].Task; imported.Priority != TaskPriorityUrgent || !reflect.DeepEqual(imported.Labels, task.Labels) {
+				t.Errorf("Expected priority %s and labels %v, got %+v", TaskPriorityUrgent, task.Labels, imported)
+			}
+		})
+	}
+}
+
+func TestImportTasksExportUpsertsCalendarTasks(t *testing.T) {
+	tests := []struct {
+		export, format string
+		body           func(string) string
+	}{
+		{"csv", "csv", func(body string) string { return body }},
+		{"jsonl", "json", func(body string) string {
+			return "[" + strings.Join(strings.Split(strings.TrimSpace(body), "\n"), ",") + "]"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.export, func(t *testing.T) {
+			// Arrange: a task imported from a calendar, exported
+			store := NewMockTaskStore()
+			api := NewAPI(store)
+			calendar := "BEGIN:VCALENDAR\nBEGIN:VTODO\nUID:call@example.com\nSUMMARY:Call Mom\n" +
+				"DESCRIPTION:-Ask about\\nthe weekend\nDUE;VALUE=DATE:20240501\nRRULE:FREQ=WEEKLY;BYDAY=SU\nEND:VTODO\nEND:VCALENDAR\n"
+			imported := runImport(t, api, importRequest(calendar, map[string]string{"format": "ics"}))
+			want := *imported.Results[0].Task
+			exported, _ := api.HandleRequest(context.Background(), exportRequest(tt.export, nil))
+
+			// Act
+			result := runImport(t, api, importRequest(tt.body(exported.Body), map[string]string{"format": tt.format}))
+
+			// Assert
+			if len(result.Results) != 1 || result.Results[0].Status != http.StatusOK {
+				t.Fatalf("Expected the task to be updated, got %+v", result.Results)
+			}
+			stored, err := store.GetByID(context.Background(), want.ID, want.Owner)
+			if err != nil || !reflect.DeepEqual(stored, want) {
+				t.Errorf("Expected %+v, got %+v (%v)", want, stored, err)
+			}
+		})
+	}
+}
+
+func TestImportTasksJSON(t *testing.T) {
+	// Arrange
+	api := NewAPI(NewMockTaskStore())
+	body := `[{"title": "First", "id": "ignored"}, {"title": "Second", "status": "CLOSED"}, {"title": 3}, "not an object", {"title": "Bad", "status": "archived"}]`
+	request := importRequest(body, nil)
+	request.Headers = map[string]string{"content-type": "application/json; charset=utf-8"}
+
+	// Act
+	result := runImport(t, api, request)
+
+	// Assert
+	statuses := make([]int, len(result.Results))
+	for i, r := range result.Results {
+		statuses[i] = r.Status
+	}
+	if !reflect.DeepEqual(statuses, []int{201, 201, 400, 400, 400}) {
+		t.Errorf("Expected statuses [201 201 400 400 400], got %v", statuses)
+	}
+	if errs := result.Results[2].Errors; len(errs) != 1 || errs[0] != (FieldError{Field: "title", Code: FieldInvalidType}) {
+		t.Errorf("Expected a type error for row 3, got %+v", result.Results[2])
+	}
+	if errs := result.Results[4].Errors; len(errs) != 1 || errs[0] != (FieldError{Field: "status", Code: FieldInvalidValue}) {
+		t.Errorf("Expected an invalid status for row 5, got %+v", result.Results[4])
+	}
+}
+
+func TestParseTodoTxtLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected importRow
+	}{
+		{
+			"(A) 2024-01-02 Call Mom +Family @phone",
+			importRow{title: "Call Mom +Family @phone", status: "OPEN", priority: "A", projects: []string{"Family"}, contexts: []string{"phone"}, fields: todoTxtFields},
+		},
+		{
+			"x 2024-01-03 2024-01-01 Pay rent pri:B",
+			importRow{title: "Pay rent pri:B", status: "CLOSED", priority: "B", fields: todoTxtFields},
+		},
+		{
+			"x Done without dates",
+			importRow{title: "Done without dates", status: "CLOSED", fields: todoTxtFields},
+		},
+		{
+			"xylophone lessons (B) + @",
+			importRow{title: "xylophone lessons (B) + @", status: "OPEN", fields: todoTxtFields},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			// Act
+			row := parseTodoTxtLine(tt.line)
+
+			// Assert
+			if !reflect.DeepEqual(row, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, row)
+			}
+		})
+	}
+}
+
+func TestTodoTxtPriority(t *testing.T) {
+	tests := []struct {
+		letter string
+		want   TaskPriority
+	}{
+		{"", ""},
+		{"A", TaskPriorityHigh},
+		{"B", TaskPriorityNormal},
+		{"C", TaskPriorityLow},
+		{"Z", TaskPriorityLow},
+	}
+
+	for _, tt := range tests {
+		// Act
+		got := todoTxtPriority(tt.letter)
+
+		// Assert
+		if got != tt.want {
+			t.Errorf("Expected %q for %q, got %q", tt.want, tt.letter, got)
+		}
+	}
+}
+
+func TestImportTasksTodoTxtDryRun(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	api := NewAPI(store)
+	body := "(A) Call Mom +Family @phone\n\nx 2024-01-03 Pay rent\n(B) " + strings.Repeat("long ", 50) + "\n"
+
+	// Act
+	result := runImport(t, api, importRequest(body, map[string]string{"format": "todotxt", "dryRun": "true"}))
+
+	// Assert
+	if !result.DryRun || result.Imported != 2 || result.Failed != 1 {
+		t.Errorf("Expected a dry run with 2 valid and 1 invalid row, got %+v", result)
+	}
+	if r := result.Results[0]; r.Status != http.StatusOK || r.Priority != "A" || !reflect.DeepEqual(r.Projects, []string{"Family"}) ||
+		r.Task == nil || r.Task.Priority != TaskPriorityHigh {
+		t.Errorf("Expected row 1 to be valid with its metadata and a high priority, got %+v", r)
+	}
+	if r := result.Results[1]; r.Task == nil || r.Task.Priority != "" {
+		t.Errorf("Expected row 2 to have no priority, got %+v", r)
+	}
+	if r := result.Results[2]; r.Row != 3 || len(r.Errors) != 1 || r.Errors[0].Code != FieldTooLong {
+		t.Errorf("Expected row 3 to have a title that is too long, got %+v", r)
+	}
+	if open, _ := store.ListOpen(context.Background(), "test@example.com"); len(open) != 0 {
+		t.Errorf("Expected a dry run to store nothing, got %d tasks", len(open))
+	}
+}
+
+func TestImportTasksICS(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	api := NewAPI(store)
+	body := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\nUID:event\r\nSUMMARY:Not a task\r\nEND:VEVENT\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:call@example.com\r\n" +
+		"SUMMARY:Call Mom\\, then Dad\r\n" +
+		"DESCRIPTION:Ask about\\nthe weekend\r\n" +
+		"DUE;TZID=Europe/Berlin:20240501T090000\r\n" +
+		"RRULE:FREQ=WEEKLY;BYDAY=SU\r\n" +
+		"STATUS:NEEDS-ACTION\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:rent@example.com\r\nSUMMARY:Pay rent\r\nCOMPLETED:20240103T100000Z\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:bad@example.com\r\nSUMMARY:Bad\r\nDUE:soon\r\nRRULE:WEEKLY\r\nEND:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+	request := importRequest(body, nil)
+	request.Headers = map[string]string{"Content-Type": "text/calendar; charset=utf-8"}
+
+	// Act
+	result := runImport(t, api, request)
+
+	// Assert
+	if result.Imported != 2 || result.Failed != 1 {
+		t.Fatalf("Expected 2 imported and 1 failed, got %+v", result)
+	}
+	want := Task{
+		Title:       "Call Mom, then Dad",
+		Status:      TaskStatusOpen,
+		Owner:       "test@example.com",
+		Description: "Ask about\nthe weekend",
+		Due:         "2024-05-01T07:00:00Z",
+		Recurrence:  "FREQ=WEEKLY;BYDAY=SU",
+		UID:         "call@example.com",
+	}
+	if r := result.Results[0]; r.Status != http.StatusCreated || r.Task == nil {
+		t.Fatalf("Expected row 1 to be created, got %+v", r)
+	}
+	want.ID = result.Results[0].Task.ID
+	if stored, _ := store.GetByID(context.Background(), want.ID, want.Owner); !reflect.DeepEqual(stored, want) {
+		t.Errorf("Expected %+v, got %+v", want, stored)
+	}
+	if r := result.Results[1]; r.Task == nil || r.Task.Status != TaskStatusClosed {
+		t.Errorf("Expected a task with a completion time to be closed, got %+v", r)
+	}
+	if r := result.Results[2]; !reflect.DeepEqual(r.Errors, []FieldError{{Field: "due", Code: FieldInvalidFormat}, {Field: "recurrence", Code: FieldInvalidFormat}}) {
+		t.Errorf("Expected the due date and recurrence to be invalid, got %+v", r.Errors)
+	}
+}
+
+func TestImportTasksICSUpserts(t *testing.T) {
+	// Arrange
+	api := newExportAPI(t, "From the feed")
+	created := createTestFeedToken(t, api, "test@example.com")
+	feed, _ := api.HandleRequest(context.Background(), calendarRequest(created.Token))
+	feedBody := strings.Replace(feed.Body, "STATUS:NEEDS-ACTION", "STATUS:COMPLETED", 1)
+	first := runImport(t, api, importRequest("BEGIN:VCALENDAR\nBEGIN:VTODO\nUID:abc\nSUMMARY:Imported\nEND:VTODO\nEND:VCALENDAR\n", map[string]string{"format": "ics"}))
+
+	// Act
+	fromFeed := runImport(t, api, importRequest(feedBody, map[string]string{"format": "ics"}))
+	again := runImport(t, api, importRequest("BEGIN:VCALENDAR\n"+
+		"BEGIN:VTODO\nUID:abc\nSUMMARY:Renamed\nEND:VTODO\n"+
+		"BEGIN:VTODO\nUID:abc\nSUMMARY:Duplicate\nEND:VTODO\n"+
+		"END:VCALENDAR\n", map[string]string{"format": "ics"}))
+
+	// Assert
+	open, _ := api.store.ListOpen(context.Background(), "test@example.com")
+	closed, _ := api.store.ListClosed(context.Background(), "test@example.com")
+	if len(open) != 1 || len(closed) != 1 {
+		t.Fatalf("Expected 1 open and 1 closed task, got %d and %d", len(open), len(closed))
+	}
+	if r := fromFeed.Results[0]; r.Status != http.StatusOK || closed[0].Title != "From the feed" || closed[0].UID != "" {
+		t.Errorf("Expected the feed's task to be updated by ID, got %+v and %+v", r, closed[0])
+	}
+	if r := again.Results[0]; r.Status != http.StatusOK || r.Task.ID != first.Results[0].Task.ID {
+		t.Errorf("Expected the task to be updated by UID, got %+v", r)
+	}
+	if open[0].Title != "Renamed" || open[0].UID != "abc" {
+		t.Errorf("Expected the task to be renamed and keep its UID, got %+v", open[0])
+	}
+	if r := again.Results[1]; r.Status != http.StatusConflict || r.Code != CodeConflict {
+		t.Errorf("Expected a duplicate UID to conflict, got %+v", r)
+	}
+}
+
+func TestImportTasksICSKeepsFieldsItDoesNotCarry(t *testing.T) {
+	// Arrange: a task with a priority and labels, which calendars do not carry
+	store := NewMockTaskStore()
+	api := NewAPI(store)
+	calendar := func(summary string) events.APIGatewayProxyRequest {
+		return importRequest("BEGIN:VCALENDAR\nBEGIN:VTODO\nUID:abc\nSUMMARY:"+summary+"\nEND:VTODO\nEND:VCALENDAR\n", map[string]string{"format": "ics"})
+	}
+	first := runImport(t, api, calendar("Imported"))
+	task := *first.Results[0].Task
+	task.Priority = TaskPriorityUrgent
+	task.Labels = []string{"home", "weekly"}
+	if err := store.Add(context.Background(), task); err != nil {
+		t.Fatalf("Failed to set priority and labels: %v", err)
+	}
+
+	// Act
+	again := runImport(t, api, calendar("Renamed"))
+
+	// Assert
+	stored, err := store.GetByID(context.Background(), task.ID, "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get task: %v", err)
+	}
+	if r := again.Results[0]; r.Status != http.StatusOK || stored.Title != "Renamed" {
+		t.Errorf("Expected the task to be renamed, got %+v and %+v", r, stored)
+	}
+	if stored.Priority != TaskPriorityUrgent || !reflect.DeepEqual(stored.Labels, []string{"home", "weekly"}) {
+		t.Errorf("Expected the priority and labels to survive the import, got %+v", stored)
+	}
+}
+
+func TestImportTasksWriteFailure(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	store.InjectFault(MockOpBatchWrite, MockFault{Err: ErrThrottled})
+	api := NewAPI(store)
+
+	// Act
+	result := runImport(t, api, importRequest("Buy milk\n", map[string]string{"format": "todotxt"}))
+
+	// Assert
+	if r := result.Results[0]; r.Status != http.StatusServiceUnavailable || r.Code != CodeThrottled || r.Task != nil {
+		t.Errorf("Expected the write to fail as throttled, got %+v", r)
+	}
+	if result.Failed != 1 {
+		t.Errorf("Expected 1 failed row, got %d", result.Failed)
+	}
+}
+
+func TestImportTasksInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		params map[string]string
+		code   ErrorCode
+	}{
+		{"unknown format", "a", map[string]string{"format": "xml"}, CodeValidationFailed},
+		{"no format", "a", nil, CodeValidationFailed},
+		{"invalid mapping", "a", map[string]string{"format": "csv", "columns": "owner:Assignee"}, CodeValidationFailed},
+		{"invalid dry run", "a", map[string]string{"format": "csv", "dryRun": "maybe"}, CodeValidationFailed},
+		{"missing title column", "name\nx\n", map[string]string{"format": "csv"}, CodeInvalidBody},
+		{"missing mapped column", "title\nx\n", map[string]string{"format": "csv", "columns": "status:State"}, CodeInvalidBody},
+		{"not an array", `{"title": "x"}`, map[string]string{"format": "json"}, CodeInvalidBody},
+		{"mapping for JSON", `[]`, map[string]string{"format": "json", "columns": "title:name"}, CodeInvalidBody},
+		{"no rows", "title\n", map[string]string{"format": "csv"}, CodeValidationFailed},
+		{"empty", "", map[string]string{"format": "todotxt"}, CodeInvalidBody},
+		{"too many rows", strings.Repeat("task\n", maxImportRows+1), map[string]string{"format": "todotxt"}, CodeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			api := NewAPI(NewMockTaskStore())
+
+			// Act
+			response, _ := api.HandleRequest(context.Background(), importRequest(tt.body, tt.params))
+
+			// Assert
+			var problem Problem
+			_ = json.Unmarshal([]byte(response.Body), &problem)
+			if response.StatusCode != http.StatusBadRequest || problem.Code != tt.code {
+				t.Errorf("Expected status code %d with code %s, got %d: %s", http.StatusBadRequest, tt.code, response.StatusCode, response.Body)
+			}
+		})
+	}
+}
+
+func TestParseImportStatus(t *testing.T) {
+	for value, expected := range map[string]TaskStatus{"": TaskStatusOpen, "Open": TaskStatusOpen, "DONE": TaskStatusClosed, " closed ": TaskStatusClosed} {
+		if status, ok := parseImportStatus(value); !ok || status != expected {
+			t.Errorf("Expected %q to be %s, got %s", value, expected, status)
+		}
+	}
+	if _, ok := parseImportStatus("archived"); ok {
+		t.Error("Expected archived to be rejected")
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxLocalBodySize matches the Lambda synchronous invocation payload limit
+	maxLocalBodySize = 6 << 20
+	// shutdownTimeout is how long in-flight requests get to finish on shutdown
+	shutdownTimeout = 10 * time.Second
+)
+
+// localHandler adapts the API to net/http so it can run without Lambda
+func localHandler(api *API) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, err := proxyRequest(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		response, err := api.HandleRequest(r.Context(), request)
+		if err != nil {
+			log.Printf("request %s: handler failed: %v", request.RequestContext.RequestID, err)
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+
+		if err := writeProxyResponse(w, response); err != nil {
+			log.Printf("request %s: failed to write response: %v", request.RequestContext.RequestID, err)
+		}
+	})
+}
+
+// proxyRequest converts an HTTP request to the event API Gateway would send
+func proxyRequest(w http.ResponseWriter, r *http.Request) (events.APIGatewayProxyRequest, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxLocalBodySize))
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	request := events.APIGatewayProxyRequest{
+		Resource:   "/{proxy+}",
+		Path:       r.URL.Path,
+		HTTPMethod: r.Method,
+		Body:       string(body),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  uuid.New().String(),
+			HTTPMethod: r.Method,
+			Path:       r.URL.Path,
+			Stage:      "local",
+		},
+	}
+
+	// API Gateway base64-encodes bodies that are not text
+	if !utf8.Valid(body) {
+		request.Body = base64.StdEncoding.EncodeToString(body)
+		request.IsBase64Encoded = true
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		request.RequestContext.Identity.SourceIP = host
+	}
+
+	// Copy the headers, keeping the last value in the single-value map as API Gateway does
+	if len(r.Header) > 0 {
+		request.Headers = make(map[string]string, len(r.Header))
+		request.MultiValueHeaders = make(map[string][]string, len(r.Header))
+		for key, values := range r.Header {
+			request.Headers[key] = values[len(values)-1]
+			request.MultiValueHeaders[key] = values
+		}
+	}
+
+	// Copy the query string parameters in the same way
+	if query := r.URL.Query(); len(query) > 0 {
+		request.QueryStringParameters = make(map[string]string, len(query))
+		request.MultiValueQueryStringParameters = make(map[string][]string, len(query))
+		for key, values := range query {
+			request.QueryStringParameters[key] = values[len(values)-1]
+			request.MultiValueQueryStringParameters[key] = values
+		}
+	}
+
+	return request, nil
+}
+
+// writeProxyResponse writes an API Gateway proxy response to an HTTP response
+func writeProxyResponse(w http.ResponseWriter, response events.APIGatewayProxyResponse) error {
+	for key, value := range response.Headers {
+		w.Header().Set(key, value)
+	}
+	for key, values := range response.MultiValueHeaders {
+		w.Header().Del(key)
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(response.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return fmt.Errorf("failed to decode response body: %w", err)
+		}
+		body = decoded
+	}
+
+	w.WriteHeader(response.StatusCode)
+	_, err := w.Write(body)
+	return err
+}
+
+// serveLocal serves the API over HTTP on addr until it receives SIGINT or
+// SIGTERM, then waits for in-flight requests to finish
+func serveLocal(addr string, api *API) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           localHandler(api),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", addr)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down: %w", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
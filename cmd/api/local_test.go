@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocalHandler(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(localHandler(&API{store: NewMockTaskStore()}))
+	defer server.Close()
+
+	// Act
+	createResponse, err := http.Post(server.URL+"/api/tasks", "application/json", strings.NewReader(`{"title": "Test Task", "owner": "test@example.com"}`))
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	defer createResponse.Body.Close()
+
+	var created Task
+	if err := json.NewDecoder(createResponse.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to parse create response: %v", err)
+	}
+
+	getResponse, err := http.Get(server.URL + "/api/tasks/" + created.ID.String() + "?owner=test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get task: %v", err)
+	}
+	defer getResponse.Body.Close()
+
+	var retrieved Task
+	if err := json.NewDecoder(getResponse.Body).Decode(&retrieved); err != nil {
+		t.Fatalf("Failed to parse get response: %v", err)
+	}
+
+	// Assert
+	if createResponse.StatusCode != http.StatusCreated {
+		t.Errorf("Expected status code %d, got %d", http.StatusCreated, createResponse.StatusCode)
+	}
+	if getResponse.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, getResponse.StatusCode)
+	}
+	if contentType := getResponse.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Expected content type application/json, got %s", contentType)
+	}
+	if retrieved.ID != created.ID {
+		t.Errorf("Expected task ID to be %v, got %v", created.ID, retrieved.ID)
+	}
+}
+
+func TestLocalHandlerMethodNotAllowed(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(localHandler(&API{store: NewMockTaskStore()}))
+	defer server.Close()
+	request, _ := http.NewRequest(http.MethodDelete, server.URL+"/api/tasks/", nil)
+
+	// Act
+	response, err := http.DefaultClient.Do(request)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code %d, got %d", http.StatusMethodNotAllowed, response.StatusCode)
+	}
+	if allow := response.Header.Get("Allow"); allow != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("Expected Allow header 'GET, HEAD, OPTIONS, POST', got '%s'", allow)
+	}
+}
+
+func TestProxyRequest(t *testing.T) {
+	// Arrange
+	r := httptest.NewRequest(http.MethodGet, "/api/tasks/?owner=a&owner=b&status=OPEN", nil)
+	r.Header.Add("X-Custom", "one")
+	r.Header.Add("X-Custom", "two")
+
+	// Act
+	request, err := proxyRequest(httptest.NewRecorder(), r)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if request.Path != "/api/tasks/" || request.HTTPMethod != http.MethodGet {
+		t.Errorf("Expected GET /api/tasks/, got %s %s", request.HTTPMethod, request.Path)
+	}
+	if request.QueryStringParameters["owner"] != "b" {
+		t.Errorf("Expected owner to be the last value 'b', got '%s'", request.QueryStringParameters["owner"])
+	}
+	if len(request.MultiValueQueryStringParameters["owner"]) != 2 {
+		t.Errorf("Expected 2 owner values, got %v", request.MultiValueQueryStringParameters["owner"])
+	}
+	if len(request.MultiValueHeaders["X-Custom"]) != 2 {
+		t.Errorf("Expected 2 header values, got %v", request.MultiValueHeaders["X-Custom"])
+	}
+	if request.RequestContext.RequestID == "" {
+		t.Error("Expected request ID to be set")
+	}
+}
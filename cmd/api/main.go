@@ -2,47 +2,204 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/user/tasks-api/internal/env"
 )
 
-// getTableName gets the DynamoDB table name from the environment
-func getTableName() string {
-	// Get the stage from the environment
-	stage := os.Getenv("APP_ENVIRONMENT")
-	if stage == "" {
-		stage = "development"
+// getHTTPClientConfig gets the DynamoDB HTTP client settings from the
+// environment, falling back to the defaults for unset or invalid values
+func getHTTPClientConfig() HTTPClientConfig {
+	httpConfig := DefaultHTTPClientConfig()
+
+	durations := map[string]*time.Duration{
+		"DYNAMODB_HTTP_TIMEOUT":      &httpConfig.Timeout,
+		"DYNAMODB_DIAL_TIMEOUT":      &httpConfig.DialTimeout,
+		"DYNAMODB_IDLE_CONN_TIMEOUT": &httpConfig.IdleConnTimeout,
+	}
+	for name, value := range durations {
+		if env := os.Getenv(name); env != "" {
+			if d, err := time.ParseDuration(env); err == nil {
+				*value = d
+			} else {
+				log.Printf("ignoring invalid %s %q: %v", name, env, err)
+			}
+		}
+	}
+
+	if env := os.Getenv("DYNAMODB_MAX_IDLE_CONNS"); env != "" {
+		if n, err := strconv.Atoi(env); err == nil {
+			httpConfig.MaxIdleConnsPerHost = n
+		} else {
+			log.Printf("ignoring invalid DYNAMODB_MAX_IDLE_CONNS %q: %v", env, err)
+		}
+	}
+
+	return httpConfig
+}
+
+// getWriteBackUpgrades reports whether items read in an older schema
+// version should be saved back in the current version
+func getWriteBackUpgrades() bool {
+	enabled, err := strconv.ParseBool(os.Getenv("SCHEMA_WRITE_BACK"))
+	return err == nil && enabled
+}
+
+// getLegacyKeys reports whether the store should still handle items stored
+// under legacy keys. It is enabled unless LEGACY_KEYS is set to false.
+func getLegacyKeys() bool {
+	enabled, err := strconv.ParseBool(os.Getenv("LEGACY_KEYS"))
+	return err != nil || enabled
+}
+
+// getSQLitePath gets the SQLite database path from the environment
+func getSQLitePath() string {
+	if path := os.Getenv("SQLITE_PATH"); path != "" {
+		return path
+	}
+	return "tasks.db"
+}
+
+// newStore creates the task store selected by the STORE_DRIVER environment
+// variable: "dynamodb" (the default), "sqlite" or "memory"
+func newStore() (Store, error) {
+	switch driver := os.Getenv("STORE_DRIVER"); driver {
+	case "", "dynamodb":
+		store, err := NewTaskStore(env.TableName(), getHTTPClientConfig())
+		if err != nil {
+			return nil, err
+		}
+		store.writeBackUpgrades = getWriteBackUpgrades()
+		store.legacyKeys = getLegacyKeys()
+		return store, nil
+	case "sqlite":
+		return NewSQLiteTaskStore(getSQLitePath())
+	case "memory":
+		return NewMockTaskStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown STORE_DRIVER %q", driver)
+	}
+}
+
+// newAPI creates the API and the task store behind it
+func newAPI() (*API, error) {
+	store, err := newStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task store: %w", err)
+	}
+
+	api := NewAPI(store)
+	if bucket := os.Getenv("EXPORT_BUCKET"); bucket != "" {
+		exports, err := NewS3ExportStore(bucket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create export store: %w", err)
+		}
+		api.exports = exports
+	}
+
+	return api, nil
+}
+
+// lazyAPI creates the API on first use and shares it between invocations,
+// so the AWS config and DynamoDB client are only set up once per execution
+// environment. Unlike sync.Once, a failed creation is retried on the next
+// invocation.
+type lazyAPI struct {
+	mu     sync.Mutex
+	api    atomic.Pointer[API]
+	create func() (*API, error)
+}
+
+// get returns the shared API, creating it if needed
+func (l *lazyAPI) get() (*API, error) {
+	if api := l.api.Load(); api != nil {
+		return api, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Another invocation may have created it while we waited
+	if api := l.api.Load(); api != nil {
+		return api, nil
+	}
+
+	api, err := l.create()
+	if err != nil {
+		return nil, err
 	}
+	l.api.Store(api)
 
-	// Return the table name
-	return fmt.Sprintf("%s-tasks-api", stage)
+	return api, nil
 }
 
-// handleRequest is the Lambda handler
-func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Get the table name
-	tableName := getTableName()
+// sharedAPI is the API used by every invocation in this execution environment
+var sharedAPI = &lazyAPI{create: newAPI}
+
+// handleRequest is the Lambda handler. It accepts API Gateway REST and HTTP
+// API, ALB and Function URL events, and responds in the matching format.
+func handleRequest(ctx context.Context, event json.RawMessage) (any, error) {
+	return handleEvent(ctx, sharedAPI, event)
+}
 
-	// Create the API
-	api, err := NewAPI(tableName)
+// handleEvent handles a raw Lambda event with the API provided by lazy
+func handleEvent(ctx context.Context, lazy *lazyAPI, event json.RawMessage) (any, error) {
+	// Detect the event type
+	adapter, err := parseEvent(event)
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"message": "Failed to create API: %s"}`, err.Error()),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, nil
+		return nil, err
+	}
+	request := adapter.request()
+
+	// Get the API
+	api, err := lazy.get()
+	if err != nil {
+		response, _ := errorResponse(request, err, "API", "Failed to create API")
+		return adapter.response(response), nil
 	}
 
 	// Handle the request
-	return api.HandleRequest(ctx, request)
+	response, err := api.HandleRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.response(response), nil
+}
+
+// getListenAddr gets the local server's listen address from the environment
+func getListenAddr() string {
+	if addr := os.Getenv("LISTEN_ADDR"); addr != "" {
+		return addr
+	}
+	return ":8080"
 }
 
 func main() {
+	local := flag.Bool("local", false, "serve the API over HTTP instead of running as a Lambda function")
+	addr := flag.String("addr", getListenAddr(), "listen address for -local")
+	flag.Parse()
+
+	// Serve the API locally
+	if *local {
+		api, err := sharedAPI.get()
+		if err != nil {
+			log.Fatalf("failed to create API: %v", err)
+		}
+		if err := serveLocal(*addr, api); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Start the Lambda handler
 	lambda.Start(handleRequest)
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// healthCheckEvent is a REST API event for the health check
+var healthCheckEvent = json.RawMessage(`{"httpMethod":"GET","path":"/api/health-check","requestContext":{"requestId":"bench"}}`)
+
+func TestLazyAPICreatesOnce(t *testing.T) {
+	// Arrange
+	var calls atomic.Int32
+	lazy := &lazyAPI{create: func() (*API, error) {
+		calls.Add(1)
+		return NewAPI(NewMockTaskStore()), nil
+	}}
+
+	// Act
+	var wg sync.WaitGroup
+	apis := make([]*API, 50)
+	for i := range apis {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			apis[i], _ = lazy.get()
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 call to create, got %d", calls.Load())
+	}
+	for i, api := range apis {
+		if api == nil || api != apis[0] {
+			t.Errorf("Expected goroutine %d to get the shared API", i)
+		}
+	}
+}
+
+func TestLazyAPIRetriesAfterError(t *testing.T) {
+	// Arrange
+	fail := true
+	lazy := &lazyAPI{create: func() (*API, error) {
+		if fail {
+			return nil, errors.New("no credentials")
+		}
+		return NewAPI(NewMockTaskStore()), nil
+	}}
+
+	// Act
+	_, firstErr := lazy.get()
+	fail = false
+	api, secondErr := lazy.get()
+
+	// Assert
+	if firstErr == nil {
+		t.Errorf("Expected an error from the first call")
+	}
+	if secondErr != nil || api == nil {
+		t.Errorf("Expected the second call to create the API, got %v", secondErr)
+	}
+}
+
+func TestHandleEventCreateError(t *testing.T) {
+	// Arrange
+	lazy := &lazyAPI{create: func() (*API, error) {
+		return nil, errors.New("no credentials")
+	}}
+
+	// Act
+	response, err := handleEvent(context.Background(), lazy, healthCheckEvent)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	proxyResponse, ok := response.(events.APIGatewayProxyResponse)
+	if !ok {
+		t.Fatalf("Expected a REST API response, got %T", response)
+	}
+	if proxyResponse.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, proxyResponse.StatusCode)
+	}
+}
+
+func TestGetHTTPClientConfig(t *testing.T) {
+	// Arrange
+	t.Setenv("DYNAMODB_HTTP_TIMEOUT", "3s")
+	t.Setenv("DYNAMODB_DIAL_TIMEOUT", "not-a-duration")
+	t.Setenv("DYNAMODB_MAX_IDLE_CONNS", "64")
+	expected := DefaultHTTPClientConfig()
+	expected.Timeout = 3 * time.Second
+	expected.MaxIdleConnsPerHost = 64
+
+	// Act
+	httpConfig := getHTTPClientConfig()
+
+	// Assert
+	if httpConfig != expected {
+		t.Errorf("Expected %+v, got %+v", expected, httpConfig)
+	}
+}
+
+// BenchmarkHandleRequestPerInvocation measures the previous behavior of
+// loading the AWS config and creating the API on every invocation
+func BenchmarkHandleRequestPerInvocation(b *testing.B) {
+	b.Setenv("AWS_REGION", "us-east-1")
+	ctx := context.Background()
+
+	for b.Loop() {
+		lazy := &lazyAPI{create: newAPI}
+		if _, err := handleEvent(ctx, lazy, healthCheckEvent); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkHandleRequestShared measures invocations sharing one API
+func BenchmarkHandleRequestShared(b *testing.B) {
+	b.Setenv("AWS_REGION", "us-east-1")
+	ctx := context.Background()
+	lazy := &lazyAPI{create: newAPI}
+
+	for b.Loop() {
+		if _, err := handleEvent(ctx, lazy, healthCheckEvent); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
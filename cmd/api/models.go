@@ -1,9 +1,12 @@
 package main
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+	"github.com/user/tasks-api/internal/stats"
 )
 
 // TaskStatus represents the status of a task
@@ -16,12 +19,61 @@ const (
 	TaskStatusClosed TaskStatus = "CLOSED"
 )
 
+// TaskPriority represents the priority of a task
+type TaskPriority string
+
+const (
+	// TaskPriorityLow represents a task that can wait
+	TaskPriorityLow TaskPriority = "LOW"
+	// TaskPriorityNormal represents a task of ordinary priority
+	TaskPriorityNormal TaskPriority = "NORMAL"
+	// TaskPriorityHigh represents a task to do before normal ones
+	TaskPriorityHigh TaskPriority = "HIGH"
+	// TaskPriorityUrgent represents a task to do first
+	TaskPriorityUrgent TaskPriority = "URGENT"
+)
+
+// taskPriorities are the known priorities, lowest first
+var taskPriorities = []TaskPriority{TaskPriorityLow, TaskPriorityNormal, TaskPriorityHigh, TaskPriorityUrgent}
+
+// rank returns the position of a priority in taskPriorities, or -1 if it is
+// not a known priority
+func (p TaskPriority) rank() int {
+	return slices.Index(taskPriorities, p)
+}
+
+// OperationType is the type of a write operation on a task
+type OperationType string
+
+const (
+	// OperationCreate creates a new task
+	OperationCreate OperationType = "create"
+	// OperationUpdate updates the title of an existing task
+	OperationUpdate OperationType = "update"
+	// OperationClose closes an existing task
+	OperationClose OperationType = "close"
+	// OperationDelete deletes an existing task
+	OperationDelete OperationType = "delete"
+)
+
 // Task represents a task in the system
 type Task struct {
 	ID     uuid.UUID  `json:"id"`
 	Title  string     `json:"title"`
 	Status TaskStatus `json:"status"`
 	Owner  string     `json:"owner"`
+	// Description holds notes on the task
+	Description string `json:"description,omitempty"`
+	// Due is a date such as 2024-05-01, or a UTC time in RFC 3339 format
+	Due string `json:"due,omitempty"`
+	// Recurrence is an RFC 5545 RRULE value, such as FREQ=WEEKLY;BYDAY=MO
+	Recurrence string `json:"recurrence,omitempty"`
+	// UID identifies the task in the calendar it was imported from
+	UID string `json:"uid,omitempty"`
+	// Priority is empty for tasks without a priority
+	Priority TaskPriority `json:"priority,omitempty"`
+	// Labels are lowercase, sorted and unique
+	Labels []string `json:"labels,omitempty"`
 }
 
 // NewTask creates a new task with the given ID, title, and owner
@@ -36,42 +88,181 @@ func NewTask(id uuid.UUID, title, owner string) Task {
 
 // DynamoDBTask represents a task in DynamoDB
 type DynamoDBTask struct {
-	PK    string     `json:"PK"`
-	SK    string     `json:"SK"`
-	GS1PK string     `json:"GS1PK"`
-	GS1SK string     `json:"GS1SK"`
-	ID    string     `json:"id"`
-	Title string     `json:"title"`
-	Owner string     `json:"owner"`
+	PK     string     `json:"PK"`
+	SK     string     `json:"SK"`
+	GS1PK  string     `json:"GS1PK"`
+	GS1SK  string     `json:"GS1SK"`
+	ID     string     `json:"id"`
+	Title  string     `json:"title"`
+	Owner  string     `json:"owner"`
 	Status TaskStatus `json:"status"`
+	// Description, Due, Recurrence, UID, Priority and Labels are optional,
+	// so they are only stored when set
+	Description string       `json:"description" dynamodbav:",omitempty"`
+	Due         string       `json:"due" dynamodbav:",omitempty"`
+	Recurrence  string       `json:"recurrence" dynamodbav:",omitempty"`
+	UID         string       `json:"uid" dynamodbav:",omitempty"`
+	Priority    TaskPriority `json:"priority" dynamodbav:",omitempty"`
+	Labels      []string     `json:"labels" dynamodbav:",omitempty"`
+	// Created and Closed are when the task was created and closed, in
+	// stats.TimeFormat; they are empty on tasks written before they were
+	// kept, and Closed is empty on open tasks
+	Created string `json:"created" dynamodbav:",omitempty"`
+	Closed  string `json:"closed" dynamodbav:",omitempty"`
+	// Counted is set once the task is counted in its owner's stats item
+	Counted bool `json:"counted" dynamodbav:",omitempty"`
+	// Revision is incremented by every write to the item; it is zero on
+	// items written before it was kept
+	Revision int64 `json:"revision" dynamodbav:",omitempty"`
+	// MovedFrom is the legacy partition key of an item moved to its current
+	// key, until the task is written again; see keys.MovedFromAttribute
+	MovedFrom string `json:"moved_from" dynamodbav:",omitempty"`
+	// EntityType identifies task items among the other items in the table
+	EntityType keys.EntityType `json:"entity_type" dynamodbav:"entity_type,omitempty"`
+	// SchemaVersion is the version of the item format; zero on items written
+	// before it was introduced
+	SchemaVersion int `json:"schema_version" dynamodbav:"schema_version,omitempty"`
+}
+
+// DynamoDBFeedToken represents a calendar feed token item in DynamoDB. Only
+// the hash of the token is stored.
+type DynamoDBFeedToken struct {
+	PK         string
+	SK         string
+	Owner      string
+	TokenHash  string
+	EntityType keys.EntityType `dynamodbav:"entity_type"`
+}
+
+// newDynamoDBFeedToken creates a feed token item with the given key
+func newDynamoDBFeedToken(key keys.Key, owner, hash string) DynamoDBFeedToken {
+	return DynamoDBFeedToken{
+		PK:         key.PK,
+		SK:         key.SK,
+		Owner:      owner,
+		TokenHash:  hash,
+		EntityType: keys.EntityFeedToken,
+	}
+}
+
+// View is a saved query of an owner's tasks
+type View struct {
+	ID    uuid.UUID `json:"id"`
+	Owner string    `json:"owner"`
+	Name  string    `json:"name"`
+	// Query is in the language of GET /api/tasks?q=
+	Query string `json:"query"`
+}
+
+// DynamoDBView represents a saved view item in DynamoDB
+type DynamoDBView struct {
+	PK         string
+	SK         string
+	ID         string
+	Owner      string
+	Name       string
+	Query      string
+	EntityType keys.EntityType `dynamodbav:"entity_type"`
+}
+
+// ToDynamoDBView converts a View to a DynamoDBView
+func ToDynamoDBView(view View) DynamoDBView {
+	key := keys.View(view.Owner, view.ID)
+	return DynamoDBView{
+		PK:         key.PK,
+		SK:         key.SK,
+		ID:         view.ID.String(),
+		Owner:      view.Owner,
+		Name:       view.Name,
+		Query:      view.Query,
+		EntityType: keys.EntityView,
+	}
 }
 
-// ToTask converts a DynamoDBTask to a Task
+// ToView converts a DynamoDBView to a View
+func (dv DynamoDBView) ToView() (View, error) {
+	id, err := uuid.Parse(dv.ID)
+	if err != nil {
+		return View{}, err
+	}
+	return View{ID: id, Owner: dv.Owner, Name: dv.Name, Query: dv.Query}, nil
+}
+
+// ToTask converts a DynamoDBTask to a Task, first upgrading it in place if
+// it was written in an older schema version
 func (dt *DynamoDBTask) ToTask() (Task, error) {
+	if err := dt.upgrade(); err != nil {
+		return Task{}, err
+	}
+
 	id, err := uuid.Parse(dt.ID)
 	if err != nil {
 		return Task{}, err
 	}
 
 	return Task{
-		ID:     id,
-		Title:  dt.Title,
-		Status: dt.Status,
-		Owner:  dt.Owner,
+		ID:          id,
+		Title:       dt.Title,
+		Status:      dt.Status,
+		Owner:       dt.Owner,
+		Description: dt.Description,
+		Due:         dt.Due,
+		Recurrence:  dt.Recurrence,
+		UID:         dt.UID,
+		Priority:    dt.Priority,
+		Labels:      dt.Labels,
 	}, nil
 }
 
 // ToDynamoDBTask converts a Task to a DynamoDBTask
 func ToDynamoDBTask(task Task) DynamoDBTask {
-	now := time.Now().UTC().Format(time.RFC3339)
+	key := keys.Task(task.Owner, task.ID)
 	return DynamoDBTask{
-		PK:    "#" + task.Owner,
-		SK:    "#" + task.ID.String(),
-		GS1PK: "#" + task.Owner + "#" + string(task.Status),
-		GS1SK: "#" + now,
-		ID:    task.ID.String(),
-		Title: task.Title,
-		Owner: task.Owner,
-		Status: task.Status,
+		PK:            key.PK,
+		SK:            key.SK,
+		GS1PK:         keys.TaskStatus(task.Owner, string(task.Status)),
+		GS1SK:         keys.Changed(time.Now()),
+		ID:            task.ID.String(),
+		Title:         task.Title,
+		Owner:         task.Owner,
+		Status:        task.Status,
+		Description:   task.Description,
+		Due:           task.Due,
+		Recurrence:    task.Recurrence,
+		UID:           task.UID,
+		Priority:      task.Priority,
+		Labels:        task.Labels,
+		EntityType:    keys.EntityTask,
+		SchemaVersion: CurrentSchemaVersion,
+	}
+}
+
+// countedTask returns the part of a task that its owner's stats count,
+// without creation or closing times
+func countedTask(task Task) stats.Task {
+	return stats.Task{Status: string(task.Status), Priority: string(task.Priority), Labels: task.Labels}
+}
+
+// state returns the part of a task item that its owner's stats count, or
+// nil if there is no item
+func (dt *DynamoDBTask) state() *stats.Task {
+	if dt == nil {
+		return nil
+	}
+	return &stats.Task{
+		Status:   string(dt.Status),
+		Priority: string(dt.Priority),
+		Labels:   dt.Labels,
+		Created:  stats.ParseTime(dt.Created),
+		Closed:   stats.ParseTime(dt.Closed),
+	}
+}
+
+// counted returns the state of a task item that its owner's stats include,
+// or nil if there is no item or it is not counted yet
+func (dt *DynamoDBTask) counted() *stats.Task {
+	if dt == nil || !dt.Counted {
+		return nil
 	}
+	return dt.state()
 }
@@ -44,14 +44,17 @@ func TestToDynamoDBTask(t *testing.T) {
 	dbTask := ToDynamoDBTask(task)
 
 	// Assert
-	if dbTask.PK != "#"+task.Owner {
-		t.Errorf("Expected PK to be #%s, got %s", task.Owner, dbTask.PK)
+	if dbTask.PK != "USER#"+task.Owner {
+		t.Errorf("Expected PK to be USER#%s, got %s", task.Owner, dbTask.PK)
 	}
-	if dbTask.SK != "#"+task.ID.String() {
-		t.Errorf("Expected SK to be #%s, got %s", task.ID.String(), dbTask.SK)
+	if dbTask.SK != "TASK#"+task.ID.String() {
+		t.Errorf("Expected SK to be TASK#%s, got %s", task.ID.String(), dbTask.SK)
 	}
-	if dbTask.GS1PK != "#"+task.Owner+"#"+string(task.Status) {
-		t.Errorf("Expected GS1PK to be #%s#%s, got %s", task.Owner, task.Status, dbTask.GS1PK)
+	if dbTask.GS1PK != "USER#"+task.Owner+"#STATUS#"+string(task.Status) {
+		t.Errorf("Expected GS1PK to be USER#%s#STATUS#%s, got %s", task.Owner, task.Status, dbTask.GS1PK)
+	}
+	if dbTask.EntityType != "TASK" {
+		t.Errorf("Expected EntityType to be TASK, got %s", dbTask.EntityType)
 	}
 	if dbTask.ID != task.ID.String() {
 		t.Errorf("Expected ID to be %s, got %s", task.ID.String(), dbTask.ID)
@@ -101,3 +104,26 @@ func TestDynamoDBTaskToTask(t *testing.T) {
 		t.Errorf("Expected task status to be %s, got %s", dbTask.Status, task.Status)
 	}
 }
+
+func TestDynamoDBViewRoundTrip(t *testing.T) {
+	// Arrange
+	view := View{ID: uuid.New(), Owner: "test@example.com", Name: "Bugs", Query: "label:bug"}
+
+	// Act
+	dbView := ToDynamoDBView(view)
+	got, err := dbView.ToView()
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if dbView.PK != "USER#"+view.Owner || dbView.SK != "VIEW#"+view.ID.String() {
+		t.Errorf("Expected keys USER#%s and VIEW#%s, got %s and %s", view.Owner, view.ID, dbView.PK, dbView.SK)
+	}
+	if dbView.EntityType != "VIEW" {
+		t.Errorf("Expected EntityType to be VIEW, got %s", dbView.EntityType)
+	}
+	if got != view {
+		t.Errorf("Expected %+v, got %+v", view, got)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// ErrorCode is a stable, machine-readable identifier for an error
+type ErrorCode string
+
+const (
+	// CodeInvalidBody means the request body could not be parsed
+	CodeInvalidBody ErrorCode = "invalid_body"
+	// CodeInvalidParameter means a path or query parameter is missing or malformed
+	CodeInvalidParameter ErrorCode = "invalid_parameter"
+	// CodeValidationFailed means the request was well-formed but its content is invalid
+	CodeValidationFailed ErrorCode = "validation_failed"
+	// CodeNotFound means the requested resource does not exist
+	CodeNotFound ErrorCode = "not_found"
+	// CodeMethodNotAllowed means the resource does not support the request method
+	CodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	// CodeConflict means the request conflicts with the current state of a resource
+	CodeConflict ErrorCode = "conflict"
+	// CodePreconditionFailed means a condition attached to the request did not hold
+	CodePreconditionFailed ErrorCode = "precondition_failed"
+	// CodeExportTooLarge means an export does not fit in a response and cannot be saved elsewhere
+	CodeExportTooLarge ErrorCode = "export_too_large"
+	// CodeThrottled means the store is temporarily over capacity
+	CodeThrottled ErrorCode = "throttled"
+	// CodeInternal means an unexpected server error occurred
+	CodeInternal ErrorCode = "internal_error"
+)
+
+// problemContentType is the media type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// Problem represents an RFC 7807 problem details response
+type Problem struct {
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Status    int       `json:"status"`
+	Detail    string    `json:"detail,omitempty"`
+	Instance  string    `json:"instance,omitempty"`
+	Code      ErrorCode `json:"code"`
+	RequestID string    `json:"requestId"`
+
+	// Index identifies the failing operation of a multi-operation request
+	Index *int `json:"index,omitempty"`
+	// Reason is the underlying cause reported by the store
+	Reason string `json:"reason,omitempty"`
+	// Errors lists every invalid field of a request that failed validation
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// requestID returns the API Gateway request ID, or a new one if there is none
+func requestID(request events.APIGatewayProxyRequest) string {
+	if request.RequestContext.RequestID != "" {
+		return request.RequestContext.RequestID
+	}
+	return uuid.New().String()
+}
+
+// newProblem creates a problem for the given request
+func newProblem(request events.APIGatewayProxyRequest, status int, code ErrorCode, detail string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  request.Path,
+		Code:      code,
+		RequestID: requestID(request),
+	}
+}
+
+// problemResponse builds a problem details response
+func problemResponse(request events.APIGatewayProxyRequest, status int, code ErrorCode, detail string) (events.APIGatewayProxyResponse, error) {
+	return newProblemResponse(newProblem(request, status, code, detail))
+}
+
+// newProblemResponse marshals a problem into a response
+func newProblemResponse(problem Problem) (events.APIGatewayProxyResponse, error) {
+	body, err := json.Marshal(problem)
+	if err != nil {
+		// Problem only holds strings and ints, so this cannot happen in practice
+		log.Printf("request %s: failed to marshal problem: %v", problem.RequestID, err)
+		body = []byte(`{"type": "about:blank", "title": "Internal Server Error", "status": 500, "code": "internal_error"}`)
+	}
+
+	headers := map[string]string{
+		"Content-Type": problemContentType,
+		"X-Request-Id": problem.RequestID,
+	}
+	if problem.Code == CodeThrottled {
+		headers["Retry-After"] = "1"
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: problem.Status,
+		Body:       string(body),
+		Headers:    headers,
+	}, nil
+}
+
+// errorStatus maps a store error to an HTTP status code, error code and a
+// detail message that is safe to show to clients. resource names what the
+// request was about, such as "Task" or "Webhook".
+func errorStatus(err error, resource string) (int, ErrorCode, string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, CodeNotFound, resource + " not found"
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict, CodeConflict, "The request conflicts with the current state of the " + strings.ToLower(resource)
+	case errors.Is(err, ErrThrottled):
+		return http.StatusServiceUnavailable, CodeThrottled, "The service is temporarily over capacity, retry later"
+	default:
+		return http.StatusInternalServerError, CodeInternal, ""
+	}
+}
+
+// errorResponse builds a problem details response for a store error. The
+// underlying error is logged rather than returned, so that DynamoDB details
+// do not leak to clients; detail is used for errors without a sentinel.
+func errorResponse(request events.APIGatewayProxyRequest, err error, resource, detail string) (events.APIGatewayProxyResponse, error) {
+	status, code, safeDetail := errorStatus(err, resource)
+	if safeDetail == "" {
+		safeDetail = detail
+	}
+
+	problem := newProblem(request, status, code, safeDetail)
+	log.Printf("request %s: %s: %v", problem.RequestID, detail, err)
+
+	return newProblemResponse(problem)
+}
+
+// invalidRequestResponse builds a problem details response for a request
+// body or parameters that failed to decode or validate
+func invalidRequestResponse(request events.APIGatewayProxyRequest, err error) (events.APIGatewayProxyResponse, error) {
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		problem := newProblem(request, http.StatusBadRequest, CodeValidationFailed, "The request has invalid fields")
+		problem.Errors = errs
+		return newProblemResponse(problem)
+	}
+
+	return problemResponse(request, http.StatusBadRequest, CodeInvalidBody, fmt.Sprintf("Invalid request body: %s", err.Error()))
+}
+
+// methodNotAllowed builds a response for an unsupported request method,
+// listing the methods the resource does support
+func methodNotAllowed(request events.APIGatewayProxyRequest, allow string) (events.APIGatewayProxyResponse, error) {
+	response, err := problemResponse(request, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method Not Allowed")
+	response.Headers["Allow"] = allow
+	return response, err
+}
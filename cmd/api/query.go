@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/user/tasks-api/internal/search"
+)
+
+// queryOperator compares a task field with a value in a query
+type queryOperator string
+
+const (
+	opEqual        queryOperator = ":"
+	opLess         queryOperator = "<"
+	opLessEqual    queryOperator = "<="
+	opGreater      queryOperator = ">"
+	opGreaterEqual queryOperator = ">="
+)
+
+// TaskFilter selects tasks by their fields. The zero value matches every
+// task.
+type TaskFilter struct {
+	// Status is empty to match tasks with either status
+	Status TaskStatus
+	// Priorities match tasks with any of them. Nil matches tasks with any
+	// priority or none; an empty slice matches no task.
+	Priorities []TaskPriority
+	// Labels match tasks with every one of them
+	Labels []string
+	// DueFrom and DueBefore match tasks due at or after DueFrom and before
+	// DueBefore, compared as strings so that a date sorts before the times
+	// on that day. Empty means no bound; tasks without a due field never
+	// match a bound.
+	DueFrom   string
+	DueBefore string
+	// Text terms must each match a term of the title or description, as
+	// in a search
+	Text []search.Term
+}
+
+// Match reports whether a task matches the filter
+func (f TaskFilter) Match(task Task) bool {
+	if f.Status != "" && task.Status != f.Status {
+		return false
+	}
+	if f.Priorities != nil && !slices.Contains(f.Priorities, task.Priority) {
+		return false
+	}
+	for _, label := range f.Labels {
+		if !slices.Contains(task.Labels, label) {
+			return false
+		}
+	}
+	if f.DueFrom != "" && (task.Due == "" || task.Due < f.DueFrom) {
+		return false
+	}
+	if f.DueBefore != "" && (task.Due == "" || task.Due >= f.DueBefore) {
+		return false
+	}
+	if len(f.Text) > 0 {
+		terms := search.Terms(task.Title, task.Description)
+		for _, term := range f.Text {
+			if !hasTerm(terms, term) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchesNothing reports whether no task can match the filter, so stores
+// can skip reading
+func (f TaskFilter) matchesNothing() bool {
+	return (f.Priorities != nil && len(f.Priorities) == 0) ||
+		(f.DueFrom != "" && f.DueBefore != "" && f.DueFrom >= f.DueBefore)
+}
+
+// QueryError is a query that cannot be parsed, pointing at the token at fault
+type QueryError struct {
+	// Position is the offset of the token in the query, in characters
+	// starting at 1
+	Position int
+	Token    string
+	Code     FieldErrorCode
+	Message  string
+}
+
+// Error returns the reason the query is invalid and where
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s at position %d: %s", e.Message, e.Position, e.Token)
+}
+
+// queryToken is a whitespace-separated part of a query
+type queryToken struct {
+	text string
+	// position is the offset of the token, in characters starting at 1
+	position int
+}
+
+// fail returns a QueryError for the token
+func (tok queryToken) fail(code FieldErrorCode, format string, args ...any) *QueryError {
+	return &QueryError{Position: tok.position, Token: tok.text, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ParseTaskQuery parses a query of whitespace-separated clauses that must
+// all hold. A clause is a field, an operator and a value, such as
+// status:open, label:bug, priority>=high or due<2026-11-01, or else a word
+// or a quoted phrase to find in the title or description. Only priority and
+// due support < <= > and >=.
+func ParseTaskQuery(query string) (TaskFilter, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return TaskFilter{}, err
+	}
+
+	var filter TaskFilter
+	for _, tok := range tokens {
+		field, op, value, ok := splitClause(tok.text)
+		if !ok {
+			if err := filter.addText(tok, unquote(tok.text)); err != nil {
+				return TaskFilter{}, err
+			}
+			continue
+		}
+		if field == "" {
+			return TaskFilter{}, tok.fail(FieldInvalidFormat, "missing field before %q", op)
+		}
+		if value == "" {
+			return TaskFilter{}, tok.fail(FieldInvalidFormat, "missing value for %s", field)
+		}
+
+		var err error
+		switch strings.ToLower(field) {
+		case "status":
+			err = filter.addStatus(tok, op, value)
+		case "priority":
+			err = filter.addPriority(tok, op, value)
+		case "label":
+			err = filter.addLabel(tok, op, value)
+		case "due":
+			err = filter.addDue(tok, op, value)
+		default:
+			err = tok.fail(FieldUnknown, "unknown field %q", field)
+		}
+		if err != nil {
+			return TaskFilter{}, err
+		}
+	}
+
+	return filter, nil
+}
+
+// tokenizeQuery splits a query on whitespace outside double quotes
+func tokenizeQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	var current strings.Builder
+	start, quoteStart := 0, 0
+	quoted := false
+
+	position := 0
+	for _, r := range query {
+		position++
+		switch {
+		case r == '"':
+			if !quoted {
+				quoteStart = position
+			}
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, queryToken{text: current.String(), position: start})
+				current.Reset()
+			}
+			continue
+		}
+		if current.Len() == 0 {
+			start = position
+		}
+		current.WriteRune(r)
+	}
+
+	if quoted {
+		tok := queryToken{text: current.String(), position: start}
+		return nil, tok.fail(FieldInvalidFormat, "unterminated quote at position %d", quoteStart)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, queryToken{text: current.String(), position: start})
+	}
+	return tokens, nil
+}
+
+// splitClause splits a token into a field, an operator and an unquoted
+// value. It reports false for a token without an operator before any
+// quote, which is text to find.
+func splitClause(text string) (field string, op queryOperator, value string, ok bool) {
+	i := strings.IndexAny(text, `:<>"`)
+	if i < 0 || text[i] == '"' {
+		return "", "", "", false
+	}
+
+	field, rest := text[:i], text[i:]
+	for _, candidate := range []queryOperator{opLessEqual, opGreaterEqual, opEqual, opLess, opGreater} {
+		if strings.HasPrefix(rest, string(candidate)) {
+			op = candidate
+			break
+		}
+	}
+	return field, op, unquote(rest[len(op):]), true
+}
+
+// unquote removes the double quotes from a value
+func unquote(value string) string {
+	return strings.ReplaceAll(value, `"`, "")
+}
+
+// requireEqual fails unless a clause uses ":"
+func requireEqual(tok queryToken, field string, op queryOperator) error {
+	if op != opEqual {
+		return tok.fail(FieldInvalidFormat, "%s only supports %q", field, opEqual)
+	}
+	return nil
+}
+
+// addStatus restricts the filter to a status
+func (f *TaskFilter) addStatus(tok queryToken, op queryOperator, value string) error {
+	if err := requireEqual(tok, "status", op); err != nil {
+		return err
+	}
+	status := TaskStatus(strings.ToUpper(value))
+	if status != TaskStatusOpen && status != TaskStatusClosed {
+		return tok.fail(FieldInvalidValue, "unknown status %q", value)
+	}
+	if f.Status != "" && f.Status != status {
+		return tok.fail(FieldInvalidValue, "conflicts with status:%s", strings.ToLower(string(f.Status)))
+	}
+	f.Status = status
+	return nil
+}
+
+// addPriority restricts the filter to the priorities that compare with a
+// priority as the operator requires
+func (f *TaskFilter) addPriority(tok queryToken, op queryOperator, value string) error {
+	rank := TaskPriority(strings.ToUpper(value)).rank()
+	if rank < 0 {
+		return tok.fail(FieldInvalidValue, "unknown priority %q", value)
+	}
+
+	matching := []TaskPriority{}
+	for i, priority := range taskPriorities {
+		if compareOrder(i, rank, op) {
+			matching = append(matching, priority)
+		}
+	}
+	if f.Priorities == nil {
+		f.Priorities = matching
+		return nil
+	}
+	f.Priorities = slices.DeleteFunc(f.Priorities, func(p TaskPriority) bool {
+		return !slices.Contains(matching, p)
+	})
+	return nil
+}
+
+// compareOrder reports whether a compares with b as the operator requires
+func compareOrder(a, b int, op queryOperator) bool {
+	switch op {
+	case opLess:
+		return a < b
+	case opLessEqual:
+		return a <= b
+	case opGreater:
+		return a > b
+	case opGreaterEqual:
+		return a >= b
+	default:
+		return a == b
+	}
+}
+
+// addLabel restricts the filter to tasks with a label
+func (f *TaskFilter) addLabel(tok queryToken, op queryOperator, value string) error {
+	if err := requireEqual(tok, "label", op); err != nil {
+		return err
+	}
+	label := strings.ToLower(value)
+	if !validLabel(label) {
+		return tok.fail(FieldInvalidValue, "invalid label %q", value)
+	}
+	if !slices.Contains(f.Labels, label) {
+		f.Labels = append(f.Labels, label)
+	}
+	return nil
+}
+
+// addDue narrows the filter's due bounds. A date stands for the whole day,
+// so due<=2026-11-01 matches times on that day; a time stands for its second.
+func (f *TaskFilter) addDue(tok queryToken, op queryOperator, value string) error {
+	start, next, ok := dueRange(value)
+	if !ok {
+		return tok.fail(FieldInvalidValue, "invalid due %q, expected a date such as 2026-11-01 or an RFC 3339 time", value)
+	}
+
+	from, before := "", ""
+	switch op {
+	case opEqual:
+		from, before = start, next
+	case opLess:
+		before = start
+	case opLessEqual:
+		before = next
+	case opGreater:
+		from = next
+	case opGreaterEqual:
+		from = start
+	}
+	if from != "" && from > f.DueFrom {
+		f.DueFrom = from
+	}
+	if before != "" && (f.DueBefore == "" || before < f.DueBefore) {
+		f.DueBefore = before
+	}
+	return nil
+}
+
+// dueRange returns the due value a date or time starts at, and the value
+// just after it, in the format validateDue stores
+func dueRange(value string) (start, next string, ok bool) {
+	if date, err := time.Parse(dueDateFormat, value); err == nil {
+		return date.Format(dueDateFormat), date.AddDate(0, 0, 1).Format(dueDateFormat), true
+	}
+	due, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", "", false
+	}
+	due = due.UTC().Truncate(time.Second)
+	return due.Format(time.RFC3339), due.Add(time.Second).Format(time.RFC3339), true
+}
+
+// addText adds the search terms of a word or phrase to the filter. Stop
+// words are ignored.
+func (f *TaskFilter) addText(tok queryToken, text string) error {
+	terms, err := search.ParseQuery(text)
+	var queryErr *search.QueryError
+	switch {
+	case errors.Is(err, search.ErrEmptyQuery):
+		return nil
+	case errors.As(err, &queryErr):
+		return tok.fail(FieldInvalidFormat, "prefix %q is too short", queryErr.Word)
+	case err != nil:
+		return tok.fail(FieldTooMany, "too many words")
+	}
+
+	for _, term := range terms {
+		if !slices.Contains(f.Text, term) {
+			f.Text = append(f.Text, term)
+		}
+	}
+	if len(f.Text) > search.MaxQueryTerms {
+		return tok.fail(FieldTooMany, "too many words, the limit is %d", search.MaxQueryTerms)
+	}
+	return nil
+}
+
+// queryLister lists an owner's tasks by status
+type queryLister interface {
+	ListOpen(ctx context.Context, owner string) ([]Task, error)
+	ListClosed(ctx context.Context, owner string) ([]Task, error)
+}
+
+// listMatching lists an owner's tasks that match a filter by listing the
+// tasks with its status, or both statuses, and filtering them in memory.
+// Open tasks come first, each status oldest change first.
+func listMatching(ctx context.Context, store queryLister, owner string, filter TaskFilter) ([]Task, error) {
+	matching := []Task{}
+	if filter.matchesNothing() {
+		return matching, nil
+	}
+
+	for _, status := range []TaskStatus{TaskStatusOpen, TaskStatusClosed} {
+		if filter.Status != "" && filter.Status != status {
+			continue
+		}
+		list := store.ListOpen
+		if status == TaskStatusClosed {
+			list = store.ListClosed
+		}
+		tasks, err := list(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range tasks {
+			if filter.Match(task) {
+				matching = append(matching, task)
+			}
+		}
+	}
+
+	return matching, nil
+}
+
+// queryField validates a query parameter and parses it into a filter,
+// reporting a QueryError as an error on the field with its position. It
+// reports false if the query is blank.
+func queryField(errs *ValidationErrors, field, query string) (TaskFilter, bool) {
+	// Only a copy is trimmed, so that positions count from the start of
+	// the query as sent
+	trimmed := query
+	before := len(*errs)
+	validateText(errs, field, &trimmed, false, maxQueryLength)
+	if len(*errs) > before || trimmed == "" {
+		return TaskFilter{}, false
+	}
+
+	filter, err := ParseTaskQuery(query)
+	var queryErr *QueryError
+	if errors.As(err, &queryErr) {
+		*errs = append(*errs, FieldError{
+			Field:    field,
+			Code:     queryErr.Code,
+			Message:  queryErr.Message,
+			Token:    queryErr.Token,
+			Position: queryErr.Position,
+		})
+	}
+	return filter, true
+}
+
+// hasTerm reports whether a query term matches any of a task's terms
+func hasTerm(terms map[string]int, term search.Term) bool {
+	for text := range terms {
+		if term.Matches(text) {
+			return true
+		}
+	}
+	return false
+}
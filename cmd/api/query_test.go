@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/search"
+)
+
+func TestParseTaskQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  TaskFilter
+	}{
+		{
+			name:  "every field",
+			query: `status:open label:bug priority>=high due<2026-11-01 "login"`,
+			want: TaskFilter{
+				Status:     TaskStatusOpen,
+				Labels:     []string{"bug"},
+				Priorities: []TaskPriority{TaskPriorityHigh, TaskPriorityUrgent},
+				DueBefore:  "2026-11-01",
+				Text:       []search.Term{{Text: "login"}},
+			},
+		},
+		{
+			name:  "case insensitive",
+			query: "STATUS:Closed Label:UI priority:LOW",
+			want:  TaskFilter{Status: TaskStatusClosed, Labels: []string{"ui"}, Priorities: []TaskPriority{TaskPriorityLow}},
+		},
+		{
+			name:  "priorities intersect",
+			query: "priority>low priority<=high",
+			want:  TaskFilter{Priorities: []TaskPriority{TaskPriorityNormal, TaskPriorityHigh}},
+		},
+		{
+			name:  "no priority matches",
+			query: "priority<low",
+			want:  TaskFilter{Priorities: []TaskPriority{}},
+		},
+		{
+			name:  "due dates cover the whole day",
+			query: "due>2026-10-01 due<=2026-10-31",
+			want:  TaskFilter{DueFrom: "2026-10-02", DueBefore: "2026-11-01"},
+		},
+		{
+			name:  "due on a day",
+			query: "due:2026-10-31",
+			want:  TaskFilter{DueFrom: "2026-10-31", DueBefore: "2026-11-01"},
+		},
+		{
+			name:  "due times in UTC",
+			query: "due>=2026-10-31T09:00:00+02:00",
+			want:  TaskFilter{DueFrom: "2026-10-31T07:00:00Z"},
+		},
+		{
+			name:  "phrases, prefixes and stop words",
+			query: `"login page" fail* the`,
+			want:  TaskFilter{Text: []search.Term{{Text: "login"}, {Text: "page"}, {Text: "fail", Prefix: true}}},
+		},
+		{
+			name:  "quoted value",
+			query: `label:"bug"`,
+			want:  TaskFilter{Labels: []string{"bug"}},
+		},
+		{
+			name:  "empty",
+			query: "  ",
+			want:  TaskFilter{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			filter, err := ParseTaskQuery(tt.query)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(filter, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, filter)
+			}
+		})
+	}
+}
+
+func TestParseTaskQueryInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		token    string
+		position int
+		code     FieldErrorCode
+	}{
+		{"unknown field", "status:open lable:bug", "lable:bug", 13, FieldUnknown},
+		{"unknown status", "status:done", "status:done", 1, FieldInvalidValue},
+		{"conflicting status", "status:open status:closed", "status:closed", 13, FieldInvalidValue},
+		{"unknown priority", "priority>=critical", "priority>=critical", 1, FieldInvalidValue},
+		{"ordered label", "label>bug", "label>bug", 1, FieldInvalidFormat},
+		{"invalid label", "label:a/b", "label:a/b", 1, FieldInvalidValue},
+		{"invalid due", "login due<soon", "due<soon", 7, FieldInvalidValue},
+		{"missing value", "priority>=", "priority>=", 1, FieldInvalidFormat},
+		{"missing field", ":open", ":open", 1, FieldInvalidFormat},
+		{"unterminated quote", `login "fails on`, `"fails on`, 7, FieldInvalidFormat},
+		{"short prefix", "report r*", "r*", 8, FieldInvalidFormat},
+		{"positions count characters", "über lable:x", "lable:x", 6, FieldUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, err := ParseTaskQuery(tt.query)
+
+			// Assert
+			var queryErr *QueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("Expected a *QueryError, got %v", err)
+			}
+			if queryErr.Token != tt.token || queryErr.Position != tt.position || queryErr.Code != tt.code {
+				t.Errorf("Expected %s at %d (%s), got %s at %d (%s)",
+					tt.token, tt.position, tt.code, queryErr.Token, queryErr.Position, queryErr.Code)
+			}
+			if queryErr.Message == "" {
+				t.Error("Expected a message")
+			}
+		})
+	}
+}
+
+func TestTaskFilterMatch(t *testing.T) {
+	// Arrange
+	task := NewTask(uuid.New(), "Fix the login page", "test@example.com")
+	task.Description = "Logins fail on Safari"
+	task.Priority = TaskPriorityHigh
+	task.Labels = []string{"bug", "ui"}
+	task.Due = "2026-10-31T18:00:00Z"
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"status:open label:bug priority>=high due<2026-11-01 login", true},
+		{"status:closed", false},
+		{"label:bug label:ui", true},
+		{"label:bug label:backend", false},
+		{"priority:urgent", false},
+		{"due:2026-10-31", true},
+		{"due<2026-10-31", false},
+		{"due<=2026-10-31T18:00:00Z", true},
+		{"due>2026-10-31T18:00:00Z", false},
+		{`"failing logins" safa*`, true},
+		{"checkout", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			filter, err := ParseTaskQuery(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to parse query: %v", err)
+			}
+
+			// Act
+			got := filter.Match(task)
+
+			// Assert
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTaskFilterMatchWithoutDue(t *testing.T) {
+	// Arrange
+	task := NewTask(uuid.New(), "Someday", "test@example.com")
+
+	for _, query := range []string{"due<2026-11-01", "due>=2026-11-01", "priority:low", "label:bug"} {
+		filter, _ := ParseTaskQuery(query)
+
+		// Act
+		got := filter.Match(task)
+
+		// Assert
+		if got {
+			t.Errorf("Expected %q not to match a task without the field", query)
+		}
+	}
+}
+
+// queryTasksRequest builds a request listing test@example.com's tasks with
+// the given query parameters
+func queryTasksRequest(params map[string]string) events.APIGatewayProxyRequest {
+	query := map[string]string{"owner": "test@example.com"}
+	for name, value := range params {
+		query[name] = value
+	}
+	return events.APIGatewayProxyRequest{
+		Path:                  "/api/tasks/",
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: query,
+	}
+}
+
+func TestListTasksQuery(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	api := NewAPI(NewMockTaskStore())
+	bug := NewTask(uuid.New(), "Fix login", "test@example.com")
+	bug.Labels = []string{"bug"}
+	bug.Priority = TaskPriorityUrgent
+	bug.Due = "2026-10-20"
+	closedBug := NewTask(uuid.New(), "Fix logout", "test@example.com")
+	closedBug.Labels = []string{"bug"}
+	closedBug.Status = TaskStatusClosed
+	chore := NewTask(uuid.New(), "Update dependencies", "test@example.com")
+	chore.Priority = TaskPriorityLow
+	for _, task := range []Task{bug, closedBug, chore, NewTask(uuid.New(), "Fix login", "other@example.com")} {
+		_ = api.store.Add(ctx, task)
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   []string
+	}{
+		{"label across statuses", map[string]string{"q": "label:bug"}, []string{"Fix login", "Fix logout"}},
+		{"every clause", map[string]string{"q": `status:open label:bug priority>=high due<2026-11-01 "login"`}, []string{"Fix login"}},
+		{"status parameter", map[string]string{"q": "label:bug", "status": "CLOSED"}, []string{"Fix logout"}},
+		{"text", map[string]string{"q": "dependency"}, []string{"Update dependencies"}},
+		{"blank query lists open tasks", map[string]string{"q": " "}, []string{"Fix login", "Update dependencies"}},
+		{"no match", map[string]string{"q": "priority:normal"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			response, err := api.HandleRequest(ctx, queryTasksRequest(tt.params))
+
+			// Assert
+			if err != nil || response.StatusCode != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d %s (%v)", http.StatusOK, response.StatusCode, response.Body, err)
+			}
+			var tasks []Task
+			if err := json.Unmarshal([]byte(response.Body), &tasks); err != nil {
+				t.Fatalf("Failed to parse response body: %v", err)
+			}
+			titles := []string{}
+			for _, task := range tasks {
+				titles = append(titles, task.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, titles)
+			}
+		})
+	}
+}
+
+func TestListTasksQueryInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   FieldError
+	}{
+		{
+			name:   "points at the token",
+			params: map[string]string{"q": "status:open lable:bug"},
+			want:   FieldError{Field: "q", Code: FieldUnknown, Message: `unknown field "lable"`, Token: "lable:bug", Position: 13},
+		},
+		{
+			name:   "positions count leading whitespace",
+			params: map[string]string{"q": "  priority>=critical"},
+			want:   FieldError{Field: "q", Code: FieldInvalidValue, Message: `unknown priority "critical"`, Token: "priority>=critical", Position: 3},
+		},
+		{
+			name:   "conflicting status parameter",
+			params: map[string]string{"q": "status:open", "status": "CLOSED"},
+			want:   FieldError{Field: "status", Code: FieldInvalidValue},
+		},
+		{
+			name:   "control characters",
+			params: map[string]string{"q": "login\x00"},
+			want:   FieldError{Field: "q", Code: FieldInvalidCharacters},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			api := NewAPI(NewMockTaskStore())
+
+			// Act
+			response, err := api.HandleRequest(context.Background(), queryTasksRequest(tt.params))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if response.StatusCode != http.StatusBadRequest {
+				t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, response.StatusCode)
+			}
+			var problem Problem
+			_ = json.Unmarshal([]byte(response.Body), &problem)
+			if len(problem.Errors) != 1 || problem.Errors[0] != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, problem.Errors)
+			}
+		})
+	}
+}
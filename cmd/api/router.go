@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// handlerFunc handles a routed request. Path parameters are available in
+// request.PathParameters.
+type handlerFunc func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// route maps a method and path pattern to a handler
+type route struct {
+	method   string
+	segments []string
+	handler  handlerFunc
+}
+
+// router dispatches requests to handlers by method and path. Patterns are
+// paths whose segments may be parameters written as {name}; a literal
+// segment always takes precedence over a parameter in the same position.
+type router struct {
+	routes []route
+}
+
+// handle registers a handler for a method and path pattern
+func (r *router) handle(method, pattern string, handler handlerFunc) {
+	r.routes = append(r.routes, route{
+		method:   method,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+// dispatch routes a request to its handler. It answers OPTIONS requests
+// and HEAD requests for GET routes itself, and returns 404 or 405 with an
+// Allow header when no handler matches.
+func (r *router) dispatch(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	segments := splitPath(request.Path)
+
+	// Find the most specific pattern matching the path
+	var best []string
+	var params map[string]string
+	for _, rt := range r.routes {
+		if p, ok := matchSegments(rt.segments, segments); ok && (best == nil || moreSpecific(rt.segments, best)) {
+			best, params = rt.segments, p
+		}
+	}
+	if best == nil {
+		return problemResponse(request, http.StatusNotFound, CodeNotFound, "Not Found")
+	}
+
+	// Find the handlers registered for that pattern
+	handlers := make(map[string]handlerFunc)
+	for _, rt := range r.routes {
+		if slices.Equal(rt.segments, best) {
+			handlers[rt.method] = rt.handler
+		}
+	}
+
+	request.PathParameters = params
+
+	if handler, ok := handlers[request.HTTPMethod]; ok {
+		return handler(ctx, request)
+	}
+
+	switch request.HTTPMethod {
+	case http.MethodHead:
+		if handler, ok := handlers[http.MethodGet]; ok {
+			response, err := handler(ctx, request)
+			response.Body = ""
+			return response, err
+		}
+	case http.MethodOptions:
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNoContent,
+			Headers: map[string]string{
+				"Allow": allowHeader(handlers),
+			},
+		}, nil
+	}
+
+	return methodNotAllowed(request, allowHeader(handlers))
+}
+
+// allowHeader lists the methods supported by a set of handlers, including
+// the HEAD and OPTIONS methods the router provides
+func allowHeader(handlers map[string]handlerFunc) string {
+	methods := []string{http.MethodOptions}
+	for method := range handlers {
+		methods = append(methods, method)
+	}
+	if _, ok := handlers[http.MethodGet]; ok {
+		if _, ok := handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// requestHeader returns a request header, matching its name case-insensitively
+func requestHeader(request events.APIGatewayProxyRequest, name string) string {
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// splitPath splits a path into segments, ignoring leading, trailing and
+// repeated slashes so that "/api/tasks", "/api/tasks/" and "//api/tasks"
+// are treated the same
+func splitPath(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// isParam reports whether a pattern segment is a parameter
+func isParam(segment string) bool {
+	return len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}'
+}
+
+// matchSegments matches path segments against a pattern and returns the
+// parameter values
+func matchSegments(pattern, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, segment := range pattern {
+		switch {
+		case isParam(segment):
+			params[segment[1:len(segment)-1]] = segments[i]
+		case segment != segments[i]:
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// moreSpecific reports whether pattern a is more specific than pattern b,
+// which have the same length: at the first position where they differ, a
+// has a literal segment and b has a parameter
+func moreSpecific(a, b []string) bool {
+	for i := range a {
+		if isParam(a[i]) != isParam(b[i]) {
+			return !isParam(a[i])
+		}
+	}
+	return false
+}
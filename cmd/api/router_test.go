@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// namedHandler returns a handler that responds with its name and path parameters
+func namedHandler(name string) handlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		headers := map[string]string{}
+		for key, value := range request.PathParameters {
+			headers["X-Param-"+key] = value
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       name,
+			Headers:    headers,
+		}, nil
+	}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	// Arrange
+	r := &router{}
+	r.handle(http.MethodGet, "/api/tasks", namedHandler("listTasks"))
+	r.handle(http.MethodPost, "/api/tasks/", namedHandler("createTask"))
+	r.handle(http.MethodPost, "/api/tasks/batch", namedHandler("batchTasks"))
+	r.handle(http.MethodGet, "/api/tasks/{id}", namedHandler("getTask"))
+	r.handle(http.MethodGet, "/api/views/{id}/tasks", namedHandler("viewTasks"))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		body   string
+		params map[string]string
+		allow  string
+	}{
+		{name: "collection without slash", method: http.MethodGet, path: "/api/tasks", status: http.StatusOK, body: "listTasks"},
+		{name: "collection with slash", method: http.MethodGet, path: "/api/tasks/", status: http.StatusOK, body: "listTasks"},
+		{name: "repeated slashes", method: http.MethodGet, path: "//api//tasks/", status: http.StatusOK, body: "listTasks"},
+		{name: "pattern with slash", method: http.MethodPost, path: "/api/tasks", status: http.StatusOK, body: "createTask"},
+		{name: "literal beats parameter", method: http.MethodPost, path: "/api/tasks/batch", status: http.StatusOK, body: "batchTasks"},
+		{name: "path parameter", method: http.MethodGet, path: "/api/tasks/abc", status: http.StatusOK, body: "getTask", params: map[string]string{"id": "abc"}},
+		{name: "parameter with trailing slash", method: http.MethodGet, path: "/api/tasks/abc/", status: http.StatusOK, body: "getTask", params: map[string]string{"id": "abc"}},
+		{name: "nested parameter", method: http.MethodGet, path: "/api/views/v1/tasks", status: http.StatusOK, body: "viewTasks", params: map[string]string{"id": "v1"}},
+		{name: "extra segments", method: http.MethodGet, path: "/api/tasks/abc/anything", status: http.StatusNotFound},
+		{name: "unknown path", method: http.MethodGet, path: "/api/unknown", status: http.StatusNotFound},
+		{name: "head of get route", method: http.MethodHead, path: "/api/tasks/abc", status: http.StatusOK, body: ""},
+		{name: "options", method: http.MethodOptions, path: "/api/tasks", status: http.StatusNoContent, allow: "GET, HEAD, OPTIONS, POST"},
+		{name: "method not allowed", method: http.MethodDelete, path: "/api/tasks/abc", status: http.StatusMethodNotAllowed, allow: "GET, HEAD, OPTIONS"},
+		{name: "method not allowed on literal", method: http.MethodGet, path: "/api/tasks/batch", status: http.StatusMethodNotAllowed, allow: "OPTIONS, POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{HTTPMethod: tt.method, Path: tt.path}
+
+			// Act
+			response, err := r.dispatch(context.Background(), request)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if response.StatusCode != tt.status {
+				t.Errorf("Expected status code %d, got %d", tt.status, response.StatusCode)
+			}
+			if tt.status == http.StatusOK && response.Body != tt.body {
+				t.Errorf("Expected body '%s', got '%s'", tt.body, response.Body)
+			}
+			if response.Headers["Allow"] != tt.allow {
+				t.Errorf("Expected Allow header '%s', got '%s'", tt.allow, response.Headers["Allow"])
+			}
+			for key, value := range tt.params {
+				if got := response.Headers["X-Param-"+key]; got != value {
+					t.Errorf("Expected parameter %s to be '%s', got '%s'", key, value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/", nil},
+		{"", nil},
+		{"/api/tasks", []string{"api", "tasks"}},
+		{"/api/tasks/", []string{"api", "tasks"}},
+		{"api//tasks", []string{"api", "tasks"}},
+	}
+
+	for _, tt := range tests {
+		if got := splitPath(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPath(%q): expected %v, got %v", tt.path, tt.want, got)
+		}
+	}
+}
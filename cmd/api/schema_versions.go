@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+const (
+	// CurrentSchemaVersion is the schema version of the items this code writes
+	CurrentSchemaVersion = 6
+	// legacySchemaVersion is the version of items written before the
+	// schema_version attribute existed
+	legacySchemaVersion = 1
+)
+
+// schemaUpgrades maps each old schema version to the function that upgrades
+// an item from it to the next version. When changing the item format, bump
+// CurrentSchemaVersion, register an upgrade from the previous version here
+// and add a fixture for the previous version to the tests.
+var schemaUpgrades = map[int]func(dt *DynamoDBTask) error{
+	// Version 1 items have the same attributes as version 2, without schema_version
+	1: func(dt *DynamoDBTask) error {
+		return nil
+	},
+	// Version 2 items have keys without entity prefixes and no entity_type.
+	// Upgrading them changes their primary key, so writing one back moves it.
+	2: func(dt *DynamoDBTask) error {
+		id, err := uuid.Parse(dt.ID)
+		if err != nil {
+			return err
+		}
+		key := keys.Task(dt.Owner, id)
+		dt.PK, dt.SK = key.PK, key.SK
+		dt.GS1PK = keys.TaskStatus(dt.Owner, string(dt.Status))
+		dt.EntityType = keys.EntityTask
+		return nil
+	},
+	// Version 3 items have no Description, Due, Recurrence or UID, which
+	// are optional
+	3: func(dt *DynamoDBTask) error {
+		return nil
+	},
+	// Version 4 items have no Priority or Labels, which are optional
+	4: func(dt *DynamoDBTask) error {
+		return nil
+	},
+	// Version 5 items have no Created, Closed or Counted. Times that were
+	// not kept cannot be recovered, and the 0004-task-stats migration
+	// counts the tasks.
+	5: func(dt *DynamoDBTask) error {
+		return nil
+	},
+}
+
+// upgrade converts the item to CurrentSchemaVersion by applying each
+// upgrade in turn
+func (dt *DynamoDBTask) upgrade() error {
+	if dt.SchemaVersion == 0 {
+		dt.SchemaVersion = legacySchemaVersion
+	}
+	if dt.SchemaVersion > CurrentSchemaVersion {
+		return fmt.Errorf("item schema version %d is newer than the supported version %d", dt.SchemaVersion, CurrentSchemaVersion)
+	}
+
+	for dt.SchemaVersion < CurrentSchemaVersion {
+		upgrade, ok := schemaUpgrades[dt.SchemaVersion]
+		if !ok {
+			return fmt.Errorf("no upgrade from item schema version %d", dt.SchemaVersion)
+		}
+		if err := upgrade(dt); err != nil {
+			return fmt.Errorf("failed to upgrade item from schema version %d: %w", dt.SchemaVersion, err)
+		}
+		dt.SchemaVersion++
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+// fixtureTask is the task every schema version fixture stores
+var fixtureTask = Task{
+	ID:     uuid.MustParse("0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"),
+	Title:  "Test Task",
+	Status: TaskStatusClosed,
+	Owner:  "test@example.com",
+}
+
+// schemaFixtures are items as stored by each historical schema version
+var schemaFixtures = map[int]map[string]types.AttributeValue{
+	1: {
+		"PK":     &types.AttributeValueMemberS{Value: "#test@example.com"},
+		"SK":     &types.AttributeValueMemberS{Value: "#0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"GS1PK":  &types.AttributeValueMemberS{Value: "#test@example.com#CLOSED"},
+		"GS1SK":  &types.AttributeValueMemberS{Value: "#2024-01-01T00:00:00Z"},
+		"ID":     &types.AttributeValueMemberS{Value: "0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"Title":  &types.AttributeValueMemberS{Value: "Test Task"},
+		"Owner":  &types.AttributeValueMemberS{Value: "test@example.com"},
+		"Status": &types.AttributeValueMemberS{Value: "CLOSED"},
+	},
+	2: {
+		"PK":             &types.AttributeValueMemberS{Value: "#test@example.com"},
+		"SK":             &types.AttributeValueMemberS{Value: "#0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"GS1PK":          &types.AttributeValueMemberS{Value: "#test@example.com#CLOSED"},
+		"GS1SK":          &types.AttributeValueMemberS{Value: "#2024-01-01T00:00:00Z"},
+		"ID":             &types.AttributeValueMemberS{Value: "0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"Title":          &types.AttributeValueMemberS{Value: "Test Task"},
+		"Owner":          &types.AttributeValueMemberS{Value: "test@example.com"},
+		"Status":         &types.AttributeValueMemberS{Value: "CLOSED"},
+		"schema_version": &types.AttributeValueMemberN{Value: "2"},
+	},
+	3: {
+		"PK":             &types.AttributeValueMemberS{Value: "USER#test@example.com"},
+		"SK":             &types.AttributeValueMemberS{Value: "TASK#0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"GS1PK":          &types.AttributeValueMemberS{Value: "USER#test@example.com#STATUS#CLOSED"},
+		"GS1SK":          &types.AttributeValueMemberS{Value: "#2024-01-01T00:00:00Z"},
+		"ID":             &types.AttributeValueMemberS{Value: "0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"Title":          &types.AttributeValueMemberS{Value: "Test Task"},
+		"Owner":          &types.AttributeValueMemberS{Value: "test@example.com"},
+		"Status":         &types.AttributeValueMemberS{Value: "CLOSED"},
+		"entity_type":    &types.AttributeValueMemberS{Value: "TASK"},
+		"schema_version": &types.AttributeValueMemberN{Value: "3"},
+	},
+	4: {
+		"PK":             &types.AttributeValueMemberS{Value: "USER#test@example.com"},
+		"SK":             &types.AttributeValueMemberS{Value: "TASK#0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"GS1PK":          &types.AttributeValueMemberS{Value: "USER#test@example.com#STATUS#CLOSED"},
+		"GS1SK":          &types.AttributeValueMemberS{Value: "#2024-01-01T00:00:00Z"},
+		"ID":             &types.AttributeValueMemberS{Value: "0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"Title":          &types.AttributeValueMemberS{Value: "Test Task"},
+		"Owner":          &types.AttributeValueMemberS{Value: "test@example.com"},
+		"Status":         &types.AttributeValueMemberS{Value: "CLOSED"},
+		"entity_type":    &types.AttributeValueMemberS{Value: "TASK"},
+		"schema_version": &types.AttributeValueMemberN{Value: "4"},
+	},
+	5: {
+		"PK":             &types.AttributeValueMemberS{Value: "USER#test@example.com"},
+		"SK":             &types.AttributeValueMemberS{Value: "TASK#0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"GS1PK":          &types.AttributeValueMemberS{Value: "USER#test@example.com#STATUS#CLOSED"},
+		"GS1SK":          &types.AttributeValueMemberS{Value: "#2024-01-01T00:00:00Z"},
+		"ID":             &types.AttributeValueMemberS{Value: "0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"Title":          &types.AttributeValueMemberS{Value: "Test Task"},
+		"Owner":          &types.AttributeValueMemberS{Value: "test@example.com"},
+		"Status":         &types.AttributeValueMemberS{Value: "CLOSED"},
+		"entity_type":    &types.AttributeValueMemberS{Value: "TASK"},
+		"schema_version": &types.AttributeValueMemberN{Value: "5"},
+	},
+	6: {
+		"PK":             &types.AttributeValueMemberS{Value: "USER#test@example.com"},
+		"SK":             &types.AttributeValueMemberS{Value: "TASK#0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"GS1PK":          &types.AttributeValueMemberS{Value: "USER#test@example.com#STATUS#CLOSED"},
+		"GS1SK":          &types.AttributeValueMemberS{Value: "#2024-01-01T00:00:00Z"},
+		"ID":             &types.AttributeValueMemberS{Value: "0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90"},
+		"Title":          &types.AttributeValueMemberS{Value: "Test Task"},
+		"Owner":          &types.AttributeValueMemberS{Value: "test@example.com"},
+		"Status":         &types.AttributeValueMemberS{Value: "CLOSED"},
+		"Created":        &types.AttributeValueMemberS{Value: "2023-12-01T00:00:00Z"},
+		"Closed":         &types.AttributeValueMemberS{Value: "2024-01-01T00:00:00Z"},
+		"Counted":        &types.AttributeValueMemberBOOL{Value: true},
+		"entity_type":    &types.AttributeValueMemberS{Value: "TASK"},
+		"schema_version": &types.AttributeValueMemberN{Value: "6"},
+	},
+}
+
+func TestSchemaVersionsHaveUpgradesAndFixtures(t *testing.T) {
+	for version := legacySchemaVersion; version <= CurrentSchemaVersion; version++ {
+		if _, ok := schemaFixtures[version]; !ok {
+			t.Errorf("Expected a fixture for schema version %d", version)
+		}
+		if _, ok := schemaUpgrades[version]; !ok && version < CurrentSchemaVersion {
+			t.Errorf("Expected an upgrade from schema version %d", version)
+		}
+	}
+}
+
+func TestToTaskUpgradesEveryVersion(t *testing.T) {
+	for version, item := range schemaFixtures {
+		t.Run(strconv.Itoa(version), func(t *testing.T) {
+			// Arrange
+			var dbTask DynamoDBTask
+			if err := attributevalue.UnmarshalMap(item, &dbTask); err != nil {
+				t.Fatalf("Failed to unmarshal fixture: %v", err)
+			}
+
+			// Act
+			task, err := dbTask.ToTask()
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(task, fixtureTask) {
+				t.Errorf("Expected %+v, got %+v", fixtureTask, task)
+			}
+			if dbTask.SchemaVersion != CurrentSchemaVersion {
+				t.Errorf("Expected the item to be upgraded to version %d, got %d", CurrentSchemaVersion, dbTask.SchemaVersion)
+			}
+			if dbTask.GS1SK != "#2024-01-01T00:00:00Z" {
+				t.Errorf("Expected the upgrade to keep GS1SK, got %s", dbTask.GS1SK)
+			}
+			current := schemaFixtures[CurrentSchemaVersion]
+			for _, name := range []string{"PK", "SK", "GS1PK"} {
+				want := current[name].(*types.AttributeValueMemberS).Value
+				if got := map[string]string{"PK": dbTask.PK, "SK": dbTask.SK, "GS1PK": dbTask.GS1PK}[name]; got != want {
+					t.Errorf("Expected %s %q, got %q", name, want, got)
+				}
+			}
+			if dbTask.EntityType != keys.EntityTask {
+				t.Errorf("Expected entity type %s, got %q", keys.EntityTask, dbTask.EntityType)
+			}
+		})
+	}
+}
+
+func TestToTaskRejectsNewerVersion(t *testing.T) {
+	// Arrange
+	dbTask := ToDynamoDBTask(fixtureTask)
+	dbTask.SchemaVersion = CurrentSchemaVersion + 1
+
+	// Act
+	_, err := dbTask.ToTask()
+
+	// Assert
+	if err == nil {
+		t.Error("Expected an error for a newer schema version")
+	}
+}
+
+func TestToDynamoDBTaskWritesCurrentVersion(t *testing.T) {
+	// Act
+	item, err := attributevalue.MarshalMap(ToDynamoDBTask(fixtureTask))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	version, ok := item["schema_version"].(*types.AttributeValueMemberN)
+	if !ok || version.Value != strconv.Itoa(CurrentSchemaVersion) {
+		t.Errorf("Expected schema_version %d, got %v", CurrentSchemaVersion, item["schema_version"])
+	}
+}
+
+func TestWriteBackInput(t *testing.T) {
+	tests := []struct {
+		name           string
+		storedRevision int64
+		condition      string
+		revision       string
+	}{
+		{"unrevised", 0, "attribute_exists(PK) AND attribute_not_exists(#revision)", "1"},
+		{"revised", 2, "attribute_exists(PK) AND #revision = :revision", "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			store := &TaskStore{tableName: "tasks"}
+			upgraded := ToDynamoDBTask(fixtureTask)
+			stored := upgraded
+			stored.SchemaVersion = 2
+			stored.Revision = tt.storedRevision
+
+			// Act
+			input, err := store.writeBackInput(stored, upgraded)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if *input.ConditionExpression != tt.condition {
+				t.Errorf("Expected condition %q, got %q", tt.condition, *input.ConditionExpression)
+			}
+			if version, ok := input.Item["schema_version"].(*types.AttributeValueMemberN); !ok || version.Value != strconv.Itoa(CurrentSchemaVersion) {
+				t.Errorf("Expected the upgraded version to be written, got %v", input.Item["schema_version"])
+			}
+			if revision, ok := input.Item["Revision"].(*types.AttributeValueMemberN); !ok || revision.Value != tt.revision {
+				t.Errorf("Expected revision %s to be written, got %v", tt.revision, input.Item["Revision"])
+			}
+		})
+	}
+}
+
+func TestMoveInput(t *testing.T) {
+	// Arrange
+	store := &TaskStore{tableName: "tasks"}
+	var stored DynamoDBTask
+	_ = attributevalue.UnmarshalMap(schemaFixtures[1], &stored)
+	upgraded := stored
+	_, _ = upgraded.ToTask()
+
+	// Act
+	input, err := store.moveInput(stored, upgraded)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(input.TransactItems) != 2 || input.TransactItems[0].Put == nil || input.TransactItems[1].Delete == nil {
+		t.Fatalf("Expected a put and a delete, got %+v", input.TransactItems)
+	}
+	put, del := input.TransactItems[0].Put, input.TransactItems[1].Delete
+	if got := keys.FromItem(put.Item); got != keys.Task(fixtureTask.Owner, fixtureTask.ID) {
+		t.Errorf("Expected the item to be put under its new key, got %+v", got)
+	}
+	if gs1sk, ok := put.Item["GS1SK"].(*types.AttributeValueMemberS); !ok || gs1sk.Value != "#2024-01-01T00:00:00Z" {
+		t.Errorf("Expected GS1SK to be kept, got %v", put.Item["GS1SK"])
+	}
+	if movedFrom, ok := put.Item[keys.MovedFromAttribute].(*types.AttributeValueMemberS); !ok || movedFrom.Value != stored.PK {
+		t.Errorf("Expected the item to be marked as moved from %s, got %v", stored.PK, put.Item[keys.MovedFromAttribute])
+	}
+	if *put.ConditionExpression != "attribute_not_exists(PK)" {
+		t.Errorf("Expected the put to require a free key, got %q", *put.ConditionExpression)
+	}
+	if got := keys.FromItem(del.Key); got != keys.LegacyTask(fixtureTask.Owner, fixtureTask.ID) {
+		t.Errorf("Expected the legacy item to be deleted, got %+v", got)
+	}
+	if want := "attribute_exists(PK) AND attribute_not_exists(#revision)"; *del.ConditionExpression != want {
+		t.Errorf("Expected condition %q, got %q", want, *del.ConditionExpression)
+	}
+}
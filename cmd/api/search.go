@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/user/tasks-api/internal/search"
+)
+
+const (
+	// defaultSearchLimit is the number of results per page unless the
+	// request sets a limit
+	defaultSearchLimit = 20
+	// maxSearchLimit is the largest number of results per page
+	maxSearchLimit = 100
+	// maxQueryLength is the maximum length of a search query, in characters
+	maxQueryLength = 500
+)
+
+// SearchResult is a task matching a search, with its relevance score
+type SearchResult struct {
+	Task  Task    `json:"task"`
+	Score float64 `json:"score"`
+}
+
+// SearchResponse is a page of search results, best match first
+type SearchResponse struct {
+	Results []SearchResult `json:"results"`
+	// Total is the number of tasks matching the query, across every page
+	Total int `json:"total"`
+	// NextCursor fetches the next page; it is omitted on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// offsetCursor is the position of a page of results that are computed
+// again for every page, such as search results, so a task written between
+// pages can move across the boundary
+type offsetCursor struct {
+	Offset int `json:"offset"`
+}
+
+// encodeOffsetCursor returns the opaque cursor of the page starting at offset
+func encodeOffsetCursor(offset int) string {
+	b, _ := json.Marshal(offsetCursor{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeOffsetCursor returns the offset of a cursor made by encodeOffsetCursor
+func decodeOffsetCursor(cursor string) (int, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+	var c offsetCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Offset < 0 {
+		return 0, false
+	}
+	return c.Offset, true
+}
+
+// validateCursor parses an optional cursor made by encodeOffsetCursor,
+// returning 0 for the first page
+func validateCursor(errs *ValidationErrors, field, value string) int {
+	if value == "" {
+		return 0
+	}
+	offset, ok := decodeOffsetCursor(value)
+	if !ok {
+		errs.add(field, FieldInvalidFormat)
+	}
+	return offset
+}
+
+// validateLimit parses an optional page size between 1 and max
+func validateLimit(errs *ValidationErrors, field, value string, defaultLimit, max int) int {
+	if value == "" {
+		return defaultLimit
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil {
+		errs.add(field, FieldInvalidFormat)
+		return 0
+	}
+	if limit < 1 || limit > max {
+		errs.add(field, FieldInvalidValue)
+		return 0
+	}
+	return limit
+}
+
+// searchTasks finds an owner's tasks whose title or description contains
+// every word of the query, best match first
+func (api *API) searchTasks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Get the owner, query and page from the query parameters
+	var errs ValidationErrors
+	params := request.QueryStringParameters
+	owner := params["owner"]
+	validateText(&errs, "owner", &owner, true, maxOwnerLength)
+	query := params["q"]
+	validateText(&errs, "q", &query, true, maxQueryLength)
+	limit := validateLimit(&errs, "limit", params["limit"], defaultSearchLimit, maxSearchLimit)
+	offset := validateCursor(&errs, "cursor", params["cursor"])
+
+	var terms []search.Term
+	if query != "" {
+		var err error
+		terms, err = search.ParseQuery(query)
+		var queryErr *search.QueryError
+		switch {
+		case errors.Is(err, search.ErrEmptyQuery):
+			errs.add("q", FieldInvalidValue)
+		case errors.Is(err, search.ErrTooManyTerms):
+			errs.add("q", FieldTooMany)
+		case errors.As(err, &queryErr):
+			errs.add("q", FieldInvalidFormat)
+		}
+	}
+
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	// Rank the tasks matching every term
+	postings, total, err := api.store.SearchTerms(ctx, owner, terms)
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to search tasks")
+	}
+	ranked := search.Rank(postings, total)
+
+	// Get the tasks on this page
+	page := ranked[min(offset, len(ranked)):min(offset+limit, len(ranked))]
+	keys := make([]TaskKey, len(page))
+	for i, result := range page {
+		keys[i] = TaskKey{Owner: owner, ID: result.ID}
+	}
+	tasks, err := api.store.BatchGet(ctx, keys)
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to get tasks")
+	}
+
+	response := SearchResponse{Results: []SearchResult{}, Total: len(ranked)}
+	for i, result := range page {
+		// A task deleted since it was ranked is left out
+		if task, ok := tasks[keys[i]]; ok {
+			response.Results = append(response.Results, SearchResult{Task: task, Score: result.Score})
+		}
+	}
+	if offset+limit < len(ranked) {
+		response.NextCursor = encodeOffsetCursor(offset + limit)
+	}
+
+	// Marshal the results to JSON
+	body, err := json.Marshal(response)
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to marshal search results")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// searchRequest builds a search request with the given query parameters,
+// for test@example.com unless they set an owner
+func searchRequest(params map[string]string) events.APIGatewayProxyRequest {
+	query := map[string]string{"owner": "test@example.com"}
+	for name, value := range params {
+		query[name] = value
+	}
+	return events.APIGatewayProxyRequest{
+		Path:                  "/api/tasks/search",
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: query,
+	}
+}
+
+// searchTitles searches through the API and returns the matching titles
+func searchTitles(t *testing.T, api *API, params map[string]string) ([]string, SearchResponse) {
+	t.Helper()
+	response, err := api.HandleRequest(context.Background(), searchRequest(params))
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to search: %d %s %v", response.StatusCode, response.Body, err)
+	}
+	var results SearchResponse
+	if err := json.Unmarshal([]byte(response.Body), &results); err != nil {
+		t.Fatalf("Failed to unmarshal search results: %v", err)
+	}
+	titles := []string{}
+	for _, result := range results.Results {
+		titles = append(titles, result.Task.Title)
+	}
+	return titles, results
+}
+
+func TestSearchTasks(t *testing.T) {
+	// Arrange
+	api := newExportAPI(t, "Fix login page", "Write quarterly report", "Review report template")
+	described := NewTask(uuid.New(), "Call the bank", "test@example.com")
+	described.Description = "Ask why the login to online banking fails"
+	_ = api.store.Add(context.Background(), described)
+	_ = api.store.Add(context.Background(), NewTask(uuid.New(), "Fix login", "other@example.com"))
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"title before description", "login", []string{"Fix login page", "Call the bank"}},
+		{"stemming", "failing", []string{"Call the bank"}},
+		{"every word", "report quarterly", []string{"Write quarterly report"}},
+		{"prefix", "templ*", []string{"Review report template"}},
+		{"case insensitive", "LOGIN PAGE", []string{"Fix login page"}},
+		{"no match", "groceries", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			titles, results := searchTitles(t, api, map[string]string{"q": tt.query})
+
+			// Assert
+			if len(titles) != len(tt.want) {
+				t.Fatalf("Expected %q, got %q", tt.want, titles)
+			}
+			for i := range titles {
+				if titles[i] != tt.want[i] {
+					t.Errorf("Expected %q, got %q", tt.want, titles)
+					break
+				}
+			}
+			if results.Total != len(tt.want) {
+				t.Errorf("Expected a total of %d, got %d", len(tt.want), results.Total)
+			}
+			if results.NextCursor != "" {
+				t.Errorf("Expected no next cursor, got %q", results.NextCursor)
+			}
+		})
+	}
+}
+
+func TestSearchTasksPagination(t *testing.T) {
+	// Arrange
+	api := newExportAPI(t, "Report one", "Report two", "Report three", "Report four", "Report five")
+
+	// Act: page through the results two at a time
+	var titles []string
+	params := map[string]string{"q": "report", "limit": "2"}
+	pages := 0
+	for {
+		page, results := searchTitles(t, api, params)
+		titles = append(titles, page...)
+		pages++
+		if results.Total != 5 {
+			t.Errorf("Expected a total of 5, got %d", results.Total)
+		}
+		if results.NextCursor == "" || pages > 5 {
+			break
+		}
+		params["cursor"] = results.NextCursor
+	}
+
+	// Assert
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+	seen := make(map[string]bool)
+	for _, title := range titles {
+		seen[title] = true
+	}
+	if len(titles) != 5 || len(seen) != 5 {
+		t.Errorf("Expected every task exactly once, got %q", titles)
+	}
+}
+
+func TestSearchTasksInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]string
+		field  string
+		code   FieldErrorCode
+	}{
+		{"missing query", map[string]string{}, "q", FieldRequired},
+		{"only stop words", map[string]string{"q": "the and"}, "q", FieldInvalidValue},
+		{"short prefix", map[string]string{"q": "r*"}, "q", FieldInvalidFormat},
+		{"too many terms", map[string]string{"q": "a1 b2 c3 d4 e5 f6 g7 h8 i9 j10 k11"}, "q", FieldTooMany},
+		{"missing owner", map[string]string{"q": "report", "owner": ""}, "owner", FieldRequired},
+		{"limit not a number", map[string]string{"q": "report", "limit": "ten"}, "limit", FieldInvalidFormat},
+		{"limit too large", map[string]string{"q": "report", "limit": "101"}, "limit", FieldInvalidValue},
+		{"invalid cursor", map[string]string{"q": "report", "cursor": "!"}, "cursor", FieldInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			api := NewAPI(NewMockTaskStore())
+
+			// Act
+			response, err := api.HandleRequest(context.Background(), searchRequest(tt.params))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if response.StatusCode != http.StatusBadRequest {
+				t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, response.StatusCode)
+			}
+			var problem Problem
+			_ = json.Unmarshal([]byte(response.Body), &problem)
+			if len(problem.Errors) != 1 || problem.Errors[0].Field != tt.field || problem.Errors[0].Code != tt.code {
+				t.Errorf("Expected %s %s, got %+v", tt.field, tt.code, problem.Errors)
+			}
+		})
+	}
+}
+
+func TestSearchTasksStoreError(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	store.InjectFault(MockOpSearch, MockFault{Err: ErrThrottled})
+	api := NewAPI(store)
+
+	// Act
+	response, err := api.HandleRequest(context.Background(), searchRequest(map[string]string{"q": "report"}))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, response.StatusCode)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/user/tasks-api/internal/stats"
+)
+
+const (
+	// defaultStatsWeeks is the number of weeks of throughput reported unless
+	// the request sets weeks
+	defaultStatsWeeks = 12
+	// maxStatsWeeks is the largest number of weeks of throughput reported
+	maxStatsWeeks = 52
+)
+
+// WeekCount is the number of tasks closed in an ISO week
+type WeekCount struct {
+	// Week is the ISO 8601 week, such as 2026-W42
+	Week string `json:"week"`
+	// Start is the date of the week's Monday
+	Start  string `json:"start"`
+	Closed int64  `json:"closed"`
+}
+
+// StatsResponse summarizes an owner's tasks
+type StatsResponse struct {
+	Owner      string           `json:"owner"`
+	Total      int64            `json:"total"`
+	ByStatus   map[string]int64 `json:"byStatus"`
+	ByPriority map[string]int64 `json:"byPriority"`
+	// ByLabel only has the labels of at least one task
+	ByLabel map[string]int64 `json:"byLabel"`
+	// ClosedPerWeek has the tasks closed in each of the last weeks, oldest
+	// first and ending with the current week
+	ClosedPerWeek []WeekCount `json:"closedPerWeek"`
+	// AverageSecondsToClose is the average time from creation to closing of
+	// the closed tasks whose creation time is known; it is omitted if there
+	// are none
+	AverageSecondsToClose *int64 `json:"averageSecondsToClose,omitempty"`
+}
+
+// newStatsResponse builds the summary of an owner's counters, with
+// throughput for the weeks ending with now's
+func newStatsResponse(owner string, counters stats.Counters, weeks int, now time.Time) StatsResponse {
+	response := StatsResponse{
+		Owner:      owner,
+		ByStatus:   map[string]int64{},
+		ByPriority: map[string]int64{},
+		ByLabel:    counters.Group(stats.LabelPrefix),
+	}
+
+	// Report every status and priority, even those without tasks
+	for _, status := range []TaskStatus{TaskStatusOpen, TaskStatusClosed} {
+		response.ByStatus[string(status)] = 0
+	}
+	for _, priority := range []TaskPriority{TaskPriorityLow, TaskPriorityNormal, TaskPriorityHigh, TaskPriorityUrgent} {
+		response.ByPriority[string(priority)] = 0
+	}
+	for status, n := range counters.Group(stats.StatusPrefix) {
+		response.ByStatus[status] = n
+		response.Total += n
+	}
+	for priority, n := range counters.Group(stats.PriorityPrefix) {
+		response.ByPriority[priority] = n
+	}
+
+	// Weeks start on Monday
+	now = now.UTC()
+	monday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).
+		AddDate(0, 0, -(int(now.Weekday())+6)%7)
+	closed := counters.Group(stats.ClosedWeekPrefix)
+	for i := weeks - 1; i >= 0; i-- {
+		start := monday.AddDate(0, 0, -7*i)
+		week := stats.Week(start)
+		response.ClosedPerWeek = append(response.ClosedPerWeek, WeekCount{
+			Week:   week,
+			Start:  start.Format(time.DateOnly),
+			Closed: closed[week],
+		})
+	}
+
+	if timed := counters[stats.TimedCloses]; timed > 0 {
+		average := counters[stats.CloseSeconds] / timed
+		response.AverageSecondsToClose = &average
+	}
+
+	return response
+}
+
+// getStats summarizes an owner's tasks from the counters the store keeps,
+// without reading the tasks
+func (api *API) getStats(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Get the owner and number of weeks from the query parameters
+	var errs ValidationErrors
+	params := request.QueryStringParameters
+	owner := params["owner"]
+	validateText(&errs, "owner", &owner, true, maxOwnerLength)
+	weeks := validateLimit(&errs, "weeks", params["weeks"], defaultStatsWeeks, maxStatsWeeks)
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	counters, err := api.store.Stats(ctx, owner)
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to get stats")
+	}
+
+	// Marshal the stats to JSON
+	body, err := json.Marshal(newStatsResponse(owner, counters, weeks, time.Now()))
+	if err != nil {
+		return errorResponse(request, err, "Task", "Failed to marshal stats")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/stats"
+)
+
+func TestNewStatsResponse(t *testing.T) {
+	// Arrange: a Sunday, the last day of 2026-W42
+	now := time.Date(2026, 10, 18, 23, 30, 0, 0, time.UTC)
+	counters := stats.Counters{
+		"status#OPEN": 3, "status#CLOSED": 5, "priority#HIGH": 2, "label#bug": 4, "label#ui": 0,
+		"closed#2026-W40": 1, "closed#2026-W42": 4, "closed#2025-W42": 7,
+		stats.CloseSeconds: 3000, stats.TimedCloses: 4,
+	}
+
+	// Act
+	response := newStatsResponse("test@example.com", counters, 3, now)
+
+	// Assert
+	if response.Total != 8 {
+		t.Errorf("Expected 8 tasks, got %d", response.Total)
+	}
+	if want := map[string]int64{"LOW": 0, "NORMAL": 0, "HIGH": 2, "URGENT": 0}; !reflect.DeepEqual(response.ByPriority, want) {
+		t.Errorf("Expected %v, got %v", want, response.ByPriority)
+	}
+	if want := map[string]int64{"bug": 4}; !reflect.DeepEqual(response.ByLabel, want) {
+		t.Errorf("Expected %v, got %v", want, response.ByLabel)
+	}
+	want := []WeekCount{
+		{Week: "2026-W40", Start: "2026-09-28", Closed: 1},
+		{Week: "2026-W41", Start: "2026-10-05", Closed: 0},
+		{Week: "2026-W42", Start: "2026-10-12", Closed: 4},
+	}
+	if !reflect.DeepEqual(response.ClosedPerWeek, want) {
+		t.Errorf("Expected %+v, got %+v", want, response.ClosedPerWeek)
+	}
+	if response.AverageSecondsToClose == nil || *response.AverageSecondsToClose != 750 {
+		t.Errorf("Expected an average of 750 seconds, got %v", response.AverageSecondsToClose)
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	// Arrange
+	api := newViewsAPI(t)
+	request := events.APIGatewayProxyRequest{
+		Path:                  "/api/stats",
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: map[string]string{"owner": "test@example.com", "weeks": "2"},
+	}
+
+	// Act
+	response, err := api.HandleRequest(context.Background(), request)
+
+	// Assert
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s (%v)", http.StatusOK, response.StatusCode, response.Body, err)
+	}
+	var got StatsResponse
+	if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+		t.Fatalf("Failed to unmarshal stats: %v", err)
+	}
+	if want := map[string]int64{"OPEN": 3, "CLOSED": 1}; got.Total != 4 || !reflect.DeepEqual(got.ByStatus, want) {
+		t.Errorf("Expected %v, 4 in total, got %v, %d in total", want, got.ByStatus, got.Total)
+	}
+	if want := map[string]int64{"bug": 3}; !reflect.DeepEqual(got.ByLabel, want) {
+		t.Errorf("Expected %v, got %v", want, got.ByLabel)
+	}
+	if len(got.ClosedPerWeek) != 2 || got.ClosedPerWeek[1].Week != stats.Week(time.Now()) || got.ClosedPerWeek[1].Closed != 1 {
+		t.Errorf("Expected the closed task in the current week, got %+v", got.ClosedPerWeek)
+	}
+	if got.AverageSecondsToClose == nil {
+		t.Error("Expected an average time to close")
+	}
+}
+
+func TestGetStatsReflectsWrites(t *testing.T) {
+	// Arrange
+	api := NewAPI(NewMockTaskStore())
+	ctx := context.Background()
+	task := NewTask(uuid.New(), "Fix login", "test@example.com")
+	_ = api.store.Add(ctx, task)
+	_ = api.store.Transact(ctx, []TaskMutation{{Op: OperationClose, Task: task}})
+	request := events.APIGatewayProxyRequest{
+		Path:                  "/api/stats",
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: map[string]string{"owner": "test@example.com"},
+	}
+
+	// Act
+	response, _ := api.HandleRequest(ctx, request)
+	_ = api.store.BatchWrite(ctx, []TaskWrite{{Task: task, Op: OperationDelete}})
+	afterDelete, _ := api.HandleRequest(ctx, request)
+
+	// Assert
+	var closed, deleted StatsResponse
+	_ = json.Unmarshal([]byte(response.Body), &closed)
+	_ = json.Unmarshal([]byte(afterDelete.Body), &deleted)
+	if closed.ByStatus["CLOSED"] != 1 || closed.ByStatus["OPEN"] != 0 || len(closed.ClosedPerWeek) != defaultStatsWeeks {
+		t.Errorf("Expected one closed task over %d weeks, got %+v", defaultStatsWeeks, closed)
+	}
+	if deleted.Total != 0 || deleted.AverageSecondsToClose != nil {
+		t.Errorf("Expected no tasks after deleting, got %+v", deleted)
+	}
+}
+
+func TestGetStatsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   FieldError
+	}{
+		{"missing owner", map[string]string{}, FieldError{Field: "owner", Code: FieldRequired}},
+		{"too many weeks", map[string]string{"owner": "test@example.com", "weeks": "53"}, FieldError{Field: "weeks", Code: FieldInvalidValue}},
+		{"weeks not a number", map[string]string{"owner": "test@example.com", "weeks": "all"}, FieldError{Field: "weeks", Code: FieldInvalidFormat}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			api := NewAPI(NewMockTaskStore())
+			request := events.APIGatewayProxyRequest{
+				Path:                  "/api/stats",
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: tt.params,
+			}
+
+			// Act
+			response, err := api.HandleRequest(context.Background(), request)
+
+			// Assert
+			if err != nil || response.StatusCode != http.StatusBadRequest {
+				t.Fatalf("Expected status code %d, got %d (%v)", http.StatusBadRequest, response.StatusCode, err)
+			}
+			var problem Problem
+			_ = json.Unmarshal([]byte(response.Body), &problem)
+			if len(problem.Errors) != 1 || problem.Errors[0] != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, problem.Errors)
+			}
+		})
+	}
+}
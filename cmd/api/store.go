@@ -2,26 +2,275 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+	"github.com/user/tasks-api/internal/outbox"
+	"github.com/user/tasks-api/internal/search"
+	"github.com/user/tasks-api/internal/stats"
+	"github.com/user/tasks-api/internal/webhooks"
 )
 
+const (
+	// batchWriteLimit is the maximum number of requests in a single BatchWriteItem call
+	batchWriteLimit = 25
+	// batchGetLimit is the maximum number of keys in a single BatchGetItem call
+	batchGetLimit = 100
+	// maxBatchRetries is the number of times unprocessed items are retried
+	maxBatchRetries = 5
+	// baseBatchBackoff is the initial delay before retrying unprocessed items
+	baseBatchBackoff = 50 * time.Millisecond
+	// transactionLimit is the maximum number of items in a single TransactWriteItems call
+	transactionLimit = 100
+)
+
+const (
+	// TransactionReasonNotFound means the task a mutation refers to does not exist
+	TransactionReasonNotFound = "NotFound"
+	// TransactionReasonAlreadyExists means a created task already exists
+	TransactionReasonAlreadyExists = "AlreadyExists"
+	// TransactionReasonConditionFailed means a mutation's condition did not hold
+	TransactionReasonConditionFailed = "ConditionalCheckFailed"
+	// TransactionReasonConflict means another request modified a task concurrently
+	TransactionReasonConflict = "TransactionConflict"
+)
+
+// Store is the interface implemented by task stores
+type Store interface {
+	FeedTokenStore
+	SearchStore
+	ViewStore
+	WebhookStore
+
+	Add(ctx context.Context, task Task) error
+	GetByID(ctx context.Context, taskID uuid.UUID, owner string) (Task, error)
+	ListOpen(ctx context.Context, owner string) ([]Task, error)
+	ListClosed(ctx context.Context, owner string) ([]Task, error)
+	// QueryTasks lists an owner's tasks matching a filter, open tasks first
+	// and each status oldest change first
+	QueryTasks(ctx context.Context, owner string, filter TaskFilter) ([]Task, error)
+	BatchGet(ctx context.Context, keys []TaskKey) (map[TaskKey]Task, error)
+	// BatchWrite applies writes that need not succeed together, returning
+	// one error per write, which is nil if it succeeded. The Task of each
+	// write that succeeded is set to the task as written, or as it was
+	// before a delete.
+	BatchWrite(ctx context.Context, writes []TaskWrite) []error
+	Transact(ctx context.Context, mutations []TaskMutation) error
+	// Stats returns the counters summarizing an owner's tasks, which every
+	// write to a task keeps up to date
+	Stats(ctx context.Context, owner string) (stats.Counters, error)
+}
+
+// FeedTokenStore stores the tokens that authenticate calendar feeds. Only a
+// hash of each token is stored, so reading the store does not reveal tokens.
+type FeedTokenStore interface {
+	// PutFeedToken sets an owner's feed token, revoking any previous one
+	PutFeedToken(ctx context.Context, owner, hash string) error
+	// DeleteFeedToken revokes an owner's feed token, returning ErrNotFound if there is none
+	DeleteFeedToken(ctx context.Context, owner string) error
+	// FeedTokenOwner returns the owner of a feed token, or ErrNotFound
+	FeedTokenOwner(ctx context.Context, hash string) (string, error)
+}
+
+// SearchStore finds tasks by the terms of their titles and descriptions.
+// Stores keep an index of every task's terms, as returned by search.Terms,
+// up to date as tasks are written.
+type SearchStore interface {
+	// SearchTerms returns the postings of an owner's tasks matching each
+	// query term, and the number of the owner's indexed tasks
+	SearchTerms(ctx context.Context, owner string, terms []search.Term) ([][]search.Posting, int, error)
+}
+
+// ViewStore stores saved views, and which tasks have been seen through
+// each of them
+type ViewStore interface {
+	// PutView creates or replaces a view
+	PutView(ctx context.Context, view View) error
+	// GetView gets a view by owner and ID, or returns ErrNotFound
+	GetView(ctx context.Context, owner string, id uuid.UUID) (View, error)
+	// ListViews lists an owner's views in no particular order
+	ListViews(ctx context.Context, owner string) ([]View, error)
+	// DeleteView deletes a view and the record of the tasks seen through
+	// it, returning ErrNotFound if it does not exist
+	DeleteView(ctx context.Context, owner string, id uuid.UUID) error
+	// MarkSeen records that tasks were seen through a view
+	MarkSeen(ctx context.Context, owner string, viewID uuid.UUID, taskIDs []uuid.UUID) error
+	// SeenTasks returns the IDs of the tasks seen through a view
+	SeenTasks(ctx context.Context, owner string, viewID uuid.UUID) (map[uuid.UUID]bool, error)
+}
+
+// WebhookStore stores webhook subscriptions and the log of deliveries to
+// each of them. It is also the store the stream consumer dispatches task
+// events with.
+type WebhookStore interface {
+	// AddWebhook saves a new subscription
+	AddWebhook(ctx context.Context, subscription webhooks.Subscription) error
+	// GetWebhook gets a subscription by owner and ID, or returns ErrNotFound
+	GetWebhook(ctx context.Context, owner string, id uuid.UUID) (webhooks.Subscription, error)
+	// ListWebhooks lists an owner's subscriptions in no particular order
+	ListWebhooks(ctx context.Context, owner string) ([]webhooks.Subscription, error)
+	// DeleteWebhook deletes a subscription and its deliveries, returning
+	// ErrNotFound if it does not exist
+	DeleteWebhook(ctx context.Context, owner string, id uuid.UUID) error
+	// AddDelivery records a delivery to a subscription
+	AddDelivery(ctx context.Context, delivery webhooks.Delivery) error
+	// ListDeliveries lists up to limit of the latest deliveries to a
+	// subscription, newest first
+	ListDeliveries(ctx context.Context, owner string, webhookID uuid.UUID, limit int) ([]webhooks.Delivery, error)
+}
+
+// TaskKey identifies a task by owner and ID
+type TaskKey struct {
+	Owner string
+	ID    uuid.UUID
+}
+
+// TaskWrite is a single write in a batch write. With no Op, Task is put as
+// it is. OperationUpdate sets the stored task's title to Task's,
+// OperationClose closes the stored task and OperationDelete deletes it, so
+// these only need Task's owner and ID, and apply to the task as stored when
+// the write commits.
+type TaskWrite struct {
+	Op   OperationType
+	Task Task
+}
+
+// apply returns the task a write leaves over the stored task, which is nil
+// if there is none, or the task it deletes. Updates, closes and deletes of a
+// task that does not exist return ErrNotFound.
+func (w TaskWrite) apply(stored *Task) (Task, error) {
+	if w.Op == "" {
+		return w.Task, nil
+	}
+	if stored == nil {
+		return Task{}, ErrNotFound
+	}
+
+	task := *stored
+	switch w.Op {
+	case OperationUpdate:
+		task.Title = w.Task.Title
+	case OperationClose:
+		task.Status = TaskStatusClosed
+	case OperationDelete:
+	default:
+		return Task{}, fmt.Errorf("unknown operation: %q", w.Op)
+	}
+	return task, nil
+}
+
+// TaskCondition is an optional condition a task must meet for a mutation to apply
+type TaskCondition struct {
+	Status TaskStatus `json:"status,omitempty"`
+}
+
+// TaskMutation is a single write in a transaction. Create mutations carry the
+// full task; other mutations only need the owner and ID, plus the title for updates.
+type TaskMutation struct {
+	Op        OperationType
+	Task      Task
+	Condition *TaskCondition
+}
+
+// TransactionError reports which mutation caused a transaction to be cancelled
+type TransactionError struct {
+	Index   int
+	Reason  string
+	Message string
+}
+
+// Error implements the error interface
+func (e *TransactionError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("transaction cancelled by mutation %d: %s: %s", e.Index, e.Reason, e.Message)
+	}
+	return fmt.Sprintf("transaction cancelled by mutation %d: %s", e.Index, e.Reason)
+}
+
+// Unwrap returns the sentinel error matching the cancellation reason
+func (e *TransactionError) Unwrap() error {
+	switch e.Reason {
+	case TransactionReasonNotFound:
+		return ErrNotFound
+	case TransactionReasonAlreadyExists, TransactionReasonConditionFailed, TransactionReasonConflict:
+		return ErrConflict
+	case "ThrottlingError", "ProvisionedThroughputExceeded", "RequestLimitExceeded":
+		return ErrThrottled
+	}
+	return nil
+}
+
 // TaskStore handles operations on tasks in DynamoDB
 type TaskStore struct {
 	client    *dynamodb.Client
 	tableName string
+	// queryPageSize limits the items read per query page; zero means
+	// DynamoDB's 1 MB page limit. Tests set it to exercise pagination.
+	queryPageSize int32
+	// writeBackUpgrades saves items read in an older schema version back in
+	// the current version
+	writeBackUpgrades bool
+	// legacyKeys also reads tasks stored under the keys used before entity
+	// prefixes were introduced, and removes those items when the tasks are
+	// written. Disable it once the 0002-entity-keys migration has run.
+	legacyKeys bool
+}
+
+// HTTPClientConfig configures the HTTP client used to call DynamoDB
+type HTTPClientConfig struct {
+	// Timeout bounds each HTTP request, including reading the response
+	Timeout time.Duration
+	// DialTimeout bounds establishing a new connection
+	DialTimeout time.Duration
+	// IdleConnTimeout is how long an idle keep-alive connection is kept open
+	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost is the number of idle keep-alive connections kept per host
+	MaxIdleConnsPerHost int
+}
+
+// DefaultHTTPClientConfig returns the HTTP client settings used unless overridden
+func DefaultHTTPClientConfig() HTTPClientConfig {
+	return HTTPClientConfig{
+		Timeout:             5 * time.Second,
+		DialTimeout:         2 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+		MaxIdleConnsPerHost: 16,
+	}
+}
+
+// newHTTPClient builds an SDK HTTP client with keep-alive connections and timeouts
+func newHTTPClient(httpConfig HTTPClientConfig) *awshttp.BuildableClient {
+	return awshttp.NewBuildableClient().
+		WithTimeout(httpConfig.Timeout).
+		WithDialerOptions(func(d *net.Dialer) {
+			d.Timeout = httpConfig.DialTimeout
+			d.KeepAlive = 30 * time.Second
+		}).
+		WithTransportOptions(func(tr *http.Transport) {
+			tr.IdleConnTimeout = httpConfig.IdleConnTimeout
+			tr.MaxIdleConnsPerHost = httpConfig.MaxIdleConnsPerHost
+		})
 }
 
-// NewTaskStore creates a new TaskStore
-func NewTaskStore(tableName string) (*TaskStore, error) {
+// NewTaskStore creates a new TaskStore. The store holds a DynamoDB client
+// and is safe to share between requests and goroutines.
+func NewTaskStore(tableName string, httpConfig HTTPClientConfig) (*TaskStore, error) {
 	// Load the AWS SDK configuration
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithHTTPClient(newHTTPClient(httpConfig)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -30,71 +279,205 @@ func NewTaskStore(tableName string) (*TaskStore, error) {
 	client := dynamodb.NewFromConfig(cfg)
 
 	return &TaskStore{
-		client:    client,
-		tableName: tableName,
+		client:     client,
+		tableName:  tableName,
+		legacyKeys: true,
 	}, nil
 }
 
-// Add adds a task to DynamoDB
-func (ts *TaskStore) Add(ctx context.Context, task Task) error {
-	// Convert the task to a DynamoDB item
-	item := ToDynamoDBTask(task)
+var _ Store = (*TaskStore)(nil)
 
-	// Marshal the item to a map
-	av, err := attributevalue.MarshalMap(item)
-	if err != nil {
-		return fmt.Errorf("failed to marshal task: %w", err)
+// Add adds a task to DynamoDB, counting it in its owner's stats
+func (ts *TaskStore) Add(ctx context.Context, task Task) error {
+	if _, err := ts.writeTasks(ctx, []TaskWrite{{Task: task}}); err != nil {
+		return err
 	}
 
-	// Put the item in DynamoDB
-	_, err = ts.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(ts.tableName),
-		Item:      av,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to put task in DynamoDB: %w", err)
+	// The task has been written, so index failures are only logged
+	if err := ts.reindex(ctx, task.Owner, task.ID, &task); err != nil {
+		log.Printf("failed to update search index of task %s: %v", task.ID, err)
 	}
-
 	return nil
 }
 
 // GetByID gets a task by ID and owner
 func (ts *TaskStore) GetByID(ctx context.Context, taskID uuid.UUID, owner string) (Task, error) {
-	// Create the key
-	key := map[string]types.AttributeValue{
-		"PK": &types.AttributeValueMemberS{Value: "#" + owner},
-		"SK": &types.AttributeValueMemberS{Value: "#" + taskID.String()},
+	// Get the item from DynamoDB, falling back to its legacy key
+	item, err := ts.getItem(ctx, keys.Task(owner, taskID), false)
+	if err == nil && item == nil && ts.legacyKeys {
+		item, err = ts.getItem(ctx, keys.LegacyTask(owner, taskID), false)
 	}
-
-	// Get the item from DynamoDB
-	result, err := ts.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(ts.tableName),
-		Key:       key,
-	})
 	if err != nil {
-		return Task{}, fmt.Errorf("failed to get task from DynamoDB: %w", err)
+		return Task{}, err
 	}
 
 	// Check if the item exists
-	if result.Item == nil {
-		return Task{}, fmt.Errorf("task not found")
+	if item == nil {
+		return Task{}, ErrNotFound
 	}
 
 	// Unmarshal the item
 	var dbTask DynamoDBTask
-	if err := attributevalue.UnmarshalMap(result.Item, &dbTask); err != nil {
+	if err := attributevalue.UnmarshalMap(item, &dbTask); err != nil {
 		return Task{}, fmt.Errorf("failed to unmarshal task: %w", err)
 	}
 
 	// Convert to a Task
+	return ts.taskFromItem(ctx, dbTask)
+}
+
+// getItem gets an item by key, returning nil if it does not exist
+func (ts *TaskStore) getItem(ctx context.Context, key keys.Key, consistent bool) (map[string]types.AttributeValue, error) {
+	result, err := ts.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(ts.tableName),
+		Key:            key.Item(),
+		ConsistentRead: aws.Bool(consistent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task from DynamoDB: %w", storeError(err))
+	}
+	return result.Item, nil
+}
+
+// taskFromItem converts an item to a Task, upgrading it to the current
+// schema version and writing the upgraded item back if enabled
+func (ts *TaskStore) taskFromItem(ctx context.Context, dbTask DynamoDBTask) (Task, error) {
+	stored := dbTask
+
 	task, err := dbTask.ToTask()
 	if err != nil {
 		return Task{}, fmt.Errorf("failed to convert to task: %w", err)
 	}
 
+	if ts.writeBackUpgrades && dbTask.SchemaVersion != stored.SchemaVersion {
+		if err := ts.writeBack(ctx, stored, dbTask); err != nil {
+			log.Printf("failed to write back upgraded task %s: %v", dbTask.ID, err)
+		}
+	}
+
 	return task, nil
 }
 
+// writeBack saves an upgraded item, unless the stored item has changed since
+// it was read. Write-back failures are only logged by readers, since the
+// item is upgraded again on every read until a write-back succeeds.
+func (ts *TaskStore) writeBack(ctx context.Context, stored, upgraded DynamoDBTask) error {
+	if stored.PK != upgraded.PK || stored.SK != upgraded.SK {
+		return ts.move(ctx, stored, upgraded)
+	}
+
+	input, err := ts.writeBackInput(stored, upgraded)
+	if err != nil {
+		return err
+	}
+	_, err = ts.client.PutItem(ctx, input)
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &conditionFailed) {
+		return storeError(err)
+	}
+	return nil
+}
+
+// move saves an upgraded item under its new key and deletes the stored item
+// in one transaction. If an item already exists under the new key, it was
+// written since the stored item and the stored item is a stale copy.
+func (ts *TaskStore) move(ctx context.Context, stored, upgraded DynamoDBTask) error {
+	input, err := ts.moveInput(stored, upgraded)
+	if err != nil {
+		return err
+	}
+	_, err = ts.client.TransactWriteItems(ctx, input)
+
+	var cancelled *types.TransactionCanceledException
+	if !errors.As(err, &cancelled) {
+		return storeError(err)
+	}
+	if len(cancelled.CancellationReasons) == 0 || aws.ToString(cancelled.CancellationReasons[0].Code) != TransactionReasonConditionFailed {
+		// The stored item changed or was deleted since it was read
+		return nil
+	}
+
+	_, err = ts.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(ts.tableName),
+		Key:       keys.Key{PK: stored.PK, SK: stored.SK}.Item(),
+	})
+	return storeError(err)
+}
+
+// writeBackInput builds the put for an upgraded item whose key is unchanged
+func (ts *TaskStore) writeBackInput(stored, upgraded DynamoDBTask) (*dynamodb.PutItemInput, error) {
+	upgraded.Revision = stored.Revision + 1
+	av, err := attributevalue.MarshalMap(upgraded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	condition, names, values := unchangedCondition(stored)
+	return &dynamodb.PutItemInput{
+		TableName:                 aws.String(ts.tableName),
+		Item:                      av,
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}, nil
+}
+
+// moveInput builds the transaction that moves an upgraded item to its new
+// key, marking it as moved so that it is not taken for a new task
+func (ts *TaskStore) moveInput(stored, upgraded DynamoDBTask) (*dynamodb.TransactWriteItemsInput, error) {
+	upgraded.Revision = stored.Revision + 1
+	upgraded.MovedFrom = stored.PK
+	av, err := attributevalue.MarshalMap(upgraded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	condition, names, values := unchangedCondition(stored)
+	return &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName:           aws.String(ts.tableName),
+				Item:                av,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			}},
+			{Delete: &types.Delete{
+				TableName:                 aws.String(ts.tableName),
+				Key:                       keys.Key{PK: stored.PK, SK: stored.SK}.Item(),
+				ConditionExpression:       aws.String(condition),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			}},
+		},
+	}, nil
+}
+
+// unchangedCondition builds a condition that the stored item still exists
+// at the revision it was read at
+func unchangedCondition(stored DynamoDBTask) (string, map[string]string, map[string]types.AttributeValue) {
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+	condition := revisionCondition("attribute_exists(PK)", names, values, &stored)
+	return condition, names, values
+}
+
+// revisionCondition extends a condition so that it also fails unless the
+// item is at the revision of the stored item it was read as. If stored is
+// nil, there was no item, and the condition fails if there is one now.
+func revisionCondition(condition string, names map[string]string, values map[string]types.AttributeValue, stored *DynamoDBTask) string {
+	switch {
+	case stored == nil:
+		return condition + " AND attribute_not_exists(PK)"
+	case stored.Revision == 0:
+		names["#revision"] = stats.RevisionAttribute
+		return condition + " AND attribute_not_exists(#revision)"
+	default:
+		names["#revision"] = stats.RevisionAttribute
+		values[":revision"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(stored.Revision, 10)}
+		return condition + " AND #revision = :revision"
+	}
+}
+
 // ListOpen lists open tasks for an owner
 func (ts *TaskStore) ListOpen(ctx context.Context, owner string) ([]Task, error) {
 	return ts.listByStatus(ctx, owner, TaskStatusOpen)
@@ -107,53 +490,774 @@ func (ts *TaskStore) ListClosed(ctx context.Context, owner string) ([]Task, erro
 
 // listByStatus lists tasks by status for an owner
 func (ts *TaskStore) listByStatus(ctx context.Context, owner string, status TaskStatus) ([]Task, error) {
+	dbTasks, err := ts.queryGS1(ctx, keys.TaskStatus(owner, string(status)))
+	if err != nil {
+		return nil, err
+	}
+	if ts.legacyKeys {
+		legacy, err := ts.queryGS1(ctx, keys.LegacyTaskStatus(owner, string(status)))
+		if err != nil {
+			return nil, err
+		}
+		dbTasks = mergeLegacyTasks(dbTasks, legacy)
+	}
+
+	// Convert to Tasks
+	var tasks []Task
+	for _, dbTask := range dbTasks {
+		task, err := ts.taskFromItem(ctx, dbTask)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// queryGS1 reads every item with a GS1 partition key, in GS1SK order
+func (ts *TaskStore) queryGS1(ctx context.Context, gs1pk string) ([]DynamoDBTask, error) {
 	// Create the query input
 	input := &dynamodb.QueryInput{
 		TableName:              aws.String(ts.tableName),
 		IndexName:              aws.String("GS1"),
 		KeyConditionExpression: aws.String("GS1PK = :gspk"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":gspk": &types.AttributeValueMemberS{Value: "#" + owner + "#" + string(status)},
+			":gspk": &types.AttributeValueMemberS{Value: gs1pk},
 		},
 	}
+	if ts.queryPageSize > 0 {
+		input.Limit = aws.Int32(ts.queryPageSize)
+	}
 
-	// Query DynamoDB
-	var tasks []Task
-	var lastKey map[string]types.AttributeValue
+	return ts.queryTasks(ctx, input)
+}
+
+// mergeLegacyTasks merges two lists of items sorted by GS1SK, dropping
+// legacy items for tasks that also have a current item
+func mergeLegacyTasks(current, legacy []DynamoDBTask) []DynamoDBTask {
+	if len(legacy) == 0 {
+		return current
+	}
+
+	ids := make(map[string]bool, len(current))
+	for _, dbTask := range current {
+		ids[dbTask.ID] = true
+	}
+
+	merged := make([]DynamoDBTask, 0, len(current)+len(legacy))
+	i := 0
+	for _, dbTask := range legacy {
+		if ids[dbTask.ID] {
+			continue
+		}
+		for i < len(current) && current[i].GS1SK <= dbTask.GS1SK {
+			merged = append(merged, current[i])
+			i++
+		}
+		merged = append(merged, dbTask)
+	}
+	return append(merged, current[i:]...)
+}
+
+// BatchGet gets multiple tasks by key, omitting keys that do not exist
+func (ts *TaskStore) BatchGet(ctx context.Context, taskKeys []TaskKey) (map[TaskKey]Task, error) {
+	tasks := make(map[TaskKey]Task, len(taskKeys))
 
-	for {
-		// If there's a last evaluated key, use it
-		if lastKey != nil {
-			input.ExclusiveStartKey = lastKey
+	itemKeys := make([]keys.Key, len(taskKeys))
+	for i, key := range taskKeys {
+		itemKeys[i] = keys.Task(key.Owner, key.ID)
+	}
+	if err := ts.batchGet(ctx, itemKeys, tasks); err != nil {
+		return nil, err
+	}
+
+	// Look for the missing tasks under their legacy keys
+	if ts.legacyKeys {
+		var legacyKeys []keys.Key
+		for _, key := range taskKeys {
+			if _, ok := tasks[key]; !ok {
+				legacyKeys = append(legacyKeys, keys.LegacyTask(key.Owner, key.ID))
+			}
 		}
+		if err := ts.batchGet(ctx, legacyKeys, tasks); err != nil {
+			return nil, err
+		}
+	}
 
-		// Execute the query
-		result, err := ts.client.Query(ctx, input)
+	return tasks, nil
+}
+
+// batchGet gets the items with the given keys and adds them to tasks
+func (ts *TaskStore) batchGet(ctx context.Context, itemKeys []keys.Key, tasks map[TaskKey]Task) error {
+	items, err := ts.batchGetItems(ctx, itemKeys, false)
+	if err != nil {
+		return err
+	}
+
+	// Convert to Tasks
+	for _, key := range itemKeys {
+		item, ok := items[key]
+		if !ok {
+			continue
+		}
+		var dbTask DynamoDBTask
+		if err := attributevalue.UnmarshalMap(item, &dbTask); err != nil {
+			return fmt.Errorf("failed to unmarshal task: %w", err)
+		}
+		task, err := ts.taskFromItem(ctx, dbTask)
 		if err != nil {
-			return nil, fmt.Errorf("failed to query tasks: %w", err)
+			return err
 		}
+		tasks[TaskKey{Owner: task.Owner, ID: task.ID}] = task
+	}
 
-		// Unmarshal the items
-		var dbTasks []DynamoDBTask
-		if err := attributevalue.UnmarshalListOfMaps(result.Items, &dbTasks); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal tasks: %w", err)
+	return nil
+}
+
+// batchGetItems gets the items with the given keys, by key, omitting keys
+// that do not exist. The keys must be distinct.
+func (ts *TaskStore) batchGetItems(ctx context.Context, itemKeys []keys.Key, consistent bool) (map[keys.Key]map[string]types.AttributeValue, error) {
+	items := make(map[keys.Key]map[string]types.AttributeValue, len(itemKeys))
+	for start := 0; start < len(itemKeys); start += batchGetLimit {
+		end := min(start+batchGetLimit, len(itemKeys))
+
+		// Build the keys for this chunk
+		var avKeys []map[string]types.AttributeValue
+		for _, key := range itemKeys[start:end] {
+			avKeys = append(avKeys, key.Item())
+		}
+
+		request := map[string]types.KeysAndAttributes{
+			ts.tableName: {Keys: avKeys, ConsistentRead: aws.Bool(consistent)},
 		}
 
-		// Convert to Tasks
-		for _, dbTask := range dbTasks {
-			task, err := dbTask.ToTask()
+		// Get the items, retrying unprocessed keys with backoff
+		for attempt := 0; len(request) > 0; attempt++ {
+			if attempt > maxBatchRetries {
+				return nil, fmt.Errorf("failed to get items: unprocessed keys after %d retries: %w", maxBatchRetries, ErrThrottled)
+			}
+			if attempt > 0 {
+				if err := batchBackoff(ctx, attempt); err != nil {
+					return nil, err
+				}
+			}
+
+			result, err := ts.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: request,
+			})
 			if err != nil {
-				return nil, fmt.Errorf("failed to convert to task: %w", err)
+				return nil, fmt.Errorf("failed to batch get items from DynamoDB: %w", storeError(err))
+			}
+
+			for _, item := range result.Responses[ts.tableName] {
+				items[keys.FromItem(item)] = item
 			}
-			tasks = append(tasks, task)
+
+			request = result.UnprocessedKeys
+		}
+	}
+
+	return items, nil
+}
+
+// BatchWrite applies multiple writes. Consecutive writes are grouped into
+// transactions that also update each owner's stats once and append one
+// outbox entry per owner, since BatchWriteItem cannot do either. A failed
+// transaction fails each of its writes, except that writes of tasks that do
+// not exist fail on their own with ErrNotFound. The returned slice has one
+// entry per write, which is nil if the write succeeded.
+func (ts *TaskStore) BatchWrite(ctx context.Context, writes []TaskWrite) []error {
+	errs := make([]error, len(writes))
+	var updates []indexUpdate
+	for _, chunk := range writeChunks(writes, ts.legacyKeys) {
+		missing, err := ts.writeTasks(ctx, chunk.writes)
+		for i, write := range chunk.writes {
+			j := chunk.indexes[i]
+			switch {
+			case err != nil:
+				errs[j] = err
+				continue
+			case missing[i] != nil:
+				errs[j] = missing[i]
+				continue
+			}
+			writes[j].Task = write.Task
+			task := &write.Task
+			if write.Op == OperationDelete {
+				task = nil
+			}
+			updates = append(updates, indexUpdate{Owner: write.Task.Owner, ID: write.Task.ID, Task: task})
+		}
+	}
+
+	// Update the search index of the tasks that were written, logging
+	// failures since the writes have committed
+	if err := ts.reindexTasks(ctx, updates); err != nil {
+		log.Printf("failed to update search index of %d tasks: %v", len(updates), err)
+	}
+
+	return errs
+}
+
+// Transact applies all mutations atomically. If DynamoDB cancels the
+// transaction, the returned error is a *TransactionError identifying the
+// mutation responsible.
+func (ts *TaskStore) Transact(ctx context.Context, mutations []TaskMutation) error {
+	if len(mutations) > transactionLimit {
+		return fmt.Errorf("too many mutations in transaction: %d, the maximum is %d", len(mutations), transactionLimit)
+	}
+
+	// Tasks must be under their current keys for the conditions to see them
+	if ts.legacyKeys {
+		if err := ts.moveLegacyTasks(ctx, mutations); err != nil {
+			return err
+		}
+	}
+
+	// Build the transaction from the stored tasks, and build it again if
+	// they change before it commits
+	for attempt := 1; ; attempt++ {
+		stored, err := ts.mutatedTasks(ctx, mutations)
+		if err != nil {
+			return err
+		}
+		heads, err := ts.outboxHeads(ctx, mutations)
+		if err != nil {
+			return err
+		}
+		items, err := ts.transactItems(mutations, stored, heads, time.Now())
+		if err != nil {
+			return err
 		}
 
-		// Check if there are more items
-		lastKey = result.LastEvaluatedKey
-		if lastKey == nil {
+		_, err = ts.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+		if err == nil {
 			break
 		}
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) {
+			if attempt < maxWriteAttempts && staleTransaction(mutations, stored, cancelled.CancellationReasons) {
+				if err := batchBackoff(ctx, attempt); err != nil {
+					return err
+				}
+				continue
+			}
+			if txErr := transactionError(mutations, stored, cancelled.CancellationReasons); txErr != nil {
+				return txErr
+			}
+		}
+		return fmt.Errorf("failed to execute transaction in DynamoDB: %w", transactWriteError(err))
 	}
 
-	return tasks, nil
+	// The transaction has committed, so index failures are only logged
+	for _, mutation := range mutations {
+		if err := ts.reindexMutation(ctx, mutation); err != nil {
+			log.Printf("failed to update search index of task %s: %v", mutation.Task.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// reindexMutation updates the search index of the task a committed mutation
+// wrote. Closing a task does not change its text, so it is not reindexed.
+func (ts *TaskStore) reindexMutation(ctx context.Context, mutation TaskMutation) error {
+	owner, id := mutation.Task.Owner, mutation.Task.ID
+	switch mutation.Op {
+	case OperationCreate:
+		return ts.reindex(ctx, owner, id, &mutation.Task)
+	case OperationUpdate:
+		// Updates only carry the title, so index the stored task
+		return ts.reindexStored(ctx, owner, id)
+	case OperationDelete:
+		return ts.reindex(ctx, owner, id, nil)
+	}
+	return nil
+}
+
+// moveLegacyTasks moves the tasks a transaction changes from their legacy
+// keys to their current keys
+func (ts *TaskStore) moveLegacyTasks(ctx context.Context, mutations []TaskMutation) error {
+	for _, mutation := range mutations {
+		if mutation.Op == OperationCreate {
+			continue
+		}
+
+		item, err := ts.getItem(ctx, keys.LegacyTask(mutation.Task.Owner, mutation.Task.ID), true)
+		if err != nil || item == nil {
+			return err
+		}
+
+		var stored DynamoDBTask
+		if err := attributevalue.UnmarshalMap(item, &stored); err != nil {
+			return fmt.Errorf("failed to unmarshal task: %w", err)
+		}
+		upgraded := stored
+		if _, err := upgraded.ToTask(); err != nil {
+			return fmt.Errorf("failed to convert to task: %w", err)
+		}
+		if err := ts.move(ctx, stored, upgraded); err != nil {
+			return fmt.Errorf("failed to move legacy task %s: %w", stored.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// mutatedTasks reads the stored items of the tasks that close and delete
+// mutations change, since their counters are taken out of the owner's
+// stats. The other entries, and those of missing tasks, are nil.
+func (ts *TaskStore) mutatedTasks(ctx context.Context, mutations []TaskMutation) ([]*DynamoDBTask, error) {
+	stored := make([]*DynamoDBTask, len(mutations))
+	for i, mutation := range mutations {
+		if mutation.Op != OperationClose && mutation.Op != OperationDelete {
+			continue
+		}
+		item, err := ts.getItem(ctx, keys.Task(mutation.Task.Owner, mutation.Task.ID), true)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			continue
+		}
+		var dbTask DynamoDBTask
+		if err := attributevalue.UnmarshalMap(item, &dbTask); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+		}
+		stored[i] = &dbTask
+	}
+	return stored, nil
+}
+
+// transactItems builds a transaction applying the mutations to the stored
+// tasks, followed by one update of each affected owner's stats, and one
+// entry in each affected owner's outbox holding the events of the owner's
+// mutations, appended after the owner's head
+func (ts *TaskStore) transactItems(mutations []TaskMutation, stored []*DynamoDBTask, heads map[string]outbox.Head, now time.Time) ([]types.TransactWriteItem, error) {
+	items := make([]types.TransactWriteItem, 0, len(mutations))
+	var owners, eventOwners []string
+	deltas := map[string]stats.Counters{}
+	events := map[string][]outbox.Event{}
+	for i, mutation := range mutations {
+		item, delta, err := ts.transactItem(mutation, stored[i], now)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		event, err := mutationEvent(mutation, stored[i])
+		if err != nil {
+			return nil, err
+		}
+		if event != nil {
+			owner := mutation.Task.Owner
+			if events[owner] == nil {
+				eventOwners = append(eventOwners, owner)
+			}
+			events[owner] = append(events[owner], *event)
+		}
+
+		if len(delta) == 0 {
+			continue
+		}
+		owner := mutation.Task.Owner
+		if deltas[owner] == nil {
+			owners = append(owners, owner)
+			deltas[owner] = stats.Counters{}
+		}
+		deltas[owner].Add(delta, 1)
+	}
+
+	for _, owner := range owners {
+		items = append(items, types.TransactWriteItem{Update: stats.Update(ts.tableName, owner, deltas[owner])})
+	}
+	for _, owner := range eventOwners {
+		appended, err := outbox.Append(ts.tableName, heads[owner], outbox.Entry{
+			ID:      uuid.New(),
+			Owner:   owner,
+			Events:  events[owner],
+			Created: now,
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, appended...)
+	}
+	if len(items) > transactionLimit {
+		return nil, fmt.Errorf("too many items in transaction: %d with the owners' stats and outboxes, the maximum is %d", len(items), transactionLimit)
+	}
+
+	return items, nil
+}
+
+// transactItem converts a TaskMutation to a DynamoDB transaction item and
+// the change it makes to the owner's stats. Close and delete mutations only
+// apply to the stored item they were built from, which is nil if the task
+// did not exist.
+func (ts *TaskStore) transactItem(mutation TaskMutation, stored *DynamoDBTask, now time.Time) (types.TransactWriteItem, stats.Counters, error) {
+	key := keys.Task(mutation.Task.Owner, mutation.Task.ID).Item()
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+
+	// Every mutation except create requires the task to exist
+	condition := "attribute_exists(PK)"
+	if mutation.Op == OperationCreate {
+		condition = "attribute_not_exists(PK)"
+	}
+	if mutation.Condition != nil && mutation.Condition.Status != "" {
+		condition += " AND #status = :expectedStatus"
+		names["#status"] = "Status"
+		values[":expectedStatus"] = &types.AttributeValueMemberS{Value: string(mutation.Condition.Status)}
+	}
+
+	// Closes and deletes change the counters from the stored task, so they
+	// only apply if it is still at the revision it was read at and was not
+	// counted since by the migration
+	if mutation.Op == OperationClose || mutation.Op == OperationDelete {
+		condition = revisionCondition(condition, names, values, stored)
+		condition = uncountedCondition(condition, names, stored)
+	}
+
+	switch mutation.Op {
+	case OperationCreate:
+		stamped := stats.Stamp(nil, countedTask(mutation.Task), now)
+		item := ToDynamoDBTask(mutation.Task)
+		item.Created, item.Closed = stats.FormatTime(stamped.Created), stats.FormatTime(stamped.Closed)
+		item.Counted = true
+		item.Revision = 1
+		av, err := attributevalue.MarshalMap(item)
+		if err != nil {
+			return types.TransactWriteItem{}, nil, fmt.Errorf("failed to marshal task: %w", err)
+		}
+		return types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:                           aws.String(ts.tableName),
+				Item:                                av,
+				ConditionExpression:                 aws.String(condition),
+				ExpressionAttributeNames:            nilIfEmpty(names),
+				ExpressionAttributeValues:           nilIfEmpty(values),
+				ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+			},
+		}, stats.Delta(nil, &stamped), nil
+
+	case OperationUpdate:
+		names["#title"] = "Title"
+		names["#revision"] = stats.RevisionAttribute
+		values[":title"] = &types.AttributeValueMemberS{Value: mutation.Task.Title}
+		values[":one"] = &types.AttributeValueMemberN{Value: "1"}
+		return types.TransactWriteItem{
+			Update: &types.Update{
+				TableName:                           aws.String(ts.tableName),
+				Key:                                 key,
+				UpdateExpression:                    aws.String("SET #title = :title ADD #revision :one"),
+				ConditionExpression:                 aws.String(condition),
+				ExpressionAttributeNames:            names,
+				ExpressionAttributeValues:           values,
+				ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+			},
+		}, nil, nil
+
+	case OperationClose:
+		expression := "SET #status = :closed, GS1PK = :gs1pk, GS1SK = :gs1sk, #counted = :counted"
+		names["#status"] = "Status"
+		names["#counted"] = stats.CountedAttribute
+		values[":closed"] = &types.AttributeValueMemberS{Value: string(TaskStatusClosed)}
+		values[":gs1pk"] = &types.AttributeValueMemberS{Value: keys.TaskStatus(mutation.Task.Owner, string(TaskStatusClosed))}
+		values[":gs1sk"] = &types.AttributeValueMemberS{Value: keys.Changed(now)}
+		values[":counted"] = &types.AttributeValueMemberBOOL{Value: true}
+
+		var delta stats.Counters
+		if state := stored.state(); state != nil {
+			closed := *state
+			closed.Status = string(TaskStatusClosed)
+			stamped := stats.Stamp(state, closed, now)
+			delta = stats.Delta(stored.counted(), &stamped)
+
+			// A task that is closed already keeps its closing time
+			if stored.Status != TaskStatusClosed {
+				expression += ", #closedAt = :closedAt"
+				names["#closedAt"] = "Closed"
+				values[":closedAt"] = &types.AttributeValueMemberS{Value: stats.FormatTime(stamped.Closed)}
+			}
+		}
+		names["#revision"] = stats.RevisionAttribute
+		values[":one"] = &types.AttributeValueMemberN{Value: "1"}
+		expression += " ADD #revision :one"
+
+		return types.TransactWriteItem{
+			Update: &types.Update{
+				TableName:                           aws.String(ts.tableName),
+				Key:                                 key,
+				UpdateExpression:                    aws.String(expression),
+				ConditionExpression:                 aws.String(condition),
+				ExpressionAttributeNames:            names,
+				ExpressionAttributeValues:           values,
+				ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+			},
+		}, delta, nil
+
+	case OperationDelete:
+		return types.TransactWriteItem{
+			Delete: &types.Delete{
+				TableName:                           aws.String(ts.tableName),
+				Key:                                 key,
+				ConditionExpression:                 aws.String(condition),
+				ExpressionAttributeNames:            nilIfEmpty(names),
+				ExpressionAttributeValues:           nilIfEmpty(values),
+				ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+			},
+		}, stats.Delta(stored.counted(), nil), nil
+	}
+
+	return types.TransactWriteItem{}, nil, fmt.Errorf("unknown operation: %q", mutation.Op)
+}
+
+// staleTransaction reports whether a transaction was cancelled because a
+// task changed after it was read, or because another request was writing
+// the same items, rather than because of a mutation's own conditions
+func staleTransaction(mutations []TaskMutation, stored []*DynamoDBTask, reasons []types.CancellationReason) bool {
+	for i := range reasons {
+		if staleReason(mutations, stored, reasons, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// staleReason reports whether the item at index i of a cancelled transaction
+// failed because it changed after it was read or was being written
+// concurrently
+func staleReason(mutations []TaskMutation, stored []*DynamoDBTask, reasons []types.CancellationReason, i int) bool {
+	reason := reasons[i]
+	switch aws.ToString(reason.Code) {
+	case TransactionReasonConflict:
+		return true
+	case TransactionReasonConditionFailed:
+		// After the mutations, only the outbox heads have conditions,
+		// which fail if another write appended to the outbox
+		if i >= len(mutations) {
+			return true
+		}
+		if reason.Item == nil || (mutations[i].Op != OperationClose && mutations[i].Op != OperationDelete) {
+			return false
+		}
+		if stored[i] == nil || itemRevision(reason.Item) != stored[i].Revision {
+			return true
+		}
+		if _, counted := reason.Item[stats.CountedAttribute]; counted && stored[i] != nil && !stored[i].Counted {
+			return true
+		}
+	}
+	return false
+}
+
+// itemRevision returns the revision of a task item, which is zero if it has
+// none
+func itemRevision(item map[string]types.AttributeValue) int64 {
+	n, ok := item[stats.RevisionAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0
+	}
+	revision, _ := strconv.ParseInt(n.Value, 10, 64)
+	return revision
+}
+
+// transactionError maps DynamoDB cancellation reasons to the first mutation
+// that caused the transaction to be cancelled. A task that kept changing
+// after it was read is reported as a conflict rather than a failed
+// precondition, since the condition that failed was not the client's, and
+// so is an outbox that kept changing, against the first mutation.
+func transactionError(mutations []TaskMutation, stored []*DynamoDBTask, reasons []types.CancellationReason) *TransactionError {
+	outboxChanged := false
+	for i, reason := range reasons {
+		code := aws.ToString(reason.Code)
+		if code == "" || code == "None" {
+			continue
+		}
+		if i >= len(mutations) {
+			outboxChanged = outboxChanged || staleReason(mutations, stored, reasons, i)
+			continue
+		}
+
+		// Tell missing and duplicate tasks apart from failed explicit conditions
+		if code == TransactionReasonConditionFailed {
+			switch {
+			case mutations[i].Op == OperationCreate:
+				code = TransactionReasonAlreadyExists
+			case reason.Item == nil:
+				code = TransactionReasonNotFound
+			case staleReason(mutations, stored, reasons, i):
+				code = TransactionReasonConflict
+			}
+		}
+
+		return &TransactionError{
+			Index:   i,
+			Reason:  code,
+			Message: aws.ToString(reason.Message),
+		}
+	}
+
+	if outboxChanged && len(mutations) > 0 {
+		return &TransactionError{
+			Index:   0,
+			Reason:  TransactionReasonConflict,
+			Message: "the owner's outbox changed on every attempt",
+		}
+	}
+	return nil
+}
+
+// PutFeedToken sets an owner's feed token. The user's token item and the
+// lookup item are written in one transaction, which also deletes the lookup
+// item of the previous token so that it stops working.
+func (ts *TaskStore) PutFeedToken(ctx context.Context, owner, hash string) error {
+	current, err := ts.getFeedToken(ctx, keys.UserFeedToken(owner))
+	if err != nil {
+		return err
+	}
+
+	user, err := attributevalue.MarshalMap(newDynamoDBFeedToken(keys.UserFeedToken(owner), owner, hash))
+	if err != nil {
+		return fmt.Errorf("failed to marshal feed token: %w", err)
+	}
+	lookup, err := attributevalue.MarshalMap(newDynamoDBFeedToken(keys.FeedToken(hash), owner, hash))
+	if err != nil {
+		return fmt.Errorf("failed to marshal feed token: %w", err)
+	}
+
+	// Fail if another request changed the token since it was read
+	userPut := &types.Put{
+		TableName:           aws.String(ts.tableName),
+		Item:                user,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	}
+	if current != nil {
+		userPut.ConditionExpression = aws.String("TokenHash = :hash")
+		userPut.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":hash": &types.AttributeValueMemberS{Value: current.TokenHash},
+		}
+	}
+
+	items := []types.TransactWriteItem{
+		{Put: userPut},
+		{Put: &types.Put{TableName: aws.String(ts.tableName), Item: lookup}},
+	}
+	if current != nil && current.TokenHash != hash {
+		items = append(items, types.TransactWriteItem{Delete: &types.Delete{
+			TableName: aws.String(ts.tableName),
+			Key:       keys.FeedToken(current.TokenHash).Item(),
+		}})
+	}
+
+	_, err = ts.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		return fmt.Errorf("failed to put feed token in DynamoDB: %w", transactWriteError(err))
+	}
+
+	return nil
+}
+
+// DeleteFeedToken revokes an owner's feed token by deleting both of its items
+func (ts *TaskStore) DeleteFeedToken(ctx context.Context, owner string) error {
+	current, err := ts.getFeedToken(ctx, keys.UserFeedToken(owner))
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return ErrNotFound
+	}
+
+	_, err = ts.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Delete: &types.Delete{
+				TableName:           aws.String(ts.tableName),
+				Key:                 keys.UserFeedToken(owner).Item(),
+				ConditionExpression: aws.String("TokenHash = :hash"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":hash": &types.AttributeValueMemberS{Value: current.TokenHash},
+				},
+			}},
+			{Delete: &types.Delete{
+				TableName: aws.String(ts.tableName),
+				Key:       keys.FeedToken(current.TokenHash).Item(),
+			}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete feed token from DynamoDB: %w", transactWriteError(err))
+	}
+
+	return nil
+}
+
+// FeedTokenOwner returns the owner of a feed token
+func (ts *TaskStore) FeedTokenOwner(ctx context.Context, hash string) (string, error) {
+	token, err := ts.getFeedToken(ctx, keys.FeedToken(hash))
+	if err != nil {
+		return "", err
+	}
+	if token == nil {
+		return "", ErrNotFound
+	}
+
+	return token.Owner, nil
+}
+
+// getFeedToken gets a feed token item, returning nil if it does not exist.
+// Reads are strongly consistent, so a revoked token stops working at once.
+func (ts *TaskStore) getFeedToken(ctx context.Context, key keys.Key) (*DynamoDBFeedToken, error) {
+	result, err := ts.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(ts.tableName),
+		Key:            key.Item(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed token from DynamoDB: %w", storeError(err))
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var token DynamoDBFeedToken
+	if err := attributevalue.UnmarshalMap(result.Item, &token); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal feed token: %w", err)
+	}
+	return &token, nil
+}
+
+// transactWriteError classifies an error from a transaction whose conditions
+// only check that the items it writes are unchanged. A cancelled transaction
+// means they changed concurrently.
+func transactWriteError(err error) error {
+	var cancelled *types.TransactionCanceledException
+	if errors.As(err, &cancelled) {
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+	return storeError(err)
+}
+
+// nilIfEmpty returns nil for an empty map, since DynamoDB rejects empty expression maps
+func nilIfEmpty[V any](m map[string]V) map[string]V {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// batchBackoff waits before retrying unprocessed batch items or a cancelled
+// write, using exponential backoff with full jitter
+func batchBackoff(ctx context.Context, attempt int) error {
+	delay := baseBatchBackoff << (attempt - 1)
+	delay = time.Duration(rand.Int63n(int64(delay)) + 1)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
 }
@@ -0,0 +1,886 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/search"
+	"github.com/user/tasks-api/internal/stats"
+	"github.com/user/tasks-api/internal/webhooks"
+)
+
+// storeConformance describes a Store implementation under test
+type storeConformance struct {
+	// newStore returns an empty store
+	newStore func(t *testing.T) Store
+	// ordered means listings are ordered by last status change, like the GS1 index
+	ordered bool
+	// concurrent means the store is safe for concurrent use
+	concurrent bool
+	// clockResolution is the precision of the store's change timestamps;
+	// the ordering test waits this long between writes
+	clockResolution time.Duration
+}
+
+// runStoreConformance runs the tests every Store implementation must pass
+func runStoreConformance(t *testing.T, sc storeConformance) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store Store)
+	}{
+		{"AddAndGet", testStoreAddAndGet},
+		{"AddReplaces", testStoreAddReplaces},
+		{"GetNotFound", testStoreGetNotFound},
+		{"ListByStatus", testStoreListByStatus},
+		{"ListMany", testStoreListMany},
+		{"BatchGet", testStoreBatchGet},
+		{"BatchWrite", testStoreBatchWrite},
+		{"BatchWriteOperations", testStoreBatchWriteOperations},
+		{"Transact", testStoreTransact},
+		{"TransactCancelled", testStoreTransactCancelled},
+		{"OptionalFields", testStoreOptionalFields},
+		{"FeedTokens", testStoreFeedTokens},
+		{"Search", testStoreSearch},
+		{"Query", testStoreQuery},
+		{"Views", testStoreViews},
+		{"Stats", testStoreStats},
+		{"Webhooks", testStoreWebhooks},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, sc.newStore(t))
+		})
+	}
+
+	t.Run("ListOrder", func(t *testing.T) {
+		if !sc.ordered {
+			t.Skip("store does not order listings")
+		}
+		testStoreListOrder(t, sc.newStore(t), sc.clockResolution)
+	})
+	t.Run("Concurrency", func(t *testing.T) {
+		if !sc.concurrent {
+			t.Skip("store is not safe for concurrent use")
+		}
+		testStoreConcurrency(t, sc.newStore(t))
+	})
+}
+
+func testStoreAddAndGet(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+
+	// Act
+	addErr := store.Add(ctx, task)
+	got, getErr := store.GetByID(ctx, task.ID, task.Owner)
+
+	// Assert
+	if addErr != nil || getErr != nil {
+		t.Fatalf("Expected no errors, got %v and %v", addErr, getErr)
+	}
+	if !reflect.DeepEqual(got, task) {
+		t.Errorf("Expected %+v, got %+v", task, got)
+	}
+}
+
+func testStoreAddReplaces(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	_ = store.Add(ctx, task)
+	task.Title = "Renamed Task"
+	task.Status = TaskStatusClosed
+
+	// Act
+	err := store.Add(ctx, task)
+	got, _ := store.GetByID(ctx, task.ID, task.Owner)
+	open, _ := store.ListOpen(ctx, task.Owner)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, task) {
+		t.Errorf("Expected %+v, got %+v", task, got)
+	}
+	if len(open) != 0 {
+		t.Errorf("Expected no open tasks, got %d", len(open))
+	}
+}
+
+func testStoreGetNotFound(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	_ = store.Add(ctx, task)
+
+	// Act
+	_, missingErr := store.GetByID(ctx, uuid.New(), task.Owner)
+	_, otherOwnerErr := store.GetByID(ctx, task.ID, "other@example.com")
+
+	// Assert
+	if !errors.Is(missingErr, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing ID, got %v", missingErr)
+	}
+	if !errors.Is(otherOwnerErr, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for another owner, got %v", otherOwnerErr)
+	}
+}
+
+func testStoreListByStatus(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	owner := "test@example.com"
+	open1 := NewTask(uuid.New(), "Open 1", owner)
+	open2 := NewTask(uuid.New(), "Open 2", owner)
+	closed := NewTask(uuid.New(), "Closed", owner)
+	closed.Status = TaskStatusClosed
+	otherOwner := NewTask(uuid.New(), "Other", "other@example.com")
+	for _, task := range []Task{open1, open2, closed, otherOwner} {
+		_ = store.Add(ctx, task)
+	}
+
+	// Act
+	openTasks, openErr := store.ListOpen(ctx, owner)
+	closedTasks, closedErr := store.ListClosed(ctx, owner)
+	noTasks, noErr := store.ListOpen(ctx, "nobody@example.com")
+
+	// Assert
+	if openErr != nil || closedErr != nil || noErr != nil {
+		t.Fatalf("Expected no errors, got %v, %v and %v", openErr, closedErr, noErr)
+	}
+	if !sameTasks(openTasks, []Task{open1, open2}) {
+		t.Errorf("Expected open tasks %v, got %v", []Task{open1, open2}, openTasks)
+	}
+	if !sameTasks(closedTasks, []Task{closed}) {
+		t.Errorf("Expected closed tasks %v, got %v", []Task{closed}, closedTasks)
+	}
+	if len(noTasks) != 0 {
+		t.Errorf("Expected no tasks for an unknown owner, got %v", noTasks)
+	}
+}
+
+func testStoreListMany(t *testing.T, store Store) {
+	// Arrange: more tasks than fit in one batch write, batch get or query page
+	ctx := context.Background()
+	owner := "test@example.com"
+	var tasks []Task
+	var writes []TaskWrite
+	var keys []TaskKey
+	for i := range 130 {
+		task := NewTask(uuid.New(), fmt.Sprintf("Task %d", i), owner)
+		tasks = append(tasks, task)
+		writes = append(writes, TaskWrite{Task: task})
+		keys = append(keys, TaskKey{Owner: owner, ID: task.ID})
+	}
+
+	// Act
+	errs := store.BatchWrite(ctx, writes)
+	got, getErr := store.BatchGet(ctx, keys)
+	listed, listErr := store.ListOpen(ctx, owner)
+
+	// Assert
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Expected write %d to succeed, got %v", i, err)
+		}
+	}
+	if getErr != nil || len(got) != len(tasks) {
+		t.Errorf("Expected %d tasks from BatchGet, got %d (%v)", len(tasks), len(got), getErr)
+	}
+	if listErr != nil || !sameTasks(listed, tasks) {
+		t.Errorf("Expected all %d tasks to be listed, got %d (%v)", len(tasks), len(listed), listErr)
+	}
+}
+
+func testStoreListOrder(t *testing.T, store Store, clockResolution time.Duration) {
+	// Arrange
+	ctx := context.Background()
+	owner := "test@example.com"
+	first := NewTask(uuid.New(), "First", owner)
+	second := NewTask(uuid.New(), "Second", owner)
+	third := NewTask(uuid.New(), "Third", owner)
+	for _, task := range []Task{first, second, third} {
+		_ = store.Add(ctx, task)
+		time.Sleep(clockResolution)
+	}
+
+	// Act: putting a task again moves it to the end, like rewriting GS1SK
+	before, _ := store.ListOpen(ctx, owner)
+	_ = store.Add(ctx, first)
+	after, err := store.ListOpen(ctx, owner)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(before, []Task{first, second, third}) {
+		t.Errorf("Expected tasks in the order they were added, got %v", before)
+	}
+	if !reflect.DeepEqual(after, []Task{second, third, first}) {
+		t.Errorf("Expected the rewritten task last, got %v", after)
+	}
+}
+
+func testStoreConcurrency(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	owner := "test@example.com"
+	contested := NewTask(uuid.New(), "Contested", owner)
+	const workers = 10
+
+	// Act: each worker adds its own task and races to create the same one
+	var wg sync.WaitGroup
+	addErrs := make([]error, workers)
+	createErrs := make([]error, workers)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addErrs[i] = store.Add(ctx, NewTask(uuid.New(), fmt.Sprintf("Task %d", i), owner))
+			createErrs[i] = store.Transact(ctx, []TaskMutation{{Op: OperationCreate, Task: contested}})
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	created := 0
+	for i := range workers {
+		if addErrs[i] != nil {
+			t.Errorf("Expected add %d to succeed, got %v", i, addErrs[i])
+		}
+		switch {
+		case createErrs[i] == nil:
+			created++
+		case !errors.Is(createErrs[i], ErrConflict):
+			t.Errorf("Expected create %d to succeed or conflict, got %v", i, createErrs[i])
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected exactly one create to succeed, got %d", created)
+	}
+	if tasks, _ := store.ListOpen(ctx, owner); len(tasks) != workers+1 {
+		t.Errorf("Expected %d open tasks, got %d", workers+1, len(tasks))
+	}
+}
+
+func testStoreBatchGet(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	_ = store.Add(ctx, task)
+	found := TaskKey{Owner: task.Owner, ID: task.ID}
+	missing := TaskKey{Owner: task.Owner, ID: uuid.New()}
+
+	// Act
+	tasks, err := store.BatchGet(ctx, []TaskKey{found, missing})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(tasks) != 1 || !reflect.DeepEqual(tasks[found], task) {
+		t.Errorf("Expected only %+v, got %v", task, tasks)
+	}
+}
+
+func testStoreBatchWrite(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	existing := NewTask(uuid.New(), "Existing", "test@example.com")
+	_ = store.Add(ctx, existing)
+	created := NewTask(uuid.New(), "Created", "test@example.com")
+
+	// Act
+	errs := store.BatchWrite(ctx, []TaskWrite{
+		{Task: created},
+		{Task: existing, Op: OperationDelete},
+	})
+	_, createdErr := store.GetByID(ctx, created.ID, created.Owner)
+	_, deletedErr := store.GetByID(ctx, existing.ID, existing.Owner)
+
+	// Assert
+	if len(errs) != 2 || errs[0] != nil || errs[1] != nil {
+		t.Fatalf("Expected two nil errors, got %v", errs)
+	}
+	if createdErr != nil {
+		t.Errorf("Expected the created task to exist, got %v", createdErr)
+	}
+	if !errors.Is(deletedErr, ErrNotFound) {
+		t.Errorf("Expected the deleted task to be gone, got %v", deletedErr)
+	}
+}
+
+func testStoreBatchWriteOperations(t *testing.T, store Store) {
+	// Arrange: tasks with fields the operations do not carry
+	ctx := context.Background()
+	owner := "test@example.com"
+	renamed := NewTask(uuid.New(), "Before", owner)
+	renamed.Description = "Notes"
+	renamed.Labels = []string{"home"}
+	closed := NewTask(uuid.New(), "To Close", owner)
+	closed.Priority = TaskPriorityHigh
+	deleted := NewTask(uuid.New(), "To Delete", owner)
+	for _, task := range []Task{renamed, closed, deleted} {
+		if err := store.Add(ctx, task); err != nil {
+			t.Fatalf("Failed to add task: %v", err)
+		}
+	}
+	writes := []TaskWrite{
+		{Op: OperationUpdate, Task: Task{ID: renamed.ID, Owner: owner, Title: "After"}},
+		{Op: OperationClose, Task: Task{ID: closed.ID, Owner: owner}},
+		{Op: OperationDelete, Task: Task{ID: deleted.ID, Owner: owner}},
+		{Op: OperationUpdate, Task: Task{ID: uuid.New(), Owner: owner, Title: "Missing"}},
+	}
+
+	// Act
+	errs := store.BatchWrite(ctx, writes)
+	storedRenamed, renamedErr := store.GetByID(ctx, renamed.ID, owner)
+	storedClosed, closedErr := store.GetByID(ctx, closed.ID, owner)
+	_, deletedErr := store.GetByID(ctx, deleted.ID, owner)
+
+	// Assert
+	if errs[0] != nil || errs[1] != nil || errs[2] != nil || !errors.Is(errs[3], ErrNotFound) {
+		t.Fatalf("Expected three writes and a missing task, got %v", errs)
+	}
+	wantRenamed := renamed
+	wantRenamed.Title = "After"
+	if renamedErr != nil || !reflect.DeepEqual(storedRenamed, wantRenamed) || !reflect.DeepEqual(writes[0].Task, wantRenamed) {
+		t.Errorf("Expected only the title to change, got %+v and %+v (%v)", storedRenamed, writes[0].Task, renamedErr)
+	}
+	wantClosed := closed
+	wantClosed.Status = TaskStatusClosed
+	if closedErr != nil || !reflect.DeepEqual(storedClosed, wantClosed) || !reflect.DeepEqual(writes[1].Task, wantClosed) {
+		t.Errorf("Expected only the status to change, got %+v and %+v (%v)", storedClosed, writes[1].Task, closedErr)
+	}
+	if !errors.Is(deletedErr, ErrNotFound) || writes[2].Task.Title != "To Delete" {
+		t.Errorf("Expected the task to be deleted and returned, got %+v (%v)", writes[2].Task, deletedErr)
+	}
+}
+
+func testStoreTransact(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	owner := "test@example.com"
+	toUpdate := NewTask(uuid.New(), "Before", owner)
+	toClose := NewTask(uuid.New(), "To Close", owner)
+	toDelete := NewTask(uuid.New(), "To Delete", owner)
+	for _, task := range []Task{toUpdate, toClose, toDelete} {
+		_ = store.Add(ctx, task)
+	}
+	created := NewTask(uuid.New(), "Created", owner)
+
+	// Act
+	err := store.Transact(ctx, []TaskMutation{
+		{Op: OperationCreate, Task: created},
+		{Op: OperationUpdate, Task: Task{ID: toUpdate.ID, Owner: owner, Title: "After"}},
+		{Op: OperationClose, Task: Task{ID: toClose.ID, Owner: owner}, Condition: &TaskCondition{Status: TaskStatusOpen}},
+		{Op: OperationDelete, Task: Task{ID: toDelete.ID, Owner: owner}},
+	})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got, _ := store.GetByID(ctx, created.ID, owner); !reflect.DeepEqual(got, created) {
+		t.Errorf("Expected created task %+v, got %+v", created, got)
+	}
+	if got, _ := store.GetByID(ctx, toUpdate.ID, owner); got.Title != "After" || got.Status != TaskStatusOpen {
+		t.Errorf("Expected the title to be updated, got %+v", got)
+	}
+	if got, _ := store.GetByID(ctx, toClose.ID, owner); got.Status != TaskStatusClosed || got.Title != toClose.Title {
+		t.Errorf("Expected the task to be closed, got %+v", got)
+	}
+	if _, err := store.GetByID(ctx, toDelete.ID, owner); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the task to be deleted, got %v", err)
+	}
+}
+
+func testStoreTransactCancelled(t *testing.T, store Store) {
+	ctx := context.Background()
+	owner := "test@example.com"
+	existing := NewTask(uuid.New(), "Existing", owner)
+	_ = store.Add(ctx, existing)
+
+	tests := []struct {
+		name     string
+		mutation TaskMutation
+		reason   string
+		sentinel error
+	}{
+		{
+			name:     "create existing",
+			mutation: TaskMutation{Op: OperationCreate, Task: existing},
+			reason:   TransactionReasonAlreadyExists,
+			sentinel: ErrConflict,
+		},
+		{
+			name:     "update missing",
+			mutation: TaskMutation{Op: OperationUpdate, Task: Task{ID: uuid.New(), Owner: owner, Title: "Missing"}},
+			reason:   TransactionReasonNotFound,
+			sentinel: ErrNotFound,
+		},
+		{
+			name:     "condition failed",
+			mutation: TaskMutation{Op: OperationClose, Task: Task{ID: existing.ID, Owner: owner}, Condition: &TaskCondition{Status: TaskStatusClosed}},
+			reason:   TransactionReasonConditionFailed,
+			sentinel: ErrConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			created := NewTask(uuid.New(), "Created", owner)
+
+			// Act
+			err := store.Transact(ctx, []TaskMutation{
+				{Op: OperationCreate, Task: created},
+				tt.mutation,
+			})
+
+			// Assert
+			var txErr *TransactionError
+			if !errors.As(err, &txErr) {
+				t.Fatalf("Expected a TransactionError, got %v", err)
+			}
+			if txErr.Index != 1 || txErr.Reason != tt.reason {
+				t.Errorf("Expected mutation 1 to fail with %s, got mutation %d with %s", tt.reason, txErr.Index, txErr.Reason)
+			}
+			if !errors.Is(err, tt.sentinel) {
+				t.Errorf("Expected the error to wrap %v", tt.sentinel)
+			}
+			if _, err := store.GetByID(ctx, created.ID, owner); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected no mutation to be applied, got %v", err)
+			}
+		})
+	}
+}
+
+func testStoreOptionalFields(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	task.Description = "First line\nSecond line"
+	task.Due = "2024-05-01T07:00:00Z"
+	task.Recurrence = "FREQ=WEEKLY;BYDAY=SU"
+	task.UID = "abc@example.com"
+	task.Priority = TaskPriorityUrgent
+	task.Labels = []string{"bug", "ui"}
+	batched := NewTask(uuid.New(), "Batched Task", task.Owner)
+	batched.Due = "2024-05-01"
+	batched.Labels = []string{"chore"}
+
+	// Act
+	addErr := store.Add(ctx, task)
+	writeErrs := store.BatchWrite(ctx, []TaskWrite{{Task: batched}})
+	got, getErr := store.GetByID(ctx, task.ID, task.Owner)
+	open, listErr := store.ListOpen(ctx, task.Owner)
+
+	// Assert
+	if addErr != nil || writeErrs[0] != nil || getErr != nil || listErr != nil {
+		t.Fatalf("Expected no errors, got %v, %v, %v and %v", addErr, writeErrs[0], getErr, listErr)
+	}
+	if !reflect.DeepEqual(got, task) {
+		t.Errorf("Expected %+v, got %+v", task, got)
+	}
+	if !sameTasks(open, []Task{task, batched}) {
+		t.Errorf("Expected %+v and %+v, got %+v", task, batched, open)
+	}
+}
+
+func testStoreFeedTokens(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	owner := "test@example.com"
+	first, second := uuid.NewString(), uuid.NewString()
+	_ = store.PutFeedToken(ctx, "other@example.com", uuid.NewString())
+
+	// Act
+	firstErr := store.PutFeedToken(ctx, owner, first)
+	firstOwner, firstOwnerErr := store.FeedTokenOwner(ctx, first)
+	secondErr := store.PutFeedToken(ctx, owner, second)
+	_, replacedErr := store.FeedTokenOwner(ctx, first)
+	secondOwner, secondOwnerErr := store.FeedTokenOwner(ctx, second)
+	deleteErr := store.DeleteFeedToken(ctx, owner)
+	_, revokedErr := store.FeedTokenOwner(ctx, second)
+	deleteAgainErr := store.DeleteFeedToken(ctx, owner)
+
+	// Assert
+	if firstErr != nil || secondErr != nil || deleteErr != nil {
+		t.Fatalf("Expected no errors, got %v, %v and %v", firstErr, secondErr, deleteErr)
+	}
+	if firstOwnerErr != nil || firstOwner != owner {
+		t.Errorf("Expected the first token to belong to %q, got %q and %v", owner, firstOwner, firstOwnerErr)
+	}
+	if !errors.Is(replacedErr, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a replaced token, got %v", replacedErr)
+	}
+	if secondOwnerErr != nil || secondOwner != owner {
+		t.Errorf("Expected the second token to belong to %q, got %q and %v", owner, secondOwner, secondOwnerErr)
+	}
+	if !errors.Is(revokedErr, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a revoked token, got %v", revokedErr)
+	}
+	if !errors.Is(deleteAgainErr, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing token, got %v", deleteAgainErr)
+	}
+}
+
+func testStoreSearch(t *testing.T, store Store) {
+	// Arrange: index tasks through every kind of write
+	ctx := context.Background()
+	owner := "test@example.com"
+	added := NewTask(uuid.New(), "Fix the login page", owner)
+	added.Description = "Logins fail on mobile"
+	batched := NewTask(uuid.New(), "Write report", owner)
+	transacted := NewTask(uuid.New(), "Review login copy", owner)
+	deleted := NewTask(uuid.New(), "Old login task", owner)
+	_ = store.Add(ctx, NewTask(uuid.New(), "Login for someone else", "other@example.com"))
+
+	addErr := store.Add(ctx, added)
+	writeErrs := store.BatchWrite(ctx, []TaskWrite{{Task: batched}, {Task: deleted}})
+	createErr := store.Transact(ctx, []TaskMutation{{Op: OperationCreate, Task: transacted}})
+	if addErr != nil || writeErrs[0] != nil || writeErrs[1] != nil || createErr != nil {
+		t.Fatalf("Failed to add tasks: %v, %v, %v and %v", addErr, writeErrs[0], writeErrs[1], createErr)
+	}
+
+	// Act: change the text of one task and delete another
+	transacted.Title = "Review signup copy"
+	updateErr := store.Transact(ctx, []TaskMutation{
+		{Op: OperationUpdate, Task: transacted},
+		{Op: OperationClose, Task: batched},
+	})
+	deleteErr := store.BatchWrite(ctx, []TaskWrite{{Task: deleted, Op: OperationDelete}})[0]
+
+	// Assert
+	if updateErr != nil || deleteErr != nil {
+		t.Fatalf("Expected no errors, got %v and %v", updateErr, deleteErr)
+	}
+	tests := []struct {
+		query string
+		want  []uuid.UUID
+	}{
+		{"login", []uuid.UUID{added.ID}},
+		{"failing LOGINS", []uuid.UUID{added.ID}},
+		{"rep*", []uuid.UUID{batched.ID}},
+		{"signup", []uuid.UUID{transacted.ID}},
+		{"review", []uuid.UUID{transacted.ID}},
+		{"login mobile", []uuid.UUID{added.ID}},
+		{"login report", nil},
+		{"old", nil},
+	}
+	for _, tt := range tests {
+		terms, err := search.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.query, err)
+		}
+		postings, total, err := store.SearchTerms(ctx, owner, terms)
+		if err != nil {
+			t.Fatalf("Expected no error searching %q, got %v", tt.query, err)
+		}
+		if total != 3 {
+			t.Errorf("Expected 3 indexed tasks searching %q, got %d", tt.query, total)
+		}
+		var ids []uuid.UUID
+		for _, result := range search.Rank(postings, total) {
+			ids = append(ids, result.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("Expected %q to match %v, got %v", tt.query, tt.want, ids)
+		}
+	}
+}
+
+func testStoreQuery(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	owner := "test@example.com"
+	bug := NewTask(uuid.New(), "Fix login", owner)
+	bug.Labels = []string{"bug", "ui"}
+	bug.Priority = TaskPriorityUrgent
+	bug.Due = "2026-10-31T18:00:00Z"
+	closedBug := NewTask(uuid.New(), "Fix logout", owner)
+	closedBug.Labels = []string{"bug"}
+	closedBug.Priority = TaskPriorityHigh
+	closedBug.Status = TaskStatusClosed
+	chore := NewTask(uuid.New(), "Update dependencies", owner)
+	chore.Priority = TaskPriorityLow
+	chore.Due = "2026-11-01"
+	other := NewTask(uuid.New(), "Fix login", "other@example.com")
+	other.Labels = []string{"bug"}
+	for _, task := range []Task{bug, closedBug, chore, other} {
+		if err := store.Add(ctx, task); err != nil {
+			t.Fatalf("Failed to add task: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []Task
+	}{
+		{"", []Task{bug, chore, closedBug}},
+		{"status:closed", []Task{closedBug}},
+		{"label:bug", []Task{bug, closedBug}},
+		{"label:bug label:ui", []Task{bug}},
+		{"priority>=high", []Task{bug, closedBug}},
+		{"priority<normal", []Task{chore}},
+		{"due<2026-11-01", []Task{bug}},
+		{"due:2026-11-01", []Task{chore}},
+		{"status:open login", []Task{bug}},
+		{"priority<low", []Task{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			filter, err := ParseTaskQuery(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to parse query: %v", err)
+			}
+
+			// Act
+			tasks, err := store.QueryTasks(ctx, owner, filter)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if tasks == nil || !sameTasks(tasks, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, tasks)
+			}
+		})
+	}
+}
+
+// sameTasks reports whether two lists hold the same tasks in any order
+func sameTasks(a, b []Task) bool {
+	byID := func(x, y Task) int { return strings.Compare(x.ID.String(), y.ID.String()) }
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.SortFunc(a, byID)
+	slices.SortFunc(b, byID)
+	return reflect.DeepEqual(a, b)
+}
+
+func testStoreViews(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	owner := "test@example.com"
+	view := View{ID: uuid.New(), Owner: owner, Name: "Bugs", Query: "label:bug"}
+	other := View{ID: uuid.New(), Owner: "other@example.com", Name: "Bugs", Query: "label:bug"}
+	first, second := uuid.New(), uuid.New()
+	if err := store.PutView(ctx, other); err != nil {
+		t.Fatalf("Failed to put view: %v", err)
+	}
+
+	// Act
+	putErr := store.PutView(ctx, view)
+	got, getErr := store.GetView(ctx, owner, view.ID)
+	views, listErr := store.ListViews(ctx, owner)
+	markErr := store.MarkSeen(ctx, owner, view.ID, []uuid.UUID{first})
+	markAgainErr := store.MarkSeen(ctx, owner, view.ID, []uuid.UUID{first, second})
+	seen, seenErr := store.SeenTasks(ctx, owner, view.ID)
+	otherSeen, otherSeenErr := store.SeenTasks(ctx, other.Owner, other.ID)
+	deleteErr := store.DeleteView(ctx, owner, view.ID)
+	_, deletedErr := store.GetView(ctx, owner, view.ID)
+	deletedSeen, deletedSeenErr := store.SeenTasks(ctx, owner, view.ID)
+	deleteAgainErr := store.DeleteView(ctx, owner, view.ID)
+
+	// Assert
+	if putErr != nil || getErr != nil || listErr != nil || markErr != nil || markAgainErr != nil || seenErr != nil || otherSeenErr != nil || deleteErr != nil || deletedSeenErr != nil {
+		t.Fatalf("Expected no errors, got %v, %v, %v, %v, %v, %v, %v, %v and %v",
+			putErr, getErr, listErr, markErr, markAgainErr, seenErr, otherSeenErr, deleteErr, deletedSeenErr)
+	}
+	if got != view {
+		t.Errorf("Expected %+v, got %+v", view, got)
+	}
+	if len(views) != 1 || views[0] != view {
+		t.Errorf("Expected only %+v, got %+v", view, views)
+	}
+	if !reflect.DeepEqual(seen, map[uuid.UUID]bool{first: true, second: true}) {
+		t.Errorf("Expected both tasks seen, got %v", seen)
+	}
+	if len(otherSeen) != 0 {
+		t.Errorf("Expected no tasks seen through another owner's view, got %v", otherSeen)
+	}
+	if !errors.Is(deletedErr, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted view, got %v", deletedErr)
+	}
+	if len(deletedSeen) != 0 {
+		t.Errorf("Expected no tasks seen through a deleted view, got %v", deletedSeen)
+	}
+	if !errors.Is(deleteAgainErr, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing view, got %v", deleteAgainErr)
+	}
+}
+
+func testStoreWebhooks(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	owner := "test@example.com"
+	created := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	subscription := webhooks.Subscription{
+		ID:      uuid.New(),
+		Owner:   owner,
+		URL:     "https://example.com/hooks",
+		Events:  []string{webhooks.EventTaskClosed, webhooks.EventTaskDeleted},
+		Secret:  "whsec_test",
+		Created: created,
+	}
+	other := webhooks.Subscription{ID: uuid.New(), Owner: "other@example.com", URL: "https://example.com/other", Secret: "whsec_other", Created: created}
+	var deliveries []webhooks.Delivery
+	for i, status := range []webhooks.DeliveryStatus{webhooks.StatusDelivered, webhooks.StatusDeadLetter, webhooks.StatusDelivered} {
+		at := created.Add(time.Duration(i) * time.Millisecond)
+		delivery := webhooks.Delivery{
+			ID:        uuid.New(),
+			WebhookID: subscription.ID,
+			Owner:     owner,
+			EventID:   fmt.Sprintf("evt-%d", i),
+			EventType: webhooks.EventTaskClosed,
+			Status:    status,
+			Attempts:  []webhooks.Attempt{{Time: at, StatusCode: 200}},
+			Created:   at,
+		}
+		if status == webhooks.StatusDeadLetter {
+			delivery.Attempts = []webhooks.Attempt{{Time: at, Error: "connection refused"}, {Time: at.Add(time.Second), StatusCode: 503, Error: "receiver responded 503"}}
+			delivery.Payload = []byte(`{"type":"TaskClosed"}`)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := store.AddWebhook(ctx, other); err != nil {
+		t.Fatalf("Failed to add webhook: %v", err)
+	}
+
+	// Act
+	addErr := store.AddWebhook(ctx, subscription)
+	got, getErr := store.GetWebhook(ctx, owner, subscription.ID)
+	listed, listErr := store.ListWebhooks(ctx, owner)
+	var deliveryErr error
+	for _, delivery := range deliveries {
+		deliveryErr = errors.Join(deliveryErr, store.AddDelivery(ctx, delivery))
+	}
+	latest, latestErr := store.ListDeliveries(ctx, owner, subscription.ID, 2)
+	deleteErr := store.DeleteWebhook(ctx, owner, subscription.ID)
+	_, deletedErr := store.GetWebhook(ctx, owner, subscription.ID)
+	deletedDeliveries, deletedDeliveriesErr := store.ListDeliveries(ctx, owner, subscription.ID, 10)
+	deleteAgainErr := store.DeleteWebhook(ctx, owner, subscription.ID)
+
+	// Assert
+	if addErr != nil || getErr != nil || listErr != nil || deliveryErr != nil || latestErr != nil || deleteErr != nil || deletedDeliveriesErr != nil {
+		t.Fatalf("Expected no errors, got %v, %v, %v, %v, %v, %v and %v",
+			addErr, getErr, listErr, deliveryErr, latestErr, deleteErr, deletedDeliveriesErr)
+	}
+	if !reflect.DeepEqual(got, subscription) {
+		t.Errorf("Expected %+v, got %+v", subscription, got)
+	}
+	if len(listed) != 1 || !reflect.DeepEqual(listed[0], subscription) {
+		t.Errorf("Expected only %+v, got %+v", subscription, listed)
+	}
+	if want := []webhooks.Delivery{deliveries[2], deliveries[1]}; !reflect.DeepEqual(latest, want) {
+		t.Errorf("Expected the 2 latest deliveries, newest first, %+v, got %+v", want, latest)
+	}
+	if !errors.Is(deletedErr, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted webhook, got %v", deletedErr)
+	}
+	if len(deletedDeliveries) != 0 {
+		t.Errorf("Expected no deliveries to a deleted webhook, got %+v", deletedDeliveries)
+	}
+	if !errors.Is(deleteAgainErr, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing webhook, got %v", deleteAgainErr)
+	}
+}
+
+func testStoreStats(t *testing.T, store Store) {
+	// Arrange
+	ctx := context.Background()
+	owner := "test@example.com"
+	a := NewTask(uuid.New(), "Fix login", owner)
+	a.Priority, a.Labels = TaskPriorityHigh, []string{"bug"}
+	b := NewTask(uuid.New(), "Fix signup", owner)
+	b.Labels = []string{"bug", "ui"}
+	c := NewTask(uuid.New(), "Write docs", owner)
+	for _, task := range []Task{a, b, c, NewTask(uuid.New(), "Other task", "other@example.com")} {
+		if err := store.Add(ctx, task); err != nil {
+			t.Fatalf("Failed to add task: %v", err)
+		}
+	}
+	closedB := b
+	closedB.Status = TaskStatusClosed
+	reopenedA := a
+
+	// Act: close a and delete c in a transaction, close b, rename it and
+	// delete c again in a batch, then reopen a
+	txErr := store.Transact(ctx, []TaskMutation{
+		{Op: OperationClose, Task: Task{ID: a.ID, Owner: owner}, Condition: &TaskCondition{Status: TaskStatusOpen}},
+		{Op: OperationCreate, Task: NewTask(uuid.New(), "Release", owner)},
+		{Op: OperationDelete, Task: Task{ID: c.ID, Owner: owner}},
+	})
+	closed, closedErr := store.Stats(ctx, owner)
+	renamedB := closedB
+	renamedB.Title = "Fix sign-up"
+	batchErrs := store.BatchWrite(ctx, []TaskWrite{
+		{Task: closedB},
+		{Task: renamedB},
+		{Task: c, Op: OperationDelete},
+	})
+	reopenErr := store.Add(ctx, reopenedA)
+	reopened, reopenedErr := store.Stats(ctx, owner)
+	empty, emptyErr := store.Stats(ctx, "nobody@example.com")
+
+	// Assert
+	if txErr != nil || closedErr != nil || reopenErr != nil || reopenedErr != nil || emptyErr != nil {
+		t.Fatalf("Expected no errors, got %v, %v, %v, %v and %v", txErr, closedErr, reopenErr, reopenedErr, emptyErr)
+	}
+	for i, err := range batchErrs[:2] {
+		if err != nil {
+			t.Fatalf("Expected write %d to succeed, got %v", i, err)
+		}
+	}
+	if !errors.Is(batchErrs[2], ErrNotFound) {
+		t.Errorf("Expected deleting c again to find nothing, got %v", batchErrs[2])
+	}
+	closedWeeks := func(counters stats.Counters) int64 {
+		var total int64
+		for _, n := range counters.Group(stats.ClosedWeekPrefix) {
+			total += n
+		}
+		return total
+	}
+	if closed[stats.StatusPrefix+"OPEN"] != 2 || closed[stats.StatusPrefix+"CLOSED"] != 1 || closedWeeks(closed) != 1 || closed[stats.TimedCloses] != 1 {
+		t.Errorf("Expected b and the new task open and a closed, got %v", closed)
+	}
+	want := map[string]int64{
+		"status#OPEN": 2, "status#CLOSED": 1, "priority#HIGH": 1, "label#bug": 2, "label#ui": 1,
+		stats.TimedCloses: 1,
+	}
+	for name, value := range want {
+		if reopened[name] != value {
+			t.Errorf("Expected %s %d, got %d", name, value, reopened[name])
+		}
+	}
+	if closedWeeks(reopened) != 1 || reopened[stats.CloseSeconds] < 0 {
+		t.Errorf("Expected b closed once, got %v", reopened)
+	}
+	if len(empty.Group("")) != 0 {
+		t.Errorf("Expected no counters for an owner without tasks, got %v", empty)
+	}
+}
+
+func TestMockTaskStoreConformance(t *testing.T) {
+	runStoreConformance(t, storeConformance{
+		newStore: func(t *testing.T) Store {
+			return NewMockTaskStore()
+		},
+		ordered:    true,
+		concurrent: true,
+	})
+}
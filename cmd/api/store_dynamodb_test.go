@@ -0,0 +1,181 @@
+//go:build dynamodb
+
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+	"github.com/user/tasks-api/internal/outbox"
+)
+
+// These tests run against DynamoDB Local and only build with the dynamodb tag:
+//
+//	docker run -d -p 8000:8000 amazon/dynamodb-local
+//	go test -tags dynamodb ./cmd/api
+//
+// Set DYNAMODB_ENDPOINT to use an endpoint other than http://localhost:8000.
+
+// newTestDynamoDBStore creates a TaskStore backed by a new table in DynamoDB
+// Local, with the same keys and index as resources/dynamodb.yml
+func newTestDynamoDBStore(t *testing.T) *TaskStore {
+	t.Helper()
+	ctx := context.Background()
+
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "http://localhost:8000"
+	}
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(endpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider("local", "local", ""),
+	})
+
+	tableName := "conformance-" + uuid.NewString()
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(tableName),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("PK"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("SK"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("GS1PK"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("GS1SK"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("PK"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("SK"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName: aws.String("GS1"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("GS1PK"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("GS1SK"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create table at %s (is DynamoDB Local running?): %v", endpoint, err)
+	}
+	t.Cleanup(func() {
+		_, _ = client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(tableName)})
+	})
+
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, 30*time.Second); err != nil {
+		t.Fatalf("Table %s did not become active: %v", tableName, err)
+	}
+
+	// A small page size makes listings span several query pages
+	return &TaskStore{client: client, tableName: tableName, queryPageSize: 7, writeBackUpgrades: true, legacyKeys: true}
+}
+
+func TestTaskStoreConformance(t *testing.T) {
+	runStoreConformance(t, storeConformance{
+		newStore: func(t *testing.T) Store {
+			return newTestDynamoDBStore(t)
+		},
+		ordered:    true,
+		concurrent: true,
+		// GS1SK has a precision of one second
+		clockResolution: 1100 * time.Millisecond,
+	})
+}
+
+func TestTaskStoreLegacyKeys(t *testing.T) {
+	// Arrange: an open task stored under its legacy key
+	ctx := context.Background()
+	store := newTestDynamoDBStore(t)
+	store.writeBackUpgrades = false
+	task := NewTask(uuid.New(), "Legacy Task", "test@example.com")
+	legacyKey := keys.LegacyTask(task.Owner, task.ID)
+	item := legacyKey.Item()
+	item["GS1PK"] = &types.AttributeValueMemberS{Value: keys.LegacyTaskStatus(task.Owner, string(task.Status))}
+	item["GS1SK"] = &types.AttributeValueMemberS{Value: keys.Changed(time.Now())}
+	item["ID"] = &types.AttributeValueMemberS{Value: task.ID.String()}
+	item["Title"] = &types.AttributeValueMemberS{Value: task.Title}
+	item["Owner"] = &types.AttributeValueMemberS{Value: task.Owner}
+	item["Status"] = &types.AttributeValueMemberS{Value: string(task.Status)}
+	if _, err := store.client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(store.tableName), Item: item}); err != nil {
+		t.Fatalf("Failed to put legacy item: %v", err)
+	}
+
+	// Act
+	got, getErr := store.GetByID(ctx, task.ID, task.Owner)
+	open, listErr := store.ListOpen(ctx, task.Owner)
+	closeErr := store.Transact(ctx, []TaskMutation{{Op: OperationClose, Task: task, Condition: &TaskCondition{Status: TaskStatusOpen}}})
+
+	// Assert
+	if getErr != nil || !reflect.DeepEqual(got, task) {
+		t.Errorf("Expected %+v, got %+v (%v)", task, got, getErr)
+	}
+	if listErr != nil || len(open) != 1 {
+		t.Errorf("Expected the legacy task to be listed, got %+v (%v)", open, listErr)
+	}
+	if closeErr != nil {
+		t.Fatalf("Expected the legacy task to be closed, got %v", closeErr)
+	}
+	if legacy, _ := store.getItem(ctx, legacyKey, true); legacy != nil {
+		t.Errorf("Expected the legacy item to be moved, got %v", legacy)
+	}
+	moved, _ := store.getItem(ctx, keys.Task(task.Owner, task.ID), true)
+	if entityType, ok := moved["entity_type"].(*types.AttributeValueMemberS); !ok || entityType.Value != string(keys.EntityTask) {
+		t.Errorf("Expected the moved item to have an entity type, got %v", moved)
+	}
+}
+
+func TestTaskStoreOutbox(t *testing.T) {
+	// Arrange: tasks written alone, in a transaction and in a batch
+	ctx := context.Background()
+	store := newTestDynamoDBStore(t)
+	owner := "test@example.com"
+	task := NewTask(uuid.New(), "Outbox Task", owner)
+	other := NewTask(uuid.New(), "Other Task", owner)
+	if err := store.Add(ctx, task); err != nil {
+		t.Fatalf("Failed to add task: %v", err)
+	}
+	if err := store.Transact(ctx, []TaskMutation{{Op: OperationCreate, Task: other}, {Op: OperationClose, Task: task}}); err != nil {
+		t.Fatalf("Failed to execute transaction: %v", err)
+	}
+	if errs := store.BatchWrite(ctx, []TaskWrite{{Task: other, Op: OperationDelete}}); errs[0] != nil {
+		t.Fatalf("Failed to delete task: %v", errs[0])
+	}
+	table := outbox.NewTable(store.client, store.tableName)
+	queue := &outbox.MemorySQS{}
+	relay := outbox.NewRelay(table, outbox.NewSQSPublisher(queue, "https://sqs.eu-west-1.amazonaws.com/123456789012/events.fifo"))
+
+	// Act
+	err := relay.Drain(ctx)
+	again := relay.Drain(ctx)
+	pending, pendingErr := table.PendingOwners(ctx)
+
+	// Assert
+	if err != nil || again != nil {
+		t.Fatalf("Expected no errors, got %v and %v", err, again)
+	}
+	var got []string
+	for _, message := range queue.Messages() {
+		got = append(got, aws.ToString(message.MessageAttributes["type"].StringValue))
+	}
+	want := []string{outbox.EventTaskCreated, outbox.EventTaskCreated, outbox.EventTaskClosed, outbox.EventTaskDeleted}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v published once in order, got %v", want, got)
+	}
+	if pendingErr != nil || len(pending) != 0 {
+		t.Errorf("Expected no pending outboxes, got %v (%v)", pending, pendingErr)
+	}
+	head, _ := store.outboxHead(ctx, owner)
+	if head != (outbox.Head{Sequence: 3, Delivered: 3}) {
+		t.Errorf("Expected three entries delivered, got %+v", head)
+	}
+}
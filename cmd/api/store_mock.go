@@ -1,49 +1,247 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/search"
+	"github.com/user/tasks-api/internal/stats"
+	"github.com/user/tasks-api/internal/webhooks"
 )
 
-// MockTaskStore is a mock implementation of the TaskStore for testing
+// MockOperation names a store method for fault injection
+type MockOperation string
+
+const (
+	// MockOpAll matches every operation that has no fault of its own
+	MockOpAll MockOperation = "*"
+	// MockOpAdd is Add
+	MockOpAdd MockOperation = "Add"
+	// MockOpGetByID is GetByID
+	MockOpGetByID MockOperation = "GetByID"
+	// MockOpList is ListOpen, ListClosed and QueryTasks
+	MockOpList MockOperation = "List"
+	// MockOpBatchGet is BatchGet
+	MockOpBatchGet MockOperation = "BatchGet"
+	// MockOpBatchWrite is BatchWrite
+	MockOpBatchWrite MockOperation = "BatchWrite"
+	// MockOpTransact is Transact
+	MockOpTransact MockOperation = "Transact"
+	// MockOpFeedToken is PutFeedToken, DeleteFeedToken and FeedTokenOwner
+	MockOpFeedToken MockOperation = "FeedToken"
+	// MockOpSearch is SearchTerms
+	MockOpSearch MockOperation = "Search"
+	// MockOpView is every ViewStore method
+	MockOpView MockOperation = "View"
+	// MockOpStats is Stats
+	MockOpStats MockOperation = "Stats"
+	// MockOpWebhook is every WebhookStore method
+	MockOpWebhook MockOperation = "Webhook"
+)
+
+// MockFault is a failure injected into a MockTaskStore operation
+type MockFault struct {
+	// Latency delays the operation, or until the context is done
+	Latency time.Duration
+	// Err is returned instead of performing the operation, e.g. ErrThrottled
+	Err error
+	// Times limits the fault to the next n calls; zero means every call
+	Times int
+}
+
+// mockTask is a stored task with the sequence number of its last put or
+// status change, which orders listings like GS1SK, and its state as its
+// owner's stats count it
+type mockTask struct {
+	task    Task
+	seq     uint64
+	counted stats.Task
+}
+
+// MockSnapshot is a copy of a MockTaskStore's tasks, made by Snapshot
+type MockSnapshot struct {
+	tasks map[string]map[string]mockTask
+	seq   uint64
+}
+
+// MockTaskStore is an in-memory implementation of the task store, for tests
+// and demos. It is safe for concurrent use.
 type MockTaskStore struct {
-	tasks map[string]map[string]Task // map[owner]map[taskID]Task
+	mu    sync.RWMutex
+	tasks map[string]map[string]mockTask // map[owner]map[taskID]mockTask
+	seq   uint64
+	// feedTokens maps owners to their feed token hashes; snapshots do not
+	// include them
+	feedTokens map[string]string
+	// views maps owners to their views, and seen maps view IDs to the IDs
+	// of the tasks seen through them; snapshots do not include them
+	views map[string]map[uuid.UUID]View
+	seen  map[uuid.UUID]map[uuid.UUID]bool
+	// subscriptions maps owners to their webhook subscriptions, and
+	// deliveries maps subscription IDs to their deliveries, oldest first;
+	// snapshots do not include them
+	subscriptions map[string]map[uuid.UUID]webhooks.Subscription
+	deliveries    map[uuid.UUID][]webhooks.Delivery
+
+	faultMu sync.Mutex
+	faults  map[MockOperation]MockFault
 }
 
 // NewMockTaskStore creates a new MockTaskStore
 func NewMockTaskStore() *MockTaskStore {
 	return &MockTaskStore{
-		tasks: make(map[string]map[string]Task),
+		tasks:      make(map[string]map[string]mockTask),
+		feedTokens: make(map[string]string),
+		views:      make(map[string]map[uuid.UUID]View),
+		seen:       make(map[uuid.UUID]map[uuid.UUID]bool),
+		faults:     make(map[MockOperation]MockFault),
+
+		subscriptions: make(map[string]map[uuid.UUID]webhooks.Subscription),
+		deliveries:    make(map[uuid.UUID][]webhooks.Delivery),
 	}
 }
 
+var _ Store = (*MockTaskStore)(nil)
+
+// InjectFault makes future calls to op fail or slow down. It replaces any
+// fault already injected for op.
+func (m *MockTaskStore) InjectFault(op MockOperation, fault MockFault) {
+	m.faultMu.Lock()
+	defer m.faultMu.Unlock()
+	m.faults[op] = fault
+}
+
+// ClearFaults removes every injected fault
+func (m *MockTaskStore) ClearFaults() {
+	m.faultMu.Lock()
+	defer m.faultMu.Unlock()
+	clear(m.faults)
+}
+
+// fault applies the fault injected for op, if any, and returns its error
+func (m *MockTaskStore) fault(ctx context.Context, op MockOperation) error {
+	m.faultMu.Lock()
+	key := op
+	f, ok := m.faults[key]
+	if !ok {
+		key = MockOpAll
+		f, ok = m.faults[key]
+	}
+	if ok && f.Times > 0 {
+		if f.Times--; f.Times == 0 {
+			delete(m.faults, key)
+		} else {
+			m.faults[key] = f
+		}
+	}
+	m.faultMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return f.Err
+}
+
+// Snapshot returns a copy of the store's tasks
+func (m *MockTaskStore) Snapshot() MockSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return MockSnapshot{tasks: copyMockTasks(m.tasks), seq: m.seq}
+}
+
+// Restore replaces the store's tasks with a snapshot. A snapshot can be
+// restored any number of times.
+func (m *MockTaskStore) Restore(snapshot MockSnapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tasks = copyMockTasks(snapshot.tasks)
+	if m.tasks == nil {
+		m.tasks = make(map[string]map[string]mockTask)
+	}
+	m.seq = snapshot.seq
+}
+
+// copyMockTasks deep copies the tasks map
+func copyMockTasks(tasks map[string]map[string]mockTask) map[string]map[string]mockTask {
+	if tasks == nil {
+		return nil
+	}
+	copied := make(map[string]map[string]mockTask, len(tasks))
+	for owner, ownerTasks := range tasks {
+		copied[owner] = maps.Clone(ownerTasks)
+	}
+	return copied
+}
+
 // Add adds a task to the mock store
 func (m *MockTaskStore) Add(ctx context.Context, task Task) error {
+	if err := m.fault(ctx, MockOpAdd); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(task)
+
+	return nil
+}
+
+// put adds or replaces a task, moving it to the end of its listing and
+// stamping its creation and closing times. The caller must hold the write
+// lock.
+func (m *MockTaskStore) put(task Task) {
 	// Initialize the owner's map if it doesn't exist
 	if _, ok := m.tasks[task.Owner]; !ok {
-		m.tasks[task.Owner] = make(map[string]Task)
+		m.tasks[task.Owner] = make(map[string]mockTask)
 	}
 
-	// Add the task
-	m.tasks[task.Owner][task.ID.String()] = task
+	var state *stats.Task
+	if stored, ok := m.tasks[task.Owner][task.ID.String()]; ok {
+		state = &stored.counted
+	}
 
-	return nil
+	m.seq++
+	m.tasks[task.Owner][task.ID.String()] = mockTask{
+		task:    task,
+		seq:     m.seq,
+		counted: stats.Stamp(state, countedTask(task), time.Now()),
+	}
+}
+
+// get gets a task by ID and owner. The caller must hold the lock.
+func (m *MockTaskStore) get(taskID uuid.UUID, owner string) (Task, bool) {
+	stored, ok := m.tasks[owner][taskID.String()]
+	return stored.task, ok
 }
 
 // GetByID gets a task by ID and owner
 func (m *MockTaskStore) GetByID(ctx context.Context, taskID uuid.UUID, owner string) (Task, error) {
-	// Check if the owner exists
-	ownerTasks, ok := m.tasks[owner]
-	if !ok {
-		return Task{}, fmt.Errorf("task not found")
+	if err := m.fault(ctx, MockOpGetByID); err != nil {
+		return Task{}, err
 	}
 
-	// Check if the task exists
-	task, ok := ownerTasks[taskID.String()]
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	task, ok := m.get(taskID, owner)
 	if !ok {
-		return Task{}, fmt.Errorf("task not found")
+		return Task{}, ErrNotFound
 	}
 
 	return task, nil
@@ -59,21 +257,425 @@ func (m *MockTaskStore) ListClosed(ctx context.Context, owner string) ([]Task, e
 	return m.listByStatus(ctx, owner, TaskStatusClosed)
 }
 
-// listByStatus lists tasks by status for an owner
+// QueryTasks lists an owner's tasks matching a filter, filtering the
+// listings in memory
+func (m *MockTaskStore) QueryTasks(ctx context.Context, owner string, filter TaskFilter) ([]Task, error) {
+	return listMatching(ctx, m, owner, filter)
+}
+
+// listByStatus lists tasks by status for an owner, oldest change first as
+// the GS1 index returns them
 func (m *MockTaskStore) listByStatus(ctx context.Context, owner string, status TaskStatus) ([]Task, error) {
-	// Check if the owner exists
-	ownerTasks, ok := m.tasks[owner]
-	if !ok {
-		return []Task{}, nil
+	if err := m.fault(ctx, MockOpList); err != nil {
+		return nil, err
 	}
 
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	// Filter tasks by status
-	var tasks []Task
-	for _, task := range ownerTasks {
-		if task.Status == status {
-			tasks = append(tasks, task)
+	var matching []mockTask
+	for _, stored := range m.tasks[owner] {
+		if stored.task.Status == status {
+			matching = append(matching, stored)
+		}
+	}
+	slices.SortFunc(matching, func(a, b mockTask) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+
+	tasks := make([]Task, len(matching))
+	for i, stored := range matching {
+		tasks[i] = stored.task
+	}
+
+	return tasks, nil
+}
+
+// BatchGet gets multiple tasks by key, omitting keys that do not exist
+func (m *MockTaskStore) BatchGet(ctx context.Context, keys []TaskKey) (map[TaskKey]Task, error) {
+	if err := m.fault(ctx, MockOpBatchGet); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tasks := make(map[TaskKey]Task, len(keys))
+	for _, key := range keys {
+		if task, ok := m.get(key.ID, key.Owner); ok {
+			tasks[key] = task
 		}
 	}
 
 	return tasks, nil
 }
+
+// BatchWrite applies multiple writes. An injected fault fails every write.
+func (m *MockTaskStore) BatchWrite(ctx context.Context, writes []TaskWrite) []error {
+	errs := make([]error, len(writes))
+	if err := m.fault(ctx, MockOpBatchWrite); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, write := range writes {
+		var stored *Task
+		if existing, ok := m.get(write.Task.ID, write.Task.Owner); ok {
+			stored = &existing
+		}
+		task, err := write.apply(stored)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		if write.Op == OperationDelete {
+			delete(m.tasks[write.Task.Owner], write.Task.ID.String())
+		} else {
+			m.put(task)
+		}
+		writes[i].Task = task
+	}
+
+	return errs
+}
+
+// Transact applies all mutations atomically
+func (m *MockTaskStore) Transact(ctx context.Context, mutations []TaskMutation) error {
+	if err := m.fault(ctx, MockOpTransact); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Check every condition before applying any mutation
+	for i, mutation := range mutations {
+		existing, exists := m.get(mutation.Task.ID, mutation.Task.Owner)
+
+		switch {
+		case mutation.Op == OperationCreate && exists:
+			return &TransactionError{Index: i, Reason: TransactionReasonAlreadyExists}
+		case mutation.Op != OperationCreate && !exists:
+			return &TransactionError{Index: i, Reason: TransactionReasonNotFound}
+		case mutation.Condition != nil && mutation.Condition.Status != "" && existing.Status != mutation.Condition.Status:
+			return &TransactionError{Index: i, Reason: TransactionReasonConditionFailed}
+		}
+	}
+
+	// Apply the mutations
+	for _, mutation := range mutations {
+		owner, id := mutation.Task.Owner, mutation.Task.ID.String()
+		switch mutation.Op {
+		case OperationCreate:
+			m.put(mutation.Task)
+		case OperationUpdate:
+			// Updating the title does not change GS1SK, so keep the position
+			stored := m.tasks[owner][id]
+			stored.task.Title = mutation.Task.Title
+			m.tasks[owner][id] = stored
+		case OperationClose:
+			task, _ := m.get(mutation.Task.ID, owner)
+			task.Status = TaskStatusClosed
+			m.put(task)
+		case OperationDelete:
+			delete(m.tasks[owner], id)
+		}
+	}
+
+	return nil
+}
+
+// PutFeedToken sets an owner's feed token, revoking any previous one
+func (m *MockTaskStore) PutFeedToken(ctx context.Context, owner, hash string) error {
+	if err := m.fault(ctx, MockOpFeedToken); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.feedTokens[owner] = hash
+
+	return nil
+}
+
+// DeleteFeedToken revokes an owner's feed token
+func (m *MockTaskStore) DeleteFeedToken(ctx context.Context, owner string) error {
+	if err := m.fault(ctx, MockOpFeedToken); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.feedTokens[owner]; !ok {
+		return ErrNotFound
+	}
+	delete(m.feedTokens, owner)
+
+	return nil
+}
+
+// FeedTokenOwner returns the owner of a feed token
+func (m *MockTaskStore) FeedTokenOwner(ctx context.Context, hash string) (string, error) {
+	if err := m.fault(ctx, MockOpFeedToken); err != nil {
+		return "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for owner, ownerHash := range m.feedTokens {
+		if ownerHash == hash {
+			return owner, nil
+		}
+	}
+
+	return "", ErrNotFound
+}
+
+// PutView creates or replaces a view
+func (m *MockTaskStore) PutView(ctx context.Context, view View) error {
+	if err := m.fault(ctx, MockOpView); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.views[view.Owner]; !ok {
+		m.views[view.Owner] = make(map[uuid.UUID]View)
+	}
+	m.views[view.Owner][view.ID] = view
+
+	return nil
+}
+
+// GetView gets a view by owner and ID
+func (m *MockTaskStore) GetView(ctx context.Context, owner string, id uuid.UUID) (View, error) {
+	if err := m.fault(ctx, MockOpView); err != nil {
+		return View{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	view, ok := m.views[owner][id]
+	if !ok {
+		return View{}, ErrNotFound
+	}
+
+	return view, nil
+}
+
+// ListViews lists an owner's views
+func (m *MockTaskStore) ListViews(ctx context.Context, owner string) ([]View, error) {
+	if err := m.fault(ctx, MockOpView); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Collect(maps.Values(m.views[owner])), nil
+}
+
+// DeleteView deletes a view and the record of the tasks seen through it
+func (m *MockTaskStore) DeleteView(ctx context.Context, owner string, id uuid.UUID) error {
+	if err := m.fault(ctx, MockOpView); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.views[owner][id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.views[owner], id)
+	delete(m.seen, id)
+
+	return nil
+}
+
+// MarkSeen records that tasks were seen through a view
+func (m *MockTaskStore) MarkSeen(ctx context.Context, owner string, viewID uuid.UUID, taskIDs []uuid.UUID) error {
+	if err := m.fault(ctx, MockOpView); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.seen[viewID]; !ok {
+		m.seen[viewID] = make(map[uuid.UUID]bool)
+	}
+	for _, taskID := range taskIDs {
+		m.seen[viewID][taskID] = true
+	}
+
+	return nil
+}
+
+// SeenTasks returns the IDs of the tasks seen through a view
+func (m *MockTaskStore) SeenTasks(ctx context.Context, owner string, viewID uuid.UUID) (map[uuid.UUID]bool, error) {
+	if err := m.fault(ctx, MockOpView); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return maps.Clone(m.seen[viewID]), nil
+}
+
+// AddWebhook saves a new subscription
+func (m *MockTaskStore) AddWebhook(ctx context.Context, subscription webhooks.Subscription) error {
+	if err := m.fault(ctx, MockOpWebhook); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.subscriptions[subscription.Owner]; !ok {
+		m.subscriptions[subscription.Owner] = make(map[uuid.UUID]webhooks.Subscription)
+	}
+	m.subscriptions[subscription.Owner][subscription.ID] = subscription
+
+	return nil
+}
+
+// GetWebhook gets a subscription by owner and ID
+func (m *MockTaskStore) GetWebhook(ctx context.Context, owner string, id uuid.UUID) (webhooks.Subscription, error) {
+	if err := m.fault(ctx, MockOpWebhook); err != nil {
+		return webhooks.Subscription{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	subscription, ok := m.subscriptions[owner][id]
+	if !ok {
+		return webhooks.Subscription{}, ErrNotFound
+	}
+
+	return subscription, nil
+}
+
+// ListWebhooks lists an owner's subscriptions
+func (m *MockTaskStore) ListWebhooks(ctx context.Context, owner string) ([]webhooks.Subscription, error) {
+	if err := m.fault(ctx, MockOpWebhook); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	subscriptions := []webhooks.Subscription{}
+	for _, subscription := range m.subscriptions[owner] {
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteWebhook deletes a subscription and its deliveries
+func (m *MockTaskStore) DeleteWebhook(ctx context.Context, owner string, id uuid.UUID) error {
+	if err := m.fault(ctx, MockOpWebhook); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.subscriptions[owner][id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.subscriptions[owner], id)
+	delete(m.deliveries, id)
+
+	return nil
+}
+
+// AddDelivery records a delivery to a subscription
+func (m *MockTaskStore) AddDelivery(ctx context.Context, delivery webhooks.Delivery) error {
+	if err := m.fault(ctx, MockOpWebhook); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[delivery.WebhookID] = append(m.deliveries[delivery.WebhookID], delivery)
+
+	return nil
+}
+
+// ListDeliveries lists the latest deliveries to a subscription, newest first
+func (m *MockTaskStore) ListDeliveries(ctx context.Context, owner string, webhookID uuid.UUID, limit int) ([]webhooks.Delivery, error) {
+	if err := m.fault(ctx, MockOpWebhook); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Deliveries are kept oldest first, so reverse them before sorting
+	deliveries := []webhooks.Delivery{}
+	recorded := m.deliveries[webhookID]
+	for i := len(recorded) - 1; i >= 0; i-- {
+		if recorded[i].Owner == owner {
+			deliveries = append(deliveries, recorded[i])
+		}
+	}
+	slices.SortStableFunc(deliveries, func(a, b webhooks.Delivery) int {
+		return b.Created.Compare(a.Created)
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
+
+// Stats counts an owner's tasks. Rather than keeping counters, it counts the
+// tasks on every call, which gives the same counters.
+func (m *MockTaskStore) Stats(ctx context.Context, owner string) (stats.Counters, error) {
+	if err := m.fault(ctx, MockOpStats); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counters := stats.Counters{}
+	for _, stored := range m.tasks[owner] {
+		counters.Add(stats.Count(stored.counted), 1)
+	}
+
+	return counters, nil
+}
+
+// SearchTerms returns the postings of an owner's tasks matching each query
+// term. Rather than keeping an index, it analyzes the tasks on every call.
+func (m *MockTaskStore) SearchTerms(ctx context.Context, owner string, terms []search.Term) ([][]search.Posting, int, error) {
+	if err := m.fault(ctx, MockOpSearch); err != nil {
+		return nil, 0, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	postings := make([][]search.Posting, len(terms))
+	for _, stored := range m.tasks[owner] {
+		taskTerms := search.Terms(stored.task.Title, stored.task.Description)
+		for i, term := range terms {
+			for text, weight := range taskTerms {
+				if term.Matches(text) {
+					postings[i] = append(postings[i], search.Posting{ID: stored.task.ID, Term: text, Weight: weight})
+				}
+			}
+		}
+	}
+
+	return postings, len(m.tasks[owner]), nil
+}
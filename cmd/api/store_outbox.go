@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+	"github.com/user/tasks-api/internal/outbox"
+)
+
+// outboxHead reads an owner's outbox head with a strongly consistent read,
+// so that the entry appended after it is numbered after every committed one
+func (ts *TaskStore) outboxHead(ctx context.Context, owner string) (outbox.Head, error) {
+	item, err := ts.getItem(ctx, keys.OutboxHead(owner), true)
+	if err != nil {
+		return outbox.Head{}, err
+	}
+	return outbox.HeadFromItem(item)
+}
+
+// outboxHeads reads the outbox heads of the owners of mutated tasks
+func (ts *TaskStore) outboxHeads(ctx context.Context, mutations []TaskMutation) (map[string]outbox.Head, error) {
+	heads := make(map[string]outbox.Head)
+	for _, mutation := range mutations {
+		owner := mutation.Task.Owner
+		if _, ok := heads[owner]; ok {
+			continue
+		}
+		head, err := ts.outboxHead(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		heads[owner] = head
+	}
+	return heads, nil
+}
+
+// writeEvent returns the outbox event of writing a task over its stored
+// item, or nil if the write leaves the task unchanged
+func writeEvent(write TaskWrite, stored *DynamoDBTask) (*outbox.Event, error) {
+	if stored == nil {
+		if write.Op != "" {
+			return nil, nil
+		}
+		return taskEvent(outbox.EventTaskCreated, write.Task)
+	}
+
+	// Convert a copy, since converting upgrades the item in place
+	previousItem := *stored
+	previous, err := previousItem.ToTask()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert to task: %w", err)
+	}
+
+	switch {
+	case write.Op == OperationDelete:
+		return taskEvent(outbox.EventTaskDeleted, previous)
+	case reflect.DeepEqual(previous, write.Task):
+		return nil, nil
+	case previous.Status != TaskStatusClosed && write.Task.Status == TaskStatusClosed:
+		return taskEvent(outbox.EventTaskClosed, write.Task)
+	default:
+		return taskEvent(outbox.EventTaskUpdated, write.Task)
+	}
+}
+
+// mutationEvent returns the outbox event of a mutation in a transaction
+// over the stored task it was built from, which is only read for closes and
+// deletes. Closing a task that is closed already has no event.
+func mutationEvent(mutation TaskMutation, stored *DynamoDBTask) (*outbox.Event, error) {
+	var previous *Task
+	if stored != nil {
+		previousItem := *stored
+		task, err := previousItem.ToTask()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert to task: %w", err)
+		}
+		previous = &task
+	}
+
+	switch mutation.Op {
+	case OperationCreate:
+		return taskEvent(outbox.EventTaskCreated, mutation.Task)
+
+	case OperationUpdate:
+		// Updates only carry the title, so the event only has the fields
+		// that identify the task and the title
+		body, err := json.Marshal(struct {
+			ID    uuid.UUID `json:"id"`
+			Owner string    `json:"owner"`
+			Title string    `json:"title"`
+		}{mutation.Task.ID, mutation.Task.Owner, mutation.Task.Title})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal task: %w", err)
+		}
+		return &outbox.Event{Type: outbox.EventTaskUpdated, TaskID: mutation.Task.ID, Task: body}, nil
+
+	case OperationClose:
+		if previous == nil {
+			// The mutation fails, since the task does not exist
+			return &outbox.Event{Type: outbox.EventTaskClosed, TaskID: mutation.Task.ID}, nil
+		}
+		if previous.Status == TaskStatusClosed {
+			return nil, nil
+		}
+		closed := *previous
+		closed.Status = TaskStatusClosed
+		return taskEvent(outbox.EventTaskClosed, closed)
+
+	case OperationDelete:
+		if previous == nil {
+			return &outbox.Event{Type: outbox.EventTaskDeleted, TaskID: mutation.Task.ID}, nil
+		}
+		return taskEvent(outbox.EventTaskDeleted, *previous)
+	}
+
+	return nil, fmt.Errorf("unknown operation: %q", mutation.Op)
+}
+
+// taskEvent returns an outbox event carrying a task
+func taskEvent(eventType string, task Task) (*outbox.Event, error) {
+	body, err := json.Marshal(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task: %w", err)
+	}
+	return &outbox.Event{Type: eventType, TaskID: task.ID, Task: body}, nil
+}
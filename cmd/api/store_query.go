@@ -0,0 +1,247 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+// taskIndex is the table or a secondary index, as a source of an owner's
+// tasks for a query
+type taskIndex struct {
+	// name is the index name, or empty for the table
+	name string
+	// keyCondition returns the key condition and its values reading the
+	// owner's tasks that match a filter, under legacy keys if legacy is set,
+	// and the part of the filter the condition does not cover. It reports
+	// false if the index cannot serve the filter.
+	keyCondition func(owner string, legacy bool, filter TaskFilter) (string, map[string]types.AttributeValue, TaskFilter, bool)
+}
+
+// taskIndexes are the sources a query can read, most selective first. The
+// planner picks the first that can serve a filter, so the table, which
+// serves every filter, comes last. A new GSI becomes available to queries
+// by adding it here.
+var taskIndexes = []taskIndex{
+	{name: "GS1", keyCondition: statusKeyCondition},
+	{name: "", keyCondition: ownerKeyCondition},
+}
+
+// statusKeyCondition reads the GS1 partition of the filter's status
+func statusKeyCondition(owner string, legacy bool, filter TaskFilter) (string, map[string]types.AttributeValue, TaskFilter, bool) {
+	if filter.Status == "" {
+		return "", nil, filter, false
+	}
+
+	gs1pk := keys.TaskStatus(owner, string(filter.Status))
+	if legacy {
+		gs1pk = keys.LegacyTaskStatus(owner, string(filter.Status))
+	}
+	filter.Status = ""
+	return "GS1PK = :pk", map[string]types.AttributeValue{
+		":pk": &types.AttributeValueMemberS{Value: gs1pk},
+	}, filter, true
+}
+
+// ownerKeyCondition reads the task items of the owner's partition
+func ownerKeyCondition(owner string, legacy bool, filter TaskFilter) (string, map[string]types.AttributeValue, TaskFilter, bool) {
+	if legacy {
+		return "PK = :pk", map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: keys.LegacyUser(owner)},
+		}, filter, true
+	}
+	return "PK = :pk AND begins_with(SK, :prefix)", map[string]types.AttributeValue{
+		":pk":     &types.AttributeValueMemberS{Value: keys.User(owner)},
+		":prefix": &types.AttributeValueMemberS{Value: keys.TaskPrefix},
+	}, filter, true
+}
+
+// taskQueryPlan is how TaskStore reads the tasks matching a filter
+type taskQueryPlan struct {
+	// index is the name of the index queried, or empty for the table
+	index string
+	// inputs are the queries to run: the current keys, then the legacy
+	// keys if the store reads them
+	inputs []*dynamodb.QueryInput
+	// remainder is the part of the filter that a filter expression cannot
+	// evaluate, applied to the tasks read
+	remainder TaskFilter
+}
+
+// planQuery picks the index to read an owner's tasks matching a filter
+// from, and applies what its key condition does not cover as a filter
+// expression, except for text, which is matched after reading
+func (ts *TaskStore) planQuery(owner string, filter TaskFilter) taskQueryPlan {
+	plan := taskQueryPlan{remainder: TaskFilter{Text: filter.Text}}
+	filter.Text = nil
+
+	for _, index := range taskIndexes {
+		condition, values, rest, ok := index.keyCondition(owner, false, filter)
+		if !ok {
+			continue
+		}
+		plan.index = index.name
+		plan.inputs = append(plan.inputs, ts.taskQueryInput(index.name, condition, values, rest))
+		if ts.legacyKeys {
+			condition, values, rest, _ := index.keyCondition(owner, true, filter)
+			plan.inputs = append(plan.inputs, ts.taskQueryInput(index.name, condition, values, rest))
+		}
+		break
+	}
+
+	return plan
+}
+
+// taskQueryInput builds a query of an index with a key condition, filtering
+// the items it reads by the rest of a filter
+func (ts *TaskStore) taskQueryInput(index, condition string, values map[string]types.AttributeValue, rest TaskFilter) *dynamodb.QueryInput {
+	filterExpression, names, filterValues := taskFilterExpression(rest)
+	for name, value := range filterValues {
+		values[name] = value
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(ts.tableName),
+		KeyConditionExpression:    aws.String(condition),
+		ExpressionAttributeNames:  nilIfEmpty(names),
+		ExpressionAttributeValues: values,
+	}
+	if index != "" {
+		input.IndexName = aws.String(index)
+	}
+	if filterExpression != "" {
+		input.FilterExpression = aws.String(filterExpression)
+	}
+	if ts.queryPageSize > 0 {
+		input.Limit = aws.Int32(ts.queryPageSize)
+	}
+	return input
+}
+
+// taskFilterExpression builds a filter expression for every part of a
+// filter but Text. A missing attribute fails every comparison, so tasks
+// without a due field never match a due bound.
+func taskFilterExpression(filter TaskFilter) (string, map[string]string, map[string]types.AttributeValue) {
+	var conditions []string
+	names := make(map[string]string)
+	values := make(map[string]types.AttributeValue)
+	value := func(placeholder, v string) string {
+		values[placeholder] = &types.AttributeValueMemberS{Value: v}
+		return placeholder
+	}
+
+	if filter.Status != "" {
+		names["#status"] = "Status"
+		conditions = append(conditions, "#status = "+value(":status", string(filter.Status)))
+	}
+	if filter.Priorities != nil {
+		names["#priority"] = "Priority"
+		placeholders := make([]string, len(filter.Priorities))
+		for i, priority := range filter.Priorities {
+			placeholders[i] = value(fmt.Sprintf(":priority%d", i), string(priority))
+		}
+		conditions = append(conditions, "#priority IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	for i, label := range filter.Labels {
+		names["#labels"] = "Labels"
+		conditions = append(conditions, "contains(#labels, "+value(fmt.Sprintf(":label%d", i), label)+")")
+	}
+	if filter.DueFrom != "" {
+		names["#due"] = "Due"
+		conditions = append(conditions, "#due >= "+value(":dueFrom", filter.DueFrom))
+	}
+	if filter.DueBefore != "" {
+		names["#due"] = "Due"
+		conditions = append(conditions, "#due < "+value(":dueBefore", filter.DueBefore))
+	}
+
+	return strings.Join(conditions, " AND "), names, values
+}
+
+// QueryTasks lists an owner's tasks matching a filter, reading the index
+// planQuery picks
+func (ts *TaskStore) QueryTasks(ctx context.Context, owner string, filter TaskFilter) ([]Task, error) {
+	tasks := []Task{}
+	if filter.matchesNothing() {
+		return tasks, nil
+	}
+
+	plan := ts.planQuery(owner, filter)
+	dbTasks, err := ts.queryTasks(ctx, plan.inputs[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.inputs) > 1 {
+		legacy, err := ts.queryTasks(ctx, plan.inputs[1])
+		if err != nil {
+			return nil, err
+		}
+		current := make(map[string]bool, len(dbTasks))
+		for _, dbTask := range dbTasks {
+			current[dbTask.ID] = true
+		}
+		for _, dbTask := range legacy {
+			if !current[dbTask.ID] {
+				dbTasks = append(dbTasks, dbTask)
+			}
+		}
+	}
+
+	// Order the tasks like listings, whichever index they were read from
+	slices.SortStableFunc(dbTasks, func(a, b DynamoDBTask) int {
+		return cmp.Or(
+			cmp.Compare(statusOrder(a.Status), statusOrder(b.Status)),
+			strings.Compare(a.GS1SK, b.GS1SK),
+		)
+	})
+
+	for _, dbTask := range dbTasks {
+		task, err := ts.taskFromItem(ctx, dbTask)
+		if err != nil {
+			return nil, err
+		}
+		if plan.remainder.Match(task) {
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks, nil
+}
+
+// statusOrder sorts open tasks before closed ones
+func statusOrder(status TaskStatus) int {
+	if status == TaskStatusOpen {
+		return 0
+	}
+	return 1
+}
+
+// queryTasks reads every task item a query returns, in sort key order
+func (ts *TaskStore) queryTasks(ctx context.Context, input *dynamodb.QueryInput) ([]DynamoDBTask, error) {
+	var dbTasks []DynamoDBTask
+	for {
+		result, err := ts.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query tasks: %w", storeError(err))
+		}
+
+		var page []DynamoDBTask
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tasks: %w", err)
+		}
+		dbTasks = append(dbTasks, page...)
+
+		if result.LastEvaluatedKey == nil {
+			return dbTasks, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+	"github.com/user/tasks-api/internal/search"
+)
+
+// SearchTerms returns the postings of an owner's tasks matching each query
+// term, and the number of the owner's indexed tasks
+func (ts *TaskStore) SearchTerms(ctx context.Context, owner string, terms []search.Term) ([][]search.Posting, int, error) {
+	postings := make([][]search.Posting, len(terms))
+	for i, term := range terms {
+		prefix := keys.SearchTermSK(term.Text)
+		if term.Prefix {
+			prefix = keys.SearchTermPrefix + term.Text
+		}
+
+		items, err := ts.queryPrefix(ctx, owner, prefix, "SK, Weight")
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, item := range items {
+			p, err := search.PostingFromItem(item)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to read search term: %w", err)
+			}
+			postings[i] = append(postings[i], p)
+		}
+	}
+
+	docs, err := ts.countPrefix(ctx, owner, keys.SearchDocPrefix)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return postings, docs, nil
+}
+
+// queryPrefix reads every item in an owner's partition whose sort key
+// starts with prefix, with only the projected attributes unless projection
+// is empty
+func (ts *TaskStore) queryPrefix(ctx context.Context, owner, prefix, projection string) ([]map[string]types.AttributeValue, error) {
+	input := ts.prefixQuery(owner, prefix)
+	if projection != "" {
+		input.ProjectionExpression = aws.String(projection)
+	}
+
+	var items []map[string]types.AttributeValue
+	for {
+		result, err := ts.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query items: %w", storeError(err))
+		}
+		items = append(items, result.Items...)
+
+		if result.LastEvaluatedKey == nil {
+			return items, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// countPrefix counts the items in an owner's partition whose sort key starts
+// with prefix
+func (ts *TaskStore) countPrefix(ctx context.Context, owner, prefix string) (int, error) {
+	input := ts.prefixQuery(owner, prefix)
+	input.Select = types.SelectCount
+
+	count := 0
+	for {
+		result, err := ts.client.Query(ctx, input)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count search documents: %w", storeError(err))
+		}
+		count += int(result.Count)
+
+		if result.LastEvaluatedKey == nil {
+			return count, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// prefixQuery builds a query for the items in an owner's partition whose
+// sort key starts with prefix
+func (ts *TaskStore) prefixQuery(owner, prefix string) *dynamodb.QueryInput {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(ts.tableName),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: keys.User(owner)},
+			":prefix": &types.AttributeValueMemberS{Value: prefix},
+		},
+	}
+	if ts.queryPageSize > 0 {
+		input.Limit = aws.Int32(ts.queryPageSize)
+	}
+	return input
+}
+
+// indexUpdate is a task whose search index is brought up to date, or
+// removed from the index if Task is nil
+type indexUpdate struct {
+	Owner string
+	ID    uuid.UUID
+	Task  *Task
+}
+
+// reindex brings the search index of a task up to date with the task, or
+// removes the task from the index if task is nil
+func (ts *TaskStore) reindex(ctx context.Context, owner string, id uuid.UUID, task *Task) error {
+	return ts.reindexTasks(ctx, []indexUpdate{{Owner: owner, ID: id, Task: task}})
+}
+
+// reindexTasks brings the search index of tasks up to date. Only the term
+// items whose weight changed are written, found by comparing with the terms
+// recorded in each task's search document. Each step is taken for all the
+// tasks at once, in batches, and a task updated more than once is indexed
+// as last updated.
+//
+// The index is written after the tasks, so a failure leaves it stale until
+// the tasks are written again. Callers log the error rather than return it,
+// since the task writes have committed and retrying them could duplicate
+// the tasks. While the term items are written, each document records both
+// the old and the new terms, so an interrupted update leaves no term item
+// that a later one would not remove.
+func (ts *TaskStore) reindexTasks(ctx context.Context, updates []indexUpdate) error {
+	last := make(map[keys.Key]indexUpdate, len(updates))
+	var docKeys []keys.Key
+	for _, update := range updates {
+		key := keys.SearchDoc(update.Owner, update.ID)
+		if _, ok := last[key]; !ok {
+			docKeys = append(docKeys, key)
+		}
+		last[key] = update
+	}
+	docs, err := ts.batchGetItems(ctx, docKeys, true)
+	if err != nil {
+		return err
+	}
+
+	var pending, termWrites, final []types.WriteRequest
+	for _, key := range docKeys {
+		update, item := last[key], docs[key]
+		owner, id := update.Owner, update.ID
+		if item == nil && update.Task == nil {
+			continue
+		}
+		var old map[string]int
+		if item != nil {
+			if old, err = search.DocTerms(item); err != nil {
+				return fmt.Errorf("failed to read search document: %w", err)
+			}
+		}
+
+		var terms map[string]int
+		if update.Task != nil {
+			terms = search.Terms(update.Task.Title, update.Task.Description)
+		}
+		put, remove := search.Diff(old, terms)
+		if item != nil && update.Task != nil && len(put) == 0 && len(remove) == 0 {
+			continue
+		}
+
+		// Record the terms about to be written before writing them
+		if len(put) > 0 {
+			recorded := make(map[string]int, len(old)+len(put))
+			for term, weight := range old {
+				recorded[term] = weight
+			}
+			for _, term := range put {
+				recorded[term] = terms[term]
+			}
+			pending = append(pending, types.WriteRequest{
+				PutRequest: &types.PutRequest{Item: search.DocItem(owner, id, recorded)},
+			})
+		}
+		for _, term := range put {
+			termWrites = append(termWrites, types.WriteRequest{
+				PutRequest: &types.PutRequest{Item: search.TermItem(owner, id, term, terms[term])},
+			})
+		}
+		for _, term := range remove {
+			termWrites = append(termWrites, types.WriteRequest{
+				DeleteRequest: &types.DeleteRequest{Key: keys.SearchTerm(owner, term, id).Item()},
+			})
+		}
+
+		// Record the new terms, or remove the document of a deleted task
+		if update.Task == nil {
+			final = append(final, types.WriteRequest{
+				DeleteRequest: &types.DeleteRequest{Key: key.Item()},
+			})
+		} else {
+			final = append(final, types.WriteRequest{
+				PutRequest: &types.PutRequest{Item: search.DocItem(owner, id, terms)},
+			})
+		}
+	}
+
+	for _, requests := range [][]types.WriteRequest{pending, termWrites, final} {
+		if err := ts.batchWriteItems(ctx, requests); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reindexStored brings the search index of a task up to date with the
+// task as stored, reading it with a consistent read
+func (ts *TaskStore) reindexStored(ctx context.Context, owner string, id uuid.UUID) error {
+	item, err := ts.getItem(ctx, keys.Task(owner, id), true)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return ts.reindex(ctx, owner, id, nil)
+	}
+
+	var dbTask DynamoDBTask
+	if err := attributevalue.UnmarshalMap(item, &dbTask); err != nil {
+		return fmt.Errorf("failed to unmarshal task: %w", err)
+	}
+	task, err := dbTask.ToTask()
+	if err != nil {
+		return fmt.Errorf("failed to convert to task: %w", err)
+	}
+	return ts.reindex(ctx, owner, id, &task)
+}
+
+// batchWriteItems writes items other than tasks, such as search index
+// items, in batches, retrying unprocessed items with backoff
+func (ts *TaskStore) batchWriteItems(ctx context.Context, requests []types.WriteRequest) error {
+	for start := 0; start < len(requests); start += batchWriteLimit {
+		pending := requests[start:min(start+batchWriteLimit, len(requests))]
+
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt > maxBatchRetries {
+				return fmt.Errorf("failed to write items: unprocessed after %d retries: %w", maxBatchRetries, ErrThrottled)
+			}
+			if attempt > 0 {
+				if err := batchBackoff(ctx, attempt); err != nil {
+					return err
+				}
+			}
+
+			result, err := ts.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]types.WriteRequest{ts.tableName: pending},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to write items in DynamoDB: %w", storeError(err))
+			}
+			pending = result.UnprocessedItems[ts.tableName]
+		}
+	}
+
+	return nil
+}
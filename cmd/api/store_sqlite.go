@@ -0,0 +1,1081 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/search"
+	"github.com/user/tasks-api/internal/stats"
+	"github.com/user/tasks-api/internal/webhooks"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteTimeFormat is a fixed-width timestamp format, so that timestamps sort
+// the same as strings and as times
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// sqliteMigrations are the schema changes applied in order. The index of a
+// migration plus one is the schema version it produces, which is stored in
+// the database's user_version. Never edit a migration once released; append
+// a new one instead.
+var sqliteMigrations = []string{
+	// 1: tasks keyed by owner and ID, like PK and SK, with an index on owner,
+	// status and last status change, like GS1PK and GS1SK
+	`CREATE TABLE tasks (
+		owner      TEXT NOT NULL,
+		id         TEXT NOT NULL,
+		title      TEXT NOT NULL,
+		status     TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		PRIMARY KEY (owner, id)
+	) WITHOUT ROWID;
+	CREATE INDEX tasks_owner_status ON tasks (owner, status, updated_at, id);`,
+	// 2: calendar feed tokens, one per owner, looked up by the token's hash
+	`CREATE TABLE feed_tokens (
+		owner      TEXT NOT NULL PRIMARY KEY,
+		token_hash TEXT NOT NULL UNIQUE
+	) WITHOUT ROWID;`,
+	// 3: optional task fields imported from calendars
+	`ALTER TABLE tasks ADD COLUMN description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN due TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN uid TEXT NOT NULL DEFAULT '';`,
+	// 4: the search index, with a row per term of each task
+	`CREATE TABLE search_terms (
+		owner   TEXT NOT NULL,
+		term    TEXT NOT NULL,
+		task_id TEXT NOT NULL,
+		weight  INTEGER NOT NULL,
+		PRIMARY KEY (owner, term, task_id)
+	) WITHOUT ROWID;
+	CREATE INDEX search_terms_task ON search_terms (owner, task_id);`,
+	// 5: task priority, and labels joined with commas, which labels cannot
+	// contain
+	`ALTER TABLE tasks ADD COLUMN priority TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN labels TEXT NOT NULL DEFAULT '';`,
+	// 6: saved views, and the tasks seen through each
+	`CREATE TABLE views (
+		owner TEXT NOT NULL,
+		id    TEXT NOT NULL,
+		name  TEXT NOT NULL,
+		query TEXT NOT NULL,
+		PRIMARY KEY (owner, id)
+	) WITHOUT ROWID;
+	CREATE TABLE view_seen (
+		owner   TEXT NOT NULL,
+		view_id TEXT NOT NULL,
+		task_id TEXT NOT NULL,
+		PRIMARY KEY (owner, view_id, task_id)
+	) WITHOUT ROWID;`,
+	// 7: task creation and closing times, which are empty for tasks written
+	// before they were kept, and the counters summarizing each owner's tasks
+	`ALTER TABLE tasks ADD COLUMN created TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN closed TEXT NOT NULL DEFAULT '';
+	CREATE TABLE task_counters (
+		owner TEXT NOT NULL,
+		name  TEXT NOT NULL,
+		value INTEGER NOT NULL,
+		PRIMARY KEY (owner, name)
+	) WITHOUT ROWID;`,
+	// 8: webhook subscriptions, with event types joined with commas, and the
+	// log of deliveries to each, with their attempts as JSON
+	`CREATE TABLE webhooks (
+		owner   TEXT NOT NULL,
+		id      TEXT NOT NULL,
+		url     TEXT NOT NULL,
+		events  TEXT NOT NULL,
+		secret  TEXT NOT NULL,
+		created TEXT NOT NULL,
+		PRIMARY KEY (owner, id)
+	) WITHOUT ROWID;
+	CREATE TABLE webhook_deliveries (
+		owner      TEXT NOT NULL,
+		webhook_id TEXT NOT NULL,
+		created    TEXT NOT NULL,
+		id         TEXT NOT NULL,
+		event_id   TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		status     TEXT NOT NULL,
+		attempts   TEXT NOT NULL,
+		payload    TEXT NOT NULL,
+		PRIMARY KEY (owner, webhook_id, created, id)
+	) WITHOUT ROWID;`,
+}
+
+// sqliteBackfills fill in data that a migration cannot derive in SQL, keyed
+// by the schema version whose migration they follow. They run in the same
+// transaction as the migrations.
+var sqliteBackfills = map[int]func(ctx context.Context, tx *sql.Tx) error{
+	4: indexAllTasks,
+	7: countAllTasks,
+}
+
+// taskColumns are the columns scanTask reads, in order
+const taskColumns = `id, title, status, owner, description, due, recurrence, uid, priority, labels`
+
+// SQLiteTaskStore handles operations on tasks in a SQLite database, for
+// running without DynamoDB
+type SQLiteTaskStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteTaskStore opens or creates the SQLite database at path and
+// migrates it to the latest schema
+func NewSQLiteTaskStore(path string) (*SQLiteTaskStore, error) {
+	// Wait for locks rather than failing immediately, and take the write lock
+	// when a transaction begins so that concurrent transactions cannot deadlock
+	params := url.Values{}
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	store := &SQLiteTaskStore{db: db}
+	if err := store.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+var _ Store = (*SQLiteTaskStore)(nil)
+
+// Close closes the database
+func (s *SQLiteTaskStore) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies any migrations the database has not seen yet
+func (s *SQLiteTaskStore) migrate(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", sqliteError(err))
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", sqliteError(err))
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", version, len(sqliteMigrations))
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		if _, err := tx.ExecContext(ctx, sqliteMigrations[i]); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, sqliteError(err))
+		}
+		if backfill := sqliteBackfills[i+1]; backfill != nil {
+			if err := backfill(ctx, tx); err != nil {
+				return fmt.Errorf("failed to backfill migration %d: %w", i+1, err)
+			}
+		}
+	}
+
+	// PRAGMA does not accept parameters
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", len(sqliteMigrations))); err != nil {
+		return fmt.Errorf("failed to write schema version: %w", sqliteError(err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+// sqlExecer is implemented by *sql.DB and *sql.Tx
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Add adds a task, replacing any task with the same owner and ID
+func (s *SQLiteTaskStore) Add(ctx context.Context, task Task) error {
+	if _, err := s.write(ctx, TaskWrite{Task: task}); err != nil {
+		return fmt.Errorf("failed to put task in SQLite: %w", err)
+	}
+
+	return nil
+}
+
+// write applies a write to the stored task and updates its search index and
+// its owner's counters in one transaction, returning the task as written or
+// as it was before a delete
+func (s *SQLiteTaskStore) write(ctx context.Context, write TaskWrite) (Task, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Task{}, sqliteError(err)
+	}
+	defer tx.Rollback()
+
+	var stored *Task
+	if write.Op != "" {
+		existing, err := getTask(ctx, tx, write.Task.ID, write.Task.Owner)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return Task{}, err
+		}
+		if err == nil {
+			stored = &existing
+		}
+	}
+	task, err := write.apply(stored)
+	if err != nil {
+		return Task{}, err
+	}
+
+	if write.Op == OperationDelete {
+		if err := deleteTask(ctx, tx, task.Owner, task.ID); err != nil {
+			return Task{}, err
+		}
+		err = unindexTask(ctx, tx, task.Owner, task.ID)
+	} else {
+		if err := putTask(ctx, tx, task); err != nil {
+			return Task{}, err
+		}
+		err = indexTask(ctx, tx, task)
+	}
+	if err != nil {
+		return Task{}, err
+	}
+
+	return task, sqliteError(tx.Commit())
+}
+
+// putTask inserts or replaces a task, stamping its creation and closing
+// times and counting it in its owner's counters
+func putTask(ctx context.Context, db sqlExecer, task Task) error {
+	stored, err := storedState(ctx, db, task.Owner, task.ID)
+	if err != nil {
+		return err
+	}
+	stamped := stats.Stamp(stored, countedTask(task), time.Now())
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO tasks (owner, id, title, status, updated_at, description, due, recurrence, uid, priority, labels, created, closed)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (owner, id) DO UPDATE SET
+			title = excluded.title, status = excluded.status, updated_at = excluded.updated_at,
+			description = excluded.description, due = excluded.due,
+			recurrence = excluded.recurrence, uid = excluded.uid,
+			priority = excluded.priority, labels = excluded.labels,
+			created = excluded.created, closed = excluded.closed`,
+		task.Owner, task.ID.String(), task.Title, string(task.Status), sqliteNow(),
+		task.Description, task.Due, task.Recurrence, task.UID,
+		string(task.Priority), strings.Join(task.Labels, ","),
+		stats.FormatTime(stamped.Created), stats.FormatTime(stamped.Closed))
+	if err != nil {
+		return sqliteError(err)
+	}
+
+	return addCounters(ctx, db, task.Owner, stats.Delta(stored, &stamped))
+}
+
+// deleteTask deletes a task, if it exists, and takes it out of its owner's
+// counters
+func deleteTask(ctx context.Context, db sqlExecer, owner string, id uuid.UUID) error {
+	stored, err := storedState(ctx, db, owner, id)
+	if err != nil || stored == nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, `DELETE FROM tasks WHERE owner = ? AND id = ?`, owner, id.String()); err != nil {
+		return sqliteError(err)
+	}
+
+	return addCounters(ctx, db, owner, stats.Delta(stored, nil))
+}
+
+// storedState reads the state of a task that its owner's counters count, or
+// nil if it does not exist
+func storedState(ctx context.Context, db sqlExecer, owner string, id uuid.UUID) (*stats.Task, error) {
+	var state stats.Task
+	var labels, created, closed string
+	err := db.QueryRowContext(ctx, `SELECT status, priority, labels, created, closed FROM tasks WHERE owner = ? AND id = ?`,
+		owner, id.String()).Scan(&state.Status, &state.Priority, &labels, &created, &closed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	if labels != "" {
+		state.Labels = strings.Split(labels, ",")
+	}
+	state.Created, state.Closed = stats.ParseTime(created), stats.ParseTime(closed)
+	return &state, nil
+}
+
+// addCounters adds a delta to an owner's counters
+func addCounters(ctx context.Context, db sqlExecer, owner string, delta stats.Counters) error {
+	for name, value := range delta {
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO task_counters (owner, name, value) VALUES (?, ?, ?)
+			ON CONFLICT (owner, name) DO UPDATE SET value = value + excluded.value`,
+			owner, name, value); err != nil {
+			return sqliteError(err)
+		}
+	}
+	return nil
+}
+
+// GetByID gets a task by ID and owner
+func (s *SQLiteTaskStore) GetByID(ctx context.Context, taskID uuid.UUID, owner string) (Task, error) {
+	task, err := getTask(ctx, s.db, taskID, owner)
+	if errors.Is(err, ErrNotFound) {
+		return Task{}, err
+	}
+	if err != nil {
+		return Task{}, fmt.Errorf("failed to get task from SQLite: %w", err)
+	}
+
+	return task, nil
+}
+
+// getTask gets a task by ID and owner, returning ErrNotFound if it does not exist
+func getTask(ctx context.Context, db sqlExecer, taskID uuid.UUID, owner string) (Task, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM tasks WHERE owner = ? AND id = ?`,
+		owner, taskID.String())
+
+	task, err := scanTask(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Task{}, ErrNotFound
+	}
+
+	return task, err
+}
+
+// ListOpen lists open tasks for an owner
+func (s *SQLiteTaskStore) ListOpen(ctx context.Context, owner string) ([]Task, error) {
+	return s.listByStatus(ctx, owner, TaskStatusOpen)
+}
+
+// ListClosed lists closed tasks for an owner
+func (s *SQLiteTaskStore) ListClosed(ctx context.Context, owner string) ([]Task, error) {
+	return s.listByStatus(ctx, owner, TaskStatusClosed)
+}
+
+// QueryTasks lists an owner's tasks matching a filter, filtering the
+// listings in memory
+func (s *SQLiteTaskStore) QueryTasks(ctx context.Context, owner string, filter TaskFilter) ([]Task, error) {
+	return listMatching(ctx, s, owner, filter)
+}
+
+// listByStatus lists tasks by status for an owner, oldest change first as
+// the GS1 index returns them
+func (s *SQLiteTaskStore) listByStatus(ctx context.Context, owner string, status TaskStatus) ([]Task, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+taskColumns+` FROM tasks
+		WHERE owner = ? AND status = ?
+		ORDER BY updated_at, id`,
+		owner, string(status))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tasks: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	tasks := []Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read task: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query tasks: %w", sqliteError(err))
+	}
+
+	return tasks, nil
+}
+
+// BatchGet gets multiple tasks by key, omitting keys that do not exist
+func (s *SQLiteTaskStore) BatchGet(ctx context.Context, keys []TaskKey) (map[TaskKey]Task, error) {
+	tasks := make(map[TaskKey]Task, len(keys))
+	for _, key := range keys {
+		task, err := getTask(ctx, s.db, key.ID, key.Owner)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to batch get tasks from SQLite: %w", err)
+		}
+		tasks[key] = task
+	}
+
+	return tasks, nil
+}
+
+// BatchWrite applies multiple writes. As with DynamoDB, the writes are not
+// atomic: the returned slice has one entry per write, which is nil if the
+// write succeeded.
+func (s *SQLiteTaskStore) BatchWrite(ctx context.Context, writes []TaskWrite) []error {
+	errs := make([]error, len(writes))
+	for i, write := range writes {
+		task, err := s.write(ctx, write)
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to batch write tasks in SQLite: %w", err)
+			continue
+		}
+		writes[i].Task = task
+	}
+
+	return errs
+}
+
+// Transact applies all mutations atomically. If a mutation's condition does
+// not hold, nothing is applied and the returned error is a *TransactionError
+// identifying the mutation responsible.
+func (s *SQLiteTaskStore) Transact(ctx context.Context, mutations []TaskMutation) error {
+	if len(mutations) > transactionLimit {
+		return fmt.Errorf("too many mutations in transaction: %d, the maximum is %d", len(mutations), transactionLimit)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction in SQLite: %w", sqliteError(err))
+	}
+	defer tx.Rollback()
+
+	for i, mutation := range mutations {
+		if err := transactMutation(ctx, tx, mutation); err != nil {
+			var txErr *TransactionError
+			if errors.As(err, &txErr) {
+				txErr.Index = i
+				return txErr
+			}
+			return fmt.Errorf("failed to execute transaction in SQLite: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction in SQLite: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+// transactMutation checks a mutation's conditions and applies it within a transaction
+func transactMutation(ctx context.Context, tx *sql.Tx, mutation TaskMutation) error {
+	existing, err := getTask(ctx, tx, mutation.Task.ID, mutation.Task.Owner)
+	exists := err == nil
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	// Every mutation except create requires the task to exist
+	switch {
+	case mutation.Op == OperationCreate && exists:
+		return &TransactionError{Reason: TransactionReasonAlreadyExists}
+	case mutation.Op != OperationCreate && !exists:
+		return &TransactionError{Reason: TransactionReasonNotFound}
+	case mutation.Condition != nil && mutation.Condition.Status != "" && existing.Status != mutation.Condition.Status:
+		return &TransactionError{Reason: TransactionReasonConditionFailed}
+	}
+
+	key := []any{mutation.Task.Owner, mutation.Task.ID.String()}
+	switch mutation.Op {
+	case OperationCreate:
+		if err := putTask(ctx, tx, mutation.Task); err != nil {
+			return err
+		}
+		return indexTask(ctx, tx, mutation.Task)
+	case OperationUpdate:
+		if _, err := tx.ExecContext(ctx, `UPDATE tasks SET title = ? WHERE owner = ? AND id = ?`,
+			append([]any{mutation.Task.Title}, key...)...); err != nil {
+			return sqliteError(err)
+		}
+		existing.Title = mutation.Task.Title
+		return indexTask(ctx, tx, existing)
+	case OperationClose:
+		// Closing a task does not change its text, so it is not reindexed
+		existing.Status = TaskStatusClosed
+		return putTask(ctx, tx, existing)
+	case OperationDelete:
+		if err := deleteTask(ctx, tx, mutation.Task.Owner, mutation.Task.ID); err != nil {
+			return err
+		}
+		return unindexTask(ctx, tx, mutation.Task.Owner, mutation.Task.ID)
+	}
+
+	return fmt.Errorf("unknown operation %q", mutation.Op)
+}
+
+// PutFeedToken sets an owner's feed token, revoking any previous one
+func (s *SQLiteTaskStore) PutFeedToken(ctx context.Context, owner, hash string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO feed_tokens (owner, token_hash) VALUES (?, ?)
+		ON CONFLICT (owner) DO UPDATE SET token_hash = excluded.token_hash`,
+		owner, hash)
+	if err != nil {
+		return fmt.Errorf("failed to put feed token in SQLite: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+// DeleteFeedToken revokes an owner's feed token
+func (s *SQLiteTaskStore) DeleteFeedToken(ctx context.Context, owner string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM feed_tokens WHERE owner = ?`, owner)
+	if err != nil {
+		return fmt.Errorf("failed to delete feed token from SQLite: %w", sqliteError(err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete feed token from SQLite: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// FeedTokenOwner returns the owner of a feed token
+func (s *SQLiteTaskStore) FeedTokenOwner(ctx context.Context, hash string) (string, error) {
+	var owner string
+	err := s.db.QueryRowContext(ctx, `SELECT owner FROM feed_tokens WHERE token_hash = ?`, hash).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get feed token from SQLite: %w", sqliteError(err))
+	}
+
+	return owner, nil
+}
+
+// PutView creates or replaces a view
+func (s *SQLiteTaskStore) PutView(ctx context.Context, view View) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO views (owner, id, name, query) VALUES (?, ?, ?, ?)
+		ON CONFLICT (owner, id) DO UPDATE SET name = excluded.name, query = excluded.query`,
+		view.Owner, view.ID.String(), view.Name, view.Query)
+	if err != nil {
+		return fmt.Errorf("failed to put view in SQLite: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+// GetView gets a view by owner and ID
+func (s *SQLiteTaskStore) GetView(ctx context.Context, owner string, id uuid.UUID) (View, error) {
+	view := View{ID: id, Owner: owner}
+	err := s.db.QueryRowContext(ctx, `SELECT name, query FROM views WHERE owner = ? AND id = ?`,
+		owner, id.String()).Scan(&view.Name, &view.Query)
+	if errors.Is(err, sql.ErrNoRows) {
+		return View{}, ErrNotFound
+	}
+	if err != nil {
+		return View{}, fmt.Errorf("failed to get view from SQLite: %w", sqliteError(err))
+	}
+
+	return view, nil
+}
+
+// ListViews lists an owner's views in ID order
+func (s *SQLiteTaskStore) ListViews(ctx context.Context, owner string) ([]View, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, query FROM views WHERE owner = ? ORDER BY id`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query views: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	views := []View{}
+	for rows.Next() {
+		view := View{Owner: owner}
+		var id string
+		if err := rows.Scan(&id, &view.Name, &view.Query); err != nil {
+			return nil, fmt.Errorf("failed to read view: %w", sqliteError(err))
+		}
+		if view.ID, err = uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("failed to convert to view: %w", err)
+		}
+		views = append(views, view)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query views: %w", sqliteError(err))
+	}
+
+	return views, nil
+}
+
+// DeleteView deletes a view and the record of the tasks seen through it in
+// one transaction
+func (s *SQLiteTaskStore) DeleteView(ctx context.Context, owner string, id uuid.UUID) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction in SQLite: %w", sqliteError(err))
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM views WHERE owner = ? AND id = ?`, owner, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete view from SQLite: %w", sqliteError(err))
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete view from SQLite: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM view_seen WHERE owner = ? AND view_id = ?`, owner, id.String()); err != nil {
+		return fmt.Errorf("failed to delete seen tasks from SQLite: %w", sqliteError(err))
+	}
+
+	return sqliteError(tx.Commit())
+}
+
+// MarkSeen records that tasks were seen through a view
+func (s *SQLiteTaskStore) MarkSeen(ctx context.Context, owner string, viewID uuid.UUID, taskIDs []uuid.UUID) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction in SQLite: %w", sqliteError(err))
+	}
+	defer tx.Rollback()
+
+	for _, taskID := range taskIDs {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO view_seen (owner, view_id, task_id) VALUES (?, ?, ?)`,
+			owner, viewID.String(), taskID.String()); err != nil {
+			return fmt.Errorf("failed to mark task seen in SQLite: %w", sqliteError(err))
+		}
+	}
+
+	return sqliteError(tx.Commit())
+}
+
+// SeenTasks returns the IDs of the tasks seen through a view
+func (s *SQLiteTaskStore) SeenTasks(ctx context.Context, owner string, viewID uuid.UUID) (map[uuid.UUID]bool, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT task_id FROM view_seen WHERE owner = ? AND view_id = ?`,
+		owner, viewID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seen tasks: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	seen := make(map[uuid.UUID]bool)
+	for rows.Next() {
+		var rawID string
+		if err := rows.Scan(&rawID); err != nil {
+			return nil, fmt.Errorf("failed to read seen task: %w", sqliteError(err))
+		}
+		id, err := uuid.Parse(rawID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read seen task: %w", err)
+		}
+		seen[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query seen tasks: %w", sqliteError(err))
+	}
+
+	return seen, nil
+}
+
+// AddWebhook saves a new subscription
+func (s *SQLiteTaskStore) AddWebhook(ctx context.Context, subscription webhooks.Subscription) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhooks (owner, id, url, events, secret, created) VALUES (?, ?, ?, ?, ?, ?)`,
+		subscription.Owner, subscription.ID.String(), subscription.URL, strings.Join(subscription.Events, ","),
+		subscription.Secret, subscription.Created.UTC().Format(sqliteTimeFormat))
+	if err != nil {
+		return fmt.Errorf("failed to put webhook in SQLite: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+// GetWebhook gets a subscription by owner and ID
+func (s *SQLiteTaskStore) GetWebhook(ctx context.Context, owner string, id uuid.UUID) (webhooks.Subscription, error) {
+	subscription, err := scanWebhook(s.db.QueryRowContext(ctx, `
+		SELECT owner, id, url, events, secret, created FROM webhooks WHERE owner = ? AND id = ?`,
+		owner, id.String()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhooks.Subscription{}, ErrNotFound
+	}
+	if err != nil {
+		return webhooks.Subscription{}, fmt.Errorf("failed to get webhook from SQLite: %w", sqliteError(err))
+	}
+
+	return subscription, nil
+}
+
+// ListWebhooks lists an owner's subscriptions in ID order
+func (s *SQLiteTaskStore) ListWebhooks(ctx context.Context, owner string) ([]webhooks.Subscription, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT owner, id, url, events, secret, created FROM webhooks WHERE owner = ? ORDER BY id`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	subscriptions := []webhooks.Subscription{}
+	for rows.Next() {
+		subscription, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook: %w", sqliteError(err))
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", sqliteError(err))
+	}
+
+	return subscriptions, nil
+}
+
+// scanWebhook reads a subscription from the columns owner, id, url, events,
+// secret and created
+func scanWebhook(row sqlScanner) (webhooks.Subscription, error) {
+	var subscription webhooks.Subscription
+	var id, events, created string
+	if err := row.Scan(&subscription.Owner, &id, &subscription.URL, &events, &subscription.Secret, &created); err != nil {
+		return webhooks.Subscription{}, err
+	}
+
+	var err error
+	if subscription.ID, err = uuid.Parse(id); err != nil {
+		return webhooks.Subscription{}, fmt.Errorf("invalid webhook ID: %w", err)
+	}
+	if subscription.Created, err = time.Parse(sqliteTimeFormat, created); err != nil {
+		return webhooks.Subscription{}, fmt.Errorf("invalid webhook creation time: %w", err)
+	}
+	if events != "" {
+		subscription.Events = strings.Split(events, ",")
+	}
+	return subscription, nil
+}
+
+// DeleteWebhook deletes a subscription and its deliveries in one transaction
+func (s *SQLiteTaskStore) DeleteWebhook(ctx context.Context, owner string, id uuid.UUID) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction in SQLite: %w", sqliteError(err))
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE owner = ? AND id = ?`, owner, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook from SQLite: %w", sqliteError(err))
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook from SQLite: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE owner = ? AND webhook_id = ?`, owner, id.String()); err != nil {
+		return fmt.Errorf("failed to delete deliveries from SQLite: %w", sqliteError(err))
+	}
+
+	return sqliteError(tx.Commit())
+}
+
+// AddDelivery records a delivery to a subscription
+func (s *SQLiteTaskStore) AddDelivery(ctx context.Context, delivery webhooks.Delivery) error {
+	attempts, err := json.Marshal(delivery.Attempts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal delivery attempts: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (owner, webhook_id, created, id, event_id, event_type, status, attempts, payload)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		delivery.Owner, delivery.WebhookID.String(), delivery.Created.UTC().Format(sqliteTimeFormat), delivery.ID.String(),
+		delivery.EventID, delivery.EventType, string(delivery.Status), string(attempts), string(delivery.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to put delivery in SQLite: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+// ListDeliveries lists the latest deliveries to a subscription, newest first
+func (s *SQLiteTaskStore) ListDeliveries(ctx context.Context, owner string, webhookID uuid.UUID, limit int) ([]webhooks.Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT created, id, event_id, event_type, status, attempts, payload FROM webhook_deliveries
+		WHERE owner = ? AND webhook_id = ? ORDER BY created DESC, id DESC LIMIT ?`,
+		owner, webhookID.String(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	deliveries := []webhooks.Delivery{}
+	for rows.Next() {
+		delivery := webhooks.Delivery{Owner: owner, WebhookID: webhookID}
+		var created, id, status, attempts, payload string
+		if err := rows.Scan(&created, &id, &delivery.EventID, &delivery.EventType, &status, &attempts, &payload); err != nil {
+			return nil, fmt.Errorf("failed to read delivery: %w", sqliteError(err))
+		}
+		if delivery.ID, err = uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("failed to read delivery: %w", err)
+		}
+		if delivery.Created, err = time.Parse(sqliteTimeFormat, created); err != nil {
+			return nil, fmt.Errorf("failed to read delivery: %w", err)
+		}
+		if err := json.Unmarshal([]byte(attempts), &delivery.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to read delivery attempts: %w", err)
+		}
+		delivery.Status = webhooks.DeliveryStatus(status)
+		if payload != "" {
+			delivery.Payload = []byte(payload)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", sqliteError(err))
+	}
+
+	return deliveries, nil
+}
+
+// Stats returns an owner's counters
+func (s *SQLiteTaskStore) Stats(ctx context.Context, owner string) (stats.Counters, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name, value FROM task_counters WHERE owner = ?`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats from SQLite: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	counters := stats.Counters{}
+	for rows.Next() {
+		var name string
+		var value int64
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("failed to get stats from SQLite: %w", sqliteError(err))
+		}
+		counters[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get stats from SQLite: %w", sqliteError(err))
+	}
+
+	return counters, nil
+}
+
+// SearchTerms returns the postings of an owner's tasks matching each query
+// term, and the number of the owner's tasks
+func (s *SQLiteTaskStore) SearchTerms(ctx context.Context, owner string, terms []search.Term) ([][]search.Posting, int, error) {
+	postings := make([][]search.Posting, len(terms))
+	for i, term := range terms {
+		// Terms only contain letters and digits, so they have no GLOB wildcards
+		query := `SELECT task_id, term, weight FROM search_terms WHERE owner = ? AND term = ?`
+		pattern := term.Text
+		if term.Prefix {
+			query = `SELECT task_id, term, weight FROM search_terms WHERE owner = ? AND term GLOB ?`
+			pattern += "*"
+		}
+
+		termPostings, err := queryPostings(ctx, s.db, query, owner, pattern)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to query search index: %w", err)
+		}
+		postings[i] = termPostings
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE owner = ?`, owner).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count tasks: %w", sqliteError(err))
+	}
+
+	return postings, total, nil
+}
+
+// queryPostings reads the task_id, term and weight rows of a query
+func queryPostings(ctx context.Context, db *sql.DB, query string, args ...any) ([]search.Posting, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	var postings []search.Posting
+	for rows.Next() {
+		var p search.Posting
+		var id string
+		if err := rows.Scan(&id, &p.Term, &p.Weight); err != nil {
+			return nil, sqliteError(err)
+		}
+		if p.ID, err = uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("search term has an invalid task ID: %w", err)
+		}
+		postings = append(postings, p)
+	}
+
+	return postings, sqliteError(rows.Err())
+}
+
+// indexTask replaces the search index rows of a task
+func indexTask(ctx context.Context, db sqlExecer, task Task) error {
+	if err := unindexTask(ctx, db, task.Owner, task.ID); err != nil {
+		return err
+	}
+
+	for term, weight := range search.Terms(task.Title, task.Description) {
+		if _, err := db.ExecContext(ctx, `INSERT INTO search_terms (owner, term, task_id, weight) VALUES (?, ?, ?, ?)`,
+			task.Owner, term, task.ID.String(), weight); err != nil {
+			return sqliteError(err)
+		}
+	}
+
+	return nil
+}
+
+// unindexTask deletes the search index rows of a task
+func unindexTask(ctx context.Context, db sqlExecer, owner string, id uuid.UUID) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM search_terms WHERE owner = ? AND task_id = ?`, owner, id.String())
+	return sqliteError(err)
+}
+
+// indexAllTasks indexes every task, for databases created before the search
+// index was introduced
+func indexAllTasks(ctx context.Context, tx *sql.Tx) error {
+	// Backfills run before later migrations, so this reads only the columns
+	// that exist at schema version 4 rather than taskColumns
+	rows, err := tx.QueryContext(ctx, `SELECT owner, id, title, description FROM tasks`)
+	if err != nil {
+		return sqliteError(err)
+	}
+	var tasks []Task
+	for rows.Next() {
+		var task Task
+		var id string
+		if err := rows.Scan(&task.Owner, &id, &task.Title, &task.Description); err != nil {
+			rows.Close()
+			return sqliteError(err)
+		}
+		if task.ID, err = uuid.Parse(id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to convert to task: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return sqliteError(err)
+	}
+
+	for _, task := range tasks {
+		if err := indexTask(ctx, tx, task); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// countAllTasks counts every task in its owner's counters, for databases
+// created before the counters were introduced
+func countAllTasks(ctx context.Context, tx *sql.Tx) error {
+	// Backfills run before later migrations, so this reads only the columns
+	// that exist at schema version 7
+	rows, err := tx.QueryContext(ctx, `SELECT owner, status, priority, labels, created, closed FROM tasks`)
+	if err != nil {
+		return sqliteError(err)
+	}
+	counters := make(map[string]stats.Counters)
+	for rows.Next() {
+		var owner, labels, created, closed string
+		var state stats.Task
+		if err := rows.Scan(&owner, &state.Status, &state.Priority, &labels, &created, &closed); err != nil {
+			rows.Close()
+			return sqliteError(err)
+		}
+		if labels != "" {
+			state.Labels = strings.Split(labels, ",")
+		}
+		state.Created, state.Closed = stats.ParseTime(created), stats.ParseTime(closed)
+		if counters[owner] == nil {
+			counters[owner] = stats.Counters{}
+		}
+		counters[owner].Add(stats.Count(state), 1)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return sqliteError(err)
+	}
+
+	for owner, delta := range counters {
+		if err := addCounters(ctx, tx, owner, delta); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sqlScanner is implemented by *sql.Row and *sql.Rows
+type sqlScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTask reads a task from a row of taskColumns
+func scanTask(row sqlScanner) (Task, error) {
+	var task Task
+	var id, status, priority, labels string
+	if err := row.Scan(&id, &task.Title, &status, &task.Owner,
+		&task.Description, &task.Due, &task.Recurrence, &task.UID, &priority, &labels); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Task{}, err
+		}
+		return Task{}, sqliteError(err)
+	}
+
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return Task{}, fmt.Errorf("failed to convert to task: %w", err)
+	}
+	task.ID = parsed
+	task.Status = TaskStatus(status)
+	task.Priority = TaskPriority(priority)
+	if labels != "" {
+		task.Labels = strings.Split(labels, ",")
+	}
+
+	return task, nil
+}
+
+// sqliteNow returns the current time in sqliteTimeFormat
+func sqliteNow() string {
+	return time.Now().UTC().Format(sqliteTimeFormat)
+}
+
+// sqliteError classifies a SQLite error, wrapping lock timeouts with
+// ErrThrottled and constraint violations with ErrConflict
+func sqliteError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// Extended result codes keep the primary code in the low byte
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return fmt.Errorf("%w: %w", ErrThrottled, err)
+		case sqlite3.SQLITE_CONSTRAINT:
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		}
+	}
+
+	return err
+}
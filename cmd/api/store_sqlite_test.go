@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/search"
+	"github.com/user/tasks-api/internal/stats"
+)
+
+// newTestSQLiteStore creates a SQLite store in a temporary directory
+func newTestSQLiteStore(t *testing.T) *SQLiteTaskStore {
+	t.Helper()
+	store, err := NewSQLiteTaskStore(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatalf("Failed to create SQLite store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteTaskStoreConformance(t *testing.T) {
+	runStoreConformance(t, storeConformance{
+		newStore: func(t *testing.T) Store {
+			return newTestSQLiteStore(t)
+		},
+		ordered:         true,
+		concurrent:      true,
+		clockResolution: time.Millisecond,
+	})
+}
+
+func TestSQLiteTaskStore_ReopenKeepsTasks(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	store, err := NewSQLiteTaskStore(path)
+	if err != nil {
+		t.Fatalf("Failed to create SQLite store: %v", err)
+	}
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	_ = store.Add(ctx, task)
+	store.Close()
+
+	// Act
+	reopened, err := NewSQLiteTaskStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen SQLite store: %v", err)
+	}
+	defer reopened.Close()
+	got, err := reopened.GetByID(ctx, task.ID, task.Owner)
+
+	// Assert
+	if err != nil || !reflect.DeepEqual(got, task) {
+		t.Errorf("Expected %+v, got %+v (%v)", task, got, err)
+	}
+}
+
+func TestSQLiteTaskStore_RejectsNewerSchema(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, _ = db.Exec("PRAGMA user_version = 1000")
+	db.Close()
+
+	// Act
+	_, err = NewSQLiteTaskStore(path)
+
+	// Assert
+	if err == nil {
+		t.Errorf("Expected an error for a newer schema version")
+	}
+}
+
+func TestSQLiteTaskStore_MigrationIndexesExistingTasks(t *testing.T) {
+	// Arrange: a database at schema version 3, before the search index
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for _, migration := range sqliteMigrations[:3] {
+		if _, err := db.Exec(migration); err != nil {
+			t.Fatalf("Failed to apply migration: %v", err)
+		}
+	}
+	task := NewTask(uuid.New(), "Write the quarterly report", "test@example.com")
+	_, _ = db.Exec("PRAGMA user_version = 3")
+	if _, err := db.Exec(`INSERT INTO tasks (owner, id, title, status, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		task.Owner, task.ID.String(), task.Title, string(task.Status), sqliteNow()); err != nil {
+		t.Fatalf("Failed to insert task: %v", err)
+	}
+	db.Close()
+
+	// Act
+	store, err := NewSQLiteTaskStore(path)
+	if err != nil {
+		t.Fatalf("Failed to migrate SQLite store: %v", err)
+	}
+	defer store.Close()
+	postings, total, err := store.SearchTerms(ctx, task.Owner, []search.Term{{Text: "report"}})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if total != 1 || len(postings[0]) != 1 || postings[0][0].ID != task.ID {
+		t.Errorf("Expected the existing task to be indexed, got %v of %d", postings, total)
+	}
+}
+
+func TestSQLiteTaskStore_MigrationCountsExistingTasks(t *testing.T) {
+	// Arrange: a database at schema version 6, before the counters
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for _, migration := range sqliteMigrations[:6] {
+		if _, err := db.Exec(migration); err != nil {
+			t.Fatalf("Failed to apply migration: %v", err)
+		}
+	}
+	_, _ = db.Exec("PRAGMA user_version = 6")
+	owner := "test@example.com"
+	for _, status := range []TaskStatus{TaskStatusOpen, TaskStatusOpen, TaskStatusClosed} {
+		if _, err := db.Exec(`INSERT INTO tasks (owner, id, title, status, updated_at, priority, labels) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			owner, uuid.NewString(), "Task", string(status), sqliteNow(), "HIGH", "bug,ui"); err != nil {
+			t.Fatalf("Failed to insert task: %v", err)
+		}
+	}
+	db.Close()
+
+	// Act
+	store, err := NewSQLiteTaskStore(path)
+	if err != nil {
+		t.Fatalf("Failed to migrate SQLite store: %v", err)
+	}
+	defer store.Close()
+	counters, err := store.Stats(ctx, owner)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := stats.Counters{"status#OPEN": 2, "status#CLOSED": 1, "priority#HIGH": 3, "label#bug": 3, "label#ui": 3}
+	if !reflect.DeepEqual(counters, want) {
+		t.Errorf("Expected %v, got %v", want, counters)
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+	"github.com/user/tasks-api/internal/outbox"
+	"github.com/user/tasks-api/internal/stats"
+)
+
+// maxWriteAttempts is the number of times a write is attempted when the task
+// or its owner's outbox changes between reading them and writing the task
+// with its counters and event. Every write to an owner's tasks appends to
+// the same outbox, so concurrent writes for one owner are retried after a
+// backoff until each has its turn.
+const maxWriteAttempts = 6
+
+// Stats returns an owner's counters, which are empty if the owner has never
+// had a task
+func (ts *TaskStore) Stats(ctx context.Context, owner string) (stats.Counters, error) {
+	item, err := ts.getItem(ctx, keys.Stats(owner), false)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return stats.Counters{}, nil
+	}
+
+	counters, err := stats.FromItem(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stats of %s: %w", owner, err)
+	}
+	return counters, nil
+}
+
+// writeChunk is a run of writes that fits in one transaction, with the
+// indexes of the writes in the batch they came from
+type writeChunk struct {
+	writes  []TaskWrite
+	indexes []int
+}
+
+// writeChunks splits writes into runs that each fit in one transaction with
+// one stats update and one outbox entry per owner. A task written twice
+// starts a new run, since a transaction cannot write an item twice.
+func writeChunks(writes []TaskWrite, legacyKeys bool) []writeChunk {
+	perWrite := 1
+	if legacyKeys {
+		perWrite = 2
+	}
+
+	var chunks []writeChunk
+	var chunk writeChunk
+	owners := map[string]bool{}
+	tasks := map[TaskKey]bool{}
+	for i, write := range writes {
+		owner := write.Task.Owner
+		key := TaskKey{Owner: owner, ID: write.Task.ID}
+
+		// Each owner adds a stats update and an outbox entry and head
+		ownerItems := len(owners) * 3
+		if !owners[owner] {
+			ownerItems += 3
+		}
+		if len(chunk.writes) > 0 && (tasks[key] || (len(chunk.writes)+1)*perWrite+ownerItems > transactionLimit) {
+			chunks = append(chunks, chunk)
+			chunk = writeChunk{}
+			clear(owners)
+			clear(tasks)
+		}
+
+		chunk.writes = append(chunk.writes, write)
+		chunk.indexes = append(chunk.indexes, i)
+		owners[owner] = true
+		tasks[key] = true
+	}
+	if len(chunk.writes) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// storedTasks reads the stored items of written tasks, under their current
+// key or else their legacy key, and the outbox heads of their owners, with
+// strongly consistent reads. A task that does not exist has a nil item.
+func (ts *TaskStore) storedTasks(ctx context.Context, writes []TaskWrite) ([]*DynamoDBTask, map[string]outbox.Head, error) {
+	var itemKeys []keys.Key
+	owners := map[string]bool{}
+	for _, write := range writes {
+		owner, id := write.Task.Owner, write.Task.ID
+		itemKeys = append(itemKeys, keys.Task(owner, id))
+		if ts.legacyKeys {
+			itemKeys = append(itemKeys, keys.LegacyTask(owner, id))
+		}
+		if !owners[owner] {
+			owners[owner] = true
+			itemKeys = append(itemKeys, keys.OutboxHead(owner))
+		}
+	}
+
+	items, err := ts.batchGetItems(ctx, itemKeys, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored := make([]*DynamoDBTask, len(writes))
+	for i, write := range writes {
+		owner, id := write.Task.Owner, write.Task.ID
+		item, ok := items[keys.Task(owner, id)]
+		if !ok && ts.legacyKeys {
+			item, ok = items[keys.LegacyTask(owner, id)]
+		}
+		if !ok {
+			continue
+		}
+		var dbTask DynamoDBTask
+		if err := attributevalue.UnmarshalMap(item, &dbTask); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal task: %w", err)
+		}
+		stored[i] = &dbTask
+	}
+
+	heads := make(map[string]outbox.Head, len(owners))
+	for owner := range owners {
+		head, err := outbox.HeadFromItem(items[keys.OutboxHead(owner)])
+		if err != nil {
+			return nil, nil, err
+		}
+		heads[owner] = head
+	}
+	return stored, heads, nil
+}
+
+// writeTasks applies writes to the stored tasks, applies the changes to
+// their owners' counters and appends their events to the owners' outboxes
+// in one transaction. The transaction only commits if the tasks and the
+// outboxes are unchanged since they were read, and is built again from the
+// new state if not. The writes must fit in one transaction, as split by
+// writeChunks. Updates, closes and deletes of tasks that do not exist write
+// nothing and have ErrNotFound in the returned slice; the Task of each
+// other write is set to the task as written, or as it was before a delete.
+func (ts *TaskStore) writeTasks(ctx context.Context, writes []TaskWrite) ([]error, error) {
+	for attempt := 1; ; attempt++ {
+		stored, heads, err := ts.storedTasks(ctx, writes)
+		if err != nil {
+			return nil, err
+		}
+		applied, missing, err := appliedWrites(writes, stored)
+		if err != nil {
+			return nil, err
+		}
+
+		items, err := ts.writeTaskItems(applied, stored, heads, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > 0 {
+			_, err = ts.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+				TransactItems: items,
+			})
+		}
+		if err == nil {
+			for i := range writes {
+				writes[i].Task = applied[i].Task
+			}
+			return missing, nil
+		}
+		if attempt == maxWriteAttempts || !retryableCancellation(err) {
+			return nil, fmt.Errorf("failed to write task in DynamoDB: %w", transactWriteError(err))
+		}
+		if err := batchBackoff(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// appliedWrites applies updates and closes to the stored tasks, which are
+// nil if there are none, giving puts of the tasks they leave, and gives
+// deletes the task they delete. Writes of tasks that do not exist are left
+// as they are, with ErrNotFound in the second slice.
+func appliedWrites(writes []TaskWrite, stored []*DynamoDBTask) ([]TaskWrite, []error, error) {
+	applied := slices.Clone(writes)
+	missing := make([]error, len(writes))
+	for i, write := range writes {
+		var previous *Task
+		if stored[i] != nil {
+			// Convert a copy, since converting upgrades the item in place
+			item := *stored[i]
+			task, err := item.ToTask()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to convert to task: %w", err)
+			}
+			previous = &task
+		}
+
+		task, err := write.apply(previous)
+		if errors.Is(err, ErrNotFound) {
+			missing[i] = err
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		applied[i].Task = task
+		if write.Op != OperationDelete {
+			applied[i].Op = ""
+		}
+	}
+	return applied, missing, nil
+}
+
+// writeTaskItems builds the transaction items that write tasks over their
+// stored items, which are nil if there are none, followed by one update of
+// each affected owner's stats and one entry in each affected owner's outbox
+// holding the events of the owner's writes, appended after the owner's head.
+// Updating, closing or deleting a task that does not exist writes nothing.
+func (ts *TaskStore) writeTaskItems(writes []TaskWrite, stored []*DynamoDBTask, heads map[string]outbox.Head, now time.Time) ([]types.TransactWriteItem, error) {
+	var items []types.TransactWriteItem
+	var owners, eventOwners []string
+	deltas := map[string]stats.Counters{}
+	events := map[string][]outbox.Event{}
+	for i, write := range writes {
+		taskItems, delta, err := ts.taskWriteItems(write, stored[i], now)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, taskItems...)
+
+		owner := write.Task.Owner
+		event, err := writeEvent(write, stored[i])
+		if err != nil {
+			return nil, err
+		}
+		if event != nil {
+			if events[owner] == nil {
+				eventOwners = append(eventOwners, owner)
+			}
+			events[owner] = append(events[owner], *event)
+		}
+
+		if len(delta) == 0 {
+			continue
+		}
+		if deltas[owner] == nil {
+			owners = append(owners, owner)
+			deltas[owner] = stats.Counters{}
+		}
+		deltas[owner].Add(delta, 1)
+	}
+
+	for _, owner := range owners {
+		items = append(items, types.TransactWriteItem{Update: stats.Update(ts.tableName, owner, deltas[owner])})
+	}
+	for _, owner := range eventOwners {
+		appended, err := outbox.Append(ts.tableName, heads[owner], outbox.Entry{
+			ID:      uuid.New(),
+			Owner:   owner,
+			Events:  events[owner],
+			Created: now,
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, appended...)
+	}
+	if len(items) > transactionLimit {
+		return nil, fmt.Errorf("too many items in transaction: %d with the owners' stats and outboxes, the maximum is %d", len(items), transactionLimit)
+	}
+
+	return items, nil
+}
+
+// taskWriteItems builds the transaction items that write a task over its
+// stored item, which is nil if there is none, and the change the write makes
+// to the owner's stats
+func (ts *TaskStore) taskWriteItems(write TaskWrite, stored *DynamoDBTask, now time.Time) ([]types.TransactWriteItem, stats.Counters, error) {
+	owner, id := write.Task.Owner, write.Task.ID
+	storedLegacy := stored != nil && keys.IsLegacy(stored.PK)
+	var items []types.TransactWriteItem
+	var written *stats.Task
+
+	// Updates, closes and deletes of a task that does not exist write nothing
+	if write.Op != "" && stored == nil {
+		return nil, nil, nil
+	}
+
+	if write.Op == OperationDelete {
+		condition, names, values := unchangedCondition(*stored)
+		condition = uncountedCondition(condition, names, stored)
+		items = append(items, types.TransactWriteItem{
+			Delete: &types.Delete{
+				TableName:                 aws.String(ts.tableName),
+				Key:                       keys.Key{PK: stored.PK, SK: stored.SK}.Item(),
+				ConditionExpression:       aws.String(condition),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		})
+	} else {
+		stamped := stats.Stamp(stored.state(), countedTask(write.Task), now)
+		written = &stamped
+
+		item := ToDynamoDBTask(write.Task)
+		item.Created, item.Closed = stats.FormatTime(stamped.Created), stats.FormatTime(stamped.Closed)
+		item.Counted = true
+		item.Revision = 1
+		if stored != nil {
+			item.Revision = stored.Revision + 1
+		}
+		av, err := attributevalue.MarshalMap(item)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal task: %w", err)
+		}
+
+		put := &types.Put{
+			TableName:           aws.String(ts.tableName),
+			Item:                av,
+			ConditionExpression: aws.String("attribute_not_exists(PK)"),
+		}
+		if stored != nil && !storedLegacy {
+			condition, names, values := unchangedCondition(*stored)
+			condition = uncountedCondition(condition, names, stored)
+			put.ConditionExpression = aws.String(condition)
+			put.ExpressionAttributeNames, put.ExpressionAttributeValues = names, values
+		}
+		items = append(items, types.TransactWriteItem{Put: put})
+	}
+
+	// Remove any copy under the legacy key, which would otherwise still be
+	// listed; a deleted legacy item was already removed above
+	if ts.legacyKeys && !(write.Op == OperationDelete && storedLegacy) {
+		del := &types.Delete{
+			TableName: aws.String(ts.tableName),
+			Key:       keys.LegacyTask(owner, id).Item(),
+		}
+		if storedLegacy {
+			condition, names, values := unchangedCondition(*stored)
+			del.ConditionExpression = aws.String(condition)
+			del.ExpressionAttributeNames, del.ExpressionAttributeValues = names, values
+		}
+		items = append(items, types.TransactWriteItem{Delete: del})
+	}
+
+	return items, stats.Delta(stored.counted(), written), nil
+}
+
+// uncountedCondition extends a condition on a stored task so that it also
+// fails if the task was uncounted when read and has been counted since, by
+// the migration that counts existing tasks
+func uncountedCondition(condition string, names map[string]string, stored *DynamoDBTask) string {
+	if stored == nil || stored.Counted {
+		return condition
+	}
+	names["#counted"] = stats.CountedAttribute
+	return condition + " AND attribute_not_exists(#counted)"
+}
+
+// retryableCancellation reports whether a transaction was cancelled because
+// an item it read changed or was being written concurrently, so that it may
+// succeed if built again
+func retryableCancellation(err error) bool {
+	var cancelled *types.TransactionCanceledException
+	if !errors.As(err, &cancelled) {
+		return false
+	}
+	for _, reason := range cancelled.CancellationReasons {
+		switch aws.ToString(reason.Code) {
+		case TransactionReasonConditionFailed, TransactionReasonConflict:
+			return true
+		}
+	}
+	return false
+}
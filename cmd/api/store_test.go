@@ -2,9 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+	"github.com/user/tasks-api/internal/outbox"
 )
 
 func TestMockTaskStore_Add(t *testing.T) {
@@ -125,3 +134,650 @@ func TestMockTaskStore_ListClosed(t *testing.T) {
 		t.Errorf("Expected task ID to be %v, got %v", closedTask.ID, tasks[0].ID)
 	}
 }
+
+func TestMockTaskStore_BatchGet(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	ctx := context.Background()
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	_ = store.Add(ctx, task)
+	found := TaskKey{Owner: task.Owner, ID: task.ID}
+	missing := TaskKey{Owner: task.Owner, ID: uuid.New()}
+
+	// Act
+	tasks, err := store.BatchGet(ctx, []TaskKey{found, missing})
+
+	// Assert
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Errorf("Expected 1 task, got %d", len(tasks))
+	}
+	if tasks[found].ID != task.ID {
+		t.Errorf("Expected task ID to be %v, got %v", task.ID, tasks[found].ID)
+	}
+}
+
+func TestMockTaskStore_BatchWrite(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	ctx := context.Background()
+	owner := "test@example.com"
+	existing := NewTask(uuid.New(), "Existing Task", owner)
+	_ = store.Add(ctx, existing)
+	created := NewTask(uuid.New(), "New Task", owner)
+
+	// Act
+	errs := store.BatchWrite(ctx, []TaskWrite{
+		{Task: created},
+		{Task: existing, Op: OperationDelete},
+	})
+
+	// Assert
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Expected no error for write %d, got %v", i, err)
+		}
+	}
+	if _, err := store.GetByID(ctx, created.ID, owner); err != nil {
+		t.Errorf("Expected created task to exist, got %v", err)
+	}
+	if _, err := store.GetByID(ctx, existing.ID, owner); err == nil {
+		t.Error("Expected deleted task to be gone")
+	}
+}
+
+func TestMockTaskStore_InjectFault(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	ctx := context.Background()
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	store.InjectFault(MockOpAdd, MockFault{Err: ErrThrottled, Times: 1})
+
+	// Act
+	firstErr := store.Add(ctx, task)
+	secondErr := store.Add(ctx, task)
+
+	// Assert
+	if !errors.Is(firstErr, ErrThrottled) {
+		t.Errorf("Expected the first add to be throttled, got %v", firstErr)
+	}
+	if secondErr != nil {
+		t.Errorf("Expected the fault to apply once, got %v", secondErr)
+	}
+}
+
+func TestMockTaskStore_InjectFaultAll(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	ctx := context.Background()
+	injected := errors.New("injected")
+	store.InjectFault(MockOpAll, MockFault{Err: injected})
+	store.InjectFault(MockOpGetByID, MockFault{Err: ErrNotFound})
+
+	// Act
+	_, listErr := store.ListOpen(ctx, "test@example.com")
+	_, getErr := store.GetByID(ctx, uuid.New(), "test@example.com")
+	writeErrs := store.BatchWrite(ctx, []TaskWrite{{Task: NewTask(uuid.New(), "Task", "test@example.com")}})
+	store.ClearFaults()
+	_, clearedErr := store.ListOpen(ctx, "test@example.com")
+
+	// Assert
+	if !errors.Is(listErr, injected) {
+		t.Errorf("Expected the catch-all fault, got %v", listErr)
+	}
+	if !errors.Is(getErr, ErrNotFound) {
+		t.Errorf("Expected the operation's own fault to take precedence, got %v", getErr)
+	}
+	if !errors.Is(writeErrs[0], injected) {
+		t.Errorf("Expected every batch write to fail, got %v", writeErrs)
+	}
+	if clearedErr != nil {
+		t.Errorf("Expected no error after clearing faults, got %v", clearedErr)
+	}
+}
+
+func TestMockTaskStore_InjectLatency(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	store.InjectFault(MockOpList, MockFault{Latency: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Act
+	_, err := store.ListOpen(ctx, "test@example.com")
+
+	// Assert
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the delay to end with the context, got %v", err)
+	}
+}
+
+func TestMockTaskStore_SnapshotRestore(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	ctx := context.Background()
+	owner := "test@example.com"
+	kept := NewTask(uuid.New(), "Kept", owner)
+	_ = store.Add(ctx, kept)
+	snapshot := store.Snapshot()
+
+	// Act
+	_ = store.Add(ctx, NewTask(uuid.New(), "Discarded", owner))
+	_ = store.BatchWrite(ctx, []TaskWrite{{Task: kept, Op: OperationDelete}})
+	store.Restore(snapshot)
+	restored, _ := store.ListOpen(ctx, owner)
+	_ = store.Add(ctx, NewTask(uuid.New(), "Discarded Again", owner))
+	store.Restore(snapshot)
+	restoredAgain, _ := store.ListOpen(ctx, owner)
+
+	// Assert
+	if len(restored) != 1 || !reflect.DeepEqual(restored[0], kept) {
+		t.Errorf("Expected only %+v after restoring, got %v", kept, restored)
+	}
+	if len(restoredAgain) != 1 || !reflect.DeepEqual(restoredAgain[0], kept) {
+		t.Errorf("Expected the snapshot to be reusable, got %v", restoredAgain)
+	}
+}
+
+func TestTaskStore_TransactItemAttributeNames(t *testing.T) {
+	// Arrange: every attribute an expression names must exist on stored items
+	store := &TaskStore{tableName: "tasks"}
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	stored := ToDynamoDBTask(task)
+	stored.Created, stored.Closed, stored.Counted, stored.Revision = "2026-10-12T09:00:00Z", "2026-10-14T09:00:00Z", true, 2
+	item, err := attributevalue.MarshalMap(stored)
+	if err != nil {
+		t.Fatalf("Failed to marshal task: %v", err)
+	}
+	condition := &TaskCondition{Status: TaskStatusOpen}
+
+	for _, op := range []OperationType{OperationUpdate, OperationClose, OperationDelete} {
+		t.Run(string(op), func(t *testing.T) {
+			// Act
+			transactItem, _, err := store.transactItem(TaskMutation{Op: op, Task: task, Condition: condition}, &stored, time.Now())
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			var names map[string]string
+			switch {
+			case transactItem.Update != nil:
+				names = transactItem.Update.ExpressionAttributeNames
+			case transactItem.Delete != nil:
+				names = transactItem.Delete.ExpressionAttributeNames
+			}
+			if len(names) == 0 {
+				t.Fatal("Expected the expression to name attributes")
+			}
+			for placeholder, name := range names {
+				if _, ok := item[name]; !ok {
+					t.Errorf("Expected %s to name a stored attribute, got %q", placeholder, name)
+				}
+			}
+		})
+	}
+}
+
+func TestTaskStore_TransactItemsOutbox(t *testing.T) {
+	// Arrange: mutations of two owners' tasks, one closing a closed task
+	store := &TaskStore{tableName: "tasks"}
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	created := NewTask(uuid.New(), "Created", "a@example.com")
+	open := ToDynamoDBTask(NewTask(uuid.New(), "Open", "a@example.com"))
+	closedTask := NewTask(uuid.New(), "Closed", "a@example.com")
+	closedTask.Status = TaskStatusClosed
+	closed := ToDynamoDBTask(closedTask)
+	mutations := []TaskMutation{
+		{Op: OperationCreate, Task: created},
+		{Op: OperationUpdate, Task: Task{ID: uuid.New(), Owner: "b@example.com", Title: "Renamed"}},
+		{Op: OperationClose, Task: Task{ID: uuid.MustParse(open.ID), Owner: "a@example.com"}},
+		{Op: OperationClose, Task: Task{ID: closedTask.ID, Owner: "a@example.com"}},
+	}
+	stored := []*DynamoDBTask{nil, nil, &open, &closed}
+	heads := map[string]outbox.Head{"a@example.com": {Sequence: 7, Delivered: 7}}
+
+	// Act
+	items, err := store.transactItems(mutations, stored, heads, now)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var entries []outbox.Entry
+	var conditions []string
+	for _, item := range items[len(mutations):] {
+		switch {
+		case item.Put != nil:
+			entry, err := outbox.EntryFromItem(item.Put.Item)
+			if err != nil {
+				t.Fatalf("Failed to read entry: %v", err)
+			}
+			entries = append(entries, entry)
+		case keys.FromItem(item.Update.Key).SK == keys.OutboxHead("").SK:
+			conditions = append(conditions, aws.ToString(item.Update.ConditionExpression))
+		}
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected an entry for each owner, got %+v", entries)
+	}
+	a, b := entries[0], entries[1]
+	if a.Owner != "a@example.com" || a.Sequence != 8 || len(a.Events) != 2 ||
+		a.Events[0].Type != outbox.EventTaskCreated || a.Events[1].Type != outbox.EventTaskClosed || a.Events[1].TaskID.String() != open.ID {
+		t.Errorf("Expected entry 8 creating and closing a's tasks in order, got %+v", a)
+	}
+	if b.Owner != "b@example.com" || b.Sequence != 1 || len(b.Events) != 1 || b.Events[0].Type != outbox.EventTaskUpdated ||
+		!strings.Contains(string(b.Events[0].Task), `"title":"Renamed"`) {
+		t.Errorf("Expected entry 1 renaming b's task, got %+v", b)
+	}
+	if want := []string{"#sequence = :previous", "attribute_not_exists(#sequence)"}; !reflect.DeepEqual(conditions, want) {
+		t.Errorf("Expected the heads to require %v, got %v", want, conditions)
+	}
+}
+
+func TestStaleTransactionOutboxHead(t *testing.T) {
+	// Arrange: the transaction's last items append to the outbox
+	mutations := []TaskMutation{{Op: OperationCreate, Task: NewTask(uuid.New(), "Test Task", "test@example.com")}}
+	none := types.CancellationReason{Code: aws.String("None")}
+	failed := types.CancellationReason{Code: aws.String(TransactionReasonConditionFailed)}
+
+	// Act
+	headChanged := staleTransaction(mutations, []*DynamoDBTask{nil}, []types.CancellationReason{none, none, failed, none})
+	taskExists := staleTransaction(mutations, []*DynamoDBTask{nil}, []types.CancellationReason{failed, none, none, none})
+
+	// Assert
+	if !headChanged {
+		t.Error("Expected a failed outbox head condition to make the transaction stale")
+	}
+	if taskExists {
+		t.Error("Expected a failed create not to make the transaction stale")
+	}
+}
+
+func TestTransactionError(t *testing.T) {
+	// Arrange
+	owner := "test@example.com"
+	mutations := []TaskMutation{
+		{Op: OperationCreate, Task: NewTask(uuid.New(), "New Task", owner)},
+		{Op: OperationClose, Task: NewTask(uuid.New(), "", owner)},
+		{Op: OperationClose, Task: NewTask(uuid.New(), "", owner), Condition: &TaskCondition{Status: TaskStatusOpen}},
+	}
+	existing := map[string]types.AttributeValue{
+		"PK":       &types.AttributeValueMemberS{Value: "USER#" + owner},
+		"Revision": &types.AttributeValueMemberN{Value: "2"},
+	}
+	read := &DynamoDBTask{Revision: 2, Counted: true}
+	changed := &DynamoDBTask{Revision: 1, Counted: true}
+	tests := []struct {
+		name    string
+		stored  []*DynamoDBTask
+		reasons []types.CancellationReason
+		index   int
+		reason  string
+	}{
+		{
+			name:    "create of existing task",
+			stored:  []*DynamoDBTask{nil, nil, read},
+			reasons: []types.CancellationReason{{Code: aws.String("ConditionalCheckFailed"), Item: existing}, {Code: aws.String("None")}, {Code: aws.String("None")}},
+			index:   0,
+			reason:  TransactionReasonAlreadyExists,
+		},
+		{
+			name:    "close of missing task",
+			stored:  []*DynamoDBTask{nil, nil, read},
+			reasons: []types.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")}},
+			index:   1,
+			reason:  TransactionReasonNotFound,
+		},
+		{
+			name:    "failed status condition",
+			stored:  []*DynamoDBTask{nil, nil, read},
+			reasons: []types.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("None")}, {Code: aws.String("ConditionalCheckFailed"), Item: existing}},
+			index:   2,
+			reason:  TransactionReasonConditionFailed,
+		},
+		{
+			name:    "conflict",
+			stored:  []*DynamoDBTask{nil, nil, read},
+			reasons: []types.CancellationReason{{Code: aws.String("TransactionConflict")}, {Code: aws.String("None")}, {Code: aws.String("None")}},
+			index:   0,
+			reason:  TransactionReasonConflict,
+		},
+		{
+			name:    "task changed after it was read",
+			stored:  []*DynamoDBTask{nil, changed, read},
+			reasons: []types.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("ConditionalCheckFailed"), Item: existing}, {Code: aws.String("None")}},
+			index:   1,
+			reason:  TransactionReasonConflict,
+		},
+		{
+			name:    "outbox changed after it was read",
+			stored:  []*DynamoDBTask{nil, nil, read},
+			reasons: []types.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("None")}, {Code: aws.String("None")}, {Code: aws.String("ConditionalCheckFailed")}},
+			index:   0,
+			reason:  TransactionReasonConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			txErr := transactionError(mutations, tt.stored, tt.reasons)
+
+			// Assert
+			if txErr == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if txErr.Index != tt.index {
+				t.Errorf("Expected index %d, got %d", tt.index, txErr.Index)
+			}
+			if txErr.Reason != tt.reason {
+				t.Errorf("Expected reason %s, got %s", tt.reason, txErr.Reason)
+			}
+		})
+	}
+}
+
+func TestMergeLegacyTasks(t *testing.T) {
+	// Arrange
+	current := []DynamoDBTask{
+		{ID: "a", GS1SK: "#2024-01-01T00:00:01Z"},
+		{ID: "c", GS1SK: "#2024-01-01T00:00:03Z"},
+	}
+	legacy := []DynamoDBTask{
+		{ID: "b", GS1SK: "#2024-01-01T00:00:02Z"},
+		{ID: "c", GS1SK: "#2024-01-01T00:00:00Z"},
+		{ID: "d", GS1SK: "#2024-01-01T00:00:04Z"},
+	}
+
+	// Act
+	merged := mergeLegacyTasks(current, legacy)
+
+	// Assert
+	var ids []string
+	for _, dbTask := range merged {
+		ids = append(ids, dbTask.ID)
+	}
+	if strings.Join(ids, ",") != "a,b,c,d" {
+		t.Errorf("Expected a,b,c,d, got %v", ids)
+	}
+	if merged[2].GS1SK != "#2024-01-01T00:00:03Z" {
+		t.Errorf("Expected the current item for c, got %+v", merged[2])
+	}
+}
+
+func TestTaskStore_WriteTaskItems(t *testing.T) {
+	// Arrange
+	store := &TaskStore{tableName: "tasks"}
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	head := outbox.Head{Sequence: 4, Delivered: 2}
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	counted := ToDynamoDBTask(task)
+	counted.Created, counted.Counted, counted.Revision = "2026-10-12T09:00:00Z", true, 3
+	uncounted := ToDynamoDBTask(task)
+	closedTask := task
+	closedTask.Status = TaskStatusClosed
+	renamed := task
+	renamed.Title = "Renamed Task"
+
+	tests := []struct {
+		name   string
+		write  TaskWrite
+		stored *DynamoDBTask
+		items  int
+		delta  map[string]string
+		event  string
+	}{
+		{"new task", TaskWrite{Task: task}, nil, 4, map[string]string{"status#OPEN": "1"}, outbox.EventTaskCreated},
+		{"closing", TaskWrite{Task: closedTask}, &counted, 4, map[string]string{
+			"status#OPEN": "-1", "status#CLOSED": "1", "closed#2026-W42": "1", "closeSeconds": "172800", "timedCloses": "1",
+		}, outbox.EventTaskClosed},
+		{"closing an uncounted task", TaskWrite{Task: closedTask}, &uncounted, 4, map[string]string{
+			"status#CLOSED": "1", "closed#2026-W42": "1",
+		}, outbox.EventTaskClosed},
+		{"renaming", TaskWrite{Task: renamed}, &counted, 3, nil, outbox.EventTaskUpdated},
+		{"unchanged", TaskWrite{Task: task}, &counted, 1, nil, ""},
+		{"deleting", TaskWrite{Task: task, Op: OperationDelete}, &counted, 4, map[string]string{"status#OPEN": "-1"}, outbox.EventTaskDeleted},
+		{"deleting an uncounted task", TaskWrite{Task: task, Op: OperationDelete}, &uncounted, 3, nil, outbox.EventTaskDeleted},
+		{"deleting a missing task", TaskWrite{Task: task, Op: OperationDelete}, nil, 0, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			items, err := store.writeTaskItems([]TaskWrite{tt.write}, []*DynamoDBTask{tt.stored}, map[string]outbox.Head{task.Owner: head}, now)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(items) != tt.items {
+				t.Fatalf("Expected %d items, got %d", tt.items, len(items))
+			}
+			delta := map[string]string{}
+			for _, item := range items {
+				if item.Update == nil || keys.FromItem(item.Update.Key) != keys.Stats(task.Owner) {
+					continue
+				}
+				for placeholder, name := range item.Update.ExpressionAttributeNames {
+					value := item.Update.ExpressionAttributeValues[":"+strings.TrimPrefix(placeholder, "#")]
+					delta[name] = value.(*types.AttributeValueMemberN).Value
+				}
+			}
+			if len(delta) != len(tt.delta) || (len(delta) > 0 && !reflect.DeepEqual(delta, tt.delta)) {
+				t.Errorf("Expected %v, got %v", tt.delta, delta)
+			}
+			if tt.event != "" {
+				entry, err := outbox.EntryFromItem(items[len(items)-1].Put.Item)
+				if err != nil || entry.Sequence != head.Sequence+1 || len(entry.Events) != 1 || entry.Events[0].Type != tt.event {
+					t.Errorf("Expected entry %d with a %s event, got %+v (%v)", head.Sequence+1, tt.event, entry, err)
+				}
+			}
+			if len(items) > 0 && tt.stored != nil {
+				var condition *string
+				if put := items[0].Put; put != nil {
+					condition = put.ConditionExpression
+				} else {
+					condition = items[0].Delete.ConditionExpression
+				}
+				if !strings.Contains(aws.ToString(condition), "#revision") {
+					t.Errorf("Expected the write to require the stored task's revision, got %q", aws.ToString(condition))
+				}
+				if put := items[0].Put; put != nil && itemRevision(put.Item) != tt.stored.Revision+1 {
+					t.Errorf("Expected revision %d to be written, got %v", tt.stored.Revision+1, put.Item["Revision"])
+				}
+				if !tt.stored.Counted && !strings.Contains(aws.ToString(condition), "attribute_not_exists(#counted)") {
+					t.Errorf("Expected the write to require the stored task still uncounted, got %q", aws.ToString(condition))
+				}
+			}
+		})
+	}
+}
+
+func TestTaskStore_WriteTaskItemsCombinesOwners(t *testing.T) {
+	// Arrange
+	store := &TaskStore{tableName: "tasks"}
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	heads := map[string]outbox.Head{"a@example.com": {Sequence: 4}, "b@example.com": {Sequence: 7}}
+	writes := []TaskWrite{
+		{Task: NewTask(uuid.New(), "First", "a@example.com")},
+		{Task: NewTask(uuid.New(), "Second", "b@example.com")},
+		{Task: NewTask(uuid.New(), "Third", "a@example.com")},
+	}
+
+	// Act
+	items, err := store.writeTaskItems(writes, make([]*DynamoDBTask, len(writes)), heads, now)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(items) != 3+2+2*2 {
+		t.Fatalf("Expected 3 tasks, 2 stats updates and 2 outbox entries, got %d items", len(items))
+	}
+	opened := items[3].Update.ExpressionAttributeValues[":c0"].(*types.AttributeValueMemberN).Value
+	if owner := keys.FromItem(items[3].Update.Key); owner != keys.Stats("a@example.com") || opened != "2" {
+		t.Errorf("Expected the first owner's tasks counted together, got %v for %v", opened, owner)
+	}
+	entry, err := outbox.EntryFromItem(items[6].Put.Item)
+	if err != nil || entry.Owner != "a@example.com" || entry.Sequence != 5 || len(entry.Events) != 2 {
+		t.Errorf("Expected one entry with both of the first owner's events, got %+v (%v)", entry, err)
+	}
+}
+
+func TestWriteChunks(t *testing.T) {
+	// Arrange
+	task := NewTask(uuid.New(), "Task", "a@example.com")
+	var writes []TaskWrite
+	for i := range 60 {
+		owner := "a@example.com"
+		if i%2 == 1 {
+			owner = "b@example.com"
+		}
+		writes = append(writes, TaskWrite{Task: NewTask(uuid.New(), "Task", owner)})
+	}
+	repeated := []TaskWrite{{Task: task}, {Task: NewTask(uuid.New(), "Other", task.Owner)}, {Task: task, Op: OperationDelete}}
+
+	// Act
+	chunks := writeChunks(writes, true)
+	repeatedChunks := writeChunks(repeated, false)
+
+	// Assert: two writes per task with the legacy key, and three items for
+	// each of the two owners
+	if len(chunks) != 2 || len(chunks[0].writes) != 47 || chunks[1].indexes[0] != 47 {
+		t.Errorf("Expected chunks of 47 and 13 writes, got %d chunks", len(chunks))
+	}
+	if len(repeatedChunks) != 2 || len(repeatedChunks[0].writes) != 2 || repeatedChunks[1].indexes[0] != 2 {
+		t.Errorf("Expected a task written twice to start a new chunk, got %+v", repeatedChunks)
+	}
+}
+
+func TestAppliedWrites(t *testing.T) {
+	// Arrange: the stored task has fields the writes do not carry
+	task := NewTask(uuid.New(), "Stored", "test@example.com")
+	task.Description = "Notes"
+	task.Labels = []string{"home"}
+	item := ToDynamoDBTask(task)
+	item.Revision = 3
+	writes := []TaskWrite{
+		{Op: OperationUpdate, Task: Task{ID: task.ID, Owner: task.Owner, Title: "Renamed"}},
+		{Op: OperationClose, Task: Task{ID: task.ID, Owner: task.Owner}},
+		{Op: OperationDelete, Task: Task{ID: task.ID, Owner: task.Owner}},
+		{Op: OperationClose, Task: Task{ID: uuid.New(), Owner: task.Owner}},
+	}
+
+	// Act
+	applied, missing, err := appliedWrites(writes, []*DynamoDBTask{&item, &item, &item, nil})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	renamed, closed := task, task
+	renamed.Title = "Renamed"
+	closed.Status = TaskStatusClosed
+	want := []TaskWrite{{Task: renamed}, {Task: closed}, {Op: OperationDelete, Task: task}, writes[3]}
+	if !reflect.DeepEqual(applied, want) {
+		t.Errorf("Expected %+v, got %+v", want, applied)
+	}
+	if missing[0] != nil || missing[1] != nil || missing[2] != nil || !errors.Is(missing[3], ErrNotFound) {
+		t.Errorf("Expected only the last task to be missing, got %v", missing)
+	}
+	if writes[0].Task.Title != "Renamed" || writes[0].Task.Description != "" {
+		t.Errorf("Expected the writes to be left as they are, got %+v", writes[0])
+	}
+}
+
+func TestTaskStore_PlanQuery(t *testing.T) {
+	// Arrange
+	store := &TaskStore{tableName: "tasks"}
+	owner := "test@example.com"
+
+	tests := []struct {
+		name       string
+		query      string
+		index      string
+		keyPK      string
+		filter     string
+		remainText bool
+	}{
+		{"status reads GS1", "status:closed", "GS1", keys.TaskStatus(owner, "CLOSED"), "", false},
+		{"rest is filtered", "status:open label:bug priority>=high due<2026-11-01", "GS1", keys.TaskStatus(owner, "OPEN"),
+			"#priority IN (:priority0, :priority1) AND contains(#labels, :label0) AND #due < :dueBefore", false},
+		{"no status reads the partition", "label:bug", "", keys.User(owner), "contains(#labels, :label0)", false},
+		{"text is matched after reading", "status:open login", "GS1", keys.TaskStatus(owner, "OPEN"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseTaskQuery(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to parse query: %v", err)
+			}
+
+			// Act
+			plan := store.planQuery(owner, filter)
+
+			// Assert
+			if plan.index != tt.index || len(plan.inputs) != 1 {
+				t.Fatalf("Expected one query of %q, got %d of %q", tt.index, len(plan.inputs), plan.index)
+			}
+			input := plan.inputs[0]
+			if pk, ok := input.ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS); !ok || pk.Value != tt.keyPK {
+				t.Errorf("Expected partition key %q, got %v", tt.keyPK, input.ExpressionAttributeValues[":pk"])
+			}
+			if got := aws.ToString(input.FilterExpression); got != tt.filter {
+				t.Errorf("Expected filter %q, got %q", tt.filter, got)
+			}
+			if (len(plan.remainder.Text) > 0) != tt.remainText {
+				t.Errorf("Expected text in the remainder: %v, got %+v", tt.remainText, plan.remainder)
+			}
+		})
+	}
+}
+
+func TestTaskStore_PlanQueryLegacyKeys(t *testing.T) {
+	// Arrange
+	store := &TaskStore{tableName: "tasks", legacyKeys: true}
+	filter, _ := ParseTaskQuery("label:bug")
+
+	// Act
+	plan := store.planQuery("test@example.com", filter)
+
+	// Assert
+	if len(plan.inputs) != 2 {
+		t.Fatalf("Expected a query of the current and the legacy keys, got %d", len(plan.inputs))
+	}
+	pk, _ := plan.inputs[1].ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS)
+	if pk == nil || pk.Value != keys.LegacyUser("test@example.com") {
+		t.Errorf("Expected the legacy partition, got %v", plan.inputs[1].ExpressionAttributeValues)
+	}
+}
+
+func TestTaskFilterExpressionAttributeNames(t *testing.T) {
+	// Arrange: every attribute the filter names must exist on stored items
+	task := NewTask(uuid.New(), "Test Task", "test@example.com")
+	task.Priority = TaskPriorityHigh
+	task.Labels = []string{"bug"}
+	task.Due = "2026-10-31"
+	item, err := attributevalue.MarshalMap(ToDynamoDBTask(task))
+	if err != nil {
+		t.Fatalf("Failed to marshal task: %v", err)
+	}
+	filter, _ := ParseTaskQuery("status:open label:bug priority>=high due<2026-11-01")
+
+	// Act
+	_, names, values := taskFilterExpression(filter)
+
+	// Assert
+	if len(values) != 5 {
+		t.Errorf("Expected 5 values, got %v", values)
+	}
+	for placeholder, name := range names {
+		if _, ok := item[name]; !ok {
+			t.Errorf("Expected %s to name a stored attribute, got %q", placeholder, name)
+		}
+	}
+}
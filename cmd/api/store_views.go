@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+// PutView creates or replaces a view
+func (ts *TaskStore) PutView(ctx context.Context, view View) error {
+	item, err := attributevalue.MarshalMap(ToDynamoDBView(view))
+	if err != nil {
+		return fmt.Errorf("failed to marshal view: %w", err)
+	}
+
+	_, err = ts.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(ts.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put view in DynamoDB: %w", storeError(err))
+	}
+
+	return nil
+}
+
+// GetView gets a view by owner and ID
+func (ts *TaskStore) GetView(ctx context.Context, owner string, id uuid.UUID) (View, error) {
+	item, err := ts.getItem(ctx, keys.View(owner, id), false)
+	if err != nil {
+		return View{}, err
+	}
+	if item == nil {
+		return View{}, ErrNotFound
+	}
+
+	return viewFromItem(item)
+}
+
+// ListViews lists an owner's views in ID order
+func (ts *TaskStore) ListViews(ctx context.Context, owner string) ([]View, error) {
+	items, err := ts.queryPrefix(ctx, owner, keys.ViewPrefix, "")
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]View, 0, len(items))
+	for _, item := range items {
+		view, err := viewFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+
+	return views, nil
+}
+
+// viewFromItem converts an item to a View
+func viewFromItem(item map[string]types.AttributeValue) (View, error) {
+	var dbView DynamoDBView
+	if err := attributevalue.UnmarshalMap(item, &dbView); err != nil {
+		return View{}, fmt.Errorf("failed to unmarshal view: %w", err)
+	}
+	view, err := dbView.ToView()
+	if err != nil {
+		return View{}, fmt.Errorf("failed to convert to view: %w", err)
+	}
+	return view, nil
+}
+
+// DeleteView deletes a view, then the items recording the tasks seen
+// through it. If deleting those fails, they are left behind but unused,
+// since a view's ID is never reused.
+func (ts *TaskStore) DeleteView(ctx context.Context, owner string, id uuid.UUID) error {
+	_, err := ts.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(ts.tableName),
+		Key:                 keys.View(owner, id).Item(),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete view from DynamoDB: %w", storeError(err))
+	}
+
+	items, err := ts.queryPrefix(ctx, owner, keys.ViewSeenSK(id), "PK, SK")
+	if err != nil {
+		return err
+	}
+	requests := make([]types.WriteRequest, len(items))
+	for i, item := range items {
+		requests[i] = types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: item}}
+	}
+	return ts.batchWriteItems(ctx, requests)
+}
+
+// MarkSeen puts an item per task recording that it was seen through a
+// view. Putting an item again changes nothing, so marking is idempotent.
+func (ts *TaskStore) MarkSeen(ctx context.Context, owner string, viewID uuid.UUID, taskIDs []uuid.UUID) error {
+	requests := make([]types.WriteRequest, len(taskIDs))
+	for i, taskID := range taskIDs {
+		item := keys.ViewSeen(owner, viewID, taskID).Item()
+		item["entity_type"] = &types.AttributeValueMemberS{Value: string(keys.EntityViewSeen)}
+		requests[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+	}
+	return ts.batchWriteItems(ctx, requests)
+}
+
+// SeenTasks returns the IDs of the tasks seen through a view
+func (ts *TaskStore) SeenTasks(ctx context.Context, owner string, viewID uuid.UUID) (map[uuid.UUID]bool, error) {
+	items, err := ts.queryPrefix(ctx, owner, keys.ViewSeenSK(viewID), "PK, SK")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uuid.UUID]bool, len(items))
+	for _, item := range items {
+		_, taskID, err := keys.ParseViewSeen(keys.FromItem(item).SK)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read seen task: %w", err)
+		}
+		seen[taskID] = true
+	}
+
+	return seen, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+	"github.com/user/tasks-api/internal/webhooks"
+)
+
+// AddWebhook saves a new subscription
+func (ts *TaskStore) AddWebhook(ctx context.Context, subscription webhooks.Subscription) error {
+	item, err := webhooks.SubscriptionItem(subscription)
+	if err != nil {
+		return err
+	}
+
+	_, err = ts.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(ts.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put webhook in DynamoDB: %w", storeError(err))
+	}
+
+	return nil
+}
+
+// GetWebhook gets a subscription by owner and ID
+func (ts *TaskStore) GetWebhook(ctx context.Context, owner string, id uuid.UUID) (webhooks.Subscription, error) {
+	item, err := ts.getItem(ctx, keys.Webhook(owner, id), false)
+	if err != nil {
+		return webhooks.Subscription{}, err
+	}
+	if item == nil {
+		return webhooks.Subscription{}, ErrNotFound
+	}
+
+	return webhooks.SubscriptionFromItem(item)
+}
+
+// ListWebhooks lists an owner's subscriptions in ID order
+func (ts *TaskStore) ListWebhooks(ctx context.Context, owner string) ([]webhooks.Subscription, error) {
+	items, err := ts.queryPrefix(ctx, owner, keys.WebhookPrefix, "")
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions := make([]webhooks.Subscription, 0, len(items))
+	for _, item := range items {
+		subscription, err := webhooks.SubscriptionFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteWebhook deletes a subscription, then its deliveries. If deleting
+// those fails, they are left behind but unused, since a subscription's ID
+// is never reused.
+func (ts *TaskStore) DeleteWebhook(ctx context.Context, owner string, id uuid.UUID) error {
+	_, err := ts.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(ts.tableName),
+		Key:                 keys.Webhook(owner, id).Item(),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook from DynamoDB: %w", storeError(err))
+	}
+
+	items, err := ts.queryPrefix(ctx, owner, keys.DeliverySK(id), "PK, SK")
+	if err != nil {
+		return err
+	}
+	requests := make([]types.WriteRequest, len(items))
+	for i, item := range items {
+		requests[i] = types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: item}}
+	}
+	return ts.batchWriteItems(ctx, requests)
+}
+
+// AddDelivery records a delivery to a subscription
+func (ts *TaskStore) AddDelivery(ctx context.Context, delivery webhooks.Delivery) error {
+	item, err := webhooks.DeliveryItem(delivery)
+	if err != nil {
+		return err
+	}
+
+	_, err = ts.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(ts.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put delivery in DynamoDB: %w", storeError(err))
+	}
+
+	return nil
+}
+
+// ListDeliveries lists the latest deliveries to a subscription, newest
+// first, reading only as many items as it returns
+func (ts *TaskStore) ListDeliveries(ctx context.Context, owner string, webhookID uuid.UUID, limit int) ([]webhooks.Delivery, error) {
+	input := ts.prefixQuery(owner, keys.DeliverySK(webhookID))
+	input.ScanIndexForward = aws.Bool(false)
+	input.Limit = aws.Int32(int32(limit))
+
+	result, err := ts.client.Query(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", storeError(err))
+	}
+
+	deliveries := make([]webhooks.Delivery, 0, len(result.Items))
+	for _, item := range result.Items {
+		delivery, err := webhooks.DeliveryFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxTitleLength is the maximum length of a task title, in characters
+	maxTitleLength = 200
+	// maxOwnerLength is the maximum length of an owner, in characters
+	maxOwnerLength = 254
+	// maxDescriptionLength is the maximum length of a task description, in characters
+	maxDescriptionLength = 4000
+	// maxRecurrenceLength is the maximum length of a recurrence rule, in characters
+	maxRecurrenceLength = 500
+	// maxUIDLength is the maximum length of a calendar UID, in characters
+	maxUIDLength = 255
+	// maxLabels is the maximum number of labels on a task
+	maxLabels = 10
+	// maxLabelLength is the maximum length of a label, in characters
+	maxLabelLength = 32
+)
+
+const (
+	// dueDateFormat is the format of a due date without a time
+	dueDateFormat = "2006-01-02"
+)
+
+// FieldErrorCode is a stable, machine-readable reason a field is invalid
+type FieldErrorCode string
+
+const (
+	// FieldRequired means the field is missing or blank
+	FieldRequired FieldErrorCode = "required"
+	// FieldTooLong means the field exceeds its maximum length
+	FieldTooLong FieldErrorCode = "too_long"
+	// FieldTooMany means a list field has more items than allowed
+	FieldTooMany FieldErrorCode = "too_many"
+	// FieldInvalidCharacters means the field contains control characters or invalid UTF-8
+	FieldInvalidCharacters FieldErrorCode = "invalid_characters"
+	// FieldInvalidFormat means the field is not in the expected format, such as a UUID
+	FieldInvalidFormat FieldErrorCode = "invalid_format"
+	// FieldInvalidValue means the field is not one of the allowed values
+	FieldInvalidValue FieldErrorCode = "invalid_value"
+	// FieldInvalidType means the field has the wrong JSON type
+	FieldInvalidType FieldErrorCode = "invalid_type"
+	// FieldNotAllowed means the field may not be set in this context
+	FieldNotAllowed FieldErrorCode = "not_allowed"
+	// FieldDuplicate means the field repeats a value that must be unique
+	FieldDuplicate FieldErrorCode = "duplicate"
+	// FieldUnknown means the field is not part of the request type
+	FieldUnknown FieldErrorCode = "unknown_field"
+)
+
+// FieldError describes a single invalid field in a request
+type FieldError struct {
+	Field string         `json:"field"`
+	Code  FieldErrorCode `json:"code"`
+	// Message, Token and Position explain errors in a query parameter,
+	// pointing at the offending token; Position counts characters from 1
+	Message  string `json:"message,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Position int    `json:"position,omitempty"`
+}
+
+// ValidationErrors is a list of every invalid field in a request
+type ValidationErrors []FieldError
+
+// Error implements the error interface
+func (v ValidationErrors) Error() string {
+	parts := make([]string, len(v))
+	for i, fe := range v {
+		parts[i] = fe.Field + ": " + string(fe.Code)
+	}
+	return "invalid request: " + strings.Join(parts, ", ")
+}
+
+// add records an invalid field
+func (v *ValidationErrors) add(field string, code FieldErrorCode) {
+	*v = append(*v, FieldError{Field: field, Code: code})
+}
+
+// addPrefixed records the errors of a nested value under the given prefix
+func (v *ValidationErrors) addPrefixed(prefix string, errs ValidationErrors) {
+	for _, fe := range errs {
+		v.add(prefix+"."+fe.Field, fe.Code)
+	}
+}
+
+// err returns the errors as an error, or nil if there are none
+func (v ValidationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// validator is implemented by request types that can validate and normalize themselves
+type validator interface {
+	Validate() ValidationErrors
+}
+
+// decodeRequest decodes a JSON request body into v, rejecting unknown
+// fields, and then validates it. Field problems are reported together as
+// ValidationErrors; malformed JSON is reported as a plain error.
+func decodeRequest(request events.APIGatewayProxyRequest, v validator) error {
+	body, err := requestBody(request)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return decodeError(err)
+	}
+
+	// Reject anything after the JSON value
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after JSON value")
+	}
+
+	return v.Validate().err()
+}
+
+// requestBody returns the request body, decoding it if API Gateway
+// base64-encoded it
+func requestBody(request events.APIGatewayProxyRequest) (string, error) {
+	if !request.IsBase64Encoded {
+		return request.Body, nil
+	}
+	body, err := base64.StdEncoding.DecodeString(request.Body)
+	if err != nil {
+		return "", fmt.Errorf("invalid base64 body: %w", err)
+	}
+	return string(body), nil
+}
+
+// decodeError converts JSON decoding errors that refer to a specific field
+// into ValidationErrors
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return ValidationErrors{{Field: typeErr.Field, Code: FieldInvalidType}}
+	}
+
+	// encoding/json has no typed error for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return ValidationErrors{{Field: strings.Trim(field, `"`), Code: FieldUnknown}}
+	}
+
+	if errors.Is(err, io.EOF) {
+		return errors.New("request body is empty")
+	}
+
+	return err
+}
+
+// validateText trims surrounding whitespace from a string field and checks
+// that it is present when required, within maxLength characters and free
+// of control characters
+func validateText(errs *ValidationErrors, field string, value *string, required bool, maxLength int) {
+	*value = strings.TrimSpace(*value)
+
+	switch {
+	case *value == "":
+		if required {
+			errs.add(field, FieldRequired)
+		}
+	case !utf8.ValidString(*value) || strings.IndexFunc(*value, unicode.IsControl) >= 0:
+		errs.add(field, FieldInvalidCharacters)
+	case utf8.RuneCountInString(*value) > maxLength:
+		errs.add(field, FieldTooLong)
+	}
+}
+
+// validateDescription trims surrounding whitespace from a multi-line text
+// field and checks its length. Line breaks are normalized to \n and tabs are
+// allowed; other control characters are not.
+func validateDescription(errs *ValidationErrors, field string, value *string, maxLength int) {
+	*value = strings.TrimSpace(strings.ReplaceAll(*value, "\r\n", "\n"))
+
+	switch {
+	case !utf8.ValidString(*value) || strings.IndexFunc(*value, func(r rune) bool {
+		return r != '\n' && r != '\t' && unicode.IsControl(r)
+	}) >= 0:
+		errs.add(field, FieldInvalidCharacters)
+	case utf8.RuneCountInString(*value) > maxLength:
+		errs.add(field, FieldTooLong)
+	}
+}
+
+// validateDue checks that a due field is empty, a date or a time in RFC 3339
+// format, normalizing times to UTC
+func validateDue(errs *ValidationErrors, field string, value *string) {
+	*value = strings.TrimSpace(*value)
+	if *value == "" {
+		return
+	}
+
+	if _, err := time.Parse(dueDateFormat, *value); err == nil {
+		return
+	}
+	due, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		errs.add(field, FieldInvalidFormat)
+		return
+	}
+	*value = due.UTC().Format(time.RFC3339)
+}
+
+// validateRecurrence checks that a field is empty or an RFC 5545 RRULE
+// value: NAME=VALUE parts separated by semicolons, including FREQ
+func validateRecurrence(errs *ValidationErrors, field string, value *string) {
+	before := len(*errs)
+	validateText(errs, field, value, false, maxRecurrenceLength)
+	if *value == "" || len(*errs) > before {
+		return
+	}
+
+	hasFreq := false
+	for _, part := range strings.Split(*value, ";") {
+		name, partValue, ok := strings.Cut(part, "=")
+		if !ok || name == "" || partValue == "" {
+			errs.add(field, FieldInvalidFormat)
+			return
+		}
+		hasFreq = hasFreq || strings.EqualFold(name, "FREQ")
+	}
+	if !hasFreq {
+		errs.add(field, FieldInvalidFormat)
+	}
+}
+
+// validateTaskID checks that a field holds a task ID and returns it
+func validateTaskID(errs *ValidationErrors, field string, value *string) uuid.UUID {
+	*value = strings.TrimSpace(*value)
+	if *value == "" {
+		errs.add(field, FieldRequired)
+		return uuid.Nil
+	}
+
+	id, err := uuid.Parse(*value)
+	if err != nil {
+		errs.add(field, FieldInvalidFormat)
+		return uuid.Nil
+	}
+
+	return id
+}
+
+// validateCount checks that a list field has at least one and at most max items
+func validateCount(errs *ValidationErrors, field string, count, max int) {
+	switch {
+	case count == 0:
+		errs.add(field, FieldRequired)
+	case count > max:
+		errs.add(field, FieldTooMany)
+	}
+}
+
+// validateStatus checks that a status is a known TaskStatus
+func validateStatus(errs *ValidationErrors, field string, status TaskStatus) {
+	if status != TaskStatusOpen && status != TaskStatusClosed {
+		errs.add(field, FieldInvalidValue)
+	}
+}
+
+// validatePriority checks that a priority field is empty or a known
+// TaskPriority in any case, normalizing it to upper case
+func validatePriority(errs *ValidationErrors, field string, value *TaskPriority) {
+	*value = TaskPriority(strings.ToUpper(strings.TrimSpace(string(*value))))
+	if *value != "" && value.rank() < 0 {
+		errs.add(field, FieldInvalidValue)
+	}
+}
+
+// validateLabels checks that a labels field has at most maxLabels labels of
+// letters, digits, hyphens and underscores, normalizing them to lower case,
+// sorting them and dropping duplicates
+func validateLabels(errs *ValidationErrors, field string, value *[]string) {
+	if len(*value) > maxLabels {
+		errs.add(field, FieldTooMany)
+		return
+	}
+
+	labels := make([]string, 0, len(*value))
+	for i, label := range *value {
+		label = strings.ToLower(strings.TrimSpace(label))
+		if !validLabel(label) {
+			errs.add(indexField(field, i), FieldInvalidFormat)
+			continue
+		}
+		labels = append(labels, label)
+	}
+	slices.Sort(labels)
+	*value = slices.Compact(labels)
+	if len(*value) == 0 {
+		*value = nil
+	}
+}
+
+// validLabel reports whether a lower-cased label is within maxLabelLength
+// characters of letters, digits, hyphens and underscores
+func validLabel(label string) bool {
+	if label == "" || utf8.RuneCountInString(label) > maxLabelLength {
+		return false
+	}
+	return strings.IndexFunc(label, func(r rune) bool {
+		return r != '-' && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) < 0
+}
+
+// indexField returns the name of an element of a list field
+func indexField(field string, i int) string {
+	return fmt.Sprintf("%s[%d]", field, i)
+}
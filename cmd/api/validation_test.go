@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want ValidationErrors
+	}{
+		{
+			name: "valid",
+			body: `{"title": "  Test Task  ", "owner": "test@example.com"}`,
+		},
+		{
+			name: "every violation is reported",
+			body: `{"title": "` + strings.Repeat("a", maxTitleLength+1) + `", "owner": "   "}`,
+			want: ValidationErrors{{Field: "title", Code: FieldTooLong}, {Field: "owner", Code: FieldRequired}},
+		},
+		{
+			name: "control characters",
+			body: `{"title": "Test\u0007Task", "owner": "test@example.com"}`,
+			want: ValidationErrors{{Field: "title", Code: FieldInvalidCharacters}},
+		},
+		{
+			name: "unknown field",
+			body: `{"title": "Test Task", "owner": "test@example.com", "assignee": 1}`,
+			want: ValidationErrors{{Field: "assignee", Code: FieldUnknown}},
+		},
+		{
+			name: "wrong type",
+			body: `{"title": 42, "owner": "test@example.com"}`,
+			want: ValidationErrors{{Field: "title", Code: FieldInvalidType}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			var createRequest CreateTaskRequest
+			err := decodeRequest(events.APIGatewayProxyRequest{Body: tt.body}, &createRequest)
+
+			// Assert
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Expected validation errors, got %v", err)
+			}
+			if !reflect.DeepEqual(errs, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, errs)
+			}
+		})
+	}
+}
+
+func TestDecodeRequestTrimsFields(t *testing.T) {
+	// Arrange
+	var createRequest CreateTaskRequest
+
+	// Act
+	err := decodeRequest(events.APIGatewayProxyRequest{Body: `{"title": "  Test Task\t", "owner": " test@example.com "}`}, &createRequest)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if createRequest.Title != "Test Task" {
+		t.Errorf("Expected title to be 'Test Task', got '%s'", createRequest.Title)
+	}
+	if createRequest.Owner != "test@example.com" {
+		t.Errorf("Expected owner to be 'test@example.com', got '%s'", createRequest.Owner)
+	}
+}
+
+func TestDecodeRequestBase64(t *testing.T) {
+	// Arrange: Function URLs, ALBs and HTTP APIs may base64-encode JSON bodies
+	body := base64.StdEncoding.EncodeToString([]byte(`{"title": "Test Task", "owner": "test@example.com"}`))
+	var createRequest CreateTaskRequest
+	var invalidRequest CreateTaskRequest
+
+	// Act
+	err := decodeRequest(events.APIGatewayProxyRequest{Body: body, IsBase64Encoded: true}, &createRequest)
+	invalidErr := decodeRequest(events.APIGatewayProxyRequest{Body: "not base64!", IsBase64Encoded: true}, &invalidRequest)
+
+	// Assert
+	if err != nil || createRequest.Title != "Test Task" || createRequest.Owner != "test@example.com" {
+		t.Errorf("Expected the decoded body, got %+v (%v)", createRequest, err)
+	}
+	if invalidErr == nil {
+		t.Error("Expected an error for a body that is not base64")
+	}
+}
+
+func TestDecodeRequestMalformed(t *testing.T) {
+	for _, body := range []string{``, `{"title": `, `{"title": "a", "owner": "b"} {}`} {
+		// Act
+		var createRequest CreateTaskRequest
+		err := decodeRequest(events.APIGatewayProxyRequest{Body: body}, &createRequest)
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected error for body %q, got nil", body)
+		}
+		if errors.As(err, new(ValidationErrors)) {
+			t.Errorf("Expected a decoding error for body %q, got validation errors %v", body, err)
+		}
+	}
+}
+
+func TestTransactionRequestValidate(t *testing.T) {
+	// Arrange
+	id := "123e4567-e89b-12d3-a456-426614174000"
+	txRequest := TransactionRequest{
+		Operations: []TransactionOperation{
+			{BatchOperation: BatchOperation{Op: OperationClose, ID: id, Owner: "test@example.com"}},
+			{BatchOperation: BatchOperation{Op: OperationDelete, ID: id, Owner: "test@example.com"}},
+			{BatchOperation: BatchOperation{Op: OperationUpdate, ID: "not-a-uuid", Owner: "test@example.com"}},
+			{
+				BatchOperation: BatchOperation{Op: OperationCreate, Title: "Task", Owner: "test@example.com"},
+				Condition:      &TaskCondition{Status: TaskStatusOpen},
+			},
+		},
+	}
+
+	// Act
+	errs := txRequest.Validate()
+
+	// Assert
+	want := ValidationErrors{
+		{Field: "operations[1].id", Code: FieldDuplicate},
+		{Field: "operations[2].id", Code: FieldInvalidFormat},
+		{Field: "operations[2].title", Code: FieldRequired},
+		{Field: "operations[3].condition", Code: FieldNotAllowed},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("Expected %v, got %v", want, errs)
+	}
+}
+
+func TestTransactionRequestValidateStatsItems(t *testing.T) {
+	// Arrange: creating tasks for two owners also updates both owners' stats
+	// and appends to both owners' outboxes
+	var txRequest TransactionRequest
+	for i := range transactionLimit - 5 {
+		owner := "a@example.com"
+		if i%2 == 1 {
+			owner = "b@example.com"
+		}
+		txRequest.Operations = append(txRequest.Operations, TransactionOperation{
+			BatchOperation: BatchOperation{Op: OperationCreate, Title: "Task", Owner: owner},
+		})
+	}
+
+	// Act
+	errs := txRequest.Validate()
+	txRequest.Operations = txRequest.Operations[:transactionLimit-6]
+	fitErrs := txRequest.Validate()
+
+	// Assert
+	want := ValidationErrors{{Field: "operations", Code: FieldTooMany}}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("Expected %v, got %v", want, errs)
+	}
+	if len(fitErrs) != 0 {
+		t.Errorf("Expected no errors with room for the stats and outbox items, got %v", fitErrs)
+	}
+}
+
+func TestValidateTaskFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate func(errs *ValidationErrors, value *string)
+		value    string
+		want     string
+		wantCode FieldErrorCode
+	}{
+		{"due date", func(e *ValidationErrors, v *string) { validateDue(e, "due", v) }, "2024-05-01", "2024-05-01", ""},
+		{"due time", func(e *ValidationErrors, v *string) { validateDue(e, "due", v) }, "2024-05-01T09:00:00+02:00", "2024-05-01T07:00:00Z", ""},
+		{"due invalid", func(e *ValidationErrors, v *string) { validateDue(e, "due", v) }, "20240501", "", FieldInvalidFormat},
+		{"recurrence", func(e *ValidationErrors, v *string) { validateRecurrence(e, "recurrence", v) }, "FREQ=DAILY;COUNT=3", "FREQ=DAILY;COUNT=3", ""},
+		{"recurrence without FREQ", func(e *ValidationErrors, v *string) { validateRecurrence(e, "recurrence", v) }, "COUNT=3", "", FieldInvalidFormat},
+		{"recurrence control characters", func(e *ValidationErrors, v *string) { validateRecurrence(e, "recurrence", v) }, "FREQ=DAILY\x00", "", FieldInvalidCharacters},
+		{"description", func(e *ValidationErrors, v *string) { validateDescription(e, "description", v, maxDescriptionLength) }, " one\r\n\ttwo ", "one\n\ttwo", ""},
+		{"description control characters", func(e *ValidationErrors, v *string) { validateDescription(e, "description", v, maxDescriptionLength) }, "a\x07b", "", FieldInvalidCharacters},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var errs ValidationErrors
+			value := tt.value
+
+			// Act
+			tt.validate(&errs, &value)
+
+			// Assert
+			if tt.wantCode != "" {
+				if len(errs) != 1 || errs[0].Code != tt.wantCode {
+					t.Errorf("Expected a single %s error, got %v", tt.wantCode, errs)
+				}
+				return
+			}
+			if len(errs) != 0 {
+				t.Fatalf("Expected no errors, got %v", errs)
+			}
+			if value != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, value)
+			}
+		})
+	}
+}
+
+func TestValidatePriority(t *testing.T) {
+	tests := []struct {
+		value    TaskPriority
+		want     TaskPriority
+		wantCode FieldErrorCode
+	}{
+		{"", "", ""},
+		{" high ", TaskPriorityHigh, ""},
+		{"URGENT", TaskPriorityUrgent, ""},
+		{"critical", "", FieldInvalidValue},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.value), func(t *testing.T) {
+			// Arrange
+			var errs ValidationErrors
+			value := tt.value
+
+			// Act
+			validatePriority(&errs, "priority", &value)
+
+			// Assert
+			if tt.wantCode != "" {
+				if len(errs) != 1 || errs[0].Code != tt.wantCode {
+					t.Errorf("Expected a single %s error, got %v", tt.wantCode, errs)
+				}
+				return
+			}
+			if len(errs) != 0 || value != tt.want {
+				t.Errorf("Expected %q, got %q (%v)", tt.want, value, errs)
+			}
+		})
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		name  string
+		value []string
+		want  []string
+		errs  ValidationErrors
+	}{
+		{"normalizes", []string{" Bug", "ui", "bug", "needs-review"}, []string{"bug", "needs-review", "ui"}, nil},
+		{"empty", []string{}, nil, nil},
+		{"invalid characters", []string{"bug", "needs review", "a,b"}, nil, ValidationErrors{
+			{Field: "labels[1]", Code: FieldInvalidFormat},
+			{Field: "labels[2]", Code: FieldInvalidFormat},
+		}},
+		{"too long", []string{strings.Repeat("a", maxLabelLength+1)}, nil, ValidationErrors{{Field: "labels[0]", Code: FieldInvalidFormat}}},
+		{"too many", make([]string, maxLabels+1), nil, ValidationErrors{{Field: "labels", Code: FieldTooMany}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var errs ValidationErrors
+			value := tt.value
+
+			// Act
+			validateLabels(&errs, "labels", &value)
+
+			// Assert
+			if !reflect.DeepEqual(errs, tt.errs) {
+				t.Fatalf("Expected %v, got %v", tt.errs, errs)
+			}
+			if tt.errs == nil && !reflect.DeepEqual(value, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, value)
+			}
+		})
+	}
+}
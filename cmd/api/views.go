@@ -0,0 +1,321 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxViewNameLength is the maximum length of a view name, in characters
+	maxViewNameLength = 100
+	// maxViews is the largest number of views an owner can save
+	maxViews = 50
+	// defaultViewLimit is the number of tasks per page of a view unless the
+	// request sets a limit
+	defaultViewLimit = 50
+	// maxViewLimit is the largest number of tasks per page of a view
+	maxViewLimit = 200
+)
+
+// CreateViewRequest represents a request to save a view
+type CreateViewRequest struct {
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+	// Query selects the view's tasks; an empty query selects every task
+	Query string `json:"query"`
+}
+
+// Validate trims the request's fields and reports any that are invalid. The
+// query is parsed now so that a saved view always evaluates.
+func (r *CreateViewRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateText(&errs, "owner", &r.Owner, true, maxOwnerLength)
+	validateText(&errs, "name", &r.Name, true, maxViewNameLength)
+	if _, ok := queryField(&errs, "query", r.Query); ok {
+		r.Query = strings.TrimSpace(r.Query)
+	} else {
+		r.Query = ""
+	}
+	return errs
+}
+
+// ViewSummary is a view with the number of tasks it selects
+type ViewSummary struct {
+	View
+	Total int `json:"total"`
+	// Unread is the number of those tasks not yet returned by the view
+	Unread int `json:"unread"`
+}
+
+// ViewTask is a task selected by a view
+type ViewTask struct {
+	Task Task `json:"task"`
+	// Unread is set if the view had not returned the task before
+	Unread bool `json:"unread"`
+}
+
+// ViewTasksResponse is a page of the tasks a view selects, in the order of
+// task listings
+type ViewTasksResponse struct {
+	Tasks []ViewTask `json:"tasks"`
+	// Total is the number of tasks the view selects, across every page
+	Total int `json:"total"`
+	// Unread is the number of those tasks still unread after this page
+	Unread int `json:"unread"`
+	// NextCursor fetches the next page; it is omitted on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// viewTasks evaluates a view's query, returning the tasks it selects and
+// which of them the view has returned before
+func (api *API) viewTasks(ctx context.Context, view View) ([]Task, map[uuid.UUID]bool, error) {
+	filter, err := ParseTaskQuery(view.Query)
+	if err != nil {
+		// Queries are validated when saved, so this is not the client's fault
+		return nil, nil, fmt.Errorf("failed to parse query of view %s: %w", view.ID, err)
+	}
+	tasks, err := api.store.QueryTasks(ctx, view.Owner, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	seen, err := api.store.SeenTasks(ctx, view.Owner, view.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tasks, seen, nil
+}
+
+// summarizeView counts the tasks a view selects
+func (api *API) summarizeView(ctx context.Context, view View) (ViewSummary, error) {
+	tasks, seen, err := api.viewTasks(ctx, view)
+	if err != nil {
+		return ViewSummary{}, err
+	}
+	summary := ViewSummary{View: view, Total: len(tasks)}
+	for _, task := range tasks {
+		if !seen[task.ID] {
+			summary.Unread++
+		}
+	}
+	return summary, nil
+}
+
+// viewParameters validates the view ID and owner of a request
+func viewParameters(errs *ValidationErrors, request events.APIGatewayProxyRequest) (uuid.UUID, string) {
+	viewIDStr := request.PathParameters["id"]
+	viewID := validateTaskID(errs, "id", &viewIDStr)
+	owner := request.QueryStringParameters["owner"]
+	validateText(errs, "owner", &owner, true, maxOwnerLength)
+	return viewID, owner
+}
+
+// createView saves a view for an owner
+func (api *API) createView(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Parse the request body
+	var createRequest CreateViewRequest
+	if err := decodeRequest(request, &createRequest); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	// Check the owner has room for another view
+	views, err := api.store.ListViews(ctx, createRequest.Owner)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to list views")
+	}
+	if len(views) >= maxViews {
+		return problemResponse(request, http.StatusConflict, CodeConflict,
+			fmt.Sprintf("An owner can save at most %d views", maxViews))
+	}
+
+	// Save the view
+	view := View{
+		ID:    uuid.New(),
+		Owner: createRequest.Owner,
+		Name:  createRequest.Name,
+		Query: createRequest.Query,
+	}
+	if err := api.store.PutView(ctx, view); err != nil {
+		return errorResponse(request, err, "View", "Failed to create view")
+	}
+
+	// Every task the view selects is unread
+	summary, err := api.summarizeView(ctx, view)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to count view tasks")
+	}
+
+	// Marshal the view to JSON
+	body, err := json.Marshal(summary)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to marshal view")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusCreated,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// listViews lists an owner's views by name. Their task counts are only
+// returned for a single view, since counting evaluates the view's query.
+func (api *API) listViews(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Get the owner from the query parameters
+	var errs ValidationErrors
+	owner := request.QueryStringParameters["owner"]
+	validateText(&errs, "owner", &owner, true, maxOwnerLength)
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	views, err := api.store.ListViews(ctx, owner)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to list views")
+	}
+	slices.SortFunc(views, func(a, b View) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID.String(), b.ID.String()))
+	})
+
+	// Marshal the views to JSON, as an empty array if there are none
+	if views == nil {
+		views = []View{}
+	}
+	body, err := json.Marshal(views)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to marshal views")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// getView gets a view by ID, with its task counts
+func (api *API) getView(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var errs ValidationErrors
+	viewID, owner := viewParameters(&errs, request)
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	view, err := api.store.GetView(ctx, owner, viewID)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to get view")
+	}
+
+	summary, err := api.summarizeView(ctx, view)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to count view tasks")
+	}
+
+	// Marshal the view to JSON
+	body, err := json.Marshal(summary)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to marshal view")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// deleteView deletes a view and its record of the tasks it returned
+func (api *API) deleteView(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var errs ValidationErrors
+	viewID, owner := viewParameters(&errs, request)
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	err := api.store.DeleteView(ctx, owner, viewID)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to delete view")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusNoContent,
+	}, nil
+}
+
+// listViewTasks returns a page of the tasks a view selects and marks them
+// read, unless the request is a HEAD request, which changes nothing. The
+// query is evaluated again for every page, so a task written between pages
+// can move across the boundary.
+func (api *API) listViewTasks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var errs ValidationErrors
+	viewID, owner := viewParameters(&errs, request)
+	params := request.QueryStringParameters
+	limit := validateLimit(&errs, "limit", params["limit"], defaultViewLimit, maxViewLimit)
+	offset := validateCursor(&errs, "cursor", params["cursor"])
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	view, err := api.store.GetView(ctx, owner, viewID)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to get view")
+	}
+
+	tasks, seen, err := api.viewTasks(ctx, view)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to list view tasks")
+	}
+
+	response := ViewTasksResponse{Tasks: []ViewTask{}, Total: len(tasks)}
+	for _, task := range tasks {
+		if !seen[task.ID] {
+			response.Unread++
+		}
+	}
+
+	// Mark the unread tasks on this page read
+	var read []uuid.UUID
+	for _, task := range tasks[min(offset, len(tasks)):min(offset+limit, len(tasks))] {
+		unread := !seen[task.ID]
+		if unread {
+			read = append(read, task.ID)
+		}
+		response.Tasks = append(response.Tasks, ViewTask{Task: task, Unread: unread})
+	}
+	if len(read) > 0 && request.HTTPMethod != http.MethodHead {
+		if err := api.store.MarkSeen(ctx, owner, viewID, read); err != nil {
+			return errorResponse(request, err, "View", "Failed to mark view tasks read")
+		}
+		response.Unread -= len(read)
+	}
+	if offset+limit < len(tasks) {
+		response.NextCursor = encodeOffsetCursor(offset + limit)
+	}
+
+	// Marshal the view tasks to JSON
+	body, err := json.Marshal(response)
+	if err != nil {
+		return errorResponse(request, err, "View", "Failed to marshal view tasks")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// viewRequest builds a request for test@example.com's views
+func viewRequest(method, path string, params map[string]string, body string) events.APIGatewayProxyRequest {
+	query := map[string]string{"owner": "test@example.com"}
+	for name, value := range params {
+		query[name] = value
+	}
+	return events.APIGatewayProxyRequest{
+		Path:                  path,
+		HTTPMethod:            method,
+		QueryStringParameters: query,
+		Body:                  body,
+	}
+}
+
+// createTestView saves a view through the API and returns it
+func createTestView(t *testing.T, api *API, name, query string) ViewSummary {
+	t.Helper()
+	body, _ := json.Marshal(CreateViewRequest{Owner: "test@example.com", Name: name, Query: query})
+	response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodPost, "/api/views", nil, string(body)))
+	if err != nil || response.StatusCode != http.StatusCreated {
+		t.Fatalf("Failed to create view: %d %s %v", response.StatusCode, response.Body, err)
+	}
+	var view ViewSummary
+	if err := json.Unmarshal([]byte(response.Body), &view); err != nil {
+		t.Fatalf("Failed to unmarshal view: %v", err)
+	}
+	return view
+}
+
+// viewTaskTitles fetches a page of a view's tasks through the API
+func viewTaskTitles(t *testing.T, api *API, id uuid.UUID, params map[string]string) ([]string, ViewTasksResponse) {
+	t.Helper()
+	response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodGet, "/api/views/"+id.String()+"/tasks", params, ""))
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to list view tasks: %d %s %v", response.StatusCode, response.Body, err)
+	}
+	var page ViewTasksResponse
+	if err := json.Unmarshal([]byte(response.Body), &page); err != nil {
+		t.Fatalf("Failed to unmarshal view tasks: %v", err)
+	}
+	titles := []string{}
+	for _, task := range page.Tasks {
+		titles = append(titles, task.Task.Title)
+	}
+	return titles, page
+}
+
+// newViewsAPI returns an API whose store holds labelled tasks for
+// test@example.com and a bug for another owner
+func newViewsAPI(t *testing.T) *API {
+	t.Helper()
+	api := NewAPI(NewMockTaskStore())
+	for _, spec := range []struct {
+		title  string
+		labels []string
+		status TaskStatus
+	}{
+		{"Fix login", []string{"bug"}, TaskStatusOpen},
+		{"Fix logout", []string{"bug"}, TaskStatusOpen},
+		{"Fix signup", []string{"bug"}, TaskStatusClosed},
+		{"Write docs", nil, TaskStatusOpen},
+	} {
+		task := NewTask(uuid.New(), spec.title, "test@example.com")
+		task.Labels = spec.labels
+		task.Status = spec.status
+		if err := api.store.Add(context.Background(), task); err != nil {
+			t.Fatalf("Failed to add task: %v", err)
+		}
+	}
+	other := NewTask(uuid.New(), "Fix search", "other@example.com")
+	other.Labels = []string{"bug"}
+	_ = api.store.Add(context.Background(), other)
+	return api
+}
+
+func TestCreateView(t *testing.T) {
+	// Arrange
+	api := newViewsAPI(t)
+
+	// Act
+	view := createTestView(t, api, "  Open bugs ", " label:bug status:open ")
+
+	// Assert
+	if view.ID == uuid.Nil {
+		t.Error("Expected an ID")
+	}
+	if view.Name != "Open bugs" || view.Query != "label:bug status:open" {
+		t.Errorf("Expected the name and query trimmed, got %q and %q", view.Name, view.Query)
+	}
+	if view.Total != 2 || view.Unread != 2 {
+		t.Errorf("Expected 2 tasks, all unread, got %d and %d", view.Total, view.Unread)
+	}
+}
+
+func TestCreateViewInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want FieldError
+	}{
+		{
+			name: "missing name",
+			body: `{"owner":"test@example.com","query":"label:bug"}`,
+			want: FieldError{Field: "name", Code: FieldRequired},
+		},
+		{
+			name: "invalid query",
+			body: `{"owner":"test@example.com","name":"Bugs","query":"lable:bug"}`,
+			want: FieldError{Field: "query", Code: FieldUnknown, Message: `unknown field "lable"`, Token: "lable:bug", Position: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			api := NewAPI(NewMockTaskStore())
+
+			// Act
+			response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodPost, "/api/views", nil, tt.body))
+
+			// Assert
+			if err != nil || response.StatusCode != http.StatusBadRequest {
+				t.Fatalf("Expected status code %d, got %d (%v)", http.StatusBadRequest, response.StatusCode, err)
+			}
+			var problem Problem
+			_ = json.Unmarshal([]byte(response.Body), &problem)
+			if len(problem.Errors) != 1 || problem.Errors[0] != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, problem.Errors)
+			}
+		})
+	}
+}
+
+func TestCreateViewLimit(t *testing.T) {
+	// Arrange
+	api := NewAPI(NewMockTaskStore())
+	for i := range maxViews {
+		createTestView(t, api, fmt.Sprintf("View %d", i), "")
+	}
+
+	// Act
+	response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodPost, "/api/views", nil,
+		`{"owner":"test@example.com","name":"One too many"}`))
+
+	// Assert
+	if err != nil || response.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected status code %d, got %d (%v)", http.StatusConflict, response.StatusCode, err)
+	}
+}
+
+func TestListViews(t *testing.T) {
+	// Arrange
+	api := newViewsAPI(t)
+	everything := createTestView(t, api, "Everything", "")
+	bugs := createTestView(t, api, "Bugs", "label:bug")
+
+	// Act
+	response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodGet, "/api/views", nil, ""))
+
+	// Assert
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d (%v)", http.StatusOK, response.StatusCode, err)
+	}
+	var views []View
+	if err := json.Unmarshal([]byte(response.Body), &views); err != nil {
+		t.Fatalf("Failed to unmarshal views: %v", err)
+	}
+	want := []View{bugs.View, everything.View}
+	if !reflect.DeepEqual(views, want) {
+		t.Errorf("Expected %+v, got %+v", want, views)
+	}
+	if strings.Contains(response.Body, `"unread"`) {
+		t.Errorf("Expected no task counts, got %s", response.Body)
+	}
+}
+
+func TestViewTasksUnread(t *testing.T) {
+	// Arrange
+	api := newViewsAPI(t)
+	view := createTestView(t, api, "Bugs", "label:bug")
+
+	// Act: read the first page, then every task, then add a bug
+	firstTitles, first := viewTaskTitles(t, api, view.ID, map[string]string{"limit": "2"})
+	_, all := viewTaskTitles(t, api, view.ID, nil)
+	added := NewTask(uuid.New(), "Fix export", "test@example.com")
+	added.Labels = []string{"bug"}
+	_ = api.store.Add(context.Background(), added)
+	_, afterAdd := viewTaskTitles(t, api, view.ID, nil)
+
+	// Assert
+	if !reflect.DeepEqual(firstTitles, []string{"Fix login", "Fix logout"}) {
+		t.Errorf("Expected the open bugs first, got %q", firstTitles)
+	}
+	if first.Total != 3 || first.Unread != 1 || first.NextCursor == "" {
+		t.Errorf("Expected 3 tasks, 1 unread and a next page, got %d, %d and %q", first.Total, first.Unread, first.NextCursor)
+	}
+	for _, task := range first.Tasks {
+		if !task.Unread {
+			t.Errorf("Expected %q unread on the first read", task.Task.Title)
+		}
+	}
+	var unread []string
+	for _, task := range all.Tasks {
+		if task.Unread {
+			unread = append(unread, task.Task.Title)
+		}
+	}
+	if !reflect.DeepEqual(unread, []string{"Fix signup"}) || all.Unread != 0 {
+		t.Errorf("Expected only the closed bug unread and none left, got %q and %d", unread, all.Unread)
+	}
+	if afterAdd.Total != 4 || len(afterAdd.Tasks) != 4 {
+		t.Fatalf("Expected 4 tasks after adding one, got %d", afterAdd.Total)
+	}
+	for _, task := range afterAdd.Tasks {
+		if task.Unread != (task.Task.ID == added.ID) {
+			t.Errorf("Expected only the added task unread, got %q unread=%v", task.Task.Title, task.Unread)
+		}
+	}
+}
+
+func TestViewTasksHeadMarksNothing(t *testing.T) {
+	// Arrange
+	api := newViewsAPI(t)
+	view := createTestView(t, api, "Bugs", "label:bug")
+
+	// Act
+	head, err := api.HandleRequest(context.Background(), viewRequest(http.MethodHead, "/api/views/"+view.ID.String()+"/tasks", nil, ""))
+	_, page := viewTaskTitles(t, api, view.ID, nil)
+
+	// Assert
+	if err != nil || head.StatusCode != http.StatusOK || head.Body != "" {
+		t.Fatalf("Expected an empty 200 response, got %d %q (%v)", head.StatusCode, head.Body, err)
+	}
+	for _, task := range page.Tasks {
+		if !task.Unread {
+			t.Errorf("Expected %q still unread after a HEAD request", task.Task.Title)
+		}
+	}
+}
+
+func TestViewTasksPagination(t *testing.T) {
+	// Arrange
+	api := newViewsAPI(t)
+	view := createTestView(t, api, "Everything", "")
+
+	// Act: page through the tasks one at a time
+	var titles []string
+	params := map[string]string{"limit": "1"}
+	for range 10 {
+		page, response := viewTaskTitles(t, api, view.ID, params)
+		titles = append(titles, page...)
+		if response.NextCursor == "" {
+			break
+		}
+		params["cursor"] = response.NextCursor
+	}
+
+	// Assert
+	want := []string{"Fix login", "Fix logout", "Write docs", "Fix signup"}
+	if !reflect.DeepEqual(titles, want) {
+		t.Errorf("Expected %q, got %q", want, titles)
+	}
+}
+
+func TestViewNotFound(t *testing.T) {
+	// Arrange
+	api := newViewsAPI(t)
+	view := View{ID: uuid.New(), Owner: "other@example.com", Name: "Bugs", Query: "label:bug"}
+	_ = api.store.PutView(context.Background(), view)
+	path := "/api/views/" + view.ID.String()
+
+	for _, request := range []events.APIGatewayProxyRequest{
+		viewRequest(http.MethodGet, path, nil, ""),
+		viewRequest(http.MethodGet, path+"/tasks", nil, ""),
+		viewRequest(http.MethodDelete, path, nil, ""),
+	} {
+		t.Run(request.HTTPMethod+" "+request.Path, func(t *testing.T) {
+			// Act: ask for another owner's view
+			response, err := api.HandleRequest(context.Background(), request)
+
+			// Assert
+			if err != nil || response.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected status code %d, got %d (%v)", http.StatusNotFound, response.StatusCode, err)
+			}
+			var problem Problem
+			if err := json.Unmarshal([]byte(response.Body), &problem); err != nil || problem.Detail != "View not found" {
+				t.Errorf("Expected detail %q, got %q (%v)", "View not found", problem.Detail, err)
+			}
+		})
+	}
+}
+
+func TestDeleteView(t *testing.T) {
+	// Arrange
+	api := newViewsAPI(t)
+	view := createTestView(t, api, "Bugs", "label:bug")
+	viewTaskTitles(t, api, view.ID, nil)
+	path := "/api/views/" + view.ID.String()
+
+	// Act
+	response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodDelete, path, nil, ""))
+	getResponse, getErr := api.HandleRequest(context.Background(), viewRequest(http.MethodGet, path, nil, ""))
+
+	// Assert
+	if err != nil || response.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d (%v)", http.StatusNoContent, response.StatusCode, err)
+	}
+	if getErr != nil || getResponse.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d after deleting, got %d (%v)", http.StatusNotFound, getResponse.StatusCode, getErr)
+	}
+	seen, _ := api.store.SeenTasks(context.Background(), "test@example.com", view.ID)
+	if len(seen) != 0 {
+		t.Errorf("Expected the seen tasks deleted, got %v", seen)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/webhooks"
+)
+
+const (
+	// maxWebhookURLLength is the maximum length of a webhook URL, in bytes
+	maxWebhookURLLength = 2048
+	// maxWebhooks is the largest number of webhooks an owner can subscribe,
+	// since every task event is delivered to each of them in turn
+	maxWebhooks = 10
+	// defaultDeliveryLimit is the number of deliveries listed unless the
+	// request sets a limit
+	defaultDeliveryLimit = 20
+	// maxDeliveryLimit is the largest number of deliveries listed
+	maxDeliveryLimit = 100
+)
+
+// CreateWebhookRequest represents a request to subscribe a webhook
+type CreateWebhookRequest struct {
+	Owner string `json:"owner"`
+	// URL receives the events, as HTTP POST requests
+	URL string `json:"url"`
+	// Events are the event types delivered; empty means every type
+	Events []string `json:"events,omitempty"`
+}
+
+// Validate trims the request's fields and reports any that are invalid
+func (r *CreateWebhookRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	validateText(&errs, "owner", &r.Owner, true, maxOwnerLength)
+	validateWebhookURL(&errs, "url", &r.URL)
+	validateEventTypes(&errs, "events", &r.Events)
+	return errs
+}
+
+// CreateWebhookResponse is a new webhook with the secret its deliveries are
+// signed with, which is not returned again
+type CreateWebhookResponse struct {
+	webhooks.Subscription
+	Secret string `json:"secret"`
+}
+
+// validateWebhookURL checks that a URL field is an absolute https URL whose
+// host is not localhost or a non-public IP address. Host names are checked
+// again when deliveries resolve them.
+func validateWebhookURL(errs *ValidationErrors, field string, value *string) {
+	validateText(errs, field, value, true, maxWebhookURLLength)
+	if *value == "" || len(*value) > maxWebhookURLLength {
+		return
+	}
+	u, err := url.Parse(*value)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		errs.add(field, FieldInvalidFormat)
+		return
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if ip, err := netip.ParseAddr(host); err == nil && !webhooks.PublicAddress(ip) {
+		errs.add(field, FieldNotAllowed)
+	} else if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		errs.add(field, FieldNotAllowed)
+	}
+}
+
+// validateEventTypes checks that an events field only has the types webhooks
+// can subscribe to, sorting them and dropping duplicates
+func validateEventTypes(errs *ValidationErrors, field string, value *[]string) {
+	types := make([]string, 0, len(*value))
+	for i, eventType := range *value {
+		eventType = strings.TrimSpace(eventType)
+		if !slices.Contains(webhooks.EventTypes, eventType) {
+			errs.add(indexField(field, i), FieldInvalidValue)
+			continue
+		}
+		types = append(types, eventType)
+	}
+	slices.Sort(types)
+	*value = slices.Compact(types)
+	if len(*value) == 0 {
+		*value = nil
+	}
+}
+
+// webhookParameters validates the webhook ID and owner of a request
+func webhookParameters(errs *ValidationErrors, request events.APIGatewayProxyRequest) (uuid.UUID, string) {
+	webhookIDStr := request.PathParameters["id"]
+	webhookID := validateTaskID(errs, "id", &webhookIDStr)
+	owner := request.QueryStringParameters["owner"]
+	validateText(errs, "owner", &owner, true, maxOwnerLength)
+	return webhookID, owner
+}
+
+// createWebhook subscribes a URL to an owner's task events
+func (api *API) createWebhook(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Parse the request body
+	var createRequest CreateWebhookRequest
+	if err := decodeRequest(request, &createRequest); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	// Check the owner has room for another webhook
+	subscriptions, err := api.store.ListWebhooks(ctx, createRequest.Owner)
+	if err != nil {
+		return errorResponse(request, err, "Webhook", "Failed to list webhooks")
+	}
+	if len(subscriptions) >= maxWebhooks {
+		return problemResponse(request, http.StatusConflict, CodeConflict,
+			fmt.Sprintf("An owner can subscribe at most %d webhooks", maxWebhooks))
+	}
+
+	// Save the webhook with a new secret
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		return errorResponse(request, err, "Webhook", "Failed to create webhook")
+	}
+	subscription := webhooks.Subscription{
+		ID:      uuid.New(),
+		Owner:   createRequest.Owner,
+		URL:     createRequest.URL,
+		Events:  createRequest.Events,
+		Secret:  secret,
+		Created: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := api.store.AddWebhook(ctx, subscription); err != nil {
+		return errorResponse(request, err, "Webhook", "Failed to create webhook")
+	}
+
+	// Marshal the webhook to JSON
+	body, err := json.Marshal(CreateWebhookResponse{Subscription: subscription, Secret: secret})
+	if err != nil {
+		return errorResponse(request, err, "Webhook", "Failed to marshal webhook")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusCreated,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// listWebhooks lists an owner's webhooks, oldest first, without their secrets
+func (api *API) listWebhooks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Get the owner from the query parameters
+	var errs ValidationErrors
+	owner := request.QueryStringParameters["owner"]
+	validateText(&errs, "owner", &owner, true, maxOwnerLength)
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	subscriptions, err := api.store.ListWebhooks(ctx, owner)
+	if err != nil {
+		return errorResponse(request, err, "Webhook", "Failed to list webhooks")
+	}
+	slices.SortFunc(subscriptions, func(a, b webhooks.Subscription) int {
+		return cmp.Or(a.Created.Compare(b.Created), cmp.Compare(a.ID.String(), b.ID.String()))
+	})
+
+	// Marshal the webhooks to JSON
+	body, err := json.Marshal(subscriptions)
+	if err != nil {
+		return errorResponse(request, err, "Webhook", "Failed to marshal webhooks")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// deleteWebhook unsubscribes a webhook and deletes its deliveries
+func (api *API) deleteWebhook(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var errs ValidationErrors
+	webhookID, owner := webhookParameters(&errs, request)
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	err := api.store.DeleteWebhook(ctx, owner, webhookID)
+	if err != nil {
+		return errorResponse(request, err, "Webhook", "Failed to delete webhook")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusNoContent,
+	}, nil
+}
+
+// listDeliveries returns the latest deliveries to a webhook, newest first,
+// with every attempt of each and the payload of dead letters
+func (api *API) listDeliveries(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var errs ValidationErrors
+	webhookID, owner := webhookParameters(&errs, request)
+	limit := validateLimit(&errs, "limit", request.QueryStringParameters["limit"], defaultDeliveryLimit, maxDeliveryLimit)
+	if err := errs.err(); err != nil {
+		return invalidRequestResponse(request, err)
+	}
+
+	// Check the webhook exists, so that an unknown ID is reported rather
+	// than listed as having no deliveries
+	_, err := api.store.GetWebhook(ctx, owner, webhookID)
+	if err != nil {
+		return errorResponse(request, err, "Webhook", "Failed to get webhook")
+	}
+
+	deliveries, err := api.store.ListDeliveries(ctx, owner, webhookID, limit)
+	if err != nil {
+		return errorResponse(request, err, "Webhook", "Failed to list deliveries")
+	}
+
+	// Marshal the deliveries to JSON
+	body, err := json.Marshal(deliveries)
+	if err != nil {
+		return errorResponse(request, err, "Webhook", "Failed to marshal deliveries")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
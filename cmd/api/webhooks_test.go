@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/webhooks"
+)
+
+// createTestWebhook subscribes a webhook through the API and returns it
+func createTestWebhook(t *testing.T, api *API, events []string) CreateWebhookResponse {
+	t.Helper()
+	body, _ := json.Marshal(CreateWebhookRequest{Owner: "test@example.com", URL: "https://example.com/hooks", Events: events})
+	response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodPost, "/api/webhooks", nil, string(body)))
+	if err != nil || response.StatusCode != http.StatusCreated {
+		t.Fatalf("Failed to create webhook: %d %s %v", response.StatusCode, response.Body, err)
+	}
+	var webhook CreateWebhookResponse
+	if err := json.Unmarshal([]byte(response.Body), &webhook); err != nil {
+		t.Fatalf("Failed to unmarshal webhook: %v", err)
+	}
+	return webhook
+}
+
+func TestCreateWebhook(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	api := NewAPI(store)
+
+	// Act
+	created := createTestWebhook(t, api, []string{"TaskDeleted", " TaskClosed", "TaskClosed"})
+	response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodGet, "/api/webhooks", nil, ""))
+
+	// Assert
+	if want := []string{"TaskClosed", "TaskDeleted"}; !reflect.DeepEqual(created.Events, want) {
+		t.Errorf("Expected events %v, got %v", want, created.Events)
+	}
+	if !strings.HasPrefix(created.Secret, "whsec_") {
+		t.Errorf("Expected a secret, got %q", created.Secret)
+	}
+	stored, _ := store.GetWebhook(context.Background(), "test@example.com", created.ID)
+	if stored.Secret != created.Secret || stored.URL != "https://example.com/hooks" {
+		t.Errorf("Expected the webhook stored with its secret, got %+v", stored)
+	}
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s (%v)", http.StatusOK, response.StatusCode, response.Body, err)
+	}
+	if strings.Contains(response.Body, created.Secret) || !strings.Contains(response.Body, created.ID.String()) {
+		t.Errorf("Expected the webhook listed without its secret, got %s", response.Body)
+	}
+}
+
+func TestCreateWebhookInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want FieldError
+	}{
+		{"missing url", `{"owner":"test@example.com"}`, FieldError{Field: "url", Code: FieldRequired}},
+		{"relative url", `{"owner":"test@example.com","url":"/hooks"}`, FieldError{Field: "url", Code: FieldInvalidFormat}},
+		{"unsupported scheme", `{"owner":"test@example.com","url":"ftp://example.com/hooks"}`, FieldError{Field: "url", Code: FieldInvalidFormat}},
+		{"plain http", `{"owner":"test@example.com","url":"http://example.com/hooks"}`, FieldError{Field: "url", Code: FieldInvalidFormat}},
+		{"loopback address", `{"owner":"test@example.com","url":"https://127.0.0.1:8080/hooks"}`, FieldError{Field: "url", Code: FieldNotAllowed}},
+		{"metadata address", `{"owner":"test@example.com","url":"https://[::ffff:169.254.169.254]/latest"}`, FieldError{Field: "url", Code: FieldNotAllowed}},
+		{"localhost", `{"owner":"test@example.com","url":"https://LocalHost./hooks"}`, FieldError{Field: "url", Code: FieldNotAllowed}},
+		{"unknown event", `{"owner":"test@example.com","url":"https://example.com","events":["TaskClosed","TaskMoved"]}`, FieldError{Field: "events[1]", Code: FieldInvalidValue}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			api := NewAPI(NewMockTaskStore())
+
+			// Act
+			response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodPost, "/api/webhooks", nil, tt.body))
+
+			// Assert
+			if err != nil || response.StatusCode != http.StatusBadRequest {
+				t.Fatalf("Expected status code %d, got %d (%v)", http.StatusBadRequest, response.StatusCode, err)
+			}
+			var problem Problem
+			_ = json.Unmarshal([]byte(response.Body), &problem)
+			if len(problem.Errors) != 1 || problem.Errors[0] != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, problem.Errors)
+			}
+		})
+	}
+}
+
+func TestCreateWebhookLimit(t *testing.T) {
+	// Arrange
+	api := NewAPI(NewMockTaskStore())
+	for i := 0; i < maxWebhooks; i++ {
+		createTestWebhook(t, api, nil)
+	}
+	body := `{"owner":"test@example.com","url":"https://example.com/hooks"}`
+
+	// Act
+	response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodPost, "/api/webhooks", nil, body))
+
+	// Assert
+	if err != nil || response.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status code %d, got %d (%v)", http.StatusConflict, response.StatusCode, err)
+	}
+}
+
+func TestListDeliveries(t *testing.T) {
+	// Arrange
+	store := NewMockTaskStore()
+	api := NewAPI(store)
+	webhook := createTestWebhook(t, api, nil)
+	start := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	for i, status := range []webhooks.DeliveryStatus{webhooks.StatusDeadLetter, webhooks.StatusDelivered, webhooks.StatusDelivered} {
+		_ = store.AddDelivery(context.Background(), webhooks.Delivery{
+			ID:        uuid.New(),
+			WebhookID: webhook.ID,
+			Owner:     "test@example.com",
+			EventType: webhooks.EventTaskCreated,
+			Status:    status,
+			Created:   start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	path := "/api/webhooks/" + webhook.ID.String() + "/deliveries"
+
+	// Act
+	response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodGet, path, map[string]string{"limit": "2"}, ""))
+	unknown, _ := api.HandleRequest(context.Background(), viewRequest(http.MethodGet, "/api/webhooks/"+uuid.NewString()+"/deliveries", nil, ""))
+
+	// Assert
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s (%v)", http.StatusOK, response.StatusCode, response.Body, err)
+	}
+	var deliveries []webhooks.Delivery
+	if err := json.Unmarshal([]byte(response.Body), &deliveries); err != nil {
+		t.Fatalf("Failed to unmarshal deliveries: %v", err)
+	}
+	if len(deliveries) != 2 || !deliveries[0].Created.Equal(start.Add(2*time.Minute)) || !deliveries[1].Created.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the 2 latest deliveries, newest first, got %+v", deliveries)
+	}
+	if unknown.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d for an unknown webhook, got %d", http.StatusNotFound, unknown.StatusCode)
+	}
+	var problem Problem
+	if err := json.Unmarshal([]byte(unknown.Body), &problem); err != nil || problem.Detail != "Webhook not found" {
+		t.Errorf("Expected detail %q, got %q (%v)", "Webhook not found", problem.Detail, err)
+	}
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	// Arrange
+	api := NewAPI(NewMockTaskStore())
+	webhook := createTestWebhook(t, api, nil)
+	path := "/api/webhooks/" + webhook.ID.String()
+
+	// Act
+	response, err := api.HandleRequest(context.Background(), viewRequest(http.MethodDelete, path, nil, ""))
+	deliveries, _ := api.HandleRequest(context.Background(), viewRequest(http.MethodGet, path+"/deliveries", nil, ""))
+	again, _ := api.HandleRequest(context.Background(), viewRequest(http.MethodDelete, path, nil, ""))
+
+	// Assert
+	if err != nil || response.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d (%v)", http.StatusNoContent, response.StatusCode, err)
+	}
+	if deliveries.StatusCode != http.StatusNotFound || again.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the webhook gone, got %d and %d", deliveries.StatusCode, again.StatusCode)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/user/tasks-api/internal/env"
+	"github.com/user/tasks-api/internal/webhooks"
+)
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("failed to load AWS config: %v", err)
+	}
+
+	table := webhooks.NewTable(dynamodb.NewFromConfig(cfg), env.TableName())
+	lambda.Start(webhooks.NewDeliverer(table).DeliverDue)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/user/tasks-api/internal/env"
+)
+
+func main() {
+	tableName := flag.String("table", env.TableName(), "name of the tasks table")
+	endpoint := flag.String("endpoint", "", "DynamoDB endpoint URL, e.g. http://localhost:8000 for DynamoDB Local")
+	pageSize := flag.Int("page-size", 100, "items scanned per backfill page; progress is saved after each page")
+	status := flag.Bool("status", false, "list migrations and whether they are applied, without changing anything")
+	flag.Parse()
+
+	// Stop at the next request on Ctrl+C; an interrupted backfill resumes on the next run
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("failed to load AWS config: %v", err)
+	}
+	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if *endpoint != "" {
+			o.BaseEndpoint = aws.String(*endpoint)
+		}
+	})
+
+	m := &migrator{client: client, tableName: *tableName, pageSize: int32(*pageSize)}
+
+	if *status {
+		if err := m.printStatus(ctx, migrations); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := ensureTable(ctx, client, *tableName, taskTableSchema); err != nil {
+		log.Fatal(err)
+	}
+	if err := m.run(ctx, migrations); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("table %s is up to date", *tableName)
+}
@@ -0,0 +1,65 @@
+//go:build dynamodb
+
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+// newLocalClient creates a client for DynamoDB Local; see the API's store tests
+func newLocalClient() *dynamodb.Client {
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "http://localhost:8000"
+	}
+	return dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(endpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider("local", "local", ""),
+	})
+}
+
+func TestEnsureTableAndMigrate(t *testing.T) {
+	// Arrange: a table created without the GS1 index
+	ctx := context.Background()
+	client := newLocalClient()
+	tableName := "migrate-" + uuid.NewString()
+	bare := tableSchema{PartitionKey: "PK", SortKey: "SK"}
+	if err := ensureTable(ctx, client, tableName, bare); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(tableName)})
+	})
+	_, _ = client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(tableName), Item: taskItem(0, "")})
+
+	// Act
+	ensureErr := ensureTable(ctx, client, tableName, taskTableSchema)
+	m := &migrator{client: client, tableName: tableName, pageSize: 1}
+	runErr := m.run(ctx, migrations)
+	againErr := ensureTable(ctx, client, tableName, taskTableSchema)
+
+	// Assert
+	if ensureErr != nil || runErr != nil || againErr != nil {
+		t.Fatalf("Expected no errors, got %v, %v and %v", ensureErr, runErr, againErr)
+	}
+	result, err := client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		IndexName:              aws.String("GS1"),
+		KeyConditionExpression: aws.String("GS1PK = :gspk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":gspk": &types.AttributeValueMemberS{Value: "USER#owner0@example.com#STATUS#OPEN"},
+		},
+	})
+	if err != nil || len(result.Items) != 1 {
+		t.Errorf("Expected the migrated task in GS1, got %v (%v)", result, err)
+	}
+}
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+	"github.com/user/tasks-api/internal/search"
+	"github.com/user/tasks-api/internal/stats"
+)
+
+const (
+	// metadataPK and metadataSK are the key of the item recording which
+	// migrations have been applied. Task partition keys start with "#" or an
+	// entity prefix, so the metadata item can never collide with a task.
+	metadataPK = "_META"
+	metadataSK = "MIGRATIONS"
+)
+
+// dynamoDBAPI is the subset of the DynamoDB client the migrator uses
+type dynamoDBAPI interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// migration is a data change applied once to every task in the table
+type migration struct {
+	// ID identifies the migration; IDs must sort in the order migrations run
+	ID          string
+	Description string
+	// Backfill returns the attributes to set on a task item, or nil to leave
+	// it unchanged. It must be idempotent, because the page being processed
+	// when a run is interrupted is processed again when it resumes.
+	Backfill func(item map[string]types.AttributeValue) map[string]types.AttributeValue
+	// Move returns the item to store in place of a task item under a new
+	// key, or nil to leave it unchanged. The old item is deleted in the same
+	// transaction.
+	Move func(item map[string]types.AttributeValue) map[string]types.AttributeValue
+	// Index returns the search document item of a task and the term items it
+	// is indexed under, or a nil document to leave it unindexed. Tasks that
+	// already have a document were indexed by the API and are skipped.
+	Index func(item map[string]types.AttributeValue) (doc map[string]types.AttributeValue, terms []map[string]types.AttributeValue)
+	// Count returns the counters a task adds to its owner's stats, or nil to
+	// leave it uncounted. The task is marked counted in the same transaction
+	// as its counters are added, and tasks already counted are skipped.
+	Count func(item map[string]types.AttributeValue) (owner string, counters stats.Counters)
+}
+
+// migrations is the registry of migrations, in the order they run. Append
+// new migrations; never edit or remove one that has been released.
+var migrations = []migration{
+	{
+		ID:          "0001-gs1-keys",
+		Description: "Set GS1PK and GS1SK on tasks that are missing them or have a stale status",
+		Backfill:    backfillGS1Keys,
+	},
+	{
+		ID:          "0002-entity-keys",
+		Description: "Move tasks to USER#/TASK# keys and set entity_type",
+		Move:        moveToEntityKeys,
+	},
+	{
+		ID:          "0003-search-index",
+		Description: "Index the titles and descriptions of tasks for search",
+		Index:       indexSearchTerms,
+	},
+	{
+		ID:          "0004-task-stats",
+		Description: "Count tasks in their owners' stats",
+		Count:       countTaskStats,
+	},
+}
+
+// backfillGS1Keys sets GS1PK from a task's owner and status, and GS1SK to
+// the current time if it is missing. It only applies to items under legacy
+// keys, which are the only ones using the legacy GS1PK format.
+func backfillGS1Keys(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	owner, status := stringAttribute(item, "Owner"), stringAttribute(item, "Status")
+	if owner == "" || status == "" || !keys.IsLegacy(stringAttribute(item, "PK")) {
+		return nil
+	}
+
+	updates := make(map[string]types.AttributeValue)
+	if gs1pk := keys.LegacyTaskStatus(owner, status); stringAttribute(item, "GS1PK") != gs1pk {
+		updates["GS1PK"] = &types.AttributeValueMemberS{Value: gs1pk}
+	}
+	if stringAttribute(item, "GS1SK") == "" {
+		updates["GS1SK"] = &types.AttributeValueMemberS{Value: keys.Changed(time.Now())}
+	}
+	return updates
+}
+
+// entityKeysSchemaVersion is the API's item schema version that introduced
+// entity prefixes; moved items are written in it
+const entityKeysSchemaVersion = "3"
+
+// moveToEntityKeys rebuilds a task under a legacy key with the keys and
+// entity_type of the current item format, marked as moved from its legacy
+// partition so that stream consumers do not take it for a new task
+func moveToEntityKeys(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	owner, status := stringAttribute(item, "Owner"), stringAttribute(item, "Status")
+	id, err := uuid.Parse(stringAttribute(item, "ID"))
+	if owner == "" || status == "" || err != nil || !keys.IsLegacy(stringAttribute(item, "PK")) {
+		return nil
+	}
+
+	moved := make(map[string]types.AttributeValue, len(item)+2)
+	for name, value := range item {
+		moved[name] = value
+	}
+	for name, value := range keys.Task(owner, id).Item() {
+		moved[name] = value
+	}
+	moved["GS1PK"] = &types.AttributeValueMemberS{Value: keys.TaskStatus(owner, status)}
+	moved["entity_type"] = &types.AttributeValueMemberS{Value: string(keys.EntityTask)}
+	moved["schema_version"] = &types.AttributeValueMemberN{Value: entityKeysSchemaVersion}
+	moved[keys.MovedFromAttribute] = item["PK"]
+	return moved
+}
+
+// indexSearchTerms builds the search index items of a task under the
+// current keys. Tasks under legacy keys are moved by 0002-entity-keys first.
+func indexSearchTerms(item map[string]types.AttributeValue) (map[string]types.AttributeValue, []map[string]types.AttributeValue) {
+	owner := stringAttribute(item, "Owner")
+	id, err := uuid.Parse(stringAttribute(item, "ID"))
+	if owner == "" || err != nil || keys.IsLegacy(stringAttribute(item, "PK")) {
+		return nil, nil
+	}
+
+	weights := search.Terms(stringAttribute(item, "Title"), stringAttribute(item, "Description"))
+	terms := make([]map[string]types.AttributeValue, 0, len(weights))
+	for term, weight := range weights {
+		terms = append(terms, search.TermItem(owner, id, term, weight))
+	}
+	return search.DocItem(owner, id, weights), terms
+}
+
+// countTaskStats counts a task under the current keys that the API has not
+// counted. Tasks under legacy keys are moved by 0002-entity-keys first.
+func countTaskStats(item map[string]types.AttributeValue) (string, stats.Counters) {
+	owner := stringAttribute(item, "Owner")
+	if _, counted := item[stats.CountedAttribute]; counted || owner == "" || keys.IsLegacy(stringAttribute(item, "PK")) {
+		return "", nil
+	}
+	return owner, stats.Count(stats.TaskFromItem(item))
+}
+
+// isTaskItem reports whether an item is a task, under either key format
+func isTaskItem(item map[string]types.AttributeValue) bool {
+	return keys.IsLegacy(stringAttribute(item, "PK")) || stringAttribute(item, "entity_type") == string(keys.EntityTask)
+}
+
+// stringAttribute returns a string attribute of an item, or "" if it is missing
+func stringAttribute(item map[string]types.AttributeValue, name string) string {
+	if s, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+	return ""
+}
+
+// validateMigrations checks that migration IDs are present, unique and in order
+func validateMigrations(migrations []migration) error {
+	for i, m := range migrations {
+		switch {
+		case m.ID == "":
+			return fmt.Errorf("migration %d has no ID", i)
+		case i > 0 && m.ID <= migrations[i-1].ID:
+			return fmt.Errorf("migration %s must sort after %s", m.ID, migrations[i-1].ID)
+		}
+	}
+	return nil
+}
+
+// migrationState is the contents of the metadata item
+type migrationState struct {
+	// Applied maps the ID of each applied migration to when it finished
+	Applied map[string]string
+	// Cursors maps the ID of each partly applied migration to the key of the
+	// last item it processed
+	Cursors map[string]map[string]types.AttributeValue
+}
+
+// migrator applies migrations to a table
+type migrator struct {
+	client    dynamoDBAPI
+	tableName string
+	// pageSize is the number of items scanned per page; progress is saved after each page
+	pageSize int32
+}
+
+// metadataKey returns the key of the metadata item
+func metadataKey() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: metadataPK},
+		"SK": &types.AttributeValueMemberS{Value: metadataSK},
+	}
+}
+
+// loadState reads the metadata item, returning an empty state if it does not exist
+func (m *migrator) loadState(ctx context.Context) (migrationState, error) {
+	state := migrationState{
+		Applied: make(map[string]string),
+		Cursors: make(map[string]map[string]types.AttributeValue),
+	}
+
+	result, err := m.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(m.tableName),
+		Key:            metadataKey(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return state, fmt.Errorf("failed to read migration state: %w", err)
+	}
+
+	if applied, ok := result.Item["applied"].(*types.AttributeValueMemberM); ok {
+		for id, value := range applied.Value {
+			if s, ok := value.(*types.AttributeValueMemberS); ok {
+				state.Applied[id] = s.Value
+			}
+		}
+	}
+	if cursors, ok := result.Item["cursors"].(*types.AttributeValueMemberM); ok {
+		for id, value := range cursors.Value {
+			if cursor, ok := value.(*types.AttributeValueMemberM); ok {
+				state.Cursors[id] = cursor.Value
+			}
+		}
+	}
+
+	return state, nil
+}
+
+// saveState writes the metadata item
+func (m *migrator) saveState(ctx context.Context, state migrationState) error {
+	applied := make(map[string]types.AttributeValue, len(state.Applied))
+	for id, at := range state.Applied {
+		applied[id] = &types.AttributeValueMemberS{Value: at}
+	}
+	cursors := make(map[string]types.AttributeValue, len(state.Cursors))
+	for id, cursor := range state.Cursors {
+		cursors[id] = &types.AttributeValueMemberM{Value: cursor}
+	}
+
+	item := metadataKey()
+	item["applied"] = &types.AttributeValueMemberM{Value: applied}
+	item["cursors"] = &types.AttributeValueMemberM{Value: cursors}
+
+	if _, err := m.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(m.tableName),
+		Item:      item,
+	}); err != nil {
+		return fmt.Errorf("failed to save migration state: %w", err)
+	}
+	return nil
+}
+
+// run applies every migration that has not been applied yet, resuming any
+// that was interrupted from the last page it completed
+func (m *migrator) run(ctx context.Context, migrations []migration) error {
+	if err := validateMigrations(migrations); err != nil {
+		return err
+	}
+
+	state, err := m.loadState(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range migrations {
+		if _, ok := state.Applied[mig.ID]; ok {
+			continue
+		}
+
+		log.Printf("applying migration %s: %s", mig.ID, mig.Description)
+		if mig.Backfill != nil || mig.Move != nil || mig.Index != nil || mig.Count != nil {
+			if err := m.backfill(ctx, mig, &state); err != nil {
+				return fmt.Errorf("migration %s: %w", mig.ID, err)
+			}
+		}
+
+		state.Applied[mig.ID] = time.Now().UTC().Format(time.RFC3339)
+		delete(state.Cursors, mig.ID)
+		if err := m.saveState(ctx, state); err != nil {
+			return err
+		}
+		log.Printf("applied migration %s", mig.ID)
+	}
+
+	return nil
+}
+
+// backfill applies a migration's backfill or move to every task, saving the scan
+// position after each page so an interrupted run can resume
+func (m *migrator) backfill(ctx context.Context, mig migration, state *migrationState) error {
+	input := &dynamodb.ScanInput{
+		TableName:         aws.String(m.tableName),
+		ConsistentRead:    aws.Bool(true),
+		ExclusiveStartKey: state.Cursors[mig.ID],
+	}
+	if m.pageSize > 0 {
+		input.Limit = aws.Int32(m.pageSize)
+	}
+	if input.ExclusiveStartKey != nil {
+		log.Printf("resuming migration %s", mig.ID)
+	}
+
+	scanned, updated := 0, 0
+	for {
+		result, err := m.client.Scan(ctx, input)
+		if err != nil {
+			return fmt.Errorf("failed to scan table: %w", err)
+		}
+
+		for _, item := range result.Items {
+			// Skip the metadata item and anything else that is not a task
+			if !isTaskItem(item) {
+				continue
+			}
+			scanned++
+
+			ok, err := m.apply(ctx, mig, item)
+			if err != nil {
+				return err
+			}
+			if ok {
+				updated++
+			}
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+
+		// Save progress before moving to the next page
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+		state.Cursors[mig.ID] = result.LastEvaluatedKey
+		if err := m.saveState(ctx, *state); err != nil {
+			return err
+		}
+		log.Printf("migration %s: scanned %d tasks, updated %d", mig.ID, scanned, updated)
+	}
+
+	log.Printf("migration %s: scanned %d tasks, updated %d", mig.ID, scanned, updated)
+	return nil
+}
+
+// apply applies a migration to one task, and reports whether it changed it
+func (m *migrator) apply(ctx context.Context, mig migration, item map[string]types.AttributeValue) (bool, error) {
+	if mig.Move != nil {
+		moved := mig.Move(item)
+		if moved == nil {
+			return false, nil
+		}
+		return m.moveItem(ctx, item, moved)
+	}
+
+	if mig.Index != nil {
+		doc, terms := mig.Index(item)
+		if doc == nil {
+			return false, nil
+		}
+		return m.indexItem(ctx, doc, terms)
+	}
+
+	if mig.Count != nil {
+		owner, counters := mig.Count(item)
+		if counters == nil {
+			return false, nil
+		}
+		return m.countItem(ctx, item, owner, counters)
+	}
+
+	updates := mig.Backfill(item)
+	if len(updates) == 0 {
+		return false, nil
+	}
+	return m.updateItem(ctx, item, updates)
+}
+
+// moveItem puts an item under its new key and deletes the old item, and
+// reports whether it did. If an item already exists under the new key, the
+// API wrote it after this item, so the old item is only deleted.
+func (m *migrator) moveItem(ctx context.Context, item, moved map[string]types.AttributeValue) (bool, error) {
+	oldKey := keys.FromItem(item).Item()
+
+	_, err := m.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName:           aws.String(m.tableName),
+				Item:                moved,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			}},
+			{Delete: &types.Delete{
+				TableName:           aws.String(m.tableName),
+				Key:                 oldKey,
+				ConditionExpression: aws.String("attribute_exists(PK)"),
+			}},
+		},
+	})
+
+	var cancelled *types.TransactionCanceledException
+	switch {
+	case err == nil:
+		return true, nil
+	case !errors.As(err, &cancelled):
+		return false, fmt.Errorf("failed to move task %s: %w", stringAttribute(item, "SK"), err)
+	case len(cancelled.CancellationReasons) == 0 || aws.ToString(cancelled.CancellationReasons[0].Code) != "ConditionalCheckFailed":
+		// The task was deleted since it was scanned
+		return false, nil
+	}
+
+	if _, err := m.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(m.tableName),
+		Key:       oldKey,
+	}); err != nil {
+		return false, fmt.Errorf("failed to delete stale task %s: %w", stringAttribute(item, "SK"), err)
+	}
+	return true, nil
+}
+
+// indexItem puts a task's search term items and then its document, unless
+// it already has a document, and reports whether it did. The document is
+// put last, so a run interrupted part way through indexes the task again
+// when it resumes.
+func (m *migrator) indexItem(ctx context.Context, doc map[string]types.AttributeValue, terms []map[string]types.AttributeValue) (bool, error) {
+	result, err := m.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(m.tableName),
+		Key:            keys.FromItem(doc).Item(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get search document %s: %w", stringAttribute(doc, "SK"), err)
+	}
+	if result.Item != nil {
+		return false, nil
+	}
+
+	for _, term := range terms {
+		if _, err := m.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(m.tableName),
+			Item:      term,
+		}); err != nil {
+			return false, fmt.Errorf("failed to put search term %s: %w", stringAttribute(term, "SK"), err)
+		}
+	}
+
+	// The API may have indexed the task since the document was read
+	_, err = m.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(m.tableName),
+		Item:                doc,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &conditionFailed) {
+		return false, fmt.Errorf("failed to put search document %s: %w", stringAttribute(doc, "SK"), err)
+	}
+	return true, nil
+}
+
+// countItem marks a task counted and adds its counters to its owner's stats
+// in one transaction, and reports whether it did. The transaction is
+// cancelled if the task was deleted or written by the API since it was
+// scanned, since the API counts the tasks it writes.
+func (m *migrator) countItem(ctx context.Context, item map[string]types.AttributeValue, owner string, counters stats.Counters) (bool, error) {
+	// The task must still be at the revision it was scanned at
+	condition := "attribute_exists(PK) AND attribute_not_exists(#counted) AND attribute_not_exists(#revision)"
+	names := map[string]string{"#counted": stats.CountedAttribute, "#revision": stats.RevisionAttribute}
+	values := map[string]types.AttributeValue{":counted": &types.AttributeValueMemberBOOL{Value: true}}
+	if revision, ok := item[stats.RevisionAttribute]; ok {
+		condition = "attribute_exists(PK) AND attribute_not_exists(#counted) AND #revision = :revision"
+		values[":revision"] = revision
+	}
+
+	_, err := m.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Update: &types.Update{
+				TableName:                 aws.String(m.tableName),
+				Key:                       keys.FromItem(item).Item(),
+				UpdateExpression:          aws.String("SET #counted = :counted"),
+				ConditionExpression:       aws.String(condition),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			}},
+			{Update: stats.Update(m.tableName, owner, counters)},
+		},
+	})
+
+	var cancelled *types.TransactionCanceledException
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &cancelled) && len(cancelled.CancellationReasons) > 0 &&
+		aws.ToString(cancelled.CancellationReasons[0].Code) == "ConditionalCheckFailed":
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to count task %s: %w", stringAttribute(item, "SK"), err)
+}
+
+// updateItem sets attributes on an item if it still exists, and reports
+// whether it did
+func (m *migrator) updateItem(ctx context.Context, item, updates map[string]types.AttributeValue) (bool, error) {
+	expression, names, values := setExpression(updates)
+
+	_, err := m.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(m.tableName),
+		Key:                       keys.FromItem(item).Item(),
+		UpdateExpression:          aws.String(expression),
+		ConditionExpression:       aws.String("attribute_exists(PK)"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+
+	// The task was deleted since it was scanned
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update task %s: %w", stringAttribute(item, "SK"), err)
+	}
+
+	return true, nil
+}
+
+// setExpression builds an update expression setting the given attributes,
+// using placeholders so that any attribute name is allowed
+func setExpression(updates map[string]types.AttributeValue) (string, map[string]string, map[string]types.AttributeValue) {
+	attributes := make([]string, 0, len(updates))
+	for name := range updates {
+		attributes = append(attributes, name)
+	}
+	sort.Strings(attributes)
+
+	names := make(map[string]string, len(updates))
+	values := make(map[string]types.AttributeValue, len(updates))
+	clauses := make([]string, len(attributes))
+	for i, attribute := range attributes {
+		name, value := fmt.Sprintf("#a%d", i), fmt.Sprintf(":v%d", i)
+		names[name] = attribute
+		values[value] = updates[attribute]
+		clauses[i] = name + " = " + value
+	}
+
+	return "SET " + strings.Join(clauses, ", "), names, values
+}
+
+// printStatus logs whether each migration is applied, in progress or pending
+func (m *migrator) printStatus(ctx context.Context, migrations []migration) error {
+	state, err := m.loadState(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range migrations {
+		switch at, applied := state.Applied[mig.ID]; {
+		case applied:
+			fmt.Printf("%s\tapplied %s\t%s\n", mig.ID, at, mig.Description)
+		case state.Cursors[mig.ID] != nil:
+			fmt.Printf("%s\tin progress\t%s\n", mig.ID, mig.Description)
+		default:
+			fmt.Printf("%s\tpending\t%s\n", mig.ID, mig.Description)
+		}
+	}
+
+	return nil
+}
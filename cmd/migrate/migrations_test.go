@@ -0,0 +1,636 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+	"github.com/user/tasks-api/internal/stats"
+)
+
+// fakeDynamoDB is an in-memory table supporting the item operations the
+// migrator uses
+type fakeDynamoDB struct {
+	dynamoDBAPI
+	items map[string]map[string]types.AttributeValue
+	// failUpdatesAfter makes UpdateItem fail once it has succeeded this many times
+	failUpdatesAfter int
+	updates          int
+}
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{items: make(map[string]map[string]types.AttributeValue), failUpdatesAfter: -1}
+}
+
+func fakeKey(key map[string]types.AttributeValue) string {
+	return stringAttribute(key, "PK") + "|" + stringAttribute(key, "SK")
+}
+
+func (f *fakeDynamoDB) put(item map[string]types.AttributeValue) {
+	f.items[fakeKey(item)] = item
+}
+
+func (f *fakeDynamoDB) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: f.items[fakeKey(params.Key)]}, nil
+}
+
+func (f *fakeDynamoDB) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.put(params.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// Scan returns items in key order, one page of Limit items at a time
+func (f *fakeDynamoDB) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	keys := make([]string, 0, len(f.items))
+	for key := range f.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if params.ExclusiveStartKey != nil {
+		start = sort.SearchStrings(keys, fakeKey(params.ExclusiveStartKey)+"\x00")
+	}
+	end := len(keys)
+	if params.Limit != nil {
+		end = min(start+int(*params.Limit), len(keys))
+	}
+
+	output := &dynamodb.ScanOutput{}
+	for _, key := range keys[start:end] {
+		output.Items = append(output.Items, f.items[key])
+	}
+	if end < len(keys) {
+		last := f.items[keys[end-1]]
+		output.LastEvaluatedKey = map[string]types.AttributeValue{"PK": last["PK"], "SK": last["SK"]}
+	}
+	return output, nil
+}
+
+// UpdateItem supports "SET #a = :v, ..." with an attribute_exists condition
+func (f *fakeDynamoDB) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if f.updates == f.failUpdatesAfter {
+		return nil, errors.New("injected failure")
+	}
+
+	item, ok := f.items[fakeKey(params.Key)]
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	for _, clause := range strings.Split(strings.TrimPrefix(*params.UpdateExpression, "SET "), ", ") {
+		name, value, _ := strings.Cut(clause, " = ")
+		item[params.ExpressionAttributeNames[name]] = params.ExpressionAttributeValues[value]
+	}
+	f.updates++
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// DeleteItem deletes an item unconditionally
+func (f *fakeDynamoDB) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	delete(f.items, fakeKey(params.Key))
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+// TransactWriteItems supports puts and deletes conditional on
+// attribute_exists(PK) or attribute_not_exists(PK), and updates conditional
+// on the item existing without the attributes named in attribute_not_exists,
+// at the revision in :revision if it is set
+func (f *fakeDynamoDB) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	reasons := make([]types.CancellationReason, len(params.TransactItems))
+	cancelled := false
+	for i, item := range params.TransactItems {
+		code := "None"
+		if update := item.Update; update != nil {
+			stored, exists := f.items[fakeKey(update.Key)]
+			if condition := aws.ToString(update.ConditionExpression); condition != "" {
+				holds := exists
+				for name, attribute := range update.ExpressionAttributeNames {
+					if _, ok := stored[attribute]; ok && strings.Contains(condition, "attribute_not_exists("+name+")") {
+						holds = false
+					}
+				}
+				if revision, ok := update.ExpressionAttributeValues[":revision"]; ok && !reflect.DeepEqual(revision, stored[stats.RevisionAttribute]) {
+					holds = false
+				}
+				if !holds {
+					code, cancelled = "ConditionalCheckFailed", true
+				}
+			}
+			reasons[i] = types.CancellationReason{Code: aws.String(code)}
+			continue
+		}
+
+		key, condition := "", ""
+		if item.Put != nil {
+			key, condition = fakeKey(item.Put.Item), *item.Put.ConditionExpression
+		} else {
+			key, condition = fakeKey(item.Delete.Key), *item.Delete.ConditionExpression
+		}
+		_, exists := f.items[key]
+		if exists != (condition == "attribute_exists(PK)") {
+			code, cancelled = "ConditionalCheckFailed", true
+		}
+		reasons[i] = types.CancellationReason{Code: aws.String(code)}
+	}
+	if cancelled {
+		return nil, &types.TransactionCanceledException{CancellationReasons: reasons}
+	}
+
+	for _, item := range params.TransactItems {
+		switch {
+		case item.Put != nil:
+			f.put(item.Put.Item)
+		case item.Delete != nil:
+			delete(f.items, fakeKey(item.Delete.Key))
+		default:
+			f.applyUpdate(item.Update)
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// applyUpdate applies "SET a = :v, ... ADD #n :v, ..." to an item, creating
+// it if it does not exist
+func (f *fakeDynamoDB) applyUpdate(update *types.Update) {
+	item, ok := f.items[fakeKey(update.Key)]
+	if !ok {
+		item = maps.Clone(update.Key)
+		f.put(item)
+	}
+	name := func(placeholder string) string {
+		if attribute, ok := update.ExpressionAttributeNames[placeholder]; ok {
+			return attribute
+		}
+		return placeholder
+	}
+
+	set, add, _ := strings.Cut(strings.TrimPrefix(*update.UpdateExpression, "SET "), " ADD ")
+	for _, clause := range strings.Split(set, ", ") {
+		attribute, value, _ := strings.Cut(clause, " = ")
+		item[name(attribute)] = update.ExpressionAttributeValues[value]
+	}
+	for _, clause := range strings.Split(add, ", ") {
+		attribute, value, ok := strings.Cut(clause, " ")
+		if !ok {
+			continue
+		}
+		var total int64
+		if n, ok := item[name(attribute)].(*types.AttributeValueMemberN); ok {
+			total, _ = strconv.ParseInt(n.Value, 10, 64)
+		}
+		delta, _ := strconv.ParseInt(update.ExpressionAttributeValues[value].(*types.AttributeValueMemberN).Value, 10, 64)
+		item[name(attribute)] = &types.AttributeValueMemberN{Value: strconv.FormatInt(total+delta, 10)}
+	}
+}
+
+// taskID returns the ID of the i-th test task
+func taskID(i int) string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+}
+
+// taskItem builds a task item under a legacy key with the given GS1PK
+func taskItem(i int, gs1pk string) map[string]types.AttributeValue {
+	owner := fmt.Sprintf("owner%d@example.com", i)
+	item := map[string]types.AttributeValue{
+		"PK":     &types.AttributeValueMemberS{Value: "#" + owner},
+		"SK":     &types.AttributeValueMemberS{Value: fmt.Sprintf("#%08d", i)},
+		"ID":     &types.AttributeValueMemberS{Value: taskID(i)},
+		"Owner":  &types.AttributeValueMemberS{Value: owner},
+		"Status": &types.AttributeValueMemberS{Value: "OPEN"},
+		"GS1SK":  &types.AttributeValueMemberS{Value: "#2024-01-01T00:00:00Z"},
+	}
+	if gs1pk != "" {
+		item["GS1PK"] = &types.AttributeValueMemberS{Value: gs1pk}
+	}
+	return item
+}
+
+// countingMigration counts how many times its backfill sees each task
+func countingMigration(id string, seen map[string]int) migration {
+	return migration{
+		ID: id,
+		Backfill: func(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+			seen[stringAttribute(item, "SK")]++
+			return map[string]types.AttributeValue{
+				"migrated": &types.AttributeValueMemberS{Value: id},
+			}
+		},
+	}
+}
+
+func TestMigratorRun(t *testing.T) {
+	// Arrange
+	client := newFakeDynamoDB()
+	for i := range 10 {
+		client.put(taskItem(i, ""))
+	}
+	m := &migrator{client: client, tableName: "tasks", pageSize: 3}
+	seen := make(map[string]int)
+	registry := []migration{countingMigration("0001-first", seen)}
+
+	// Act
+	err := m.run(context.Background(), registry)
+	state, _ := m.loadState(context.Background())
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(seen) != 10 {
+		t.Errorf("Expected 10 tasks to be backfilled, got %d", len(seen))
+	}
+	if _, ok := state.Applied["0001-first"]; !ok {
+		t.Errorf("Expected the migration to be recorded as applied, got %v", state.Applied)
+	}
+	if len(state.Cursors) != 0 {
+		t.Errorf("Expected no cursors after completing, got %v", state.Cursors)
+	}
+}
+
+func TestMigratorRunSkipsApplied(t *testing.T) {
+	// Arrange
+	client := newFakeDynamoDB()
+	client.put(taskItem(0, ""))
+	m := &migrator{client: client, tableName: "tasks", pageSize: 3}
+	seen := make(map[string]int)
+	registry := []migration{countingMigration("0001-first", seen)}
+	_ = m.run(context.Background(), registry)
+
+	// Act
+	registry = append(registry, countingMigration("0002-second", seen))
+	err := m.run(context.Background(), registry)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if seen["#00000000"] != 2 {
+		t.Errorf("Expected only the new migration to run again, got %d backfills", seen["#00000000"])
+	}
+}
+
+func TestMigratorResumes(t *testing.T) {
+	// Arrange: fail part way through the third page
+	client := newFakeDynamoDB()
+	for i := range 10 {
+		client.put(taskItem(i, ""))
+	}
+	client.failUpdatesAfter = 7
+	m := &migrator{client: client, tableName: "tasks", pageSize: 3}
+	seen := make(map[string]int)
+	registry := []migration{countingMigration("0001-first", seen)}
+
+	// Act
+	firstErr := m.run(context.Background(), registry)
+	interrupted, _ := m.loadState(context.Background())
+	client.failUpdatesAfter = -1
+	secondErr := m.run(context.Background(), registry)
+
+	// Assert
+	if firstErr == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+	if interrupted.Cursors["0001-first"] == nil {
+		t.Errorf("Expected progress to be saved, got %v", interrupted.Cursors)
+	}
+	if secondErr != nil {
+		t.Fatalf("Expected the second run to succeed, got %v", secondErr)
+	}
+	if len(seen) != 10 {
+		t.Errorf("Expected every task to be backfilled, got %d", len(seen))
+	}
+	// Only the interrupted page is processed again
+	for sk, count := range seen {
+		if count > 1 && sk < "#00000006" {
+			t.Errorf("Expected task %s from a completed page to be backfilled once, got %d", sk, count)
+		}
+	}
+}
+
+func TestMigratorSkipsMetadata(t *testing.T) {
+	// Arrange
+	client := newFakeDynamoDB()
+	client.put(taskItem(0, ""))
+	m := &migrator{client: client, tableName: "tasks", pageSize: 1}
+	_ = m.saveState(context.Background(), migrationState{
+		Applied: map[string]string{},
+		Cursors: map[string]map[string]types.AttributeValue{},
+	})
+	seen := make(map[string]int)
+
+	// Act
+	err := m.run(context.Background(), []migration{countingMigration("0001-first", seen)})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(seen) != 1 || seen[metadataSK] != 0 {
+		t.Errorf("Expected only the task to be backfilled, got %v", seen)
+	}
+}
+
+func TestBackfillGS1Keys(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     map[string]types.AttributeValue
+		expected []string
+	}{
+		{"correct", taskItem(0, "#owner0@example.com#OPEN"), nil},
+		{"missing", taskItem(0, ""), []string{"GS1PK"}},
+		{"stale status", taskItem(0, "#owner0@example.com#CLOSED"), []string{"GS1PK"}},
+		{"entity keys", moveToEntityKeys(taskItem(0, "")), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			updates := backfillGS1Keys(tt.item)
+
+			// Assert
+			var names []string
+			for name := range updates {
+				names = append(names, name)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected updates to %v, got %v", tt.expected, names)
+			}
+			if gs1pk, ok := updates["GS1PK"].(*types.AttributeValueMemberS); ok && gs1pk.Value != "#owner0@example.com#OPEN" {
+				t.Errorf("Expected GS1PK #owner0@example.com#OPEN, got %s", gs1pk.Value)
+			}
+		})
+	}
+}
+
+func TestMoveToEntityKeys(t *testing.T) {
+	// Arrange
+	item := taskItem(0, "#owner0@example.com#OPEN")
+
+	// Act
+	moved := moveToEntityKeys(item)
+	again := moveToEntityKeys(moved)
+
+	// Assert
+	expected := map[string]string{
+		"PK":          "USER#owner0@example.com",
+		"SK":          "TASK#" + taskID(0),
+		"GS1PK":       "USER#owner0@example.com#STATUS#OPEN",
+		"GS1SK":       "#2024-01-01T00:00:00Z",
+		"entity_type": "TASK",
+		"MovedFrom":   "#owner0@example.com",
+	}
+	for name, value := range expected {
+		if got := stringAttribute(moved, name); got != value {
+			t.Errorf("Expected %s %q, got %q", name, value, got)
+		}
+	}
+	if version, ok := moved["schema_version"].(*types.AttributeValueMemberN); !ok || version.Value != "3" {
+		t.Errorf("Expected schema_version 3, got %v", moved["schema_version"])
+	}
+	if stringAttribute(item, "PK") != "#owner0@example.com" {
+		t.Errorf("Expected the scanned item to be left unchanged, got %v", item)
+	}
+	if again != nil {
+		t.Errorf("Expected a moved item to be left unchanged, got %v", again)
+	}
+}
+
+func TestMigratorMovesTasks(t *testing.T) {
+	// Arrange: a legacy task, and a legacy task that also has a newer current item
+	client := newFakeDynamoDB()
+	client.put(taskItem(0, "#owner0@example.com#OPEN"))
+	client.put(taskItem(1, "#owner1@example.com#OPEN"))
+	current := moveToEntityKeys(taskItem(1, "#owner1@example.com#OPEN"))
+	current["Title"] = &types.AttributeValueMemberS{Value: "Newer"}
+	client.put(current)
+	m := &migrator{client: client, tableName: "tasks", pageSize: 1}
+
+	// Act
+	err := m.run(context.Background(), migrations)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i := range 2 {
+		if _, ok := client.items[fmt.Sprintf("#owner%d@example.com|#%08d", i, i)]; ok {
+			t.Errorf("Expected legacy task %d to be removed", i)
+		}
+		if _, ok := client.items[fmt.Sprintf("USER#owner%d@example.com|TASK#%s", i, taskID(i))]; !ok {
+			t.Errorf("Expected task %d under its entity key", i)
+		}
+	}
+	if title := stringAttribute(client.items["USER#owner1@example.com|TASK#"+taskID(1)], "Title"); title != "Newer" {
+		t.Errorf("Expected the newer item to be kept, got title %q", title)
+	}
+}
+
+func TestIndexSearchTerms(t *testing.T) {
+	// Arrange
+	item := moveToEntityKeys(taskItem(0, ""))
+	item["Title"] = &types.AttributeValueMemberS{Value: "Write report"}
+	item["Description"] = &types.AttributeValueMemberS{Value: "Quarterly reports"}
+
+	// Act
+	doc, terms := indexSearchTerms(item)
+	legacyDoc, _ := indexSearchTerms(taskItem(0, ""))
+
+	// Assert
+	if got := stringAttribute(doc, "SK"); got != "SEARCHDOC#"+taskID(0) {
+		t.Errorf("Expected document SK SEARCHDOC#%s, got %q", taskID(0), got)
+	}
+	var sks []string
+	for _, term := range terms {
+		sks = append(sks, stringAttribute(term, "SK"))
+	}
+	sort.Strings(sks)
+	expected := []string{"TERM#quarter#" + taskID(0), "TERM#report#" + taskID(0), "TERM#write#" + taskID(0)}
+	if fmt.Sprint(sks) != fmt.Sprint(expected) {
+		t.Errorf("Expected terms %v, got %v", expected, sks)
+	}
+	if legacyDoc != nil {
+		t.Errorf("Expected a legacy task to be left unindexed, got %v", legacyDoc)
+	}
+}
+
+func TestMigratorIndexesTasks(t *testing.T) {
+	// Arrange: a task, and a task the API has already indexed
+	client := newFakeDynamoDB()
+	for i := range 2 {
+		item := moveToEntityKeys(taskItem(i, ""))
+		item["Title"] = &types.AttributeValueMemberS{Value: "Write report"}
+		client.put(item)
+	}
+	indexed := "USER#owner1@example.com|SEARCHDOC#" + taskID(1)
+	client.items[indexed] = map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#owner1@example.com"},
+		"SK": &types.AttributeValueMemberS{Value: "SEARCHDOC#" + taskID(1)},
+	}
+	m := &migrator{client: client, tableName: "tasks", pageSize: 2}
+
+	// Act
+	err := m.run(context.Background(), migrations)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, key := range []string{
+		"USER#owner0@example.com|SEARCHDOC#" + taskID(0),
+		"USER#owner0@example.com|TERM#write#" + taskID(0),
+		"USER#owner0@example.com|TERM#report#" + taskID(0),
+	} {
+		if _, ok := client.items[key]; !ok {
+			t.Errorf("Expected item %s", key)
+		}
+	}
+	if _, ok := client.items["USER#owner1@example.com|TERM#write#"+taskID(1)]; ok {
+		t.Errorf("Expected the indexed task to be skipped")
+	}
+}
+
+func TestCountTaskStats(t *testing.T) {
+	// Arrange
+	item := moveToEntityKeys(taskItem(0, ""))
+	item["Labels"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{
+		&types.AttributeValueMemberS{Value: "bug"},
+	}}
+	counted := maps.Clone(item)
+	counted[stats.CountedAttribute] = &types.AttributeValueMemberBOOL{Value: true}
+
+	// Act
+	owner, counters := countTaskStats(item)
+	_, countedCounters := countTaskStats(counted)
+	_, legacyCounters := countTaskStats(taskItem(1, ""))
+
+	// Assert
+	if want := (stats.Counters{"status#OPEN": 1, "label#bug": 1}); owner != "owner0@example.com" || !reflect.DeepEqual(counters, want) {
+		t.Errorf("Expected %v for owner0@example.com, got %v for %s", want, counters, owner)
+	}
+	if countedCounters != nil || legacyCounters != nil {
+		t.Errorf("Expected counted and legacy tasks to be skipped, got %v and %v", countedCounters, legacyCounters)
+	}
+}
+
+func TestMigratorCountsTasks(t *testing.T) {
+	// Arrange: two tasks of one owner, one closed, and a task the API has
+	// already counted
+	client := newFakeDynamoDB()
+	for i, status := range []string{"OPEN", "CLOSED", "OPEN"} {
+		item := moveToEntityKeys(taskItem(i, ""))
+		for name, value := range keys.Task("test@example.com", uuid.MustParse(taskID(i))).Item() {
+			item[name] = value
+		}
+		item["Owner"] = &types.AttributeValueMemberS{Value: "test@example.com"}
+		item["Status"] = &types.AttributeValueMemberS{Value: status}
+		if i == 2 {
+			item[stats.CountedAttribute] = &types.AttributeValueMemberBOOL{Value: true}
+		}
+		client.put(item)
+	}
+	m := &migrator{client: client, tableName: "tasks", pageSize: 2}
+
+	// Act: running again must not count the tasks twice
+	err := m.run(context.Background(), migrations)
+	delete(client.items, metadataPK+"|"+metadataSK)
+	againErr := m.run(context.Background(), migrations)
+
+	// Assert
+	if err != nil || againErr != nil {
+		t.Fatalf("Expected no errors, got %v and %v", err, againErr)
+	}
+	counters, err := stats.FromItem(client.items["USER#test@example.com|STATS"])
+	if err != nil {
+		t.Fatalf("Failed to read counters: %v", err)
+	}
+	if want := (stats.Counters{"status#OPEN": 1, "status#CLOSED": 1}); !reflect.DeepEqual(counters, want) {
+		t.Errorf("Expected %v, got %v", want, counters)
+	}
+}
+
+func TestMigratorCountSkipsRevisedTasks(t *testing.T) {
+	// Arrange: a task the API wrote again after it was scanned
+	client := newFakeDynamoDB()
+	scanned := moveToEntityKeys(taskItem(0, ""))
+	scanned[stats.RevisionAttribute] = &types.AttributeValueMemberN{Value: "1"}
+	stored := maps.Clone(scanned)
+	stored[stats.RevisionAttribute] = &types.AttributeValueMemberN{Value: "2"}
+	client.put(stored)
+	m := &migrator{client: client, tableName: "tasks"}
+
+	// Act
+	counted, err := m.countItem(context.Background(), scanned, "test@example.com", stats.Counters{"status#OPEN": 1})
+
+	// Assert
+	if err != nil || counted {
+		t.Errorf("Expected the revised task to be skipped, got %v (%v)", counted, err)
+	}
+	if _, ok := client.items["USER#test@example.com|STATS"]; ok {
+		t.Error("Expected no counters to be written")
+	}
+}
+
+func TestValidateMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     []string
+		wantErr bool
+	}{
+		{"registry", nil, false},
+		{"ordered", []string{"0001-a", "0002-b"}, false},
+		{"out of order", []string{"0002-b", "0001-a"}, true},
+		{"duplicate", []string{"0001-a", "0001-a"}, true},
+		{"missing ID", []string{""}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			registry := migrations
+			if tt.ids != nil {
+				registry = nil
+				for _, id := range tt.ids {
+					registry = append(registry, migration{ID: id})
+				}
+			}
+
+			// Act
+			err := validateMigrations(registry)
+
+			// Assert
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSetExpression(t *testing.T) {
+	// Act
+	expression, names, values := setExpression(map[string]types.AttributeValue{
+		"status": &types.AttributeValueMemberS{Value: "OPEN"},
+		"GS1PK":  &types.AttributeValueMemberS{Value: "#a#OPEN"},
+	})
+
+	// Assert
+	if expression != "SET #a0 = :v0, #a1 = :v1" {
+		t.Errorf("Expected a SET of two placeholders, got %s", expression)
+	}
+	if names["#a0"] != "GS1PK" || names["#a1"] != "status" {
+		t.Errorf("Expected names in sorted order, got %v", names)
+	}
+	if value, ok := values[":v1"].(*types.AttributeValueMemberS); !ok || value.Value != "OPEN" {
+		t.Errorf("Expected :v1 to be OPEN, got %v", values[":v1"])
+	}
+}
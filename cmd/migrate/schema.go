@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// indexSchema defines a global secondary index
+type indexSchema struct {
+	Name         string
+	PartitionKey string
+	SortKey      string
+}
+
+// tableSchema defines a table's keys and global secondary indexes. Every
+// key attribute is a string and every index projects all attributes.
+type tableSchema struct {
+	PartitionKey string
+	SortKey      string
+	Indexes      []indexSchema
+}
+
+// taskTableSchema is the schema of the tasks table. Keep it in sync with
+// resources/dynamodb.yml, which creates the table on deploy.
+var taskTableSchema = tableSchema{
+	PartitionKey: "PK",
+	SortKey:      "SK",
+	Indexes: []indexSchema{
+		{Name: "GS1", PartitionKey: "GS1PK", SortKey: "GS1SK"},
+	},
+}
+
+// indexWaitInterval is how often to check whether a new index is active
+var indexWaitInterval = 5 * time.Second
+
+// keySchema builds a key schema from a partition key and optional sort key
+func keySchema(partitionKey, sortKey string) []types.KeySchemaElement {
+	elements := []types.KeySchemaElement{
+		{AttributeName: aws.String(partitionKey), KeyType: types.KeyTypeHash},
+	}
+	if sortKey != "" {
+		elements = append(elements, types.KeySchemaElement{AttributeName: aws.String(sortKey), KeyType: types.KeyTypeRange})
+	}
+	return elements
+}
+
+// attributeDefinitions defines the key attributes of the table and the given indexes
+func (s tableSchema) attributeDefinitions(indexes []indexSchema) []types.AttributeDefinition {
+	names := []string{s.PartitionKey, s.SortKey}
+	for _, index := range indexes {
+		names = append(names, index.PartitionKey, index.SortKey)
+	}
+
+	seen := make(map[string]bool)
+	var definitions []types.AttributeDefinition
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		definitions = append(definitions, types.AttributeDefinition{
+			AttributeName: aws.String(name),
+			AttributeType: types.ScalarAttributeTypeS,
+		})
+	}
+	return definitions
+}
+
+// createTableInput builds the request to create a table with this schema
+func (s tableSchema) createTableInput(tableName string) *dynamodb.CreateTableInput {
+	input := &dynamodb.CreateTableInput{
+		TableName:            aws.String(tableName),
+		BillingMode:          types.BillingModePayPerRequest,
+		AttributeDefinitions: s.attributeDefinitions(s.Indexes),
+		KeySchema:            keySchema(s.PartitionKey, s.SortKey),
+	}
+	for _, index := range s.Indexes {
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, types.GlobalSecondaryIndex{
+			IndexName:  aws.String(index.Name),
+			KeySchema:  keySchema(index.PartitionKey, index.SortKey),
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		})
+	}
+	return input
+}
+
+// checkKeys returns an error if a table's primary key differs from the
+// schema, which DynamoDB cannot change in place
+func (s tableSchema) checkKeys(table *types.TableDescription) error {
+	if keys := keyNames(table.KeySchema); keys != [2]string{s.PartitionKey, s.SortKey} {
+		return fmt.Errorf("table key is %v, but the schema requires %v; the table must be recreated",
+			keys, [2]string{s.PartitionKey, s.SortKey})
+	}
+	return nil
+}
+
+// missingIndexes returns the schema's indexes that a table does not have. It
+// returns an error if an index exists with different keys, since DynamoDB
+// cannot change an index's keys in place.
+func (s tableSchema) missingIndexes(table *types.TableDescription) ([]indexSchema, error) {
+	existing := make(map[string][2]string)
+	for _, index := range table.GlobalSecondaryIndexes {
+		existing[aws.ToString(index.IndexName)] = keyNames(index.KeySchema)
+	}
+
+	var missing []indexSchema
+	for _, index := range s.Indexes {
+		keys, ok := existing[index.Name]
+		if !ok {
+			missing = append(missing, index)
+			continue
+		}
+		if keys != [2]string{index.PartitionKey, index.SortKey} {
+			return nil, fmt.Errorf("index %s has key %v, but the schema requires %v; drop it to recreate it",
+				index.Name, keys, [2]string{index.PartitionKey, index.SortKey})
+		}
+	}
+	return missing, nil
+}
+
+// keyNames returns the partition and sort key names of a key schema
+func keyNames(elements []types.KeySchemaElement) [2]string {
+	var names [2]string
+	for _, element := range elements {
+		switch element.KeyType {
+		case types.KeyTypeHash:
+			names[0] = aws.ToString(element.AttributeName)
+		case types.KeyTypeRange:
+			names[1] = aws.ToString(element.AttributeName)
+		}
+	}
+	return names
+}
+
+// ensureTable creates the table if it does not exist and adds any indexes
+// it is missing, waiting for each to become active
+func ensureTable(ctx context.Context, client dynamoDBAPI, tableName string, schema tableSchema) error {
+	described, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		log.Printf("creating table %s", tableName)
+		if _, err := client.CreateTable(ctx, schema.createTableInput(tableName)); err != nil {
+			return fmt.Errorf("failed to create table %s: %w", tableName, err)
+		}
+		return waitForTable(ctx, client, tableName, "")
+	case err != nil:
+		return fmt.Errorf("failed to describe table %s: %w", tableName, err)
+	}
+
+	if err := schema.checkKeys(described.Table); err != nil {
+		return err
+	}
+	missing, err := schema.missingIndexes(described.Table)
+	if err != nil {
+		return err
+	}
+
+	// DynamoDB only allows one index to be created per update
+	for _, index := range missing {
+		log.Printf("creating index %s on table %s", index.Name, tableName)
+		_, err := client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:            aws.String(tableName),
+			AttributeDefinitions: schema.attributeDefinitions([]indexSchema{index}),
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{
+				Create: &types.CreateGlobalSecondaryIndexAction{
+					IndexName:  aws.String(index.Name),
+					KeySchema:  keySchema(index.PartitionKey, index.SortKey),
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				},
+			}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create index %s: %w", index.Name, err)
+		}
+		if err := waitForTable(ctx, client, tableName, index.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// waitForTable waits until a table, and the named index if any, are active
+func waitForTable(ctx context.Context, client dynamoDBAPI, tableName, indexName string) error {
+	for {
+		described, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+		if err != nil {
+			return fmt.Errorf("failed to describe table %s: %w", tableName, err)
+		}
+		if tableActive(described.Table, indexName) {
+			return nil
+		}
+
+		log.Printf("waiting for table %s to become active", tableName)
+		select {
+		case <-time.After(indexWaitInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// tableActive reports whether a table, and the named index if any, are active
+func tableActive(table *types.TableDescription, indexName string) bool {
+	if table.TableStatus != types.TableStatusActive {
+		return false
+	}
+	if indexName == "" {
+		return true
+	}
+	for _, index := range table.GlobalSecondaryIndexes {
+		if aws.ToString(index.IndexName) == indexName {
+			return index.IndexStatus == types.IndexStatusActive
+		}
+	}
+	return false
+}
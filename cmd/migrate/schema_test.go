@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// describedTable builds a table description with the task table's keys and
+// the given indexes
+func describedTable(indexes ...indexSchema) *types.TableDescription {
+	table := &types.TableDescription{
+		TableStatus: types.TableStatusActive,
+		KeySchema:   keySchema("PK", "SK"),
+	}
+	for _, index := range indexes {
+		table.GlobalSecondaryIndexes = append(table.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName:   aws.String(index.Name),
+			KeySchema:   keySchema(index.PartitionKey, index.SortKey),
+			IndexStatus: types.IndexStatusActive,
+		})
+	}
+	return table
+}
+
+func TestMissingIndexes(t *testing.T) {
+	gs1 := taskTableSchema.Indexes[0]
+
+	tests := []struct {
+		name     string
+		table    *types.TableDescription
+		expected int
+		wantErr  bool
+	}{
+		{"up to date", describedTable(gs1), 0, false},
+		{"missing", describedTable(), 1, false},
+		{"different keys", describedTable(indexSchema{Name: "GS1", PartitionKey: "GS1PK"}), 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			missing, err := taskTableSchema.missingIndexes(tt.table)
+
+			// Assert
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if len(missing) != tt.expected {
+				t.Errorf("Expected %d missing indexes, got %v", tt.expected, missing)
+			}
+		})
+	}
+}
+
+func TestCheckKeys(t *testing.T) {
+	// Arrange
+	wrong := &types.TableDescription{KeySchema: keySchema("id", "")}
+
+	// Act
+	okErr := taskTableSchema.checkKeys(describedTable())
+	wrongErr := taskTableSchema.checkKeys(wrong)
+
+	// Assert
+	if okErr != nil {
+		t.Errorf("Expected no error, got %v", okErr)
+	}
+	if wrongErr == nil {
+		t.Error("Expected an error for a different primary key")
+	}
+}
+
+func TestCreateTableInput(t *testing.T) {
+	// Act
+	input := taskTableSchema.createTableInput("tasks")
+
+	// Assert
+	if len(input.AttributeDefinitions) != 4 {
+		t.Errorf("Expected 4 key attributes, got %d", len(input.AttributeDefinitions))
+	}
+	if len(input.GlobalSecondaryIndexes) != 1 || aws.ToString(input.GlobalSecondaryIndexes[0].IndexName) != "GS1" {
+		t.Errorf("Expected the GS1 index, got %v", input.GlobalSecondaryIndexes)
+	}
+	if input.BillingMode != types.BillingModePayPerRequest {
+		t.Errorf("Expected on-demand billing, got %s", input.BillingMode)
+	}
+}
+
+func TestTableActive(t *testing.T) {
+	// Arrange
+	creating := describedTable(taskTableSchema.Indexes[0])
+	creating.GlobalSecondaryIndexes[0].IndexStatus = types.IndexStatusCreating
+
+	// Act & Assert
+	if !tableActive(creating, "") {
+		t.Error("Expected the table to be active")
+	}
+	if tableActive(creating, "GS1") {
+		t.Error("Expected the index not to be active while it is being created")
+	}
+	if tableActive(describedTable(), "GS1") {
+		t.Error("Expected a missing index not to be active")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/user/tasks-api/internal/env"
+	"github.com/user/tasks-api/internal/outbox"
+)
+
+// getPublisher creates the publisher named in RELAY_PUBLISHER, which is one
+// of sns, sqs or eventbridge, to the topic ARN, queue URL or event bus in
+// RELAY_TARGET
+func getPublisher(cfg aws.Config) (outbox.Publisher, error) {
+	target := os.Getenv("RELAY_TARGET")
+	if target == "" {
+		return nil, fmt.Errorf("RELAY_TARGET is not set")
+	}
+
+	switch name := os.Getenv("RELAY_PUBLISHER"); name {
+	case "sns":
+		return outbox.NewSNSPublisher(sns.NewFromConfig(cfg), target), nil
+	case "sqs":
+		return outbox.NewSQSPublisher(sqs.NewFromConfig(cfg), target), nil
+	case "eventbridge":
+		return outbox.NewEventBridgePublisher(eventbridge.NewFromConfig(cfg), target), nil
+	default:
+		return nil, fmt.Errorf("unknown relay publisher %q", name)
+	}
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("failed to load AWS config: %v", err)
+	}
+	publisher, err := getPublisher(cfg)
+	if err != nil {
+		log.Fatalf("failed to create publisher: %v", err)
+	}
+
+	table := outbox.NewTable(dynamodb.NewFromConfig(cfg), env.TableName())
+	lambda.Start(outbox.NewRelay(table, publisher).Drain)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/user/tasks-api/internal/outbox"
+)
+
+func TestGetPublisher(t *testing.T) {
+	tests := []struct {
+		name      string
+		publisher string
+		target    string
+		want      outbox.Publisher
+	}{
+		{"sns", "sns", "arn:aws:sns:eu-west-1:123456789012:tasks.fifo", &outbox.SNSPublisher{}},
+		{"sqs", "sqs", "https://sqs.eu-west-1.amazonaws.com/123456789012/tasks.fifo", &outbox.SQSPublisher{}},
+		{"eventbridge", "eventbridge", "tasks", &outbox.EventBridgePublisher{}},
+		{"unknown publisher", "kafka", "tasks", nil},
+		{"missing target", "sqs", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			t.Setenv("RELAY_PUBLISHER", tt.publisher)
+			t.Setenv("RELAY_TARGET", tt.target)
+
+			// Act
+			publisher, err := getPublisher(aws.Config{Region: "eu-west-1"})
+
+			// Assert
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("Expected an error, got %T", publisher)
+				}
+				return
+			}
+			if err != nil || reflect.TypeOf(publisher) != reflect.TypeOf(tt.want) {
+				t.Errorf("Expected a %T, got %T (%v)", tt.want, publisher, err)
+			}
+		})
+	}
+}
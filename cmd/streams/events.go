@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+// EventType identifies the kind of change an event describes
+type EventType string
+
+const (
+	// EventTaskCreated is the type of TaskCreated events
+	EventTaskCreated EventType = "TaskCreated"
+	// EventTaskUpdated is the type of TaskUpdated events
+	EventTaskUpdated EventType = "TaskUpdated"
+	// EventTaskClosed is the type of TaskClosed events
+	EventTaskClosed EventType = "TaskClosed"
+	// EventTaskDeleted is the type of TaskDeleted events
+	EventTaskDeleted EventType = "TaskDeleted"
+)
+
+// statusClosed is the status of closed tasks
+const statusClosed = "CLOSED"
+
+// Event is a change to a task
+type Event interface {
+	Type() EventType
+	Metadata() Metadata
+}
+
+// Metadata identifies the stream record an event was decoded from
+type Metadata struct {
+	// ID is the stream record's event ID, which is the same each time the
+	// record is delivered, so sinks can drop duplicates with it
+	ID             string    `json:"id"`
+	SequenceNumber string    `json:"sequenceNumber"`
+	Time           time.Time `json:"time"`
+	Owner          string    `json:"owner"`
+	TaskID         uuid.UUID `json:"taskId"`
+}
+
+// TaskCreated is a task being added
+type TaskCreated struct {
+	Meta Metadata `json:"metadata"`
+	Task Task     `json:"task"`
+}
+
+// TaskUpdated is a change to a task other than closing it, including
+// reopening it
+type TaskUpdated struct {
+	Meta     Metadata `json:"metadata"`
+	Task     Task     `json:"task"`
+	Previous Task     `json:"previous"`
+}
+
+// TaskClosed is an open task being closed, possibly with other changes
+type TaskClosed struct {
+	Meta     Metadata `json:"metadata"`
+	Task     Task     `json:"task"`
+	Previous Task     `json:"previous"`
+}
+
+// TaskDeleted is a task being deleted; Task is the task before it was
+type TaskDeleted struct {
+	Meta Metadata `json:"metadata"`
+	Task Task     `json:"task"`
+}
+
+// Type returns EventTaskCreated
+func (e TaskCreated) Type() EventType { return EventTaskCreated }
+
+// Metadata returns the event's metadata
+func (e TaskCreated) Metadata() Metadata { return e.Meta }
+
+// Type returns EventTaskUpdated
+func (e TaskUpdated) Type() EventType { return EventTaskUpdated }
+
+// Metadata returns the event's metadata
+func (e TaskUpdated) Metadata() Metadata { return e.Meta }
+
+// Type returns EventTaskClosed
+func (e TaskClosed) Type() EventType { return EventTaskClosed }
+
+// Metadata returns the event's metadata
+func (e TaskClosed) Metadata() Metadata { return e.Meta }
+
+// Type returns EventTaskDeleted
+func (e TaskDeleted) Type() EventType { return EventTaskDeleted }
+
+// Metadata returns the event's metadata
+func (e TaskDeleted) Metadata() Metadata { return e.Meta }
+
+// MarshalEvent encodes an event as JSON, with its type alongside it
+func MarshalEvent(event Event) ([]byte, error) {
+	return json.Marshal(struct {
+		Type  EventType `json:"type"`
+		Event Event     `json:"event"`
+	}{event.Type(), event})
+}
+
+// errMissingImage is returned for records without the images their event
+// needs, which happens if the stream does not record both images
+var errMissingImage = errors.New("stream record is missing an image; the stream view type must be NEW_AND_OLD_IMAGES")
+
+// decodeRecord decodes the event a stream record describes. It returns nil
+// for records of items other than tasks, including tasks under legacy keys,
+// and for changes that leave the task itself unchanged, such as writing it
+// back in a newer schema version or moving it from its legacy key, which
+// inserts it under its current key marked with keys.MovedFromAttribute.
+func decodeRecord(record events.DynamoDBEventRecord) (Event, error) {
+	change := record.Change
+	owner, id, err := keys.ParseTask(keys.Key{
+		PK: stringAttribute(change.Keys, "PK"),
+		SK: stringAttribute(change.Keys, "SK"),
+	})
+	if err != nil {
+		return nil, nil
+	}
+
+	meta := Metadata{
+		ID:             record.EventID,
+		SequenceNumber: change.SequenceNumber,
+		Time:           change.ApproximateCreationDateTime.UTC(),
+		Owner:          owner,
+		TaskID:         id,
+	}
+
+	var previous, task *Task
+	if len(change.OldImage) > 0 {
+		if previous, err = decodeTask(change.OldImage); err != nil {
+			return nil, err
+		}
+	}
+	if len(change.NewImage) > 0 {
+		if task, err = decodeTask(change.NewImage); err != nil {
+			return nil, err
+		}
+	}
+
+	switch events.DynamoDBOperationType(record.EventName) {
+	case events.DynamoDBOperationTypeInsert:
+		if task == nil {
+			return nil, errMissingImage
+		}
+		if stringAttribute(change.NewImage, keys.MovedFromAttribute) != "" {
+			return nil, nil
+		}
+		return TaskCreated{Meta: meta, Task: *task}, nil
+	case events.DynamoDBOperationTypeModify:
+		if task == nil || previous == nil {
+			return nil, errMissingImage
+		}
+		if reflect.DeepEqual(task, previous) {
+			return nil, nil
+		}
+		if previous.Status != statusClosed && task.Status == statusClosed {
+			return TaskClosed{Meta: meta, Task: *task, Previous: *previous}, nil
+		}
+		return TaskUpdated{Meta: meta, Task: *task, Previous: *previous}, nil
+	case events.DynamoDBOperationTypeRemove:
+		if previous == nil {
+			return nil, errMissingImage
+		}
+		return TaskDeleted{Meta: meta, Task: *previous}, nil
+	}
+	return nil, fmt.Errorf("unknown stream event %q", record.EventName)
+}
+
+// stringAttribute returns a string attribute of an image, or "" if it is
+// missing or not a string
+func stringAttribute(image map[string]events.DynamoDBAttributeValue, name string) string {
+	value, ok := image[name]
+	if !ok || value.DataType() != events.DataTypeString {
+		return ""
+	}
+	return value.String()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler decodes the records of a table stream into task events and
+// publishes them to its sinks
+type Handler struct {
+	sinks []Sink
+}
+
+// NewHandler creates a handler that publishes each event to every sink
+func NewHandler(sinks ...Sink) *Handler {
+	return &Handler{sinks: sinks}
+}
+
+// Handle publishes the events of a batch of stream records in order. If a
+// sink fails, the batch is reported as failed from that record on, so Lambda
+// retries it and the records after it without reprocessing earlier ones.
+func (h *Handler) Handle(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	var response events.DynamoDBEventResponse
+
+	for _, record := range event.Records {
+		decoded, err := decodeRecord(record)
+		if err != nil {
+			// Retrying cannot decode the record, and would hold up the rest
+			// of the shard, so skip it
+			log.Printf("skipping stream record %s: %v", record.EventID, err)
+			continue
+		}
+		if decoded == nil {
+			continue
+		}
+
+		for _, sink := range h.sinks {
+			if err := sink.Publish(ctx, decoded); err != nil {
+				log.Printf("failed to publish %s event of stream record %s: %v", decoded.Type(), record.EventID, err)
+				response.BatchItemFailures = append(response.BatchItemFailures, events.DynamoDBBatchItemFailure{
+					ItemIdentifier: record.Change.SequenceNumber,
+				})
+				return response, nil
+			}
+		}
+	}
+
+	return response, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// fixtureTaskID is the ID of the task in the recorded stream events
+var fixtureTaskID = uuid.MustParse("3f0c7c1e-6a53-4c1b-9a55-8d2f0a4b7e21")
+
+// readEvent reads a recorded stream event from testdata
+func readEvent(t *testing.T, name string) events.DynamoDBEvent {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "events", name))
+	if err != nil {
+		t.Fatalf("Failed to read event %s: %v", name, err)
+	}
+	var event events.DynamoDBEvent
+	if err := json.Unmarshal(raw, &event); err != nil {
+		t.Fatalf("Failed to unmarshal event %s: %v", name, err)
+	}
+	return event
+}
+
+// recordingSink records the events it is published, failing on the failAt'th
+// event if failAt is set
+type recordingSink struct {
+	events []Event
+	failAt int
+}
+
+func (s *recordingSink) Publish(ctx context.Context, event Event) error {
+	if len(s.events)+1 == s.failAt {
+		return errors.New("sink unavailable")
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestHandle(t *testing.T) {
+	opened := Task{
+		ID:          fixtureTaskID,
+		Title:       "Fix login",
+		Status:      "OPEN",
+		Owner:       "test@example.com",
+		Description: "Users are logged out on refresh",
+		Priority:    "HIGH",
+		Labels:      []string{"bug", "login"},
+		Created:     "2026-10-18T09:00:00Z",
+	}
+	renamed := opened
+	renamed.Title = "Fix login on Safari"
+	closed := opened
+	closed.Status, closed.Closed = "CLOSED", "2026-10-18T11:00:00Z"
+
+	tests := []struct {
+		fixture string
+		want    []Event
+	}{
+		{"insert.json", []Event{TaskCreated{Task: opened}}},
+		{"update.json", []Event{TaskUpdated{Task: renamed, Previous: opened}}},
+		{"close.json", []Event{TaskClosed{Task: closed, Previous: opened}}},
+		{"reopen.json", []Event{TaskUpdated{Task: opened, Previous: closed}}},
+		{"remove.json", []Event{TaskDeleted{Task: closed}}},
+		{"bookkeeping.json", nil},
+		{"other-items.json", nil},
+		{"keys-only.json", nil},
+		{"move.json", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			// Arrange
+			event := readEvent(t, tt.fixture)
+			sink := &recordingSink{}
+			handler := NewHandler(sink)
+
+			// Act
+			response, err := handler.Handle(context.Background(), event)
+
+			// Assert
+			if err != nil || len(response.BatchItemFailures) != 0 {
+				t.Fatalf("Expected no failures, got %+v (%v)", response.BatchItemFailures, err)
+			}
+			if len(sink.events) != len(tt.want) {
+				t.Fatalf("Expected %d events, got %+v", len(tt.want), sink.events)
+			}
+			for i, got := range sink.events {
+				record := event.Records[i].Change
+				meta := got.Metadata()
+				if meta.ID != event.Records[i].EventID || meta.SequenceNumber != record.SequenceNumber ||
+					meta.Owner != "test@example.com" || meta.TaskID != fixtureTaskID ||
+					!meta.Time.Equal(record.ApproximateCreationDateTime.Time) {
+					t.Errorf("Expected the metadata of record %d, got %+v", i, meta)
+				}
+				if !reflect.DeepEqual(withoutMetadata(got), tt.want[i]) {
+					t.Errorf("Expected %+v, got %+v", tt.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+// withoutMetadata returns an event with its metadata cleared, for comparing
+// with expected events
+func withoutMetadata(event Event) Event {
+	switch e := event.(type) {
+	case TaskCreated:
+		e.Meta = Metadata{}
+		return e
+	case TaskUpdated:
+		e.Meta = Metadata{}
+		return e
+	case TaskClosed:
+		e.Meta = Metadata{}
+		return e
+	case TaskDeleted:
+		e.Meta = Metadata{}
+		return e
+	}
+	return event
+}
+
+func TestHandleSinkFailure(t *testing.T) {
+	// Arrange: a batch that creates, closes and deletes the task, with a sink
+	// that fails on the close
+	var batch events.DynamoDBEvent
+	for _, fixture := range []string{"insert.json", "bookkeeping.json", "close.json", "remove.json"} {
+		batch.Records = append(batch.Records, readEvent(t, fixture).Records...)
+	}
+	failing := &recordingSink{failAt: 2}
+	working := &recordingSink{}
+	handler := NewHandler(working, failing)
+
+	// Act
+	response, err := handler.Handle(context.Background(), batch)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []events.DynamoDBBatchItemFailure{{ItemIdentifier: batch.Records[2].Change.SequenceNumber}}
+	if !reflect.DeepEqual(response.BatchItemFailures, want) {
+		t.Errorf("Expected the close to be reported as failed, got %+v", response.BatchItemFailures)
+	}
+	if len(failing.events) != 1 || failing.events[0].Type() != EventTaskCreated {
+		t.Errorf("Expected only the creation to be published, got %+v", failing.events)
+	}
+	if len(working.events) != 2 {
+		t.Errorf("Expected sinks before the failing one to receive the close, got %+v", working.events)
+	}
+}
+
+func TestHandleSkipsUndecodableRecords(t *testing.T) {
+	// Arrange: a modification recorded without its old image, as on a stream
+	// that only records new images
+	batch := readEvent(t, "close.json")
+	batch.Records[0].Change.OldImage = nil
+	batch.Records = append(batch.Records, readEvent(t, "remove.json").Records...)
+	sink := &recordingSink{}
+
+	// Act
+	response, err := NewHandler(sink).Handle(context.Background(), batch)
+
+	// Assert
+	if err != nil || len(response.BatchItemFailures) != 0 {
+		t.Fatalf("Expected no failures, got %+v (%v)", response.BatchItemFailures, err)
+	}
+	if len(sink.events) != 1 || sink.events[0].Type() != EventTaskDeleted {
+		t.Errorf("Expected only the deletion, got %+v", sink.events)
+	}
+}
+
+func TestLogSink(t *testing.T) {
+	// Arrange
+	var out bytes.Buffer
+	sink := NewLogSink(&out)
+	meta := Metadata{ID: "evt-1", Time: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC), TaskID: fixtureTaskID}
+	task := Task{ID: fixtureTaskID, Title: "Fix login", Status: "CLOSED", Owner: "test@example.com"}
+
+	// Act
+	_ = sink.Publish(context.Background(), TaskCreated{Meta: meta, Task: task})
+	_ = sink.Publish(context.Background(), TaskDeleted{Meta: meta, Task: task})
+
+	// Assert
+	lines := bytes.Split(bytes.TrimSuffix(out.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", out.String())
+	}
+	var got struct {
+		Type  EventType   `json:"type"`
+		Event TaskDeleted `json:"event"`
+	}
+	if err := json.Unmarshal(lines[1], &got); err != nil {
+		t.Fatalf("Failed to unmarshal %s: %v", lines[1], err)
+	}
+	if got.Type != EventTaskDeleted || got.Event.Meta.ID != "evt-1" || got.Event.Task.Title != "Fix login" {
+		t.Errorf("Expected the deletion, got %+v", got)
+	}
+}
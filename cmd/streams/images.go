@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+// Task is a task as it was before or after a change
+type Task struct {
+	ID     uuid.UUID `json:"id"`
+	Title  string    `json:"title"`
+	Status string    `json:"status"`
+	Owner  string    `json:"owner"`
+	// Description, Due, Recurrence, UID, Priority and Labels are empty on
+	// tasks without them
+	Description string   `json:"description,omitempty"`
+	Due         string   `json:"due,omitempty"`
+	Recurrence  string   `json:"recurrence,omitempty"`
+	UID         string   `json:"uid,omitempty"`
+	Priority    string   `json:"priority,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	// Created and Closed are RFC 3339 times; they are empty on tasks written
+	// before they were kept, and Closed is empty on open tasks
+	Created string `json:"created,omitempty"`
+	Closed  string `json:"closed,omitempty"`
+}
+
+// taskImage is a task item as the API stores it. The attribute names must
+// match the API's DynamoDBTask; key, index and bookkeeping attributes are
+// left out, so changes to only those do not change the task.
+type taskImage struct {
+	ID          string
+	Title       string
+	Owner       string
+	Status      string
+	Description string
+	Due         string
+	Recurrence  string
+	UID         string
+	Priority    string
+	Labels      []string
+	Created     string
+	Closed      string
+}
+
+// decodeTask decodes a task from a stream image
+func decodeTask(image map[string]events.DynamoDBAttributeValue) (*Task, error) {
+	var stored taskImage
+	if err := attributevalue.UnmarshalMap(attributeValues(image), &stored); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task image: %w", err)
+	}
+
+	id, err := uuid.Parse(stored.ID)
+	if err != nil {
+		return nil, fmt.Errorf("task image has an invalid ID %q: %w", stored.ID, err)
+	}
+
+	return &Task{
+		ID:          id,
+		Title:       stored.Title,
+		Status:      stored.Status,
+		Owner:       stored.Owner,
+		Description: stored.Description,
+		Due:         stored.Due,
+		Recurrence:  stored.Recurrence,
+		UID:         stored.UID,
+		Priority:    stored.Priority,
+		Labels:      stored.Labels,
+		Created:     stored.Created,
+		Closed:      stored.Closed,
+	}, nil
+}
+
+// attributeValues converts a stream image to the attribute values the SDK
+// reads and writes
+func attributeValues(image map[string]events.DynamoDBAttributeValue) map[string]types.AttributeValue {
+	values := make(map[string]types.AttributeValue, len(image))
+	for name, value := range image {
+		values[name] = attributeValue(value)
+	}
+	return values
+}
+
+// attributeValue converts a stream attribute value to the SDK's
+func attributeValue(value events.DynamoDBAttributeValue) types.AttributeValue {
+	switch value.DataType() {
+	case events.DataTypeString:
+		return &types.AttributeValueMemberS{Value: value.String()}
+	case events.DataTypeNumber:
+		return &types.AttributeValueMemberN{Value: value.Number()}
+	case events.DataTypeBoolean:
+		return &types.AttributeValueMemberBOOL{Value: value.Boolean()}
+	case events.DataTypeBinary:
+		return &types.AttributeValueMemberB{Value: value.Binary()}
+	case events.DataTypeStringSet:
+		return &types.AttributeValueMemberSS{Value: value.StringSet()}
+	case events.DataTypeNumberSet:
+		return &types.AttributeValueMemberNS{Value: value.NumberSet()}
+	case events.DataTypeBinarySet:
+		return &types.AttributeValueMemberBS{Value: value.BinarySet()}
+	case events.DataTypeList:
+		list := make([]types.AttributeValue, 0, len(value.List()))
+		for _, element := range value.List() {
+			list = append(list, attributeValue(element))
+		}
+		return &types.AttributeValueMemberL{Value: list}
+	case events.DataTypeMap:
+		return &types.AttributeValueMemberM{Value: attributeValues(value.Map())}
+	}
+	return &types.AttributeValueMemberNULL{Value: true}
+}
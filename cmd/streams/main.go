@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/user/tasks-api/internal/env"
+	"github.com/user/tasks-api/internal/webhooks"
+)
+
+// getSinks creates the sinks named in STREAM_SINKS, a comma-separated list
+// that defaults to log
+func getSinks(ctx context.Context) []Sink {
+	names := os.Getenv("STREAM_SINKS")
+	if names == "" {
+		names = "log"
+	}
+
+	var sinks []Sink
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "log":
+			sinks = append(sinks, NewLogSink(os.Stdout))
+		case "webhooks":
+			cfg, err := config.LoadDefaultConfig(ctx)
+			if err != nil {
+				log.Fatalf("failed to load AWS config: %v", err)
+			}
+			table := webhooks.NewTable(dynamodb.NewFromConfig(cfg), env.TableName())
+			sinks = append(sinks, NewWebhookSink(webhooks.NewDispatcher(table)))
+		default:
+			log.Fatalf("unknown stream sink %q", name)
+		}
+	}
+	return sinks
+}
+
+func main() {
+	lambda.Start(NewHandler(getSinks(context.Background())...).Handle)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Sink receives task events. Events are published in stream order, each
+// once it has been decoded, and may be published again if the batch it is
+// in is retried.
+type Sink interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// LogSink writes each event as a line of JSON
+type LogSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLogSink creates a sink that writes events to w
+func NewLogSink(w io.Writer) *LogSink {
+	return &LogSink{w: w}
+}
+
+// Publish writes an event as a line of JSON
+func (s *LogSink) Publish(ctx context.Context, event Event) error {
+	line, err := MarshalEvent(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", event.Type(), err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s event: %w", event.Type(), err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/user/tasks-api/internal/webhooks"
+)
+
+// WebhookSink queues events for delivery to the webhooks their owners
+// subscribe
+type WebhookSink struct {
+	dispatcher *webhooks.Dispatcher
+}
+
+// NewWebhookSink creates a sink that queues events with dispatcher
+func NewWebhookSink(dispatcher *webhooks.Dispatcher) *WebhookSink {
+	return &WebhookSink{dispatcher: dispatcher}
+}
+
+// Publish queues an event, in the same JSON as the log sink writes, for
+// delivery to each of its owner's webhooks subscribed to its type. Nothing
+// is sent here; the Deliveries function makes the attempts, so only failing
+// to list webhooks or record deliveries fails the event.
+func (s *WebhookSink) Publish(ctx context.Context, event Event) error {
+	payload, err := MarshalEvent(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", event.Type(), err)
+	}
+
+	meta := event.Metadata()
+	return s.dispatcher.Dispatch(ctx, webhooks.Event{
+		ID:      meta.ID,
+		Type:    string(event.Type()),
+		Owner:   meta.Owner,
+		Payload: payload,
+	})
+}
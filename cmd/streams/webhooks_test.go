@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/webhooks"
+)
+
+// webhookStore is a webhooks.Store of one owner's subscriptions
+type webhookStore struct {
+	subscriptions []webhooks.Subscription
+	deliveries    []webhooks.Delivery
+}
+
+func (s *webhookStore) ListWebhooks(ctx context.Context, owner string) ([]webhooks.Subscription, error) {
+	return s.subscriptions, nil
+}
+
+func (s *webhookStore) GetWebhook(ctx context.Context, owner string, id uuid.UUID) (*webhooks.Subscription, error) {
+	return &s.subscriptions[0], nil
+}
+
+func (s *webhookStore) AddDelivery(ctx context.Context, delivery webhooks.Delivery) error {
+	s.deliveries = append(s.deliveries, delivery)
+	return nil
+}
+
+func (s *webhookStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]webhooks.Delivery, error) {
+	return s.deliveries, nil
+}
+
+func (s *webhookStore) UpdateDelivery(ctx context.Context, delivery, previous webhooks.Delivery) error {
+	s.deliveries[0] = delivery
+	return nil
+}
+
+func TestWebhookSink(t *testing.T) {
+	// Arrange: a local receiver subscribed to closed tasks
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{r.Header, body}
+	}))
+	defer server.Close()
+
+	store := &webhookStore{subscriptions: []webhooks.Subscription{{
+		ID:     uuid.New(),
+		Owner:  "test@example.com",
+		URL:    server.URL,
+		Events: []string{webhooks.EventTaskClosed},
+		Secret: "whsec_test",
+	}}}
+	handler := NewHandler(NewWebhookSink(webhooks.NewDispatcher(store)))
+	// The receiver is on a loopback address, which the default transport
+	// refuses to connect to
+	deliverer := webhooks.NewDeliverer(store)
+	deliverer.Client.Transport = http.DefaultTransport
+	batch := readEvent(t, "insert.json")
+	batch.Records = append(batch.Records, readEvent(t, "close.json").Records...)
+
+	// Act: handle the batch, then deliver what it queued
+	response, err := handler.Handle(context.Background(), batch)
+	queued := len(requests)
+	deliverErr := deliverer.DeliverDue(context.Background())
+
+	// Assert
+	if err != nil || len(response.BatchItemFailures) != 0 {
+		t.Fatalf("Expected no failures, got %+v (%v)", response.BatchItemFailures, err)
+	}
+	if queued != 0 {
+		t.Errorf("Expected the handler to only queue deliveries, got %d requests", queued)
+	}
+	if deliverErr != nil {
+		t.Fatalf("Expected no delivery error, got %v", deliverErr)
+	}
+	var request received
+	select {
+	case request = <-requests:
+	default:
+		t.Fatal("Expected the close to be delivered")
+	}
+	if err := webhooks.Verify("whsec_test", request.header.Get(webhooks.SignatureHeader), request.body, time.Now(), time.Minute); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+	var got struct {
+		Type  EventType  `json:"type"`
+		Event TaskClosed `json:"event"`
+	}
+	if err := json.Unmarshal(request.body, &got); err != nil {
+		t.Fatalf("Failed to unmarshal %s: %v", request.body, err)
+	}
+	if got.Type != EventTaskClosed || got.Event.Task.Status != "CLOSED" || got.Event.Previous.Status != "OPEN" ||
+		request.header.Get(webhooks.EventIDHeader) != batch.Records[1].EventID {
+		t.Errorf("Expected the close of record %s, got %s", batch.Records[1].EventID, request.body)
+	}
+	if len(store.deliveries) != 1 || store.deliveries[0].Status != webhooks.StatusDelivered {
+		t.Errorf("Expected one recorded delivery, got %+v", store.deliveries)
+	}
+}
@@ -0,0 +1,20 @@
+// Package env reads the settings that every command shares from the
+// environment, so that the API, the stream consumer, the relay, the
+// delivery function and the migration tool all use the same table.
+package env
+
+import (
+	"fmt"
+	"os"
+)
+
+// TableName returns the name of the tasks table of the APP_ENVIRONMENT
+// stage, which defaults to development
+func TableName() string {
+	stage := os.Getenv("APP_ENVIRONMENT")
+	if stage == "" {
+		stage = "development"
+	}
+
+	return fmt.Sprintf("%s-tasks-api", stage)
+}
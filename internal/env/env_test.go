@@ -0,0 +1,28 @@
+package env
+
+import "testing"
+
+func TestTableName(t *testing.T) {
+	tests := []struct {
+		stage string
+		want  string
+	}{
+		{"", "development-tasks-api"},
+		{"prod", "prod-tasks-api"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			// Arrange
+			t.Setenv("APP_ENVIRONMENT", tt.stage)
+
+			// Act
+			name := TableName()
+
+			// Assert
+			if name != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+// Package keys builds and parses the DynamoDB keys of the items in the
+// tasks table. Every key segment starts with an entity prefix, so items of
+// different types can share the table without colliding.
+//
+// Task items are stored as:
+//
+//	PK    USER#<owner>
+//	SK    TASK#<id>
+//	GS1PK USER#<owner>#STATUS#<status>
+//	GS1SK #<RFC3339 time of the last change>
+//
+// Calendar feed tokens are stored as two items, one recording a user's
+// current token and one looking the token up by its hash:
+//
+//	PK USER#<owner>          SK FEEDTOKEN
+//	PK FEEDTOKEN#<hash>      SK FEEDTOKEN#<hash>
+//
+// The search index has an item per term of each task, and an item per task
+// recording its terms, in the owner's partition:
+//
+//	PK USER#<owner>          SK TERM#<term>#<id>
+//	PK USER#<owner>          SK SEARCHDOC#<id>
+//
+// Terms only contain letters and digits, so they cannot contain "#".
+//
+// Saved views have an item each, and an item per task seen through a view:
+//
+//	PK USER#<owner>          SK VIEW#<id>
+//	PK USER#<owner>          SK VIEWSEEN#<view id>#<task id>
+//
+// Each owner has an item of counters summarizing their tasks:
+//
+//	PK USER#<owner>          SK STATS
+//
+// Webhook subscriptions have an item each, and an item per delivery to a
+// subscription, whose sort key orders a subscription's deliveries by time.
+// Deliveries still to be attempted are listed in GS1 by when they are next
+// attempted, in one of PendingShards partitions chosen by their owner:
+//
+//	PK USER#<owner>          SK WEBHOOK#<id>
+//	PK USER#<owner>          SK DELIVERY#<webhook id>#<time>#<id>
+//	GS1PK DELIVERY#PENDING#<shard>   GS1SK <next attempt time>#<id>
+//
+// The outbox of each owner's task events has an item per write, numbered in
+// the order the writes committed, and a head item recording the last number
+// written and delivered. Heads with undelivered entries are listed in GS1,
+// in one of PendingShards partitions chosen by their owner:
+//
+//	PK USER#<owner>          SK OUTBOX#<sequence>
+//	PK USER#<owner>          SK OUTBOX
+//	GS1PK OUTBOX#PENDING#<shard>     GS1SK USER#<owner>
+//
+// Owners are escaped, so an owner containing "#" cannot produce the key of
+// another owner or entity.
+package keys
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+const (
+	// UserPrefix starts the partition key of every item owned by a user
+	UserPrefix = "USER#"
+	// TaskPrefix starts the sort key of a task item
+	TaskPrefix = "TASK#"
+	// FeedTokenPrefix starts both keys of a feed token lookup item
+	FeedTokenPrefix = "FEEDTOKEN#"
+	// SearchTermPrefix starts the sort key of a search term item
+	SearchTermPrefix = "TERM#"
+	// SearchDocPrefix starts the sort key of a search document item
+	SearchDocPrefix = "SEARCHDOC#"
+	// ViewPrefix starts the sort key of a saved view item
+	ViewPrefix = "VIEW#"
+	// ViewSeenPrefix starts the sort key of an item recording a task seen
+	// through a view
+	ViewSeenPrefix = "VIEWSEEN#"
+	// WebhookPrefix starts the sort key of a webhook subscription item
+	WebhookPrefix = "WEBHOOK#"
+	// DeliveryPrefix starts the sort key of a webhook delivery item
+	DeliveryPrefix = "DELIVERY#"
+	// OutboxPrefix starts the sort key of an outbox entry item
+	OutboxPrefix = "OUTBOX#"
+	// DeliveryPendingPrefix starts the GS1 partition keys listing webhook
+	// deliveries still to be attempted
+	DeliveryPendingPrefix = "DELIVERY#PENDING#"
+	// OutboxPendingPrefix starts the GS1 partition keys listing the outbox
+	// heads of owners with undelivered entries
+	OutboxPendingPrefix = "OUTBOX#PENDING#"
+	// userFeedTokenSK is the sort key of the item recording a user's feed token
+	userFeedTokenSK = "FEEDTOKEN"
+	// statsSK is the sort key of a user's counters item
+	statsSK = "STATS"
+	// outboxHeadSK is the sort key of a user's outbox head item
+	outboxHeadSK = "OUTBOX"
+	// outboxSequenceFormat is the fixed-width sequence in outbox entry sort keys
+	outboxSequenceFormat = "%020d"
+	// statusSegment separates the owner from the status in GS1PK
+	statusSegment = "#STATUS#"
+	// deliveryTimeFormat is the fixed-width time in delivery sort keys
+	deliveryTimeFormat = "2006-01-02T15:04:05.000000000Z"
+)
+
+// PendingShards is the number of GS1 partitions that items listed for
+// every user are spread over
+const PendingShards = 16
+
+// EntityType is the value of the entity_type attribute, which identifies the
+// kind of an item without parsing its keys
+type EntityType string
+
+const (
+	// EntityTask is the entity type of task items
+	EntityTask EntityType = "TASK"
+	// EntityFeedToken is the entity type of calendar feed token items
+	EntityFeedToken EntityType = "FEED_TOKEN"
+	// EntitySearchTerm is the entity type of search term items
+	EntitySearchTerm EntityType = "SEARCH_TERM"
+	// EntitySearchDoc is the entity type of search document items
+	EntitySearchDoc EntityType = "SEARCH_DOC"
+	// EntityView is the entity type of saved view items
+	EntityView EntityType = "VIEW"
+	// EntityViewSeen is the entity type of items recording a task seen
+	// through a view
+	EntityViewSeen EntityType = "VIEW_SEEN"
+	// EntityStats is the entity type of the items counting a user's tasks
+	EntityStats EntityType = "STATS"
+	// EntityWebhook is the entity type of webhook subscription items
+	EntityWebhook EntityType = "WEBHOOK"
+	// EntityDelivery is the entity type of webhook delivery items
+	EntityDelivery EntityType = "WEBHOOK_DELIVERY"
+	// EntityOutboxEntry is the entity type of outbox entry items
+	EntityOutboxEntry EntityType = "OUTBOX_ENTRY"
+	// EntityOutboxHead is the entity type of the items recording the
+	// progress of a user's outbox
+	EntityOutboxHead EntityType = "OUTBOX_HEAD"
+)
+
+// MovedFromAttribute is the attribute of a task item moved from its legacy
+// key holding the partition key it was moved from, so that the move can be
+// told apart from a new task. Writing the task again removes it.
+const MovedFromAttribute = "MovedFrom"
+
+// Key is the primary key of an item
+type Key struct {
+	PK string
+	SK string
+}
+
+// Item returns the key as DynamoDB attribute values
+func (k Key) Item() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: k.PK},
+		"SK": &types.AttributeValueMemberS{Value: k.SK},
+	}
+}
+
+// FromItem returns the key of an item, or an empty key if it has none
+func FromItem(item map[string]types.AttributeValue) Key {
+	pk, _ := item["PK"].(*types.AttributeValueMemberS)
+	sk, _ := item["SK"].(*types.AttributeValueMemberS)
+	if pk == nil || sk == nil {
+		return Key{}
+	}
+	return Key{PK: pk.Value, SK: sk.Value}
+}
+
+// Task returns the key of a task item
+func Task(owner string, id uuid.UUID) Key {
+	return Key{PK: User(owner), SK: TaskPrefix + id.String()}
+}
+
+// User returns the partition key of the items owned by a user
+func User(owner string) string {
+	return UserPrefix + escape(owner)
+}
+
+// TaskStatus returns the GS1 partition key listing a user's tasks with a status
+func TaskStatus(owner, status string) string {
+	return User(owner) + statusSegment + status
+}
+
+// UserFeedToken returns the key of the item recording a user's feed token
+func UserFeedToken(owner string) Key {
+	return Key{PK: User(owner), SK: userFeedTokenSK}
+}
+
+// FeedToken returns the key of the item that maps a feed token hash to its owner
+func FeedToken(hash string) Key {
+	return Key{PK: FeedTokenPrefix + hash, SK: FeedTokenPrefix + hash}
+}
+
+// Stats returns the key of the item counting a user's tasks
+func Stats(owner string) Key {
+	return Key{PK: User(owner), SK: statsSK}
+}
+
+// Webhook returns the key of a webhook subscription item
+func Webhook(owner string, id uuid.UUID) Key {
+	return Key{PK: User(owner), SK: WebhookPrefix + id.String()}
+}
+
+// Delivery returns the key of the item recording a delivery to a webhook.
+// The time is fixed-width, so a webhook's deliveries sort by time.
+func Delivery(owner string, webhookID uuid.UUID, at time.Time, id uuid.UUID) Key {
+	return Key{PK: User(owner), SK: DeliverySK(webhookID) + at.UTC().Format(deliveryTimeFormat) + "#" + id.String()}
+}
+
+// DeliverySK returns the sort key prefix of the items recording the
+// deliveries to a webhook
+func DeliverySK(webhookID uuid.UUID) string {
+	return DeliveryPrefix + webhookID.String() + "#"
+}
+
+// PendingDeliveries returns the GS1 partition key listing a user's pending
+// deliveries, which is shared with the users in the same shard
+func PendingDeliveries(owner string) string {
+	return PendingDeliveriesShard(Shard(owner))
+}
+
+// PendingDeliveriesShard returns the GS1 partition key listing the pending
+// deliveries of the users in a shard
+func PendingDeliveriesShard(shard int) string {
+	return DeliveryPendingPrefix + strconv.Itoa(shard)
+}
+
+// PendingDeliverySK returns the GS1 sort key of a pending delivery, which
+// orders a shard's pending deliveries by when they are next attempted
+func PendingDeliverySK(next time.Time, id uuid.UUID) string {
+	return next.UTC().Format(deliveryTimeFormat) + "#" + id.String()
+}
+
+// DeliveriesDueSK returns a GS1 sort key after those of every pending
+// delivery next attempted at or before t, and before those of the rest
+func DeliveriesDueSK(t time.Time) string {
+	return t.UTC().Format(deliveryTimeFormat) + "$"
+}
+
+// OutboxPending returns the GS1 partition key listing a user's outbox head
+// while it has undelivered entries, which is shared with the users in the
+// same shard
+func OutboxPending(owner string) string {
+	return OutboxPendingShard(Shard(owner))
+}
+
+// OutboxPendingShard returns the GS1 partition key listing the outbox heads
+// with undelivered entries of the users in a shard
+func OutboxPendingShard(shard int) string {
+	return OutboxPendingPrefix + strconv.Itoa(shard)
+}
+
+// Shard returns the shard of a user's items in the GS1 partitions that
+// list items of every user, from 0 to PendingShards-1, so that writes to
+// them are spread over several partitions
+func Shard(owner string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(owner))
+	return int(h.Sum32() % PendingShards)
+}
+
+// OutboxHead returns the key of the item recording the last entries
+// written to and delivered from a user's outbox
+func OutboxHead(owner string) Key {
+	return Key{PK: User(owner), SK: outboxHeadSK}
+}
+
+// OutboxEntry returns the key of a user's outbox entry. The sequence is
+// fixed-width, so a user's entries sort in the order they were written.
+func OutboxEntry(owner string, sequence int64) Key {
+	return Key{PK: User(owner), SK: OutboxPrefix + fmt.Sprintf(outboxSequenceFormat, sequence)}
+}
+
+// SearchTerm returns the key of the item indexing a task under a term
+func SearchTerm(owner, term string, id uuid.UUID) Key {
+	return Key{PK: User(owner), SK: SearchTermSK(term) + id.String()}
+}
+
+// SearchTermSK returns the sort key prefix of the items indexing tasks
+// under exactly term
+func SearchTermSK(term string) string {
+	return SearchTermPrefix + term + "#"
+}
+
+// SearchDoc returns the key of the item recording a task's search terms
+func SearchDoc(owner string, id uuid.UUID) Key {
+	return Key{PK: User(owner), SK: SearchDocPrefix + id.String()}
+}
+
+// ParseSearchTerm returns the term and task ID from a search term sort key
+func ParseSearchTerm(sk string) (string, uuid.UUID, error) {
+	rest, ok := strings.CutPrefix(sk, SearchTermPrefix)
+	if !ok {
+		return "", uuid.Nil, fmt.Errorf("sort key %q is not a search term key", sk)
+	}
+	term, rawID, ok := strings.Cut(rest, "#")
+	if !ok || term == "" {
+		return "", uuid.Nil, fmt.Errorf("sort key %q has no term", sk)
+	}
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("sort key %q has an invalid task ID: %w", sk, err)
+	}
+	return term, id, nil
+}
+
+// View returns the key of a saved view item
+func View(owner string, id uuid.UUID) Key {
+	return Key{PK: User(owner), SK: ViewPrefix + id.String()}
+}
+
+// ViewSeen returns the key of the item recording that a task was seen
+// through a view
+func ViewSeen(owner string, viewID, taskID uuid.UUID) Key {
+	return Key{PK: User(owner), SK: ViewSeenSK(viewID) + taskID.String()}
+}
+
+// ViewSeenSK returns the sort key prefix of the items recording the tasks
+// seen through a view
+func ViewSeenSK(viewID uuid.UUID) string {
+	return ViewSeenPrefix + viewID.String() + "#"
+}
+
+// ParseViewSeen returns the view and task IDs from the sort key of an item
+// recording a task seen through a view
+func ParseViewSeen(sk string) (uuid.UUID, uuid.UUID, error) {
+	rest, ok := strings.CutPrefix(sk, ViewSeenPrefix)
+	if !ok {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("sort key %q is not a view seen key", sk)
+	}
+	rawView, rawTask, _ := strings.Cut(rest, "#")
+	viewID, err := uuid.Parse(rawView)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("sort key %q has an invalid view ID: %w", sk, err)
+	}
+	taskID, err := uuid.Parse(rawTask)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("sort key %q has an invalid task ID: %w", sk, err)
+	}
+	return viewID, taskID, nil
+}
+
+// Changed returns the GS1 sort key of an item last changed at t. It keeps the
+// leading "#" of legacy keys, so legacy and current items sort together.
+func Changed(t time.Time) string {
+	return "#" + t.UTC().Format(time.RFC3339)
+}
+
+// ParseTask returns the owner and ID of a task from its key
+func ParseTask(key Key) (string, uuid.UUID, error) {
+	owner, err := ParseUser(key.PK)
+	if err != nil {
+		return "", uuid.Nil, err
+	}
+	rest, ok := strings.CutPrefix(key.SK, TaskPrefix)
+	if !ok {
+		return "", uuid.Nil, fmt.Errorf("sort key %q is not a task key", key.SK)
+	}
+	id, err := uuid.Parse(rest)
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("sort key %q has an invalid task ID: %w", key.SK, err)
+	}
+	return owner, id, nil
+}
+
+// ParseUser returns the owner from a user partition key
+func ParseUser(pk string) (string, error) {
+	rest, ok := strings.CutPrefix(pk, UserPrefix)
+	if !ok {
+		return "", fmt.Errorf("partition key %q is not a user key", pk)
+	}
+	return unescape(rest)
+}
+
+// ParseTaskStatus returns the owner and status from a GS1 partition key
+func ParseTaskStatus(gs1pk string) (string, string, error) {
+	rest, ok := strings.CutPrefix(gs1pk, UserPrefix)
+	if !ok {
+		return "", "", fmt.Errorf("GS1 partition key %q is not a user key", gs1pk)
+	}
+	// Escaped owners contain no "#", so the first separator ends the owner
+	escaped, status, ok := strings.Cut(rest, statusSegment)
+	if !ok {
+		return "", "", fmt.Errorf("GS1 partition key %q has no status", gs1pk)
+	}
+	owner, err := unescape(escaped)
+	if err != nil {
+		return "", "", err
+	}
+	return owner, status, nil
+}
+
+// LegacyTask returns the key a task had before entity prefixes were introduced
+func LegacyTask(owner string, id uuid.UUID) Key {
+	return Key{PK: LegacyUser(owner), SK: "#" + id.String()}
+}
+
+// LegacyUser returns the partition key a user's tasks had before entity
+// prefixes were introduced. Legacy partitions hold only tasks.
+func LegacyUser(owner string) string {
+	return "#" + owner
+}
+
+// LegacyTaskStatus returns the GS1 partition key a task had before entity
+// prefixes were introduced
+func LegacyTaskStatus(owner, status string) string {
+	return LegacyUser(owner) + "#" + status
+}
+
+// IsLegacy reports whether a partition key predates entity prefixes
+func IsLegacy(pk string) bool {
+	return strings.HasPrefix(pk, "#")
+}
+
+// escape percent-encodes "%" and "#" so that a value never contains the
+// key separator
+func escape(value string) string {
+	return strings.NewReplacer("%", "%25", "#", "%23").Replace(value)
+}
+
+// unescape reverses escape
+func unescape(value string) (string, error) {
+	unescaped, err := url.PathUnescape(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid escaped key value %q: %w", value, err)
+	}
+	return unescaped, nil
+}
@@ -0,0 +1,274 @@
+package keys
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var testID = uuid.MustParse("0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90")
+
+func TestTask(t *testing.T) {
+	tests := []struct {
+		name  string
+		owner string
+		pk    string
+	}{
+		{"plain", "test@example.com", "USER#test@example.com"},
+		{"separator", "a#STATUS#OPEN", "USER#a%23STATUS%23OPEN"},
+		{"escape character", "100%#", "USER#100%25%23"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			key := Task(tt.owner, testID)
+			owner, id, err := ParseTask(key)
+
+			// Assert
+			if key.PK != tt.pk {
+				t.Errorf("Expected PK %q, got %q", tt.pk, key.PK)
+			}
+			if key.SK != "TASK#"+testID.String() {
+				t.Errorf("Expected SK TASK#%s, got %q", testID, key.SK)
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if owner != tt.owner || id != testID {
+				t.Errorf("Expected %q and %s, got %q and %s", tt.owner, testID, owner, id)
+			}
+		})
+	}
+}
+
+func TestTaskStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		owner string
+	}{
+		{"plain", "test@example.com"},
+		{"separator", "a#STATUS#OPEN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			owner, status, err := ParseTaskStatus(TaskStatus(tt.owner, "CLOSED"))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if owner != tt.owner || status != "CLOSED" {
+				t.Errorf("Expected %q and CLOSED, got %q and %q", tt.owner, owner, status)
+			}
+		})
+	}
+}
+
+func TestOwnersDoNotCollide(t *testing.T) {
+	// Arrange: with unescaped owners, both would list under "#a#OPEN#CLOSED"
+	first := TaskStatus("a#OPEN", "CLOSED")
+	second := TaskStatus("a", "OPEN#CLOSED")
+
+	// Assert
+	if first == second {
+		t.Errorf("Expected different keys, both are %q", first)
+	}
+}
+
+func TestParseInvalidKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		key  Key
+	}{
+		{"legacy", LegacyTask("test@example.com", testID)},
+		{"other entity", Key{PK: "USER#test@example.com", SK: "COMMENT#1"}},
+		{"invalid ID", Key{PK: "USER#test@example.com", SK: "TASK#1"}},
+		{"invalid escape", Key{PK: "USER#100%", SK: "TASK#" + testID.String()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, _, err := ParseTask(tt.key)
+
+			// Assert
+			if err == nil {
+				t.Errorf("Expected an error parsing %+v", tt.key)
+			}
+		})
+	}
+}
+
+func TestChanged(t *testing.T) {
+	// Act
+	sk := Changed(time.Date(2024, 1, 1, 1, 0, 0, 0, time.FixedZone("CET", 3600)))
+
+	// Assert
+	if sk != "#2024-01-01T00:00:00Z" {
+		t.Errorf("Expected #2024-01-01T00:00:00Z, got %q", sk)
+	}
+}
+
+func TestFeedTokenKeys(t *testing.T) {
+	// Act
+	user := UserFeedToken("a#b")
+	lookup := FeedToken("abc123")
+
+	// Assert
+	if user.PK != "USER#a%23b" || user.SK != "FEEDTOKEN" {
+		t.Errorf("Expected USER#a%%23b and FEEDTOKEN, got %+v", user)
+	}
+	if lookup.PK != "FEEDTOKEN#abc123" || lookup.SK != "FEEDTOKEN#abc123" {
+		t.Errorf("Expected FEEDTOKEN#abc123 for both keys, got %+v", lookup)
+	}
+	// A user's feed token item must not parse as a task
+	if _, _, err := ParseTask(user); err == nil {
+		t.Error("Expected an error parsing a feed token key as a task")
+	}
+}
+
+func TestSearchKeys(t *testing.T) {
+	// Act
+	term := SearchTerm("a#b", "run", testID)
+	parsedTerm, parsedID, err := ParseSearchTerm(term.SK)
+	doc := SearchDoc("a#b", testID)
+
+	// Assert
+	if term.PK != "USER#a%23b" || term.SK != "TERM#run#"+testID.String() {
+		t.Errorf("Expected USER#a%%23b and TERM#run#%s, got %+v", testID, term)
+	}
+	if err != nil || parsedTerm != "run" || parsedID != testID {
+		t.Errorf("Expected run and %s, got %q, %s and %v", testID, parsedTerm, parsedID, err)
+	}
+	if doc.PK != term.PK || doc.SK != "SEARCHDOC#"+testID.String() {
+		t.Errorf("Expected the document in the same partition, got %+v", doc)
+	}
+	for _, sk := range []string{"TASK#" + testID.String(), "TERM#run", "TERM##" + testID.String(), "TERM#run#not-a-uuid"} {
+		if _, _, err := ParseSearchTerm(sk); err == nil {
+			t.Errorf("Expected an error parsing %q", sk)
+		}
+	}
+}
+
+func TestViewKeys(t *testing.T) {
+	// Arrange
+	viewID := uuid.MustParse("5c1a7f2e-8d4b-4c3a-9e6f-1b2d3c4e5f60")
+
+	// Act
+	view := View("a#b", viewID)
+	seen := ViewSeen("a#b", viewID, testID)
+	parsedView, parsedTask, err := ParseViewSeen(seen.SK)
+
+	// Assert
+	if view.PK != "USER#a%23b" || view.SK != "VIEW#"+viewID.String() {
+		t.Errorf("Expected USER#a%%23b and VIEW#%s, got %+v", viewID, view)
+	}
+	if seen.PK != view.PK || !strings.HasPrefix(seen.SK, ViewSeenSK(viewID)) || strings.HasPrefix(seen.SK, ViewPrefix) {
+		t.Errorf("Expected the seen item in the same partition under its own prefix, got %+v", seen)
+	}
+	if err != nil || parsedView != viewID || parsedTask != testID {
+		t.Errorf("Expected %s and %s, got %s, %s and %v", viewID, testID, parsedView, parsedTask, err)
+	}
+	for _, sk := range []string{"VIEW#" + viewID.String(), "VIEWSEEN#" + viewID.String(), "VIEWSEEN#" + viewID.String() + "#x"} {
+		if _, _, err := ParseViewSeen(sk); err == nil {
+			t.Errorf("Expected an error parsing %q", sk)
+		}
+	}
+}
+
+func TestWebhookKeys(t *testing.T) {
+	// Arrange
+	webhookID := uuid.MustParse("7d2e4f60-1a3b-4c5d-8e9f-0a1b2c3d4e5f")
+	at := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	// Act
+	webhook := Webhook("a#b", webhookID)
+	first := Delivery("a#b", webhookID, at, testID)
+	later := Delivery("a#b", webhookID, at.Add(time.Millisecond), uuid.Nil)
+
+	// Assert
+	if webhook.PK != "USER#a%23b" || webhook.SK != "WEBHOOK#"+webhookID.String() {
+		t.Errorf("Expected USER#a%%23b and WEBHOOK#%s, got %+v", webhookID, webhook)
+	}
+	if want := "DELIVERY#" + webhookID.String() + "#2026-10-18T09:00:00.000000000Z#" + testID.String(); first.PK != webhook.PK || first.SK != want {
+		t.Errorf("Expected %s in the webhook's partition, got %+v", want, first)
+	}
+	if !strings.HasPrefix(later.SK, DeliverySK(webhookID)) || later.SK <= first.SK {
+		t.Errorf("Expected later deliveries to sort after %s, got %s", first.SK, later.SK)
+	}
+}
+
+func TestPendingDeliveryKeys(t *testing.T) {
+	// Arrange
+	at := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	// Act
+	pending := PendingDeliveries("a#b")
+	due := PendingDeliverySK(at, testID)
+	later := PendingDeliverySK(at.Add(time.Nanosecond), uuid.Nil)
+	shards := map[int]bool{}
+	for i := range 100 {
+		shards[Shard(strings.Repeat("a", i))] = true
+	}
+
+	// Assert
+	if pending != PendingDeliveriesShard(Shard("a#b")) || !strings.HasPrefix(pending, DeliveryPendingPrefix) {
+		t.Errorf("Expected the owner's shard of %s, got %s", DeliveryPendingPrefix, pending)
+	}
+	if due >= DeliveriesDueSK(at) || later <= DeliveriesDueSK(at) {
+		t.Errorf("Expected %s to be due at %s and %s not, got %s", due, at, later, DeliveriesDueSK(at))
+	}
+	if len(shards) != PendingShards {
+		t.Errorf("Expected owners spread over %d shards, got %d", PendingShards, len(shards))
+	}
+}
+
+func TestOutboxKeys(t *testing.T) {
+	// Act
+	head := OutboxHead("a#b")
+	first := OutboxEntry("a#b", 9)
+	later := OutboxEntry("a#b", 10)
+	pending := OutboxPending("a#b")
+
+	// Assert
+	if head.PK != "USER#a%23b" || head.SK != "OUTBOX" {
+		t.Errorf("Expected USER#a%%23b and OUTBOX, got %+v", head)
+	}
+	if first.PK != head.PK || first.SK != "OUTBOX#00000000000000000009" {
+		t.Errorf("Expected OUTBOX#00000000000000000009 in the head's partition, got %+v", first)
+	}
+	if !strings.HasPrefix(later.SK, OutboxPrefix) || later.SK <= first.SK || strings.HasPrefix(head.SK, OutboxPrefix) {
+		t.Errorf("Expected entries to sort by sequence apart from the head, got %s and %s", first.SK, later.SK)
+	}
+	if pending != OutboxPendingShard(Shard("a#b")) || !strings.HasPrefix(pending, OutboxPendingPrefix) {
+		t.Errorf("Expected the owner's shard of %s, got %s", OutboxPendingPrefix, pending)
+	}
+}
+
+func TestStatsKey(t *testing.T) {
+	// Act
+	key := Stats("a#b")
+
+	// Assert
+	if key.PK != "USER#a%23b" || key.SK != "STATS" {
+		t.Errorf("Expected USER#a%%23b and STATS, got %+v", key)
+	}
+}
+
+func TestItemRoundTrip(t *testing.T) {
+	// Arrange
+	key := Task("test@example.com", testID)
+
+	// Act
+	got := FromItem(key.Item())
+
+	// Assert
+	if got != key {
+		t.Errorf("Expected %+v, got %+v", key, got)
+	}
+}
@@ -0,0 +1,157 @@
+package outbox
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+// DeliveredRetention is how long delivered entries are kept before the
+// table's time to live removes them
+const DeliveredRetention = 7 * 24 * time.Hour
+
+// Head is the progress of an owner's outbox
+type Head struct {
+	// Sequence is the number of the last entry appended
+	Sequence int64
+	// Delivered is the number of the last entry delivered
+	Delivered int64
+}
+
+// Pending reports whether the outbox has entries that were not delivered
+func (h Head) Pending() bool {
+	return h.Delivered < h.Sequence
+}
+
+// headItem is an outbox head as stored in DynamoDB
+type headItem struct {
+	Sequence  int64
+	Delivered int64
+}
+
+// entryItem is an entry as stored in DynamoDB. Table.MarkDelivered also
+// sets Delivered and ExpiresAt on the entries it delivers.
+type entryItem struct {
+	PK         string
+	SK         string
+	ID         string
+	Owner      string
+	Sequence   int64
+	Events     []eventItem
+	Created    time.Time
+	EntityType keys.EntityType `dynamodbav:"entity_type"`
+}
+
+// eventItem is an event as stored in an entry item
+type eventItem struct {
+	Type   string
+	TaskID string
+	Task   string `dynamodbav:",omitempty"`
+}
+
+// HeadFromItem returns the head of an outbox head item, which is empty if
+// the item is nil because nothing was appended yet
+func HeadFromItem(item map[string]types.AttributeValue) (Head, error) {
+	var stored headItem
+	if err := attributevalue.UnmarshalMap(item, &stored); err != nil {
+		return Head{}, fmt.Errorf("failed to unmarshal outbox head: %w", err)
+	}
+	return Head(stored), nil
+}
+
+// Append returns the transaction items that append an entry, numbered after
+// the last entry of the head, to its owner's outbox. The head is only
+// advanced if no other entry was appended since it was read, so entries are
+// numbered in the order their transactions commit; a transaction cancelled
+// by the head's condition can be built again from the head read again.
+func Append(tableName string, head Head, entry Entry) ([]types.TransactWriteItem, error) {
+	entry.Sequence = head.Sequence + 1
+	events := make([]eventItem, 0, len(entry.Events))
+	for _, event := range entry.Events {
+		events = append(events, eventItem{Type: event.Type, TaskID: event.TaskID.String(), Task: string(event.Task)})
+	}
+	key := keys.OutboxEntry(entry.Owner, entry.Sequence)
+	item, err := attributevalue.MarshalMap(entryItem{
+		PK:         key.PK,
+		SK:         key.SK,
+		ID:         entry.ID.String(),
+		Owner:      entry.Owner,
+		Sequence:   entry.Sequence,
+		Events:     events,
+		Created:    entry.Created.UTC(),
+		EntityType: keys.EntityOutboxEntry,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox entry: %w", err)
+	}
+
+	condition := "attribute_not_exists(#sequence)"
+	values := map[string]types.AttributeValue{
+		":sequence": &types.AttributeValueMemberN{Value: strconv.FormatInt(entry.Sequence, 10)},
+		":pending":  &types.AttributeValueMemberS{Value: keys.OutboxPending(entry.Owner)},
+		":owner":    &types.AttributeValueMemberS{Value: keys.User(entry.Owner)},
+		":entity":   &types.AttributeValueMemberS{Value: string(keys.EntityOutboxHead)},
+	}
+	if head.Sequence > 0 {
+		condition = "#sequence = :previous"
+		values[":previous"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(head.Sequence, 10)}
+	}
+
+	return []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName:                 aws.String(tableName),
+				Key:                       keys.OutboxHead(entry.Owner).Item(),
+				UpdateExpression:          aws.String("SET #sequence = :sequence, GS1PK = :pending, GS1SK = :owner, entity_type = :entity"),
+				ConditionExpression:       aws.String(condition),
+				ExpressionAttributeNames:  map[string]string{"#sequence": "Sequence"},
+				ExpressionAttributeValues: values,
+			},
+		},
+		{
+			Put: &types.Put{
+				TableName:           aws.String(tableName),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			},
+		},
+	}, nil
+}
+
+// EntryFromItem returns the entry of a DynamoDB item
+func EntryFromItem(item map[string]types.AttributeValue) (Entry, error) {
+	var stored entryItem
+	if err := attributevalue.UnmarshalMap(item, &stored); err != nil {
+		return Entry{}, fmt.Errorf("failed to unmarshal outbox entry: %w", err)
+	}
+	id, err := uuid.Parse(stored.ID)
+	if err != nil {
+		return Entry{}, fmt.Errorf("outbox entry has an invalid ID: %w", err)
+	}
+
+	entry := Entry{
+		ID:       id,
+		Owner:    stored.Owner,
+		Sequence: stored.Sequence,
+		Events:   make([]Event, 0, len(stored.Events)),
+		Created:  stored.Created,
+	}
+	for _, event := range stored.Events {
+		taskID, err := uuid.Parse(event.TaskID)
+		if err != nil {
+			return Entry{}, fmt.Errorf("outbox entry %d has an invalid task ID: %w", stored.Sequence, err)
+		}
+		var task []byte
+		if event.Task != "" {
+			task = []byte(event.Task)
+		}
+		entry.Events = append(entry.Events, Event{Type: event.Type, TaskID: taskID, Task: task})
+	}
+	return entry, nil
+}
@@ -0,0 +1,123 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/google/uuid"
+)
+
+// memoryLog records the requests made to an in-memory client. Like a FIFO
+// topic or queue, it drops a request with the deduplication ID of one
+// recorded before.
+type memoryLog[T any] struct {
+	mu       sync.Mutex
+	requests []T
+	seen     map[string]bool
+}
+
+// add records a request unless its deduplication ID was seen before
+func (l *memoryLog[T]) add(deduplicationID *string, request T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if deduplicationID != nil {
+		if l.seen[*deduplicationID] {
+			return
+		}
+		if l.seen == nil {
+			l.seen = make(map[string]bool)
+		}
+		l.seen[*deduplicationID] = true
+	}
+	l.requests = append(l.requests, request)
+}
+
+// list returns the requests recorded, in order
+func (l *memoryLog[T]) list() []T {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]T(nil), l.requests...)
+}
+
+// MemorySNS is an in-memory SNSClient that records the messages published
+// to it, dropping duplicates as a FIFO topic does
+type MemorySNS struct {
+	// Err, if set, is returned instead of publishing
+	Err      error
+	messages memoryLog[sns.PublishInput]
+}
+
+// Publish records a message
+func (m *MemorySNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	m.messages.add(params.MessageDeduplicationId, *params)
+	return &sns.PublishOutput{MessageId: aws.String(uuid.NewString())}, nil
+}
+
+// Messages returns the messages published, in order
+func (m *MemorySNS) Messages() []sns.PublishInput {
+	return m.messages.list()
+}
+
+// MemorySQS is an in-memory SQSClient that records the messages sent to
+// it, dropping duplicates as a FIFO queue does
+type MemorySQS struct {
+	// Err, if set, is returned instead of sending
+	Err      error
+	messages memoryLog[sqs.SendMessageInput]
+}
+
+// SendMessage records a message
+func (m *MemorySQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	m.messages.add(params.MessageDeduplicationId, *params)
+	return &sqs.SendMessageOutput{MessageId: aws.String(uuid.NewString())}, nil
+}
+
+// Messages returns the messages sent, in order
+func (m *MemorySQS) Messages() []sqs.SendMessageInput {
+	return m.messages.list()
+}
+
+// MemoryEventBridge is an in-memory EventBridgeClient that records the
+// events put on it. Like EventBridge, it keeps duplicates.
+type MemoryEventBridge struct {
+	// Err, if set, is returned instead of putting events
+	Err error
+	// Reject, if set, is the error code every entry is rejected with
+	Reject string
+	events memoryLog[eventbridgetypes.PutEventsRequestEntry]
+}
+
+// PutEvents records events, or rejects them all
+func (m *MemoryEventBridge) PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	output := &eventbridge.PutEventsOutput{}
+	for _, entry := range params.Entries {
+		if m.Reject != "" {
+			output.FailedEntryCount++
+			output.Entries = append(output.Entries, eventbridgetypes.PutEventsResultEntry{ErrorCode: aws.String(m.Reject)})
+			continue
+		}
+		m.events.add(nil, entry)
+		output.Entries = append(output.Entries, eventbridgetypes.PutEventsResultEntry{EventId: aws.String(uuid.NewString())})
+	}
+	return output, nil
+}
+
+// Events returns the events put, in order
+func (m *MemoryEventBridge) Events() []eventbridgetypes.PutEventsRequestEntry {
+	return m.events.list()
+}
@@ -0,0 +1,185 @@
+// Package outbox records task events in the same transactions as the task
+// writes that cause them, and relays them to a publisher. An event is only
+// recorded if its write commits, and a write that commits always has its
+// events recorded, so none are lost if the writer stops before publishing.
+//
+// Each write appends one entry to its owner's outbox, numbered in the order
+// the writes committed. The Relay publishes each owner's entries in that
+// order and marks them delivered, so an entry that fails to publish holds
+// back the owner's later entries until it succeeds.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The types of the events recorded in the outbox, which are the same as
+// those of the stream consumer
+const (
+	EventTaskCreated = "TaskCreated"
+	EventTaskUpdated = "TaskUpdated"
+	EventTaskClosed  = "TaskClosed"
+	EventTaskDeleted = "TaskDeleted"
+)
+
+// DefaultBatchSize is the number of an owner's entries the relay reads at a time
+const DefaultBatchSize = 25
+
+// Event is a change to a task
+type Event struct {
+	Type   string
+	TaskID uuid.UUID
+	// Task is the JSON of the task as written, or as it was before it was
+	// deleted. Updates made in a transaction only change a task's title, so
+	// they only carry its ID, owner and title.
+	Task json.RawMessage
+}
+
+// Entry is the events of one write to an owner's tasks
+type Entry struct {
+	ID    uuid.UUID
+	Owner string
+	// Sequence numbers an owner's entries from 1, in the order their
+	// writes committed
+	Sequence int64
+	Events   []Event
+	Created  time.Time
+}
+
+// Message is an event as it is published
+type Message struct {
+	// ID identifies the event, and is the same each time its entry is
+	// relayed, so receivers can drop duplicates
+	ID string
+	// Owner is the owner of the task. Each owner's messages are published
+	// in order.
+	Owner string
+	Type  string
+	// Body is the JSON of the event
+	Body []byte
+}
+
+// messageBody is the JSON of a published event
+type messageBody struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Owner    string          `json:"owner"`
+	Sequence int64           `json:"sequence"`
+	TaskID   uuid.UUID       `json:"taskId"`
+	Task     json.RawMessage `json:"task,omitempty"`
+	Time     time.Time       `json:"time"`
+}
+
+// Messages returns the messages publishing an entry's events, in order
+func Messages(entry Entry) ([]Message, error) {
+	messages := make([]Message, 0, len(entry.Events))
+	for i, event := range entry.Events {
+		id := entry.ID.String() + ":" + strconv.Itoa(i)
+		body, err := json.Marshal(messageBody{
+			ID:       id,
+			Type:     event.Type,
+			Owner:    entry.Owner,
+			Sequence: entry.Sequence,
+			TaskID:   event.TaskID,
+			Task:     event.Task,
+			Time:     entry.Created,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s event: %w", event.Type, err)
+		}
+		messages = append(messages, Message{ID: id, Owner: entry.Owner, Type: event.Type, Body: body})
+	}
+	return messages, nil
+}
+
+// Publisher publishes messages to the services that consume task events
+type Publisher interface {
+	Publish(ctx context.Context, message Message) error
+}
+
+// Store reads the outbox and records which entries were delivered
+type Store interface {
+	// PendingOwners lists the owners with entries that were not delivered
+	PendingOwners(ctx context.Context) ([]string, error)
+	// Pending lists up to limit of an owner's entries that were not
+	// delivered, in sequence order
+	Pending(ctx context.Context, owner string, limit int) ([]Entry, error)
+	// MarkDelivered records that an owner's entries up to and including a
+	// sequence number were delivered. Marking entries that were already
+	// marked does nothing.
+	MarkDelivered(ctx context.Context, owner string, sequence int64) error
+}
+
+// Relay publishes the entries of outboxes in order
+type Relay struct {
+	store     Store
+	publisher Publisher
+	// BatchSize is the number of an owner's entries read at a time
+	BatchSize int
+}
+
+// NewRelay creates a relay publishing the entries of store to publisher
+func NewRelay(store Store, publisher Publisher) *Relay {
+	return &Relay{store: store, publisher: publisher, BatchSize: DefaultBatchSize}
+}
+
+// Drain publishes the undelivered entries of every owner. An owner whose
+// entries fail to publish does not hold back the others; the errors of all
+// such owners are returned together once the others are drained.
+func (r *Relay) Drain(ctx context.Context) error {
+	owners, err := r.store.PendingOwners(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list pending outboxes: %w", err)
+	}
+
+	var errs []error
+	for _, owner := range owners {
+		if err := r.DrainOwner(ctx, owner); err != nil {
+			errs = append(errs, fmt.Errorf("failed to drain outbox of %s: %w", owner, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DrainOwner publishes an owner's undelivered entries in order, marking each
+// delivered once all its events are published. It stops at the first entry
+// that fails, so no event is published before an earlier one; that entry's
+// events are all published again by the next drain, under the same IDs.
+func (r *Relay) DrainOwner(ctx context.Context, owner string) error {
+	var delivered int64
+	for {
+		entries, err := r.store.Pending(ctx, owner, r.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		if entries[0].Sequence <= delivered {
+			return fmt.Errorf("entry %d is still pending after it was marked delivered", entries[0].Sequence)
+		}
+
+		for _, entry := range entries {
+			messages, err := Messages(entry)
+			if err != nil {
+				return err
+			}
+			for _, message := range messages {
+				if err := r.publisher.Publish(ctx, message); err != nil {
+					return fmt.Errorf("failed to publish entry %d: %w", entry.Sequence, err)
+				}
+			}
+			if err := r.store.MarkDelivered(ctx, owner, entry.Sequence); err != nil {
+				return fmt.Errorf("failed to mark entry %d delivered: %w", entry.Sequence, err)
+			}
+			delivered = entry.Sequence
+		}
+	}
+}
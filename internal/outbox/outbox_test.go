@@ -0,0 +1,315 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+// memoryStore is a Store of outboxes in memory
+type memoryStore struct {
+	entries   map[string][]Entry
+	delivered map[string]int64
+	// markErr, if set, is returned by MarkDelivered without marking
+	markErr error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: make(map[string][]Entry), delivered: make(map[string]int64)}
+}
+
+// append adds an entry with one event per type to an owner's outbox
+func (s *memoryStore) append(owner string, eventTypes ...string) Entry {
+	entry := Entry{
+		ID:       uuid.New(),
+		Owner:    owner,
+		Sequence: int64(len(s.entries[owner]) + 1),
+		Created:  time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC),
+	}
+	for _, eventType := range eventTypes {
+		entry.Events = append(entry.Events, Event{Type: eventType, TaskID: uuid.New(), Task: json.RawMessage(`{"title":"Task"}`)})
+	}
+	s.entries[owner] = append(s.entries[owner], entry)
+	return entry
+}
+
+func (s *memoryStore) PendingOwners(ctx context.Context) ([]string, error) {
+	var owners []string
+	for owner, entries := range s.entries {
+		if s.delivered[owner] < int64(len(entries)) {
+			owners = append(owners, owner)
+		}
+	}
+	return owners, nil
+}
+
+func (s *memoryStore) Pending(ctx context.Context, owner string, limit int) ([]Entry, error) {
+	pending := s.entries[owner][s.delivered[owner]:]
+	return pending[:min(limit, len(pending))], nil
+}
+
+func (s *memoryStore) MarkDelivered(ctx context.Context, owner string, sequence int64) error {
+	if s.markErr != nil {
+		return s.markErr
+	}
+	s.delivered[owner] = max(s.delivered[owner], sequence)
+	return nil
+}
+
+// published returns the owner, sequence and type of the messages sent to a
+// queue, in order
+func published(queue *MemorySQS) []string {
+	var messages []string
+	for _, message := range queue.Messages() {
+		var body messageBody
+		_ = json.Unmarshal([]byte(aws.ToString(message.MessageBody)), &body)
+		messages = append(messages, fmt.Sprintf("%s %d %s", body.Owner, body.Sequence, body.Type))
+	}
+	return messages
+}
+
+func TestMessages(t *testing.T) {
+	// Arrange
+	store := newMemoryStore()
+	entry := store.append("test@example.com", EventTaskCreated, EventTaskClosed)
+
+	// Act
+	messages, err := Messages(entry)
+
+	// Assert
+	if err != nil || len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d (%v)", len(messages), err)
+	}
+	var body messageBody
+	if err := json.Unmarshal(messages[1].Body, &body); err != nil {
+		t.Fatalf("Failed to unmarshal %s: %v", messages[1].Body, err)
+	}
+	want := messageBody{
+		ID:       entry.ID.String() + ":1",
+		Type:     EventTaskClosed,
+		Owner:    "test@example.com",
+		Sequence: 1,
+		TaskID:   entry.Events[1].TaskID,
+		Task:     json.RawMessage(`{"title":"Task"}`),
+		Time:     entry.Created,
+	}
+	if !reflect.DeepEqual(body, want) || messages[1].ID != want.ID || messages[1].Type != EventTaskClosed {
+		t.Errorf("Expected %+v, got %+v", want, body)
+	}
+}
+
+func TestRelayDrain(t *testing.T) {
+	// Arrange: more entries than a batch, for two owners
+	store := newMemoryStore()
+	for range 3 {
+		store.append("a@example.com", EventTaskCreated, EventTaskUpdated)
+	}
+	store.append("b@example.com", EventTaskDeleted)
+	queue := &MemorySQS{}
+	relay := NewRelay(store, NewSQSPublisher(queue, "https://sqs.eu-west-1.amazonaws.com/123456789012/tasks.fifo"))
+	relay.BatchSize = 2
+
+	// Act
+	err := relay.Drain(context.Background())
+	again := relay.Drain(context.Background())
+
+	// Assert
+	if err != nil || again != nil {
+		t.Fatalf("Expected no errors, got %v and %v", err, again)
+	}
+	var a, b []string
+	for _, message := range published(queue) {
+		if strings.HasPrefix(message, "a@") {
+			a = append(a, message)
+		} else {
+			b = append(b, message)
+		}
+	}
+	wantA := []string{
+		"a@example.com 1 TaskCreated", "a@example.com 1 TaskUpdated",
+		"a@example.com 2 TaskCreated", "a@example.com 2 TaskUpdated",
+		"a@example.com 3 TaskCreated", "a@example.com 3 TaskUpdated",
+	}
+	if !reflect.DeepEqual(a, wantA) || !reflect.DeepEqual(b, []string{"b@example.com 1 TaskDeleted"}) {
+		t.Errorf("Expected each owner's events once in order, got %v and %v", a, b)
+	}
+	if store.delivered["a@example.com"] != 3 || store.delivered["b@example.com"] != 1 {
+		t.Errorf("Expected every entry marked delivered, got %v", store.delivered)
+	}
+}
+
+func TestRelayStopsAtFailedEntry(t *testing.T) {
+	// Arrange: a topic that is unavailable for the first drain
+	store := newMemoryStore()
+	store.append("test@example.com", EventTaskCreated)
+	store.append("test@example.com", EventTaskClosed)
+	topic := &MemorySNS{Err: errors.New("service unavailable")}
+	relay := NewRelay(store, NewSNSPublisher(topic, "arn:aws:sns:eu-west-1:123456789012:tasks"))
+
+	// Act
+	failed := relay.Drain(context.Background())
+	topic.Err = nil
+	recovered := relay.Drain(context.Background())
+
+	// Assert
+	if failed == nil || !strings.Contains(failed.Error(), "entry 1") {
+		t.Errorf("Expected the first entry to fail, got %v", failed)
+	}
+	if recovered != nil {
+		t.Fatalf("Expected no error once the topic is available, got %v", recovered)
+	}
+	messages := topic.Messages()
+	if len(messages) != 2 || messages[0].MessageGroupId != nil ||
+		aws.ToString(messages[0].MessageAttributes["type"].StringValue) != EventTaskCreated ||
+		aws.ToString(messages[1].MessageAttributes["type"].StringValue) != EventTaskClosed {
+		t.Errorf("Expected both entries published in order to the standard topic, got %+v", messages)
+	}
+}
+
+func TestRelayRedeliveryIsDeduplicated(t *testing.T) {
+	// Arrange: an entry published before the relay fails to mark it
+	store := newMemoryStore()
+	store.append("test@example.com", EventTaskCreated)
+	store.markErr = errors.New("throttled")
+	queue := &MemorySQS{}
+	relay := NewRelay(store, NewSQSPublisher(queue, "https://sqs.eu-west-1.amazonaws.com/123456789012/tasks.fifo"))
+
+	// Act
+	failed := relay.Drain(context.Background())
+	store.markErr = nil
+	recovered := relay.Drain(context.Background())
+
+	// Assert
+	if failed == nil || recovered != nil {
+		t.Fatalf("Expected only the first drain to fail, got %v and %v", failed, recovered)
+	}
+	messages := queue.Messages()
+	if len(messages) != 1 || aws.ToString(messages[0].MessageGroupId) != messageGroup("test@example.com") {
+		t.Errorf("Expected the entry sent once to the owner's message group, got %+v", messages)
+	}
+	if store.delivered["test@example.com"] != 1 {
+		t.Errorf("Expected the entry marked delivered, got %d", store.delivered["test@example.com"])
+	}
+}
+
+func TestRelayStopsWhenEntriesStayPending(t *testing.T) {
+	// Arrange: a store that never records deliveries
+	store := newMemoryStore()
+	store.append("test@example.com", EventTaskCreated)
+	stuck := &stuckStore{store}
+	relay := NewRelay(stuck, NewEventBridgePublisher(&MemoryEventBridge{}, "tasks"))
+
+	// Act
+	err := relay.DrainOwner(context.Background(), "test@example.com")
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), "still pending") {
+		t.Errorf("Expected the relay to stop, got %v", err)
+	}
+}
+
+// stuckStore is a Store whose MarkDelivered succeeds without marking
+type stuckStore struct {
+	*memoryStore
+}
+
+func (s *stuckStore) MarkDelivered(ctx context.Context, owner string, sequence int64) error {
+	return nil
+}
+
+func TestEventBridgePublisher(t *testing.T) {
+	// Arrange
+	message := Message{ID: "entry:0", Owner: "test@example.com", Type: EventTaskCreated, Body: []byte(`{"id":"entry:0"}`)}
+	bus := &MemoryEventBridge{}
+	rejecting := &MemoryEventBridge{Reject: "InternalFailure"}
+
+	// Act
+	err := NewEventBridgePublisher(bus, "tasks").Publish(context.Background(), message)
+	rejected := NewEventBridgePublisher(rejecting, "tasks").Publish(context.Background(), message)
+
+	// Assert
+	events := bus.Events()
+	if err != nil || len(events) != 1 || aws.ToString(events[0].Source) != EventSource ||
+		aws.ToString(events[0].DetailType) != EventTaskCreated || aws.ToString(events[0].Detail) != `{"id":"entry:0"}` {
+		t.Errorf("Expected the event on the bus, got %+v (%v)", events, err)
+	}
+	if rejected == nil || !strings.Contains(rejected.Error(), "InternalFailure") {
+		t.Errorf("Expected the rejected entry to fail, got %v", rejected)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	// Arrange
+	entry := Entry{
+		ID:      uuid.New(),
+		Owner:   "a#b",
+		Events:  []Event{{Type: EventTaskDeleted, TaskID: uuid.New()}},
+		Created: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name      string
+		head      Head
+		sequence  int64
+		condition string
+	}{
+		{"first entry", Head{}, 1, "attribute_not_exists(#sequence)"},
+		{"later entry", Head{Sequence: 4, Delivered: 2}, 5, "#sequence = :previous"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			items, err := Append("tasks", tt.head, entry)
+
+			// Assert
+			if err != nil || len(items) != 2 {
+				t.Fatalf("Expected 2 items, got %d (%v)", len(items), err)
+			}
+			update := items[0].Update
+			if keys.FromItem(update.Key) != keys.OutboxHead("a#b") || aws.ToString(update.ConditionExpression) != tt.condition {
+				t.Errorf("Expected the head to require %q, got %+v", tt.condition, update)
+			}
+			if n, _ := update.ExpressionAttributeValues[":sequence"].(*types.AttributeValueMemberN); n == nil || n.Value != fmt.Sprint(tt.sequence) {
+				t.Errorf("Expected the head advanced to %d, got %v", tt.sequence, update.ExpressionAttributeValues[":sequence"])
+			}
+			if s, _ := update.ExpressionAttributeValues[":pending"].(*types.AttributeValueMemberS); s == nil || s.Value != keys.OutboxPending("a#b") {
+				t.Errorf("Expected the head listed in the owner's shard, got %v", update.ExpressionAttributeValues[":pending"])
+			}
+			stored, err := EntryFromItem(items[1].Put.Item)
+			want := entry
+			want.Sequence = tt.sequence
+			if err != nil || !reflect.DeepEqual(stored, want) || keys.FromItem(items[1].Put.Item) != keys.OutboxEntry("a#b", tt.sequence) {
+				t.Errorf("Expected %+v, got %+v (%v)", want, stored, err)
+			}
+		})
+	}
+}
+
+func TestHeadFromItem(t *testing.T) {
+	// Arrange
+	item := keys.OutboxHead("test@example.com").Item()
+	item["Sequence"] = &types.AttributeValueMemberN{Value: "5"}
+	item["Delivered"] = &types.AttributeValueMemberN{Value: "5"}
+
+	// Act
+	head, err := HeadFromItem(item)
+	empty, emptyErr := HeadFromItem(nil)
+
+	// Assert
+	if err != nil || head != (Head{Sequence: 5, Delivered: 5}) || head.Pending() {
+		t.Errorf("Expected a delivered head at 5, got %+v (%v)", head, err)
+	}
+	if emptyErr != nil || empty != (Head{}) {
+		t.Errorf("Expected an empty head without an item, got %+v (%v)", empty, emptyErr)
+	}
+}
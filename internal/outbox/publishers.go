@@ -0,0 +1,156 @@
+package outbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// EventSource is the source of the events put on EventBridge
+	EventSource = "tasks-api"
+	// typeAttribute is the message attribute carrying the event type, so
+	// subscribers can filter by type without parsing the body
+	typeAttribute = "type"
+	// fifoSuffix ends the names of FIFO topics and queues
+	fifoSuffix = ".fifo"
+)
+
+// SNSClient is the part of the SNS client that SNSPublisher uses
+type SNSClient interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// SQSClient is the part of the SQS client that SQSPublisher uses
+type SQSClient interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// EventBridgeClient is the part of the EventBridge client that
+// EventBridgePublisher uses
+type EventBridgeClient interface {
+	PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error)
+}
+
+// SNSPublisher publishes messages to an SNS topic. On a FIFO topic, each
+// owner's messages are kept in order in a message group, and a message
+// relayed again is dropped as a duplicate of the first.
+type SNSPublisher struct {
+	client   SNSClient
+	topicARN string
+}
+
+// NewSNSPublisher creates a publisher to the topic with an ARN
+func NewSNSPublisher(client SNSClient, topicARN string) *SNSPublisher {
+	return &SNSPublisher{client: client, topicARN: topicARN}
+}
+
+// Publish publishes a message to the topic
+func (p *SNSPublisher) Publish(ctx context.Context, message Message) error {
+	input := &sns.PublishInput{
+		TopicArn: aws.String(p.topicARN),
+		Message:  aws.String(string(message.Body)),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			typeAttribute: {DataType: aws.String("String"), StringValue: aws.String(message.Type)},
+		},
+	}
+	if strings.HasSuffix(p.topicARN, fifoSuffix) {
+		input.MessageGroupId = aws.String(messageGroup(message.Owner))
+		input.MessageDeduplicationId = aws.String(message.ID)
+	}
+
+	if _, err := p.client.Publish(ctx, input); err != nil {
+		return fmt.Errorf("failed to publish %s to SNS: %w", message.ID, err)
+	}
+	return nil
+}
+
+// SQSPublisher sends messages to an SQS queue. On a FIFO queue, each
+// owner's messages are kept in order in a message group, and a message
+// relayed again is dropped as a duplicate of the first.
+type SQSPublisher struct {
+	client   SQSClient
+	queueURL string
+}
+
+// NewSQSPublisher creates a publisher to the queue with a URL
+func NewSQSPublisher(client SQSClient, queueURL string) *SQSPublisher {
+	return &SQSPublisher{client: client, queueURL: queueURL}
+}
+
+// Publish sends a message to the queue
+func (p *SQSPublisher) Publish(ctx context.Context, message Message) error {
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(p.queueURL),
+		MessageBody: aws.String(string(message.Body)),
+		MessageAttributes: map[string]sqstypes.MessageAttributeValue{
+			typeAttribute: {DataType: aws.String("String"), StringValue: aws.String(message.Type)},
+		},
+	}
+	if strings.HasSuffix(p.queueURL, fifoSuffix) {
+		input.MessageGroupId = aws.String(messageGroup(message.Owner))
+		input.MessageDeduplicationId = aws.String(message.ID)
+	}
+
+	if _, err := p.client.SendMessage(ctx, input); err != nil {
+		return fmt.Errorf("failed to send %s to SQS: %w", message.ID, err)
+	}
+	return nil
+}
+
+// EventBridgePublisher puts messages on an EventBridge event bus, with the
+// event type as their detail type. EventBridge neither orders events nor
+// drops duplicates, so rules' targets see the sequence and ID in each
+// event's detail to do so themselves.
+type EventBridgePublisher struct {
+	client  EventBridgeClient
+	busName string
+}
+
+// NewEventBridgePublisher creates a publisher to the event bus with a name
+// or ARN
+func NewEventBridgePublisher(client EventBridgeClient, busName string) *EventBridgePublisher {
+	return &EventBridgePublisher{client: client, busName: busName}
+}
+
+// Publish puts a message on the event bus
+func (p *EventBridgePublisher) Publish(ctx context.Context, message Message) error {
+	result, err := p.client.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []eventbridgetypes.PutEventsRequestEntry{{
+			EventBusName: aws.String(p.busName),
+			Source:       aws.String(EventSource),
+			DetailType:   aws.String(message.Type),
+			Detail:       aws.String(string(message.Body)),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put %s on EventBridge: %w", message.ID, err)
+	}
+
+	// PutEvents succeeds even if it rejects the entry
+	if result.FailedEntryCount > 0 {
+		code, detail := "unknown", ""
+		if len(result.Entries) > 0 {
+			code, detail = aws.ToString(result.Entries[0].ErrorCode), aws.ToString(result.Entries[0].ErrorMessage)
+		}
+		return fmt.Errorf("EventBridge rejected %s: %s: %s", message.ID, code, detail)
+	}
+	return nil
+}
+
+// messageGroup returns the FIFO message group of an owner's messages. Owners
+// may contain characters that group IDs may not, so the group is a hash.
+func messageGroup(owner string) string {
+	sum := sha256.Sum256([]byte(owner))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,175 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+// Table is the Store of the outboxes in the tasks table
+type Table struct {
+	client    *dynamodb.Client
+	tableName string
+	// now returns the time entries are marked delivered at
+	now func() time.Time
+}
+
+var _ Store = (*Table)(nil)
+
+// NewTable creates a store of the outboxes in a tasks table
+func NewTable(client *dynamodb.Client, tableName string) *Table {
+	return &Table{client: client, tableName: tableName, now: time.Now}
+}
+
+// PendingOwners lists the owners whose heads are in the pending index, from
+// each shard in turn. The index is eventually consistent, so it may list an
+// owner whose entries were just delivered, which Pending then finds none of.
+func (t *Table) PendingOwners(ctx context.Context) ([]string, error) {
+	var owners []string
+	for shard := 0; shard < keys.PendingShards; shard++ {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(t.tableName),
+			IndexName:              aws.String("GS1"),
+			KeyConditionExpression: aws.String("GS1PK = :pending"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pending": &types.AttributeValueMemberS{Value: keys.OutboxPendingShard(shard)},
+			},
+			ProjectionExpression: aws.String("GS1SK"),
+		}
+
+		for {
+			result, err := t.client.Query(ctx, input)
+			if err != nil {
+				return nil, fmt.Errorf("failed to query pending outboxes: %w", err)
+			}
+			for _, item := range result.Items {
+				gs1sk, _ := item["GS1SK"].(*types.AttributeValueMemberS)
+				if gs1sk == nil {
+					continue
+				}
+				owner, err := keys.ParseUser(gs1sk.Value)
+				if err != nil {
+					return nil, err
+				}
+				owners = append(owners, owner)
+			}
+
+			if result.LastEvaluatedKey == nil {
+				break
+			}
+			input.ExclusiveStartKey = result.LastEvaluatedKey
+		}
+	}
+	return owners, nil
+}
+
+// Pending lists an owner's entries after the last delivered, with strongly
+// consistent reads of the head and the entries
+func (t *Table) Pending(ctx context.Context, owner string, limit int) ([]Entry, error) {
+	result, err := t.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(t.tableName),
+		Key:            keys.OutboxHead(owner).Item(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox head from DynamoDB: %w", err)
+	}
+	head, err := HeadFromItem(result.Item)
+	if err != nil || !head.Pending() {
+		return nil, err
+	}
+
+	query, err := t.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(t.tableName),
+		KeyConditionExpression: aws.String("PK = :pk AND SK BETWEEN :first AND :last"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":    &types.AttributeValueMemberS{Value: keys.User(owner)},
+			":first": &types.AttributeValueMemberS{Value: keys.OutboxEntry(owner, head.Delivered+1).SK},
+			":last":  &types.AttributeValueMemberS{Value: keys.OutboxEntry(owner, head.Sequence).SK},
+		},
+		ConsistentRead: aws.Bool(true),
+		Limit:          aws.Int32(int32(limit)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox entries: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(query.Items))
+	for _, item := range query.Items {
+		entry, err := EntryFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// MarkDelivered advances an owner's head past an entry and stamps the entry
+// delivered, with a time to live, in one transaction. The head's condition
+// cancels the transaction if the entry was already marked, which is not an
+// error. Once the head has caught up with the last entry appended, it is
+// removed from the pending index.
+func (t *Table) MarkDelivered(ctx context.Context, owner string, sequence int64) error {
+	now := t.now().UTC()
+	number := &types.AttributeValueMemberN{Value: strconv.FormatInt(sequence, 10)}
+	_, err := t.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName:                 aws.String(t.tableName),
+					Key:                       keys.OutboxHead(owner).Item(),
+					UpdateExpression:          aws.String("SET #delivered = :sequence"),
+					ConditionExpression:       aws.String("#sequence >= :sequence AND (attribute_not_exists(#delivered) OR #delivered < :sequence)"),
+					ExpressionAttributeNames:  map[string]string{"#sequence": "Sequence", "#delivered": "Delivered"},
+					ExpressionAttributeValues: map[string]types.AttributeValue{":sequence": number},
+				},
+			},
+			{
+				Update: &types.Update{
+					TableName:           aws.String(t.tableName),
+					Key:                 keys.OutboxEntry(owner, sequence).Item(),
+					UpdateExpression:    aws.String("SET #delivered = :now, ExpiresAt = :expires"),
+					ConditionExpression: aws.String("attribute_exists(PK)"),
+					ExpressionAttributeNames: map[string]string{
+						"#delivered": "Delivered",
+					},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":now":     &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)},
+						":expires": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(DeliveredRetention).Unix(), 10)},
+					},
+				},
+			},
+		},
+	})
+	var cancelled *types.TransactionCanceledException
+	if errors.As(err, &cancelled) && len(cancelled.CancellationReasons) > 0 &&
+		aws.ToString(cancelled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox entry delivered in DynamoDB: %w", err)
+	}
+
+	// Entries appended since the head was read keep it pending
+	_, err = t.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(t.tableName),
+		Key:                       keys.OutboxHead(owner).Item(),
+		UpdateExpression:          aws.String("REMOVE GS1PK, GS1SK"),
+		ConditionExpression:       aws.String("#sequence = :sequence"),
+		ExpressionAttributeNames:  map[string]string{"#sequence": "Sequence"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":sequence": number},
+	})
+	var failed *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &failed) {
+		return fmt.Errorf("failed to remove outbox head from the pending index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+// TermItem returns the DynamoDB item indexing a task under a term
+func TermItem(owner string, id uuid.UUID, term string, weight int) map[string]types.AttributeValue {
+	item := keys.SearchTerm(owner, term, id).Item()
+	item["Weight"] = &types.AttributeValueMemberN{Value: strconv.Itoa(weight)}
+	item["entity_type"] = &types.AttributeValueMemberS{Value: string(keys.EntitySearchTerm)}
+	return item
+}
+
+// DocItem returns the DynamoDB item recording the terms a task is indexed
+// under, so that they can be removed when the task changes
+func DocItem(owner string, id uuid.UUID, terms map[string]int) map[string]types.AttributeValue {
+	weights := make(map[string]types.AttributeValue, len(terms))
+	for term, weight := range terms {
+		weights[term] = &types.AttributeValueMemberN{Value: strconv.Itoa(weight)}
+	}
+
+	item := keys.SearchDoc(owner, id).Item()
+	item["Terms"] = &types.AttributeValueMemberM{Value: weights}
+	item["entity_type"] = &types.AttributeValueMemberS{Value: string(keys.EntitySearchDoc)}
+	return item
+}
+
+// DocTerms returns the terms recorded in a search document item
+func DocTerms(item map[string]types.AttributeValue) (map[string]int, error) {
+	weights, ok := item["Terms"].(*types.AttributeValueMemberM)
+	if !ok {
+		return nil, fmt.Errorf("search document has no terms")
+	}
+
+	terms := make(map[string]int, len(weights.Value))
+	for term, value := range weights.Value {
+		n, ok := value.(*types.AttributeValueMemberN)
+		if !ok {
+			return nil, fmt.Errorf("search document term %q has no weight", term)
+		}
+		weight, err := strconv.Atoi(n.Value)
+		if err != nil {
+			return nil, fmt.Errorf("search document term %q has an invalid weight: %w", term, err)
+		}
+		terms[term] = weight
+	}
+	return terms, nil
+}
+
+// PostingFromItem returns the posting of a search term item
+func PostingFromItem(item map[string]types.AttributeValue) (Posting, error) {
+	sk, _ := item["SK"].(*types.AttributeValueMemberS)
+	if sk == nil {
+		return Posting{}, fmt.Errorf("search term item has no sort key")
+	}
+	term, id, err := keys.ParseSearchTerm(sk.Value)
+	if err != nil {
+		return Posting{}, err
+	}
+
+	n, _ := item["Weight"].(*types.AttributeValueMemberN)
+	if n == nil {
+		return Posting{}, fmt.Errorf("search term item %q has no weight", sk.Value)
+	}
+	weight, err := strconv.Atoi(n.Value)
+	if err != nil {
+		return Posting{}, fmt.Errorf("search term item %q has an invalid weight: %w", sk.Value, err)
+	}
+	return Posting{ID: id, Term: term, Weight: weight}, nil
+}
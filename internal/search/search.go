@@ -0,0 +1,237 @@
+// Package search implements the full-text search over task titles and
+// descriptions: splitting text into stemmed English terms, parsing queries
+// and ranking matches. Every store indexes tasks under the terms returned by
+// Terms and ranks what its index returns with Rank, so all stores agree on
+// what a query matches.
+package search
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/kljensen/snowball/english"
+)
+
+const (
+	// TitleWeight is how many times more a term in a title counts than a
+	// term in a description
+	TitleWeight = 3
+	// DescriptionWeight is the weight of a term in a description
+	DescriptionWeight = 1
+	// MaxTermLength is the longest indexed term, in bytes; longer words are
+	// not indexed
+	MaxTermLength = 64
+	// MaxQueryTerms is the maximum number of terms in a query
+	MaxQueryTerms = 10
+	// MinPrefixLength is the shortest prefix a query can match, in characters
+	MinPrefixLength = 2
+)
+
+// bm25K1 controls how quickly repeating a term stops raising a task's score
+const bm25K1 = 1.2
+
+var (
+	// ErrEmptyQuery means a query has no terms that can be searched for, for
+	// example because it only contains stop words
+	ErrEmptyQuery = errors.New("query has no searchable terms")
+	// ErrTooManyTerms means a query has more than MaxQueryTerms terms
+	ErrTooManyTerms = fmt.Errorf("a query can have at most %d terms", MaxQueryTerms)
+)
+
+// QueryError reports a word of a query that cannot be searched for
+type QueryError struct {
+	Word   string
+	Reason string
+}
+
+// Error implements the error interface
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%q: %s", e.Word, e.Reason)
+}
+
+// Term is a term of a query. A prefix term matches every index term
+// starting with Text; other terms match Text exactly.
+type Term struct {
+	Text   string
+	Prefix bool
+}
+
+// Posting is an entry of the index: a task indexed under a term
+type Posting struct {
+	ID     uuid.UUID
+	Term   string
+	Weight int
+}
+
+// Result is a task matching a query, with its relevance score
+type Result struct {
+	ID    uuid.UUID
+	Score float64
+}
+
+// Analyze splits text into index terms: lower-case words of letters and
+// digits, without English stop words, reduced to their stems
+func Analyze(text string) []string {
+	var terms []string
+	for _, word := range words(text) {
+		if english.IsStopWord(word) {
+			continue
+		}
+		if term := english.Stem(word, false); term != "" && len(term) <= MaxTermLength {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// words splits text into lower-case words of letters and digits
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Terms returns the terms a task is indexed under, with their weights: the
+// number of times each occurs, counting title occurrences TitleWeight times
+func Terms(title, description string) map[string]int {
+	terms := make(map[string]int)
+	for _, term := range Analyze(title) {
+		terms[term] += TitleWeight
+	}
+	for _, term := range Analyze(description) {
+		terms[term] += DescriptionWeight
+	}
+	return terms
+}
+
+// ParseQuery parses a query of words separated by spaces. Words are
+// analyzed like indexed text; a word ending in "*" is a prefix, which is
+// lower-cased but not stemmed. Duplicate terms are dropped.
+func ParseQuery(query string) ([]Term, error) {
+	var terms []Term
+	seen := make(map[Term]bool)
+	add := func(term Term) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	for _, word := range strings.Fields(query) {
+		prefix, isPrefix := strings.CutSuffix(word, "*")
+		if !isPrefix {
+			for _, term := range Analyze(word) {
+				add(Term{Text: term})
+			}
+			continue
+		}
+
+		parts := words(prefix)
+		if len(parts) != 1 || utf8.RuneCountInString(parts[0]) < MinPrefixLength {
+			return nil, &QueryError{Word: word, Reason: fmt.Sprintf("a prefix must be a single word of at least %d characters", MinPrefixLength)}
+		}
+		add(Term{Text: parts[0], Prefix: true})
+	}
+
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if len(terms) > MaxQueryTerms {
+		return nil, ErrTooManyTerms
+	}
+	return terms, nil
+}
+
+// Matches reports whether an index term matches a query term
+func (t Term) Matches(term string) bool {
+	if t.Prefix {
+		return strings.HasPrefix(term, t.Text)
+	}
+	return term == t.Text
+}
+
+// Rank scores the tasks that match every query term, best match first.
+// postings[i] holds the postings matching the i-th query term, and total is
+// the number of indexed tasks. Each query term adds the BM25 score of the
+// best index term it matches in a task; ties are broken by ID, so the order
+// is stable across pages.
+func Rank(postings [][]Posting, total int) []Result {
+	if len(postings) == 0 {
+		return nil
+	}
+
+	// Count the tasks each index term occurs in. The same term can match
+	// several query terms, so count each task once.
+	tasks := make(map[string]map[uuid.UUID]bool)
+	for _, termPostings := range postings {
+		for _, p := range termPostings {
+			if tasks[p.Term] == nil {
+				tasks[p.Term] = make(map[uuid.UUID]bool)
+			}
+			tasks[p.Term][p.ID] = true
+		}
+	}
+
+	// Score each task by the best match of each query term
+	scores := make(map[uuid.UUID]float64)
+	matched := make(map[uuid.UUID]int)
+	for i, termPostings := range postings {
+		best := make(map[uuid.UUID]float64)
+		for _, p := range termPostings {
+			score := idf(len(tasks[p.Term]), total) * float64(p.Weight) * (bm25K1 + 1) / (float64(p.Weight) + bm25K1)
+			best[p.ID] = max(best[p.ID], score)
+		}
+		for id, score := range best {
+			if matched[id] == i {
+				scores[id] += score
+				matched[id]++
+			}
+		}
+	}
+
+	var results []Result
+	for id, score := range scores {
+		if matched[id] == len(postings) {
+			results = append(results, Result{ID: id, Score: score})
+		}
+	}
+	slices.SortFunc(results, func(a, b Result) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+	return results
+}
+
+// idf is the BM25 inverse document frequency of a term occurring in n of
+// total tasks. Rare terms score higher than common ones.
+func idf(n, total int) float64 {
+	return math.Log(1 + (float64(total)-float64(n)+0.5)/(float64(n)+0.5))
+}
+
+// Diff compares a task's old and new terms, returning the terms whose
+// items must be written because they are new or their weight changed, and
+// the terms whose items must be deleted
+func Diff(old, new map[string]int) (put, remove []string) {
+	for term, weight := range new {
+		if oldWeight, ok := old[term]; !ok || oldWeight != weight {
+			put = append(put, term)
+		}
+	}
+	for term := range old {
+		if _, ok := new[term]; !ok {
+			remove = append(remove, term)
+		}
+	}
+	slices.Sort(put)
+	slices.Sort(remove)
+	return put, remove
+}
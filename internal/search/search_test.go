@@ -0,0 +1,264 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var (
+	firstID  = uuid.MustParse("0b9d6b4e-3f5f-4f0e-9d6a-2f3c1b7e8a90")
+	secondID = uuid.MustParse("5c1a7f2e-8d4b-4c3a-9e6f-1b2d3c4e5f60")
+	thirdID  = uuid.MustParse("9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b")
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"stems words", "Running tests", []string{"run", "test"}},
+		{"drops stop words", "the report for the team", []string{"report", "team"}},
+		{"splits on punctuation", "e-mail: reply@team!", []string{"e", "mail", "repli", "team"}},
+		{"keeps digits", "Q3 2024 budget", []string{"q3", "2024", "budget"}},
+		{"lower-cases non-ASCII", "Über Café", []string{"über", "café"}},
+		{"drops long words", strings.Repeat("a", MaxTermLength+1), nil},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := Analyze(tt.text)
+
+			// Assert
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTerms(t *testing.T) {
+	// Act
+	terms := Terms("Fix login", "The login page fails to load")
+
+	// Assert
+	want := map[string]int{
+		"fix":   TitleWeight,
+		"login": TitleWeight + DescriptionWeight,
+		"page":  DescriptionWeight,
+		"fail":  DescriptionWeight,
+		"load":  DescriptionWeight,
+	}
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("Expected %v, got %v", want, terms)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []Term
+	}{
+		{"stems words", "failing logins", []Term{{Text: "fail"}, {Text: "login"}}},
+		{"prefix", "Rep*", []Term{{Text: "rep", Prefix: true}}},
+		{"mixed", "login fail*", []Term{{Text: "login"}, {Text: "fail", Prefix: true}}},
+		{"drops duplicates", "login Logins login*", []Term{{Text: "login"}, {Text: "login", Prefix: true}}},
+		{"drops stop words", "the login", []Term{{Text: "login"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			terms, err := ParseQuery(tt.query)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(terms, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, terms)
+			}
+		})
+	}
+}
+
+func TestParseQueryInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  error
+	}{
+		{"empty", "  ", ErrEmptyQuery},
+		{"only stop words", "the and of", ErrEmptyQuery},
+		{"too many terms", "a1 b2 c3 d4 e5 f6 g7 h8 i9 j10 k11", ErrTooManyTerms},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, err := ParseQuery(tt.query)
+
+			// Assert
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseQueryInvalidPrefix(t *testing.T) {
+	for _, query := range []string{"login r*", "*", "e-ma*"} {
+		t.Run(query, func(t *testing.T) {
+			// Act
+			_, err := ParseQuery(query)
+
+			// Assert
+			var queryErr *QueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("Expected a *QueryError, got %v", err)
+			}
+			if strings.Contains(query, " ") && queryErr.Word != "r*" {
+				t.Errorf("Expected word %q, got %q", "r*", queryErr.Word)
+			}
+		})
+	}
+}
+
+func TestTermMatches(t *testing.T) {
+	exact := Term{Text: "run"}
+	prefix := Term{Text: "ru", Prefix: true}
+
+	if !exact.Matches("run") || exact.Matches("runner") {
+		t.Errorf("Expected exact term to only match itself")
+	}
+	if !prefix.Matches("run") || !prefix.Matches("rust") || prefix.Matches("r") {
+		t.Errorf("Expected prefix term to match terms starting with it")
+	}
+}
+
+func TestRank(t *testing.T) {
+	// Arrange: the first task has "login" in its title, the second in its
+	// description, and the third does not match "fail"
+	postings := [][]Posting{
+		{
+			{ID: firstID, Term: "login", Weight: TitleWeight},
+			{ID: secondID, Term: "login", Weight: DescriptionWeight},
+			{ID: thirdID, Term: "login", Weight: TitleWeight},
+		},
+		{
+			{ID: firstID, Term: "fail", Weight: DescriptionWeight},
+			{ID: secondID, Term: "fail", Weight: DescriptionWeight},
+		},
+	}
+
+	// Act
+	results := Rank(postings, 10)
+
+	// Assert
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].ID != firstID || results[1].ID != secondID {
+		t.Errorf("Expected %s then %s, got %s then %s", firstID, secondID, results[0].ID, results[1].ID)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("Expected the title match to score higher, got %v and %v", results[0].Score, results[1].Score)
+	}
+}
+
+func TestRankPrefixTakesBestMatch(t *testing.T) {
+	// Arrange: "rep*" matches two terms of the first task, which only count
+	// once, with the score of the better match
+	postings := [][]Posting{{
+		{ID: firstID, Term: "report", Weight: DescriptionWeight},
+		{ID: firstID, Term: "repli", Weight: DescriptionWeight},
+		{ID: secondID, Term: "report", Weight: DescriptionWeight},
+	}}
+	best := [][]Posting{{
+		{ID: firstID, Term: "repli", Weight: DescriptionWeight},
+	}}
+
+	// Act
+	results := Rank(postings, 2)
+	bestResults := Rank(best, 2)
+
+	// Assert
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].ID != firstID {
+		t.Errorf("Expected %s first, got %s", firstID, results[0].ID)
+	}
+	if results[0].Score != bestResults[0].Score {
+		t.Errorf("Expected score %v, got %v", bestResults[0].Score, results[0].Score)
+	}
+}
+
+func TestRankRareTermsScoreHigher(t *testing.T) {
+	// Arrange: "deploy" occurs in one task and "bug" in all three
+	postings := [][]Posting{{
+		{ID: firstID, Term: "deploy", Weight: DescriptionWeight},
+	}}
+	common := [][]Posting{{
+		{ID: firstID, Term: "bug", Weight: DescriptionWeight},
+		{ID: secondID, Term: "bug", Weight: DescriptionWeight},
+		{ID: thirdID, Term: "bug", Weight: DescriptionWeight},
+	}}
+
+	// Act
+	rare := Rank(postings, 3)
+	frequent := Rank(common, 3)
+
+	// Assert
+	if rare[0].Score <= frequent[0].Score {
+		t.Errorf("Expected the rare term to score higher, got %v and %v", rare[0].Score, frequent[0].Score)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	// Arrange
+	old := map[string]int{"login": 3, "page": 1, "fail": 1}
+	updated := map[string]int{"login": 4, "page": 1, "load": 1}
+
+	// Act
+	put, remove := Diff(old, updated)
+
+	// Assert
+	if !reflect.DeepEqual(put, []string{"load", "login"}) {
+		t.Errorf("Expected put [load login], got %q", put)
+	}
+	if !reflect.DeepEqual(remove, []string{"fail"}) {
+		t.Errorf("Expected remove [fail], got %q", remove)
+	}
+}
+
+func TestItems(t *testing.T) {
+	// Arrange
+	terms := map[string]int{"login": 3, "page": 1}
+
+	// Act
+	doc, err := DocTerms(DocItem("test@example.com", firstID, terms))
+	posting, postingErr := PostingFromItem(TermItem("test@example.com", firstID, "login", 3))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(doc, terms) {
+		t.Errorf("Expected %v, got %v", terms, doc)
+	}
+	if postingErr != nil {
+		t.Fatalf("Expected no error, got %v", postingErr)
+	}
+	want := Posting{ID: firstID, Term: "login", Weight: 3}
+	if posting != want {
+		t.Errorf("Expected %+v, got %+v", want, posting)
+	}
+}
@@ -0,0 +1,102 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+// CountedAttribute is the attribute set on task items once they are counted
+// in their owner's counters item. Only counted tasks are taken out of the
+// counters when they change, so tasks written before counters existed are
+// counted exactly once, by the API or by the migration that backfills them.
+const CountedAttribute = "Counted"
+
+// RevisionAttribute is the number every write to a task item increments.
+// Writes that change counters are conditioned on the revision the task was
+// read at, so that counters are only ever changed from the state they were
+// computed from; items written before it was kept have no revision.
+const RevisionAttribute = "Revision"
+
+// Update returns the transaction item adding delta to an owner's counters
+// item, creating it if it does not exist
+func Update(tableName, owner string, delta Counters) *types.Update {
+	names := make([]string, 0, len(delta))
+	for name := range delta {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	expression := "SET entity_type = :entity ADD "
+	attributeNames := make(map[string]string, len(names))
+	values := map[string]types.AttributeValue{
+		":entity": &types.AttributeValueMemberS{Value: string(keys.EntityStats)},
+	}
+	for i, name := range names {
+		if i > 0 {
+			expression += ", "
+		}
+		placeholder := strconv.Itoa(i)
+		expression += "#c" + placeholder + " :c" + placeholder
+		attributeNames["#c"+placeholder] = name
+		values[":c"+placeholder] = &types.AttributeValueMemberN{Value: strconv.FormatInt(delta[name], 10)}
+	}
+
+	return &types.Update{
+		TableName:                 aws.String(tableName),
+		Key:                       keys.Stats(owner).Item(),
+		UpdateExpression:          aws.String(expression),
+		ExpressionAttributeNames:  attributeNames,
+		ExpressionAttributeValues: values,
+	}
+}
+
+// FromItem returns the counters of a counters item
+func FromItem(item map[string]types.AttributeValue) (Counters, error) {
+	counters := make(Counters, len(item))
+	for name, value := range item {
+		if name == "PK" || name == "SK" || name == "entity_type" {
+			continue
+		}
+		n, ok := value.(*types.AttributeValueMemberN)
+		if !ok {
+			return nil, fmt.Errorf("counter %q is not a number", name)
+		}
+		v, err := strconv.ParseInt(n.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("counter %q has an invalid value: %w", name, err)
+		}
+		counters[name] = v
+	}
+	return counters, nil
+}
+
+// TaskFromItem returns the counted state of a task item
+func TaskFromItem(item map[string]types.AttributeValue) Task {
+	task := Task{
+		Status:   stringAttribute(item, "Status"),
+		Priority: stringAttribute(item, "Priority"),
+		Created:  ParseTime(stringAttribute(item, "Created")),
+		Closed:   ParseTime(stringAttribute(item, "Closed")),
+	}
+	if labels, ok := item["Labels"].(*types.AttributeValueMemberL); ok {
+		for _, value := range labels.Value {
+			if label, ok := value.(*types.AttributeValueMemberS); ok {
+				task.Labels = append(task.Labels, label.Value)
+			}
+		}
+	}
+	return task
+}
+
+// stringAttribute returns a string attribute of an item, or "" if it is missing
+func stringAttribute(item map[string]types.AttributeValue, name string) string {
+	if s, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+	return ""
+}
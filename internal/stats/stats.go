@@ -0,0 +1,156 @@
+// Package stats keeps the counters that summarize an owner's tasks, so that
+// counts and throughput can be reported without reading every task. Stores
+// apply the Delta between a task's stored and written states to its owner's
+// counters in the same transaction as the task itself, and all stores
+// derive the counters the same way from Count.
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// StatusPrefix starts the counters of tasks by status
+	StatusPrefix = "status#"
+	// PriorityPrefix starts the counters of tasks by priority
+	PriorityPrefix = "priority#"
+	// LabelPrefix starts the counters of tasks by label
+	LabelPrefix = "label#"
+	// ClosedWeekPrefix starts the counters of tasks by the ISO week they
+	// were closed in
+	ClosedWeekPrefix = "closed#"
+	// CloseSeconds is the total time from creation to closing of the closed
+	// tasks with a known creation time, in seconds
+	CloseSeconds = "closeSeconds"
+	// TimedCloses is the number of closed tasks counted in CloseSeconds
+	TimedCloses = "timedCloses"
+
+	// closedStatus is the status of closed tasks
+	closedStatus = "CLOSED"
+)
+
+// TimeFormat is the format of the creation and closing times stores keep
+// with tasks
+const TimeFormat = time.RFC3339
+
+// Task is the part of a task that its owner's counters count
+type Task struct {
+	Status   string
+	Priority string
+	Labels   []string
+	// Created is when the task was created; zero for tasks written before
+	// creation times were kept
+	Created time.Time
+	// Closed is when the task was closed; zero unless it is closed, and for
+	// tasks closed before closing times were kept
+	Closed time.Time
+}
+
+// Counters maps counter names to their values
+type Counters map[string]int64
+
+// Week returns the ISO 8601 week of a time in UTC, such as 2026-W42
+func Week(t time.Time) string {
+	year, week := t.UTC().ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// Count returns the counters a task adds to its owner's
+func Count(task Task) Counters {
+	counters := Counters{StatusPrefix + task.Status: 1}
+	if task.Priority != "" {
+		counters[PriorityPrefix+task.Priority] = 1
+	}
+	for _, label := range task.Labels {
+		counters[LabelPrefix+label]++
+	}
+
+	if task.Status == closedStatus && !task.Closed.IsZero() {
+		counters[ClosedWeekPrefix+Week(task.Closed)] = 1
+		if !task.Created.IsZero() && !task.Closed.Before(task.Created) {
+			counters[CloseSeconds] = int64(task.Closed.Sub(task.Created) / time.Second)
+			counters[TimedCloses] = 1
+		}
+	}
+
+	return counters
+}
+
+// Delta returns the change to an owner's counters when a task's state goes
+// from old to new, where nil is a task that does not exist or is not
+// counted. Counters that do not change are left out.
+func Delta(old, new *Task) Counters {
+	delta := Counters{}
+	if new != nil {
+		delta.Add(Count(*new), 1)
+	}
+	if old != nil {
+		delta.Add(Count(*old), -1)
+	}
+	for name, value := range delta {
+		if value == 0 {
+			delete(delta, name)
+		}
+	}
+	return delta
+}
+
+// Add adds other times sign to the counters
+func (c Counters) Add(other Counters, sign int64) {
+	for name, value := range other {
+		c[name] += value * sign
+	}
+}
+
+// Group returns the non-zero counters whose names start with prefix, keyed
+// by the rest of their names
+func (c Counters) Group(prefix string) map[string]int64 {
+	group := make(map[string]int64)
+	for name, value := range c {
+		if key, ok := strings.CutPrefix(name, prefix); ok && value != 0 {
+			group[key] = value
+		}
+	}
+	return group
+}
+
+// Stamp sets the creation and closing times of a task being written over
+// its stored state, which is nil for a new task. A new task is created now,
+// and a closed task was closed now unless it was closed already.
+func Stamp(stored *Task, task Task, now time.Time) Task {
+	now = now.UTC().Truncate(time.Second)
+
+	task.Created, task.Closed = now, time.Time{}
+	if stored != nil {
+		task.Created = stored.Created
+	}
+	if task.Status == closedStatus {
+		task.Closed = now
+		if stored != nil && stored.Status == closedStatus {
+			task.Closed = stored.Closed
+		}
+	}
+
+	return task
+}
+
+// FormatTime formats a creation or closing time as stores keep it, or as
+// "" if it is zero
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(TimeFormat)
+}
+
+// ParseTime parses a creation or closing time kept by a store, returning
+// the zero time if it is empty or invalid
+func ParseTime(value string) time.Time {
+	t, err := time.Parse(TimeFormat, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
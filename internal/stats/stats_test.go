@@ -0,0 +1,243 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	created = time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	closed  = time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+)
+
+func TestWeek(t *testing.T) {
+	tests := []struct {
+		time time.Time
+		want string
+	}{
+		{time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC), "2026-W42"},
+		{time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), "2026-W43"},
+		{time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), "2026-W53"},
+		{time.Date(2026, 10, 19, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60)), "2026-W42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.time.String(), func(t *testing.T) {
+			// Act
+			got := Week(tt.time)
+
+			// Assert
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name string
+		task Task
+		want Counters
+	}{
+		{
+			name: "open",
+			task: Task{Status: "OPEN", Priority: "HIGH", Labels: []string{"bug", "ui"}, Created: created},
+			want: Counters{"status#OPEN": 1, "priority#HIGH": 1, "label#bug": 1, "label#ui": 1},
+		},
+		{
+			name: "closed",
+			task: Task{Status: "CLOSED", Created: created, Closed: closed},
+			want: Counters{"status#CLOSED": 1, "closed#2026-W42": 1, "closeSeconds": 2 * 24 * 60 * 60, "timedCloses": 1},
+		},
+		{
+			name: "closed without a creation time",
+			task: Task{Status: "CLOSED", Closed: closed},
+			want: Counters{"status#CLOSED": 1, "closed#2026-W42": 1},
+		},
+		{
+			name: "closed without a closing time",
+			task: Task{Status: "CLOSED", Created: created},
+			want: Counters{"status#CLOSED": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := Count(tt.task)
+
+			// Assert
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDelta(t *testing.T) {
+	// Arrange
+	open := Task{Status: "OPEN", Priority: "HIGH", Labels: []string{"bug"}, Created: created}
+	closedTask := Stamp(&open, Task{Status: "CLOSED", Priority: "HIGH", Labels: []string{"bug", "ui"}}, closed)
+
+	tests := []struct {
+		name     string
+		old, new *Task
+		want     Counters
+	}{
+		{"create", nil, &open, Counters{"status#OPEN": 1, "priority#HIGH": 1, "label#bug": 1}},
+		{"delete", &open, nil, Counters{"status#OPEN": -1, "priority#HIGH": -1, "label#bug": -1}},
+		{"unchanged", &open, &open, Counters{}},
+		{"close", &open, &closedTask, Counters{
+			"status#OPEN": -1, "status#CLOSED": 1, "label#ui": 1,
+			"closed#2026-W42": 1, "closeSeconds": 2 * 24 * 60 * 60, "timedCloses": 1,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := Delta(tt.old, tt.new)
+
+			// Assert
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStamp(t *testing.T) {
+	// Arrange
+	now := closed.Add(1500 * time.Millisecond)
+	open := Task{Status: "OPEN", Created: created}
+	closedTask := Task{Status: "CLOSED", Created: created, Closed: closed}
+
+	tests := []struct {
+		name    string
+		stored  *Task
+		status  string
+		created time.Time
+		closed  time.Time
+	}{
+		{"new open task", nil, "OPEN", closed.Add(time.Second), time.Time{}},
+		{"new closed task", nil, "CLOSED", closed.Add(time.Second), closed.Add(time.Second)},
+		{"closing", &open, "CLOSED", created, closed.Add(time.Second)},
+		{"staying closed", &closedTask, "CLOSED", created, closed},
+		{"reopening", &closedTask, "OPEN", created, time.Time{}},
+		{"without a creation time", &Task{Status: "OPEN"}, "OPEN", time.Time{}, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := Stamp(tt.stored, Task{Status: tt.status}, now)
+
+			// Assert
+			if !got.Created.Equal(tt.created) || !got.Closed.Equal(tt.closed) {
+				t.Errorf("Expected %v and %v, got %v and %v", tt.created, tt.closed, got.Created, got.Closed)
+			}
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	// Arrange
+	counters := Counters{"label#bug": 2, "label#ui": 0, "status#OPEN": 1, "labels": 3}
+
+	// Act
+	got := counters.Group(LabelPrefix)
+
+	// Assert
+	if want := map[string]int64{"bug": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestTimeRoundTrip(t *testing.T) {
+	// Act
+	formatted := FormatTime(created)
+
+	// Assert
+	if formatted != "2026-10-12T09:00:00Z" {
+		t.Errorf("Expected 2026-10-12T09:00:00Z, got %s", formatted)
+	}
+	if got := ParseTime(formatted); !got.Equal(created) {
+		t.Errorf("Expected %v, got %v", created, got)
+	}
+	if FormatTime(time.Time{}) != "" || !ParseTime("").IsZero() || !ParseTime("yesterday").IsZero() {
+		t.Error("Expected zero times to be stored as empty strings")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	// Act
+	update := Update("tasks", "test@example.com", Counters{"status#OPEN": -1, "status#CLOSED": 1})
+
+	// Assert
+	if got := aws.ToString(update.UpdateExpression); got != "SET entity_type = :entity ADD #c0 :c0, #c1 :c1" {
+		t.Errorf("Unexpected update expression %q", got)
+	}
+	if update.ExpressionAttributeNames["#c0"] != "status#CLOSED" || update.ExpressionAttributeNames["#c1"] != "status#OPEN" {
+		t.Errorf("Expected the counters in name order, got %v", update.ExpressionAttributeNames)
+	}
+	if n, _ := update.ExpressionAttributeValues[":c1"].(*types.AttributeValueMemberN); n == nil || n.Value != "-1" {
+		t.Errorf("Expected -1 for status#OPEN, got %v", update.ExpressionAttributeValues[":c1"])
+	}
+	if pk, _ := update.Key["SK"].(*types.AttributeValueMemberS); pk == nil || pk.Value != "STATS" {
+		t.Errorf("Expected the counters item, got %v", update.Key)
+	}
+}
+
+func TestFromItem(t *testing.T) {
+	// Arrange
+	item := map[string]types.AttributeValue{
+		"PK":            &types.AttributeValueMemberS{Value: "USER#test@example.com"},
+		"SK":            &types.AttributeValueMemberS{Value: "STATS"},
+		"entity_type":   &types.AttributeValueMemberS{Value: "STATS"},
+		"status#OPEN":   &types.AttributeValueMemberN{Value: "12"},
+		"status#CLOSED": &types.AttributeValueMemberN{Value: "40"},
+	}
+
+	// Act
+	counters, err := FromItem(item)
+	item["label#bug"] = &types.AttributeValueMemberS{Value: "1"}
+	_, invalidErr := FromItem(item)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if want := (Counters{"status#OPEN": 12, "status#CLOSED": 40}); !reflect.DeepEqual(counters, want) {
+		t.Errorf("Expected %v, got %v", want, counters)
+	}
+	if invalidErr == nil {
+		t.Error("Expected an error for a counter that is not a number")
+	}
+}
+
+func TestTaskFromItem(t *testing.T) {
+	// Arrange
+	item := map[string]types.AttributeValue{
+		"Status":   &types.AttributeValueMemberS{Value: "CLOSED"},
+		"Priority": &types.AttributeValueMemberS{Value: "LOW"},
+		"Labels": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberS{Value: "bug"},
+		}},
+		"Created": &types.AttributeValueMemberS{Value: "2026-10-12T09:00:00Z"},
+		"Closed":  &types.AttributeValueMemberS{Value: "2026-10-14T09:00:00Z"},
+	}
+
+	// Act
+	task := TaskFromItem(item)
+
+	// Assert
+	want := Task{Status: "CLOSED", Priority: "LOW", Labels: []string{"bug"}, Created: created, Closed: closed}
+	if !reflect.DeepEqual(task, want) {
+		t.Errorf("Expected %+v, got %+v", want, task)
+	}
+}
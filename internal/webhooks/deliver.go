@@ -0,0 +1,205 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// EventHeader holds the type of the event delivered
+	EventHeader = "X-Webhook-Event"
+	// EventIDHeader holds the ID of the event delivered, which is the same
+	// each time it is delivered
+	EventIDHeader = "X-Webhook-Event-Id"
+	// DeliveryHeader holds the ID of the delivery, which is the same for
+	// each attempt
+	DeliveryHeader = "X-Webhook-Delivery"
+
+	// DefaultMaxAttempts is the number of attempts before a delivery is
+	// dead-lettered
+	DefaultMaxAttempts = 5
+	// DefaultBaseDelay is the wait before the second attempt, doubled
+	// before each later one
+	DefaultBaseDelay = time.Minute
+	// DefaultMaxDelay is the longest wait between attempts
+	DefaultMaxDelay = time.Hour
+	// DefaultTimeout is the time a receiver has to respond to an attempt
+	DefaultTimeout = 5 * time.Second
+	// DefaultBatchSize is the number of due deliveries attempted by each
+	// run of DeliverDue
+	DefaultBatchSize = 50
+
+	// claimDuration is how long a delivery being attempted is held back
+	// from other runs, so that a run that stops during an attempt leaves
+	// the delivery due again afterwards
+	claimDuration = time.Minute
+
+	// maxResponseBytes is the most of a response body read, so that the
+	// connection can be reused
+	maxResponseBytes = 64 << 10
+)
+
+// Deliverer attempts the pending deliveries that are due
+type Deliverer struct {
+	store Store
+	// Client makes the attempts; NewClient's only connects to public
+	// addresses
+	Client *http.Client
+	// MaxAttempts is the number of attempts before giving up
+	MaxAttempts int
+	// BaseDelay is the wait before the second attempt, doubled before each
+	// later one up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// BatchSize is the number of due deliveries attempted by each run
+	BatchSize int
+	// Now returns the time deliveries are signed and recorded at
+	Now func() time.Time
+}
+
+// NewDeliverer creates a deliverer of the deliveries in store, with the
+// default attempts and delays
+func NewDeliverer(store Store) *Deliverer {
+	return &Deliverer{
+		store:       store,
+		Client:      NewClient(DefaultTimeout),
+		MaxAttempts: DefaultMaxAttempts,
+		BaseDelay:   DefaultBaseDelay,
+		MaxDelay:    DefaultMaxDelay,
+		BatchSize:   DefaultBatchSize,
+		Now:         time.Now,
+	}
+}
+
+// Backoff returns the wait after a failed attempt, numbered from 1
+func (d *Deliverer) Backoff(attempt int) time.Duration {
+	delay := d.BaseDelay
+	for i := 1; i < attempt && delay < d.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.MaxDelay)
+}
+
+// DeliverDue makes the next attempt of each pending delivery that is due,
+// one after another. A delivery that fails to be attempted or recorded
+// does not hold back the others; the errors of all such deliveries are
+// returned together.
+func (d *Deliverer) DeliverDue(ctx context.Context) error {
+	deliveries, err := d.store.DueDeliveries(ctx, d.Now().UTC(), d.BatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to list due deliveries: %w", err)
+	}
+
+	var errs []error
+	for _, delivery := range deliveries {
+		if err := d.deliver(ctx, delivery); err != nil {
+			errs = append(errs, fmt.Errorf("failed to deliver %s to webhook %s: %w", delivery.ID, delivery.WebhookID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver claims a due delivery, so that no other run attempts it at the
+// same time, then makes its next attempt and records the outcome. A
+// delivery that changed since it was listed was claimed by another run, or
+// already attempted, and is skipped.
+func (d *Deliverer) deliver(ctx context.Context, delivery Delivery) error {
+	claimed := delivery
+	claimed.NextAttempt = d.Now().UTC().Add(claimDuration)
+	err := d.store.UpdateDelivery(ctx, claimed, delivery)
+	if errors.Is(err, ErrDeliveryChanged) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	subscription, err := d.store.GetWebhook(ctx, delivery.Owner, delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+	var attempted Delivery
+	if subscription == nil {
+		// The webhook was deleted after the delivery was listed
+		attempted = d.giveUp(claimed, Attempt{Time: d.Now().UTC(), Error: "webhook no longer exists"})
+	} else {
+		attempted = d.Attempt(ctx, *subscription, claimed)
+	}
+
+	if err := d.store.UpdateDelivery(ctx, attempted, claimed); err != nil && !errors.Is(err, ErrDeliveryChanged) {
+		return err
+	}
+	return nil
+}
+
+// Attempt posts a pending delivery's event to its subscription and returns
+// the delivery with the attempt added. A delivery the receiver accepts is
+// delivered. A failure that may pass, which is a network error, a timeout,
+// or a 408, 429 or 5xx response, leaves it pending until after a backoff,
+// unless it has had MaxAttempts; any other failure, including a redirect or
+// a host that resolves to an address that is not public, dead-letters it.
+func (d *Deliverer) Attempt(ctx context.Context, subscription Subscription, delivery Delivery) Delivery {
+	attempt, retryable := d.attempt(ctx, subscription, delivery.ID, delivery.event())
+	switch {
+	case attempt.Error == "":
+		delivery.Attempts = append(delivery.Attempts, attempt)
+		delivery.Status, delivery.Payload, delivery.NextAttempt = StatusDelivered, nil, time.Time{}
+		return delivery
+	case retryable && len(delivery.Attempts)+1 < d.MaxAttempts:
+		delivery.Attempts = append(delivery.Attempts, attempt)
+		delivery.NextAttempt = attempt.Time.Add(d.Backoff(len(delivery.Attempts)))
+		return delivery
+	default:
+		return d.giveUp(delivery, attempt)
+	}
+}
+
+// giveUp returns a delivery with its last attempt, dead-lettered
+func (d *Deliverer) giveUp(delivery Delivery, attempt Attempt) Delivery {
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.Status, delivery.NextAttempt = StatusDeadLetter, time.Time{}
+	return delivery
+}
+
+// attempt makes one request of a delivery, reporting whether it may be
+// worth retrying if it failed
+func (d *Deliverer) attempt(ctx context.Context, subscription Subscription, deliveryID uuid.UUID, event Event) (Attempt, bool) {
+	now := d.Now().UTC()
+	attempt := Attempt{Time: now}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(event.Payload))
+	if err != nil {
+		attempt.Error = fmt.Sprintf("invalid request: %v", err)
+		return attempt, false
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, event.Type)
+	request.Header.Set(EventIDHeader, event.ID)
+	request.Header.Set(DeliveryHeader, deliveryID.String())
+	request.Header.Set(SignatureHeader, Sign(subscription.Secret, now, event.Payload))
+
+	response, err := d.Client.Do(request)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, ctx.Err() == nil && !errors.Is(err, ErrForbiddenAddress)
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseBytes))
+	response.Body.Close()
+
+	attempt.StatusCode = response.StatusCode
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return attempt, true
+	}
+	attempt.Error = fmt.Sprintf("receiver responded %s", response.Status)
+	retryable := response.StatusCode == http.StatusRequestTimeout ||
+		response.StatusCode == http.StatusTooManyRequests ||
+		response.StatusCode >= 500
+	return attempt, retryable
+}
@@ -0,0 +1,154 @@
+package webhooks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+// subscriptionItem is a subscription as stored in DynamoDB
+type subscriptionItem struct {
+	PK         string
+	SK         string
+	ID         string
+	Owner      string
+	URL        string
+	Events     []string `dynamodbav:",omitempty"`
+	Secret     string
+	Created    time.Time
+	EntityType keys.EntityType `dynamodbav:"entity_type"`
+}
+
+// deliveryItem is a delivery as stored in DynamoDB
+type deliveryItem struct {
+	PK        string
+	SK        string
+	ID        string
+	WebhookID string
+	Owner     string
+	EventID   string
+	EventType string
+	Status    DeliveryStatus
+	Attempts  []Attempt `dynamodbav:",omitempty"`
+	// Payload is only stored on pending deliveries and dead letters
+	Payload string `dynamodbav:",omitempty"`
+	// NextAttempt, GS1PK and GS1SK are only stored on pending deliveries,
+	// which GS1 lists by when they are next attempted
+	NextAttempt string `dynamodbav:",omitempty"`
+	GS1PK       string `dynamodbav:",omitempty"`
+	GS1SK       string `dynamodbav:",omitempty"`
+	Created     time.Time
+	EntityType  keys.EntityType `dynamodbav:"entity_type"`
+}
+
+// nextAttemptFormat is the format of the next attempt times of pending
+// deliveries, which conditions compare exactly
+const nextAttemptFormat = time.RFC3339Nano
+
+// SubscriptionItem returns the DynamoDB item of a subscription
+func SubscriptionItem(subscription Subscription) (map[string]types.AttributeValue, error) {
+	key := keys.Webhook(subscription.Owner, subscription.ID)
+	item, err := attributevalue.MarshalMap(subscriptionItem{
+		PK:         key.PK,
+		SK:         key.SK,
+		ID:         subscription.ID.String(),
+		Owner:      subscription.Owner,
+		URL:        subscription.URL,
+		Events:     subscription.Events,
+		Secret:     subscription.Secret,
+		Created:    subscription.Created.UTC(),
+		EntityType: keys.EntityWebhook,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook: %w", err)
+	}
+	return item, nil
+}
+
+// SubscriptionFromItem returns the subscription of a DynamoDB item
+func SubscriptionFromItem(item map[string]types.AttributeValue) (Subscription, error) {
+	var stored subscriptionItem
+	if err := attributevalue.UnmarshalMap(item, &stored); err != nil {
+		return Subscription{}, fmt.Errorf("failed to unmarshal webhook: %w", err)
+	}
+	id, err := uuid.Parse(stored.ID)
+	if err != nil {
+		return Subscription{}, fmt.Errorf("webhook item has an invalid ID: %w", err)
+	}
+	return Subscription{
+		ID:      id,
+		Owner:   stored.Owner,
+		URL:     stored.URL,
+		Events:  stored.Events,
+		Secret:  stored.Secret,
+		Created: stored.Created,
+	}, nil
+}
+
+// DeliveryItem returns the DynamoDB item of a delivery
+func DeliveryItem(delivery Delivery) (map[string]types.AttributeValue, error) {
+	key := keys.Delivery(delivery.Owner, delivery.WebhookID, delivery.Created, delivery.ID)
+	stored := deliveryItem{
+		PK:         key.PK,
+		SK:         key.SK,
+		ID:         delivery.ID.String(),
+		WebhookID:  delivery.WebhookID.String(),
+		Owner:      delivery.Owner,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Status:     delivery.Status,
+		Attempts:   delivery.Attempts,
+		Payload:    string(delivery.Payload),
+		Created:    delivery.Created.UTC(),
+		EntityType: keys.EntityDelivery,
+	}
+	if delivery.Status == StatusPending {
+		stored.NextAttempt = delivery.NextAttempt.UTC().Format(nextAttemptFormat)
+		stored.GS1PK = keys.PendingDeliveries(delivery.Owner)
+		stored.GS1SK = keys.PendingDeliverySK(delivery.NextAttempt, delivery.ID)
+	}
+	item, err := attributevalue.MarshalMap(stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal delivery: %w", err)
+	}
+	return item, nil
+}
+
+// DeliveryFromItem returns the delivery of a DynamoDB item
+func DeliveryFromItem(item map[string]types.AttributeValue) (Delivery, error) {
+	var stored deliveryItem
+	if err := attributevalue.UnmarshalMap(item, &stored); err != nil {
+		return Delivery{}, fmt.Errorf("failed to unmarshal delivery: %w", err)
+	}
+	id, err := uuid.Parse(stored.ID)
+	if err != nil {
+		return Delivery{}, fmt.Errorf("delivery item has an invalid ID: %w", err)
+	}
+	webhookID, err := uuid.Parse(stored.WebhookID)
+	if err != nil {
+		return Delivery{}, fmt.Errorf("delivery item has an invalid webhook ID: %w", err)
+	}
+	delivery := Delivery{
+		ID:        id,
+		WebhookID: webhookID,
+		Owner:     stored.Owner,
+		EventID:   stored.EventID,
+		EventType: stored.EventType,
+		Status:    stored.Status,
+		Attempts:  stored.Attempts,
+		Created:   stored.Created,
+	}
+	if stored.Payload != "" {
+		delivery.Payload = []byte(stored.Payload)
+	}
+	if stored.NextAttempt != "" {
+		if delivery.NextAttempt, err = time.Parse(nextAttemptFormat, stored.NextAttempt); err != nil {
+			return Delivery{}, fmt.Errorf("delivery item has an invalid next attempt: %w", err)
+		}
+	}
+	return delivery, nil
+}
@@ -0,0 +1,190 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+// Table is the Store of the subscriptions and deliveries in the tasks
+// table, in the items the API writes
+type Table struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+var _ Store = (*Table)(nil)
+
+// NewTable creates a store of the subscriptions and deliveries in a tasks table
+func NewTable(client *dynamodb.Client, tableName string) *Table {
+	return &Table{client: client, tableName: tableName}
+}
+
+// ListWebhooks lists an owner's subscriptions
+func (t *Table) ListWebhooks(ctx context.Context, owner string) ([]Subscription, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(t.tableName),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: keys.User(owner)},
+			":prefix": &types.AttributeValueMemberS{Value: keys.WebhookPrefix},
+		},
+	}
+
+	var subscriptions []Subscription
+	for {
+		result, err := t.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query webhooks: %w", err)
+		}
+		for _, item := range result.Items {
+			subscription, err := SubscriptionFromItem(item)
+			if err != nil {
+				return nil, err
+			}
+			subscriptions = append(subscriptions, subscription)
+		}
+
+		if result.LastEvaluatedKey == nil {
+			return subscriptions, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// GetWebhook gets a subscription with a strongly consistent read, returning
+// nil if it does not exist
+func (t *Table) GetWebhook(ctx context.Context, owner string, id uuid.UUID) (*Subscription, error) {
+	result, err := t.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(t.tableName),
+		Key:            keys.Webhook(owner, id).Item(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook from DynamoDB: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	subscription, err := SubscriptionFromItem(result.Item)
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// AddDelivery records a delivery
+func (t *Table) AddDelivery(ctx context.Context, delivery Delivery) error {
+	item, err := DeliveryItem(delivery)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(t.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put delivery in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+// DueDeliveries lists the pending deliveries next attempted at or before
+// now from each shard of the pending index in turn, earliest first within
+// a shard. It starts from a random shard, so that more than limit due
+// deliveries in one shard do not hold up the other shards run after run.
+// The index is eventually consistent, so it may list a delivery that was
+// just attempted, which UpdateDelivery then finds changed.
+func (t *Table) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	return dueDeliveries(rand.IntN(keys.PendingShards), limit, func(shard, limit int) ([]Delivery, error) {
+		return t.dueInShard(ctx, shard, now, limit)
+	})
+}
+
+// dueDeliveries lists up to limit deliveries from each shard in turn,
+// starting from shard start and wrapping around
+func dueDeliveries(start, limit int, due func(shard, limit int) ([]Delivery, error)) ([]Delivery, error) {
+	var deliveries []Delivery
+	for i := 0; i < keys.PendingShards && len(deliveries) < limit; i++ {
+		shardDeliveries, err := due((start+i)%keys.PendingShards, limit-len(deliveries))
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, shardDeliveries...)
+	}
+	return deliveries, nil
+}
+
+// dueInShard lists up to limit of the pending deliveries in a shard next
+// attempted at or before now, earliest first
+func (t *Table) dueInShard(ctx context.Context, shard int, now time.Time, limit int) ([]Delivery, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(t.tableName),
+		IndexName:              aws.String("GS1"),
+		KeyConditionExpression: aws.String("GS1PK = :pending AND GS1SK < :due"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: keys.PendingDeliveriesShard(shard)},
+			":due":     &types.AttributeValueMemberS{Value: keys.DeliveriesDueSK(now)},
+		},
+	}
+
+	var deliveries []Delivery
+	for len(deliveries) < limit {
+		input.Limit = aws.Int32(int32(limit - len(deliveries)))
+		result, err := t.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pending deliveries: %w", err)
+		}
+		for _, item := range result.Items {
+			delivery, err := DeliveryFromItem(item)
+			if err != nil {
+				return nil, err
+			}
+			deliveries = append(deliveries, delivery)
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery puts a delivery over previous, on the condition that the
+// stored delivery is still pending with the next attempt previous has
+func (t *Table) UpdateDelivery(ctx context.Context, delivery, previous Delivery) error {
+	item, err := DeliveryItem(delivery)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(t.tableName),
+		Item:                     item,
+		ConditionExpression:      aws.String("#status = :pending AND #next = :next"),
+		ExpressionAttributeNames: map[string]string{"#status": "Status", "#next": "NextAttempt"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: string(StatusPending)},
+			":next":    &types.AttributeValueMemberS{Value: previous.NextAttempt.UTC().Format(nextAttemptFormat)},
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrDeliveryChanged
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update delivery in DynamoDB: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Package webhooks delivers task events to the URLs owners subscribe to.
+// The Dispatcher records a pending delivery of each event to each matching
+// subscription, and the Deliverer later attempts the deliveries that are
+// due, signed with the subscription's secret. A failed attempt is retried
+// with exponential backoff while the receiver is unavailable; deliveries
+// that never succeed are recorded as dead letters with their payload.
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The event types webhooks can subscribe to
+const (
+	EventTaskCreated = "TaskCreated"
+	EventTaskUpdated = "TaskUpdated"
+	EventTaskClosed  = "TaskClosed"
+	EventTaskDeleted = "TaskDeleted"
+)
+
+// EventTypes are the event types webhooks can subscribe to
+var EventTypes = []string{EventTaskCreated, EventTaskUpdated, EventTaskClosed, EventTaskDeleted}
+
+// secretPrefix starts every webhook secret, so leaked secrets are easy to
+// recognize
+const secretPrefix = "whsec_"
+
+// Subscription is a URL that an owner's task events are delivered to
+type Subscription struct {
+	ID    uuid.UUID `json:"id"`
+	Owner string    `json:"owner"`
+	URL   string    `json:"url"`
+	// Events are the event types delivered; empty means every type
+	Events []string `json:"events"`
+	// Secret signs deliveries; it is only returned when the subscription is
+	// created
+	Secret  string    `json:"-"`
+	Created time.Time `json:"created"`
+}
+
+// Matches reports whether the subscription receives events of a type
+func (s Subscription) Matches(eventType string) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, eventType)
+}
+
+// NewSecret generates a secret for signing a subscription's deliveries
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
+
+// DeliveryStatus is the outcome of a delivery
+type DeliveryStatus string
+
+const (
+	// StatusPending is the status of deliveries still to be attempted
+	StatusPending DeliveryStatus = "PENDING"
+	// StatusDelivered is the status of deliveries the receiver accepted
+	StatusDelivered DeliveryStatus = "DELIVERED"
+	// StatusDeadLetter is the status of deliveries given up on
+	StatusDeadLetter DeliveryStatus = "DEAD_LETTER"
+)
+
+// Attempt is one request of a delivery
+type Attempt struct {
+	Time time.Time `json:"time"`
+	// StatusCode is the receiver's response status, or zero if there was no
+	// response
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Delivery is the record of delivering an event to a subscription
+type Delivery struct {
+	ID        uuid.UUID      `json:"id"`
+	WebhookID uuid.UUID      `json:"webhookId"`
+	Owner     string         `json:"owner"`
+	EventID   string         `json:"eventId"`
+	EventType string         `json:"eventType"`
+	Status    DeliveryStatus `json:"status"`
+	Attempts  []Attempt      `json:"attempts"`
+	// Payload is kept on pending deliveries, to attempt them, and on dead
+	// letters, so they can be inspected and sent again
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// NextAttempt is when a pending delivery is next attempted
+	NextAttempt time.Time `json:"nextAttempt,omitzero"`
+	// Created is when the delivery was queued
+	Created time.Time `json:"created"`
+}
+
+// event returns the event a delivery delivers
+func (d Delivery) event() Event {
+	return Event{ID: d.EventID, Type: d.EventType, Owner: d.Owner, Payload: d.Payload}
+}
+
+// Event is a task event to deliver
+type Event struct {
+	// ID is the same each time an event is delivered, so receivers can drop
+	// duplicates
+	ID    string
+	Type  string
+	Owner string
+	// Payload is the JSON body delivered
+	Payload []byte
+}
+
+// ErrDeliveryChanged is returned when a delivery is not updated because it
+// changed since it was read
+var ErrDeliveryChanged = errors.New("delivery changed since it was read")
+
+// Store lists subscriptions and records deliveries
+type Store interface {
+	// ListWebhooks lists an owner's subscriptions
+	ListWebhooks(ctx context.Context, owner string) ([]Subscription, error)
+	// GetWebhook gets a subscription, returning nil if it does not exist
+	GetWebhook(ctx context.Context, owner string, id uuid.UUID) (*Subscription, error)
+	// AddDelivery records a delivery
+	AddDelivery(ctx context.Context, delivery Delivery) error
+	// DueDeliveries lists up to limit pending deliveries next attempted at
+	// or before now. The list may be stale, so that a delivery listed has
+	// since been attempted.
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+	// UpdateDelivery records a delivery over previous, which is the pending
+	// delivery as it was read. It returns ErrDeliveryChanged if the stored
+	// delivery is no longer pending with the same next attempt.
+	UpdateDelivery(ctx context.Context, delivery, previous Delivery) error
+}
+
+// Dispatcher queues events for delivery to the subscriptions matching them
+type Dispatcher struct {
+	store Store
+	// Now returns the time deliveries are queued at
+	Now func() time.Time
+}
+
+// NewDispatcher creates a dispatcher that finds subscriptions in and
+// queues deliveries to store
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{store: store, Now: time.Now}
+}
+
+// Dispatch records a pending delivery of an event to each of its owner's
+// subscriptions to its type, due at once. Nothing is sent, so a slow or
+// unavailable receiver does not hold up the caller; the Deliverer attempts
+// the deliveries. An error is returned if the subscriptions cannot be
+// listed or a delivery cannot be recorded.
+func (d *Dispatcher) Dispatch(ctx context.Context, event Event) error {
+	subscriptions, err := d.store.ListWebhooks(ctx, event.Owner)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks of %s: %w", event.Owner, err)
+	}
+
+	now := d.Now().UTC()
+	for _, subscription := range subscriptions {
+		if !subscription.Matches(event.Type) {
+			continue
+		}
+		delivery := Delivery{
+			ID:          uuid.New(),
+			WebhookID:   subscription.ID,
+			Owner:       subscription.Owner,
+			EventID:     event.ID,
+			EventType:   event.Type,
+			Status:      StatusPending,
+			Payload:     event.Payload,
+			NextAttempt: now,
+			Created:     now,
+		}
+		if err := d.store.AddDelivery(ctx, delivery); err != nil {
+			return fmt.Errorf("failed to record delivery to webhook %s: %w", subscription.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,541 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/user/tasks-api/internal/keys"
+)
+
+var testTime = time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+// receiver is a local webhook receiver that answers with the next of its
+// statuses, then 200, and records the requests it verifies
+type receiver struct {
+	t        *testing.T
+	secret   string
+	statuses []int
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	body, _ := io.ReadAll(request.Body)
+	if err := Verify(r.secret, request.Header.Get(SignatureHeader), body, testTime, 5*time.Minute); err != nil {
+		r.t.Errorf("Expected a valid signature, got %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, request)
+	r.bodies = append(r.bodies, string(body))
+	status := http.StatusOK
+	if n := len(r.requests); n <= len(r.statuses) {
+		status = r.statuses[n-1]
+	}
+	w.WriteHeader(status)
+}
+
+// newTestDeliverer creates a deliverer of the deliveries in store at
+// testTime, whose client connects to the local receivers, which NewClient's
+// transport refuses since they are on loopback addresses
+func newTestDeliverer(store Store) *Deliverer {
+	d := NewDeliverer(store)
+	d.Client.Transport = http.DefaultTransport
+	d.MaxAttempts = 3
+	d.Now = func() time.Time { return testTime }
+	return d
+}
+
+// memoryStore is a Store of subscriptions and recorded deliveries
+type memoryStore struct {
+	subscriptions []Subscription
+	deliveries    []Delivery
+	err           error
+}
+
+func (s *memoryStore) ListWebhooks(ctx context.Context, owner string) ([]Subscription, error) {
+	var owned []Subscription
+	for _, subscription := range s.subscriptions {
+		if subscription.Owner == owner {
+			owned = append(owned, subscription)
+		}
+	}
+	return owned, nil
+}
+
+func (s *memoryStore) GetWebhook(ctx context.Context, owner string, id uuid.UUID) (*Subscription, error) {
+	for _, subscription := range s.subscriptions {
+		if subscription.Owner == owner && subscription.ID == id {
+			return &subscription, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) AddDelivery(ctx context.Context, delivery Delivery) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.deliveries = append(s.deliveries, delivery)
+	return nil
+}
+
+func (s *memoryStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	var due []Delivery
+	for _, delivery := range s.deliveries {
+		if delivery.Status == StatusPending && !delivery.NextAttempt.After(now) && len(due) < limit {
+			due = append(due, delivery)
+		}
+	}
+	return due, nil
+}
+
+func (s *memoryStore) UpdateDelivery(ctx context.Context, delivery, previous Delivery) error {
+	for i, stored := range s.deliveries {
+		if stored.ID != delivery.ID {
+			continue
+		}
+		if stored.Status != StatusPending || !stored.NextAttempt.Equal(previous.NextAttempt) {
+			return ErrDeliveryChanged
+		}
+		s.deliveries[i] = delivery
+		return nil
+	}
+	return ErrDeliveryChanged
+}
+
+func TestSignAndVerify(t *testing.T) {
+	// Arrange
+	body := []byte(`{"type":"TaskClosed"}`)
+	header := Sign("whsec_test", testTime, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{"valid", "whsec_test", header, body, testTime.Add(time.Minute), nil},
+		{"one of several signatures", "whsec_test", header + ",v1=00", body, testTime, nil},
+		{"wrong secret", "whsec_other", header, body, testTime, ErrInvalidSignature},
+		{"changed body", "whsec_test", header, []byte(`{"type":"TaskDeleted"}`), testTime, ErrInvalidSignature},
+		{"no signature", "whsec_test", "t=1792314000", body, testTime, ErrInvalidSignature},
+		{"no timestamp", "whsec_test", strings.Split(header, ",")[1], body, testTime, ErrInvalidSignature},
+		{"too old", "whsec_test", header, body, testTime.Add(6 * time.Minute), ErrExpiredSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			err := Verify(tt.secret, tt.header, tt.body, tt.now, 5*time.Minute)
+
+			// Assert
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	// Arrange
+	d := NewDeliverer(&memoryStore{})
+
+	// Act
+	var got []time.Duration
+	for attempt := 1; attempt <= 8; attempt++ {
+		got = append(got, d.Backoff(attempt))
+	}
+
+	// Assert
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 32 * time.Minute, time.Hour, time.Hour}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestAttempt(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		wantStatus DeliveryStatus
+		wantCodes  []int
+		wantNext   []time.Duration
+	}{
+		{"accepted", nil, StatusDelivered, []int{200}, []time.Duration{0}},
+		{"retried until accepted", []int{503, 429}, StatusDelivered, []int{503, 429, 200}, []time.Duration{time.Minute, 2 * time.Minute, 0}},
+		{"dead-lettered after every attempt fails", []int{500, 502, 504}, StatusDeadLetter, []int{500, 502, 504}, []time.Duration{time.Minute, 2 * time.Minute, 0}},
+		{"dead-lettered at once when rejected", []int{410}, StatusDeadLetter, []int{410}, []time.Duration{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			r := &receiver{t: t, secret: "whsec_test", statuses: tt.statuses}
+			server := httptest.NewServer(r)
+			defer server.Close()
+			d := newTestDeliverer(&memoryStore{})
+			subscription := Subscription{ID: uuid.New(), Owner: "test@example.com", URL: server.URL, Secret: "whsec_test"}
+			delivery := Delivery{
+				ID:        uuid.New(),
+				WebhookID: subscription.ID,
+				Owner:     subscription.Owner,
+				EventID:   "evt-1",
+				EventType: EventTaskClosed,
+				Status:    StatusPending,
+				Payload:   []byte(`{"type":"TaskClosed"}`),
+			}
+
+			// Act: attempt the delivery until it is no longer pending
+			var next []time.Duration
+			for delivery.Status == StatusPending {
+				delivery = d.Attempt(context.Background(), subscription, delivery)
+				wait := time.Duration(0)
+				if !delivery.NextAttempt.IsZero() {
+					wait = delivery.NextAttempt.Sub(testTime)
+				}
+				next = append(next, wait)
+			}
+
+			// Assert
+			if delivery.Status != tt.wantStatus {
+				t.Errorf("Expected a %s delivery, got %+v", tt.wantStatus, delivery)
+			}
+			var codes []int
+			for _, attempt := range delivery.Attempts {
+				codes = append(codes, attempt.StatusCode)
+			}
+			if !reflect.DeepEqual(codes, tt.wantCodes) || !reflect.DeepEqual(next, tt.wantNext) {
+				t.Errorf("Expected attempts %v rescheduled after %v, got %v after %v", tt.wantCodes, tt.wantNext, codes, next)
+			}
+			if (delivery.Payload != nil) != (tt.wantStatus == StatusDeadLetter) {
+				t.Errorf("Expected the payload only on dead letters, got %q", delivery.Payload)
+			}
+			for i, request := range r.requests {
+				if request.Header.Get(DeliveryHeader) != delivery.ID.String() || request.Header.Get(EventHeader) != EventTaskClosed ||
+					request.Header.Get(EventIDHeader) != "evt-1" || r.bodies[i] != `{"type":"TaskClosed"}` {
+					t.Errorf("Expected request %d to carry the delivery, got %v %q", i, request.Header, r.bodies[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAttemptUnreachable(t *testing.T) {
+	// Arrange: a receiver that has gone away
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	d := newTestDeliverer(&memoryStore{})
+	subscription := Subscription{ID: uuid.New(), URL: server.URL, Secret: "whsec_test"}
+	delivery := Delivery{ID: uuid.New(), EventID: "evt-1", EventType: EventTaskCreated, Status: StatusPending, Payload: []byte(`{}`)}
+
+	// Act
+	delivery = d.Attempt(context.Background(), subscription, delivery)
+
+	// Assert
+	if delivery.Status != StatusPending || len(delivery.Attempts) != 1 || delivery.Attempts[0].Error == "" ||
+		!delivery.NextAttempt.Equal(testTime.Add(time.Minute)) {
+		t.Errorf("Expected a failed attempt retried in a minute, got %+v", delivery)
+	}
+}
+
+func TestAttemptForbiddenAddress(t *testing.T) {
+	// Arrange: a receiver on a loopback address, and one that redirects there
+	r := &receiver{t: t, secret: "whsec_test"}
+	server := httptest.NewServer(r)
+	defer server.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(server.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+	d := NewDeliverer(&memoryStore{})
+	d.Now = func() time.Time { return testTime }
+	testClient := newTestDeliverer(&memoryStore{})
+	delivery := Delivery{ID: uuid.New(), EventID: "evt-1", EventType: EventTaskCreated, Status: StatusPending, Payload: []byte(`{}`)}
+
+	// Act
+	refused := d.Attempt(context.Background(), Subscription{ID: uuid.New(), URL: server.URL, Secret: "whsec_test"}, delivery)
+	redirected := testClient.Attempt(context.Background(), Subscription{ID: uuid.New(), URL: redirect.URL, Secret: "whsec_test"}, delivery)
+
+	// Assert
+	if refused.Status != StatusDeadLetter || !strings.Contains(refused.Attempts[0].Error, ErrForbiddenAddress.Error()) {
+		t.Errorf("Expected the loopback receiver refused without retrying, got %+v", refused)
+	}
+	if redirected.Status != StatusDeadLetter || redirected.Attempts[0].StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("Expected the redirect not followed, got %+v", redirected)
+	}
+	if len(r.requests) != 0 {
+		t.Errorf("Expected no request to reach the loopback receiver, got %d", len(r.requests))
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			// Act
+			got := PublicAddress(netip.MustParseAddr(tt.ip))
+
+			// Assert
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	// Arrange
+	r := &receiver{t: t, secret: "whsec_test"}
+	server := httptest.NewServer(r)
+	defer server.Close()
+	store := &memoryStore{subscriptions: []Subscription{
+		{ID: uuid.New(), Owner: "test@example.com", URL: server.URL + "/all", Secret: "whsec_test"},
+		{ID: uuid.New(), Owner: "test@example.com", URL: server.URL + "/closed", Secret: "whsec_test", Events: []string{EventTaskClosed}},
+		{ID: uuid.New(), Owner: "test@example.com", URL: server.URL + "/deleted", Secret: "whsec_test", Events: []string{EventTaskDeleted}},
+		{ID: uuid.New(), Owner: "other@example.com", URL: server.URL + "/other", Secret: "whsec_test"},
+	}}
+	dispatcher := NewDispatcher(store)
+	dispatcher.Now = func() time.Time { return testTime }
+
+	// Act
+	err := dispatcher.Dispatch(context.Background(), Event{ID: "evt-1", Type: EventTaskClosed, Owner: "test@example.com", Payload: []byte(`{}`)})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(r.requests) != 0 {
+		t.Errorf("Expected nothing sent while dispatching, got %d requests", len(r.requests))
+	}
+	if len(store.deliveries) != 2 || store.deliveries[1].WebhookID != store.subscriptions[1].ID {
+		t.Fatalf("Expected both deliveries queued, got %+v", store.deliveries)
+	}
+	for _, delivery := range store.deliveries {
+		if delivery.Status != StatusPending || !delivery.NextAttempt.Equal(testTime) || string(delivery.Payload) != `{}` || len(delivery.Attempts) != 0 {
+			t.Errorf("Expected a pending delivery due at once with its payload, got %+v", delivery)
+		}
+	}
+}
+
+func TestDispatchRecordFailure(t *testing.T) {
+	// Arrange
+	store := &memoryStore{
+		subscriptions: []Subscription{{ID: uuid.New(), Owner: "test@example.com", URL: "https://example.com/hooks", Secret: "whsec_test"}},
+		err:           errors.New("throttled"),
+	}
+	dispatcher := NewDispatcher(store)
+
+	// Act
+	err := dispatcher.Dispatch(context.Background(), Event{ID: "evt-1", Type: EventTaskCreated, Owner: "test@example.com"})
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), "throttled") {
+		t.Errorf("Expected the recording error, got %v", err)
+	}
+}
+
+func TestDeliverDue(t *testing.T) {
+	// Arrange
+	r := &receiver{t: t, secret: "whsec_test", statuses: []int{503}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+	subscription := Subscription{ID: uuid.New(), Owner: "test@example.com", URL: server.URL, Secret: "whsec_test"}
+	pending := func(next time.Time, webhookID uuid.UUID) Delivery {
+		return Delivery{
+			ID:          uuid.New(),
+			WebhookID:   webhookID,
+			Owner:       subscription.Owner,
+			EventID:     "evt-1",
+			EventType:   EventTaskCreated,
+			Status:      StatusPending,
+			Payload:     []byte(`{}`),
+			NextAttempt: next,
+			Created:     testTime,
+		}
+	}
+	store := &memoryStore{
+		subscriptions: []Subscription{subscription},
+		deliveries: []Delivery{
+			pending(testTime.Add(-time.Minute), subscription.ID),
+			pending(testTime, subscription.ID),
+			pending(testTime.Add(time.Minute), subscription.ID),
+			pending(testTime, uuid.New()),
+		},
+	}
+	d := newTestDeliverer(store)
+
+	// Act
+	err := d.DeliverDue(context.Background())
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(r.requests) != 2 {
+		t.Errorf("Expected the 2 due deliveries to the webhook attempted, got %d requests", len(r.requests))
+	}
+	got := make([]DeliveryStatus, len(store.deliveries))
+	for i, delivery := range store.deliveries {
+		got[i] = delivery.Status
+	}
+	if want := []DeliveryStatus{StatusPending, StatusDelivered, StatusPending, StatusDeadLetter}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if retried := store.deliveries[0]; len(retried.Attempts) != 1 || !retried.NextAttempt.Equal(testTime.Add(time.Minute)) {
+		t.Errorf("Expected the failed delivery rescheduled after its backoff, got %+v", retried)
+	}
+	if untouched := store.deliveries[2]; len(untouched.Attempts) != 0 {
+		t.Errorf("Expected the delivery not yet due left alone, got %+v", untouched)
+	}
+}
+
+func TestDeliverSkipsChangedDeliveries(t *testing.T) {
+	// Arrange: another run claimed the delivery after it was listed
+	r := &receiver{t: t, secret: "whsec_test"}
+	server := httptest.NewServer(r)
+	defer server.Close()
+	subscription := Subscription{ID: uuid.New(), Owner: "test@example.com", URL: server.URL, Secret: "whsec_test"}
+	listed := Delivery{ID: uuid.New(), WebhookID: subscription.ID, Owner: subscription.Owner, Status: StatusPending, NextAttempt: testTime}
+	claimed := listed
+	claimed.NextAttempt = testTime.Add(claimDuration)
+	store := &memoryStore{subscriptions: []Subscription{subscription}, deliveries: []Delivery{claimed}}
+	d := newTestDeliverer(store)
+
+	// Act
+	err := d.deliver(context.Background(), listed)
+
+	// Assert
+	if err != nil || len(r.requests) != 0 {
+		t.Errorf("Expected the delivery skipped, got %d requests (%v)", len(r.requests), err)
+	}
+}
+
+func TestDueDeliveriesRotatesShards(t *testing.T) {
+	// Arrange: shard 0 has more due deliveries than a run attempts, and
+	// shard 5 has one
+	shards := make([][]Delivery, keys.PendingShards)
+	for range DefaultBatchSize + 10 {
+		shards[0] = append(shards[0], Delivery{ID: uuid.New()})
+	}
+	waiting := Delivery{ID: uuid.New()}
+	shards[5] = []Delivery{waiting}
+	due := func(shard, limit int) ([]Delivery, error) {
+		return shards[shard][:min(limit, len(shards[shard]))], nil
+	}
+
+	isWaiting := func(delivery Delivery) bool { return delivery.ID == waiting.ID }
+
+	// Act: a run starting from each shard
+	var reached []int
+	for start := range keys.PendingShards {
+		deliveries, err := dueDeliveries(start, DefaultBatchSize, due)
+		if err != nil || len(deliveries) != DefaultBatchSize {
+			t.Fatalf("Expected %d deliveries from shard %d, got %d (%v)", DefaultBatchSize, start, len(deliveries), err)
+		}
+		if slices.ContainsFunc(deliveries, isWaiting) {
+			reached = append(reached, start)
+		}
+	}
+
+	// Assert: runs starting from shards 1 to 5 reach shard 5 before the
+	// limit, and a run starting from shard 0 does not
+	if !reflect.DeepEqual(reached, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("Expected runs from shards 1 to 5 to reach shard 5, got %v", reached)
+	}
+}
+
+func TestItems(t *testing.T) {
+	// Arrange
+	subscription := Subscription{
+		ID:      uuid.New(),
+		Owner:   "test@example.com",
+		URL:     "https://example.com/hooks",
+		Events:  []string{EventTaskClosed},
+		Secret:  "whsec_test",
+		Created: testTime,
+	}
+	delivery := Delivery{
+		ID:        uuid.New(),
+		WebhookID: subscription.ID,
+		Owner:     subscription.Owner,
+		EventID:   "evt-1",
+		EventType: EventTaskClosed,
+		Status:    StatusDeadLetter,
+		Attempts:  []Attempt{{Time: testTime, Error: "connection refused"}, {Time: testTime.Add(time.Second), StatusCode: 503, Error: "receiver responded 503"}},
+		Payload:   []byte(`{"type":"TaskClosed"}`),
+		Created:   testTime,
+	}
+	pending := delivery
+	pending.Status, pending.Attempts, pending.NextAttempt = StatusPending, nil, testTime.Add(time.Minute)
+
+	// Act
+	subscriptionItem, err := SubscriptionItem(subscription)
+	if err != nil {
+		t.Fatalf("Failed to marshal webhook: %v", err)
+	}
+	deliveryItem, err := DeliveryItem(delivery)
+	if err != nil {
+		t.Fatalf("Failed to marshal delivery: %v", err)
+	}
+	pendingItem, err := DeliveryItem(pending)
+	if err != nil {
+		t.Fatalf("Failed to marshal pending delivery: %v", err)
+	}
+	gotSubscription, subscriptionErr := SubscriptionFromItem(subscriptionItem)
+	gotDelivery, deliveryErr := DeliveryFromItem(deliveryItem)
+	gotPending, pendingErr := DeliveryFromItem(pendingItem)
+
+	// Assert
+	if subscriptionErr != nil || !reflect.DeepEqual(gotSubscription, subscription) {
+		t.Errorf("Expected %+v, got %+v (%v)", subscription, gotSubscription, subscriptionErr)
+	}
+	if deliveryErr != nil || !reflect.DeepEqual(gotDelivery, delivery) {
+		t.Errorf("Expected %+v, got %+v (%v)", delivery, gotDelivery, deliveryErr)
+	}
+	if _, indexed := deliveryItem["GS1PK"]; indexed {
+		t.Errorf("Expected a dead letter to be left out of the pending index, got %v", deliveryItem["GS1PK"])
+	}
+	if pendingErr != nil || !reflect.DeepEqual(gotPending, pending) {
+		t.Errorf("Expected %+v, got %+v (%v)", pending, gotPending, pendingErr)
+	}
+	if gs1pk, ok := pendingItem["GS1PK"].(*types.AttributeValueMemberS); !ok || gs1pk.Value != keys.PendingDeliveries(pending.Owner) {
+		t.Errorf("Expected a pending delivery in the owner's shard of the pending index, got %v", pendingItem["GS1PK"])
+	}
+}